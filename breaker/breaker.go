@@ -0,0 +1,454 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现熔断器 Breaker。熔断器在关闭状态下用滑动窗口统计调用结果，
+// 失败率或慢调用比例达到阈值时打开并快速失败；打开一段时间后进入半开状态，
+// 放行有限数量的试探请求，根据试探结果决定重新关闭还是再次打开。
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// 熔断器相关错误定义
+var (
+	ErrOpen            = errors.New("ggu: 熔断器已打开")
+	ErrTooManyRequests = errors.New("ggu: 熔断器半开状态的试探请求已达上限")
+)
+
+// Retryable 判断错误是否值得重试：被熔断器拒绝的调用重试也会被拒绝，
+// 可以作为 retry.WithRetryIf 的分类函数使用
+func Retryable(err error) bool {
+	return !errors.Is(err, ErrOpen) && !errors.Is(err, ErrTooManyRequests)
+}
+
+// ===================== 状态 =====================
+
+// State 熔断器状态
+type State int32
+
+const (
+	// StateClosed 关闭状态，放行所有调用并统计结果
+	StateClosed State = iota
+	// StateOpen 打开状态，拒绝所有调用
+	StateOpen
+	// StateHalfOpen 半开状态，放行有限数量的试探调用
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int32(s))
+	}
+}
+
+// StateChange 状态变化事件
+type StateChange struct {
+	Name string
+	From State
+	To   State
+	Time time.Time
+}
+
+// ===================== 配置 =====================
+
+type options struct {
+	name                  string
+	windowType            WindowType
+	windowSize            int
+	windowDuration        time.Duration
+	minimumCalls          int
+	failureRateThreshold  float64
+	slowCallDuration      time.Duration
+	slowCallRateThreshold float64
+	openTimeout           time.Duration
+	halfOpenMaxCalls      int
+	isFailure             func(err error) bool
+	listeners             []func(StateChange)
+	clock                 clock.Clock
+}
+
+// Option 熔断器配置选项
+type Option func(*options)
+
+// WithName 设置熔断器名称，用于错误信息和状态变化事件
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithCountWindow 使用计数窗口统计最近 size 次调用，默认统计最近 100 次
+func WithCountWindow(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.windowType = CountWindow
+			o.windowSize = size
+		}
+	}
+}
+
+// WithTimeWindow 使用时间窗口统计最近 d 时间内的调用，精度为 d/10
+func WithTimeWindow(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.windowType = TimeWindow
+			o.windowDuration = d
+		}
+	}
+}
+
+// WithMinimumCalls 设置计算失败率所需的最少调用次数，默认 10 次，
+// 使用计数窗口时不超过窗口大小
+func WithMinimumCalls(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.minimumCalls = n
+		}
+	}
+}
+
+// WithFailureRateThreshold 设置失败率阈值（百分比，取值 (0, 100]），默认 50
+func WithFailureRateThreshold(percent float64) Option {
+	return func(o *options) {
+		if percent > 0 && percent <= 100 {
+			o.failureRateThreshold = percent
+		}
+	}
+}
+
+// WithSlowCallThreshold 设置慢调用阈值：耗时不少于 d 的调用视为慢调用，
+// 慢调用比例（百分比）达到 percent 时打开熔断器。默认不统计慢调用
+func WithSlowCallThreshold(d time.Duration, percent float64) Option {
+	return func(o *options) {
+		if d > 0 && percent > 0 && percent <= 100 {
+			o.slowCallDuration = d
+			o.slowCallRateThreshold = percent
+		}
+	}
+}
+
+// WithOpenTimeout 设置打开状态的持续时间，之后进入半开状态，默认 60 秒
+func WithOpenTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.openTimeout = d
+		}
+	}
+}
+
+// WithHalfOpenMaxCalls 设置半开状态放行的试探调用数量，默认 5 次
+func WithHalfOpenMaxCalls(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.halfOpenMaxCalls = n
+		}
+	}
+}
+
+// WithIsFailure 设置判断调用是否失败的函数，默认非 nil 的错误都视为失败
+func WithIsFailure(fn func(err error) bool) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.isFailure = fn
+		}
+	}
+}
+
+// WithStateChangeListener 添加状态变化监听器，可以添加多个，
+// 监听器在触发状态变化的协程中同步调用，调用时不持有熔断器的锁
+func WithStateChangeListener(fn func(StateChange)) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.listeners = append(o.listeners, fn)
+		}
+	}
+}
+
+// WithClock 设置熔断器使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// ===================== 熔断器 =====================
+
+// Breaker 熔断器，线程安全
+type Breaker struct {
+	opts options
+
+	mu            sync.Mutex
+	state         State
+	generation    uint64
+	window        window
+	halfOpen      *countWindow
+	halfOpenCalls int
+	openedAt      time.Time
+	notPermitted  int64
+}
+
+// New 创建熔断器
+func New(opts ...Option) *Breaker {
+	o := options{
+		windowType:            CountWindow,
+		windowSize:            100,
+		minimumCalls:          10,
+		failureRateThreshold:  50,
+		slowCallRateThreshold: 100,
+		openTimeout:           time.Minute,
+		halfOpenMaxCalls:      5,
+		isFailure:             func(err error) bool { return err != nil },
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.clock = clock.OrReal(o.clock)
+
+	b := &Breaker{opts: o, halfOpen: newCountWindow(o.halfOpenMaxCalls)}
+	if o.windowType == TimeWindow {
+		b.window = newTimeWindow(o.windowDuration)
+	} else {
+		b.opts.minimumCalls = min(o.minimumCalls, o.windowSize)
+		b.window = newCountWindow(o.windowSize)
+	}
+	return b
+}
+
+// Name 返回熔断器名称
+func (b *Breaker) Name() string {
+	return b.opts.name
+}
+
+// State 返回熔断器当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	changes := b.refresh(b.opts.clock.Now())
+	state := b.state
+	b.mu.Unlock()
+	b.notify(changes)
+	return state
+}
+
+// Allow 申请一次调用。熔断器拒绝时返回 ErrOpen 或 ErrTooManyRequests；
+// 放行时返回 done，调用结束后必须以调用结果调用 done，多次调用 done 只有第一次生效
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	now := b.opts.clock.Now()
+	changes := b.refresh(now)
+	switch {
+	case b.state == StateOpen:
+		err = ErrOpen
+	case b.state == StateHalfOpen && b.halfOpenCalls >= b.opts.halfOpenMaxCalls:
+		err = ErrTooManyRequests
+	case b.state == StateHalfOpen:
+		b.halfOpenCalls++
+	}
+	if err != nil {
+		b.notPermitted++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(changes)
+
+	if err != nil {
+		if b.opts.name != "" {
+			err = fmt.Errorf("%w: %s", err, b.opts.name)
+		}
+		return nil, err
+	}
+	var called atomic.Bool
+	return func(err error) {
+		if called.CompareAndSwap(false, true) {
+			b.onDone(generation, now, err)
+		}
+	}, nil
+}
+
+// Execute 在熔断器保护下执行 fn。fn panic 时记为失败并重新 panic
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			done(fmt.Errorf("ggu: panic: %v", r))
+			panic(r)
+		}
+	}()
+	err = fn()
+	done(err)
+	return err
+}
+
+// Do 在熔断器保护下执行带返回值的 fn，ctx 已经结束时直接返回 ctx.Err() 且不计入统计
+func Do[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	err := b.Execute(func() error {
+		var err error
+		res, err = fn(ctx)
+		return err
+	})
+	return res, err
+}
+
+// Reset 把熔断器重置为关闭状态并清空统计
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	var changes []StateChange
+	if b.state != StateClosed {
+		changes = append(changes, b.transit(StateClosed, b.opts.clock.Now()))
+	} else {
+		b.window.reset()
+	}
+	b.mu.Unlock()
+	b.notify(changes)
+}
+
+// onDone 记录一次调用的结果，状态已经变化过的旧调用不计入统计
+func (b *Breaker) onDone(generation uint64, start time.Time, err error) {
+	b.mu.Lock()
+	now := b.opts.clock.Now()
+	changes := b.refresh(now)
+	if generation != b.generation {
+		b.mu.Unlock()
+		b.notify(changes)
+		return
+	}
+
+	var o outcome
+	if b.opts.isFailure(err) {
+		o |= outcomeFailure
+	}
+	if b.opts.slowCallDuration > 0 && now.Sub(start) >= b.opts.slowCallDuration {
+		o |= outcomeSlow
+	}
+
+	switch b.state {
+	case StateClosed:
+		b.window.record(o, now)
+		c := b.window.snapshot(now)
+		if c.calls >= int64(b.opts.minimumCalls) && b.exceeded(c) {
+			changes = append(changes, b.transit(StateOpen, now))
+		}
+	case StateHalfOpen:
+		b.halfOpen.record(o, now)
+		c := b.halfOpen.snapshot(now)
+		if c.calls >= int64(b.opts.halfOpenMaxCalls) {
+			to := StateClosed
+			if b.exceeded(c) {
+				to = StateOpen
+			}
+			changes = append(changes, b.transit(to, now))
+		}
+	}
+	b.mu.Unlock()
+	b.notify(changes)
+}
+
+// exceeded 判断失败率或慢调用比例是否达到阈值
+func (b *Breaker) exceeded(c counts) bool {
+	if c.calls == 0 {
+		return false
+	}
+	if rate(c.failures, c.calls) >= b.opts.failureRateThreshold {
+		return true
+	}
+	return b.opts.slowCallDuration > 0 && rate(c.slow, c.calls) >= b.opts.slowCallRateThreshold
+}
+
+// refresh 打开状态超时后进入半开状态，调用方需持有锁
+func (b *Breaker) refresh(now time.Time) []StateChange {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.opts.openTimeout {
+		return []StateChange{b.transit(StateHalfOpen, now)}
+	}
+	return nil
+}
+
+// transit 切换状态并清空统计，调用方需持有锁
+func (b *Breaker) transit(to State, now time.Time) StateChange {
+	change := StateChange{Name: b.opts.name, From: b.state, To: to, Time: now}
+	b.state = to
+	b.generation++
+	b.window.reset()
+	b.halfOpen.reset()
+	b.halfOpenCalls = 0
+	if to == StateOpen {
+		b.openedAt = now
+	}
+	return change
+}
+
+// notify 在锁外通知监听器
+func (b *Breaker) notify(changes []StateChange) {
+	for _, change := range changes {
+		for _, fn := range b.opts.listeners {
+			fn(change)
+		}
+	}
+}
+
+// ===================== 统计 =====================
+
+// Metrics 熔断器统计信息，失败率和慢调用比例为百分比，
+// 关闭状态下统计滑动窗口，半开状态下统计试探调用
+type Metrics struct {
+	State        State
+	Calls        int64
+	Failures     int64
+	SlowCalls    int64
+	FailureRate  float64
+	SlowCallRate float64
+	NotPermitted int64
+}
+
+// Metrics 返回熔断器统计信息
+func (b *Breaker) Metrics() Metrics {
+	b.mu.Lock()
+	now := b.opts.clock.Now()
+	changes := b.refresh(now)
+	var c counts
+	switch b.state {
+	case StateClosed:
+		c = b.window.snapshot(now)
+	case StateHalfOpen:
+		c = b.halfOpen.snapshot(now)
+	}
+	m := Metrics{
+		State:        b.state,
+		Calls:        c.calls,
+		Failures:     c.failures,
+		SlowCalls:    c.slow,
+		FailureRate:  rate(c.failures, c.calls),
+		SlowCallRate: rate(c.slow, c.calls),
+		NotPermitted: b.notPermitted,
+	}
+	b.mu.Unlock()
+	b.notify(changes)
+	return m
+}
+
+func rate(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
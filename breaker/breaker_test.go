@@ -0,0 +1,181 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 breaker.go 和 window.go 的测试用例，覆盖失败率阈值、慢调用阈值、
+// 计数窗口与时间窗口、半开状态的试探、状态变化监听器以及旧调用结果的丢弃。
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+var errDown = errors.New("下游不可用")
+
+func newFakeClock() *clock.FakeClock {
+	return clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// call 申请一次调用并立即以 err 结束
+func call(t *testing.T, b *Breaker, err error) {
+	t.Helper()
+	done, allowErr := b.Allow()
+	require.NoError(t, allowErr)
+	done(err)
+}
+
+// 测试失败率达到阈值后打开，超时后半开，试探成功后关闭
+func TestBreaker_Lifecycle(t *testing.T) {
+	clk := newFakeClock()
+	var changes []StateChange
+	b := New(WithName("inventory"), WithCountWindow(10), WithMinimumCalls(4),
+		WithFailureRateThreshold(50), WithOpenTimeout(10*time.Second), WithHalfOpenMaxCalls(2),
+		WithClock(clk), WithStateChangeListener(func(c StateChange) { changes = append(changes, c) }))
+
+	call(t, b, nil)
+	call(t, b, errDown)
+	call(t, b, errDown)
+	assert.Equal(t, StateClosed, b.State(), "未达到最少调用次数")
+	call(t, b, nil)
+	assert.Equal(t, StateOpen, b.State())
+
+	_, err := b.Allow()
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Contains(t, err.Error(), "inventory")
+	assert.False(t, Retryable(err))
+	assert.Equal(t, int64(1), b.Metrics().NotPermitted)
+
+	clk.Advance(10 * time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+	d1, err := b.Allow()
+	require.NoError(t, err)
+	d2, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	d1(nil)
+	d1(errDown) // 重复调用无效
+	assert.Equal(t, StateHalfOpen, b.State())
+	d2(nil)
+	assert.Equal(t, StateClosed, b.State())
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, StateChange{Name: "inventory", From: StateClosed, To: StateOpen, Time: clk.Now().Add(-10 * time.Second)}, changes[0])
+	assert.Equal(t, StateOpen, changes[1].From)
+	assert.Equal(t, StateHalfOpen, changes[1].To)
+	assert.Equal(t, StateClosed, changes[2].To)
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+}
+
+// 测试半开状态下试探失败重新打开
+func TestBreaker_HalfOpenFailure(t *testing.T) {
+	clk := newFakeClock()
+	b := New(WithCountWindow(2), WithOpenTimeout(time.Second), WithHalfOpenMaxCalls(2), WithClock(clk))
+	call(t, b, errDown)
+	call(t, b, errDown)
+	require.Equal(t, StateOpen, b.State())
+
+	clk.Advance(time.Second)
+	call(t, b, errDown)
+	call(t, b, nil)
+	assert.Equal(t, StateOpen, b.State(), "试探失败率 50% 达到阈值")
+	clk.Advance(999 * time.Millisecond)
+	assert.Equal(t, StateOpen, b.State(), "重新打开后重新计时")
+}
+
+// 测试慢调用比例达到阈值时打开
+func TestBreaker_SlowCalls(t *testing.T) {
+	clk := newFakeClock()
+	b := New(WithCountWindow(4), WithSlowCallThreshold(time.Second, 75), WithClock(clk))
+	for i := 0; i < 4; i++ {
+		done, err := b.Allow()
+		require.NoError(t, err)
+		if i > 0 {
+			clk.Advance(time.Second)
+		}
+		done(nil)
+		if i < 3 {
+			assert.Equal(t, StateClosed, b.State())
+		}
+	}
+	assert.Equal(t, StateOpen, b.State())
+}
+
+// 测试时间窗口中过期的调用不再计入
+func TestBreaker_TimeWindow(t *testing.T) {
+	clk := newFakeClock()
+	b := New(WithTimeWindow(10*time.Second), WithMinimumCalls(3), WithClock(clk))
+	call(t, b, errDown)
+	call(t, b, errDown)
+	clk.Advance(10 * time.Second)
+	assert.Equal(t, int64(0), b.Metrics().Calls, "窗口外的调用应被丢弃")
+
+	call(t, b, errDown)
+	clk.Advance(5 * time.Second)
+	call(t, b, nil)
+	m := b.Metrics()
+	assert.Equal(t, int64(2), m.Calls)
+	assert.Equal(t, 50.0, m.FailureRate)
+	call(t, b, errDown)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+// 测试计数窗口只保留最近的调用
+func TestCountWindow(t *testing.T) {
+	w := newCountWindow(3)
+	now := time.Now()
+	w.record(outcomeFailure, now)
+	w.record(outcomeFailure|outcomeSlow, now)
+	w.record(0, now)
+	w.record(0, now)
+	assert.Equal(t, counts{calls: 3, failures: 1, slow: 1}, w.snapshot(now))
+	w.reset()
+	assert.Equal(t, counts{}, w.snapshot(now))
+}
+
+// 测试状态变化之前申请的调用结果不计入新状态
+func TestBreaker_StaleDone(t *testing.T) {
+	clk := newFakeClock()
+	b := New(WithCountWindow(2), WithClock(clk))
+	stale, err := b.Allow()
+	require.NoError(t, err)
+	call(t, b, errDown)
+	call(t, b, errDown)
+	require.Equal(t, StateOpen, b.State())
+	b.Reset()
+	stale(errDown)
+	assert.Equal(t, int64(0), b.Metrics().Calls)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+// 测试 Execute、Do 以及自定义失败判断
+func TestBreaker_Execute(t *testing.T) {
+	b := New(WithCountWindow(2), WithIsFailure(func(err error) bool {
+		return err != nil && !errors.Is(err, context.Canceled)
+	}))
+	assert.ErrorIs(t, b.Execute(func() error { return context.Canceled }), context.Canceled)
+	assert.Equal(t, int64(0), b.Metrics().Failures, "被取消的调用不算失败")
+
+	v, err := Do(context.Background(), b, func(ctx context.Context) (int, error) { return 42, nil })
+	require.NoError(t, err)
+	assert.Equal(t, 42, v)
+
+	assert.Panics(t, func() { _ = b.Execute(func() error { panic("boom") }) })
+	assert.Equal(t, StateOpen, b.State(), "panic 应计为失败，窗口内失败率达到 50%")
+
+	_, err = Do(context.Background(), b, func(ctx context.Context) (int, error) { return 1, nil })
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.True(t, Retryable(errDown))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Do(ctx, b, func(ctx context.Context) (int, error) { return 1, nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现熔断器统计调用结果的滑动窗口：按次数统计最近 N 次调用的计数窗口，
+// 以及按时间统计最近一段时间内调用的时间窗口。时间窗口被切分为固定数量的桶，
+// 过期的桶在下一次访问时清零，不需要后台协程。
+
+package breaker
+
+import "time"
+
+// WindowType 滑动窗口的类型
+type WindowType int
+
+const (
+	// CountWindow 统计最近 N 次调用
+	CountWindow WindowType = iota
+	// TimeWindow 统计最近一段时间内的调用
+	TimeWindow
+)
+
+// outcome 一次调用的结果
+type outcome uint8
+
+const (
+	outcomeFailure outcome = 1 << iota
+	outcomeSlow
+)
+
+// counts 窗口内的调用统计
+type counts struct {
+	calls    int64
+	failures int64
+	slow     int64
+}
+
+func (c *counts) add(o outcome, delta int64) {
+	c.calls += delta
+	if o&outcomeFailure != 0 {
+		c.failures += delta
+	}
+	if o&outcomeSlow != 0 {
+		c.slow += delta
+	}
+}
+
+// window 滑动窗口，调用方负责加锁
+type window interface {
+	record(o outcome, now time.Time)
+	snapshot(now time.Time) counts
+	reset()
+}
+
+// ===================== 计数窗口 =====================
+
+// countWindow 环形缓冲区保存最近 size 次调用的结果
+type countWindow struct {
+	ring  []outcome
+	next  int
+	full  bool
+	total counts
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{ring: make([]outcome, size)}
+}
+
+func (w *countWindow) record(o outcome, _ time.Time) {
+	if w.full {
+		w.total.add(w.ring[w.next], -1)
+	}
+	w.ring[w.next] = o
+	w.total.add(o, 1)
+	w.next++
+	if w.next == len(w.ring) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+func (w *countWindow) snapshot(time.Time) counts {
+	return w.total
+}
+
+func (w *countWindow) reset() {
+	clear(w.ring)
+	w.next = 0
+	w.full = false
+	w.total = counts{}
+}
+
+// ===================== 时间窗口 =====================
+
+// timeBuckets 时间窗口切分的桶数
+const timeBuckets = 10
+
+// bucket 时间窗口中的一个桶，epoch 为桶所在的时间片序号
+type bucket struct {
+	epoch int64
+	counts
+}
+
+// timeWindow 统计最近 size 时间内的调用，精度为 size/timeBuckets
+type timeWindow struct {
+	width   time.Duration
+	buckets [timeBuckets]bucket
+}
+
+func newTimeWindow(size time.Duration) *timeWindow {
+	return &timeWindow{width: max(size/timeBuckets, 1)}
+}
+
+func (w *timeWindow) record(o outcome, now time.Time) {
+	epoch := now.UnixNano() / int64(w.width)
+	b := &w.buckets[epoch%timeBuckets]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.add(o, 1)
+}
+
+func (w *timeWindow) snapshot(now time.Time) counts {
+	epoch := now.UnixNano() / int64(w.width)
+	var total counts
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.epoch > epoch-timeBuckets && b.epoch <= epoch {
+			total.calls += b.calls
+			total.failures += b.failures
+			total.slow += b.slow
+		}
+	}
+	return total
+}
+
+func (w *timeWindow) reset() {
+	w.buckets = [timeBuckets]bucket{}
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件定义时钟抽象 Clock。依赖时间的组件通过 Clock 获取当前时间和创建定时器，
+// 生产环境使用 Real，测试中使用 FakeClock 手动推进时间，避免 sleep 导致测试缓慢且不稳定。
+
+package clock
+
+import "time"
+
+// Clock 时钟接口，方法语义与 time 包中的同名函数一致
+type Clock interface {
+	// Now 返回当前时间
+	Now() time.Time
+	// Since 返回从 t 到现在经过的时间
+	Since(t time.Time) time.Duration
+	// Until 返回从现在到 t 的时间
+	Until(t time.Time) time.Duration
+	// Sleep 阻塞 d 时长
+	Sleep(d time.Duration)
+	// After 返回一个在 d 之后收到当前时间的通道
+	After(d time.Duration) <-chan time.Time
+	// NewTimer 创建一个 d 之后触发的定时器
+	NewTimer(d time.Duration) Timer
+	// AfterFunc 在 d 之后执行 f，返回可以取消执行的定时器
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker 创建一个每隔 d 触发一次的周期定时器
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 定时器接口，对应 *time.Timer
+type Timer interface {
+	// C 返回定时器触发时接收时间的通道，AfterFunc 创建的定时器返回 nil
+	C() <-chan time.Time
+	// Stop 停止定时器，如果定时器尚未触发返回 true
+	Stop() bool
+	// Reset 重新设置定时器在 d 之后触发，如果定时器在重置前尚未触发返回 true
+	Reset(d time.Duration) bool
+}
+
+// Ticker 周期定时器接口，对应 *time.Ticker
+type Ticker interface {
+	// C 返回每次触发时接收时间的通道
+	C() <-chan time.Time
+	// Stop 停止周期定时器
+	Stop()
+	// Reset 停止周期定时器并把周期改为 d
+	Reset(d time.Duration)
+}
+
+// Real 使用系统时间的时钟
+var Real Clock = realClock{}
+
+// OrReal 在 c 为 nil 时返回 Real，便于组件处理未设置时钟的情况
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// ================== 系统时钟 ==================
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration        { return time.Until(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{t: time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &realTimer{t: time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{t: time.NewTicker(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time        { return r.t.C }
+func (r *realTimer) Stop() bool                 { return r.t.Stop() }
+func (r *realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r *realTicker) C() <-chan time.Time   { return r.t.C }
+func (r *realTicker) Stop()                 { r.t.Stop() }
+func (r *realTicker) Reset(d time.Duration) { r.t.Reset(d) }
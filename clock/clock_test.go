@@ -0,0 +1,147 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 clock 包的测试用例，覆盖假时钟的定时器、周期定时器、AfterFunc 以及 BlockUntil。
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 测试系统时钟
+func TestReal(t *testing.T) {
+	start := Real.Now()
+	<-Real.After(time.Millisecond)
+	assert.GreaterOrEqual(t, Real.Since(start), time.Millisecond)
+	assert.Equal(t, Real, OrReal(nil))
+
+	c := NewFakeClock(epoch)
+	assert.Equal(t, Clock(c), OrReal(c))
+}
+
+// 测试假时钟只在推进时前进
+func TestFakeClock_Now(t *testing.T) {
+	c := NewFakeClock(epoch)
+	assert.Equal(t, epoch, c.Now())
+
+	c.Advance(time.Hour)
+	assert.Equal(t, epoch.Add(time.Hour), c.Now())
+	assert.Equal(t, time.Hour, c.Since(epoch))
+	assert.Equal(t, -time.Hour, c.Until(epoch))
+
+	c.Set(epoch)
+	assert.Equal(t, epoch, c.Now())
+}
+
+// 测试定时器到期、停止和重置
+func TestFakeClock_Timer(t *testing.T) {
+	c := NewFakeClock(epoch)
+	timer := c.NewTimer(10 * time.Second)
+
+	c.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("定时器提前触发")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		assert.Equal(t, epoch.Add(10*time.Second), now)
+	default:
+		t.Fatal("定时器没有触发")
+	}
+	assert.False(t, timer.Stop())
+
+	assert.False(t, timer.Reset(5*time.Second))
+	assert.True(t, timer.Stop())
+	c.Advance(time.Minute)
+	assert.Len(t, timer.C(), 0)
+	assert.Equal(t, 0, c.WaiterCount())
+}
+
+// 测试多个定时器按到期顺序触发，触发时的当前时间等于到期时间
+func TestFakeClock_AfterFuncOrder(t *testing.T) {
+	c := NewFakeClock(epoch)
+	var fired []time.Duration
+	for _, d := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second} {
+		d := d
+		c.AfterFunc(d, func() {
+			assert.Equal(t, epoch.Add(d), c.Now())
+			fired = append(fired, d)
+		})
+	}
+	cancelled := c.AfterFunc(time.Second, func() { t.Fatal("已取消的回调被执行") })
+	assert.True(t, cancelled.Stop())
+
+	c.Advance(5 * time.Second)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, fired)
+	assert.Equal(t, epoch.Add(5*time.Second), c.Now())
+}
+
+// 测试回调中再次注册定时器
+func TestFakeClock_AfterFuncReschedule(t *testing.T) {
+	c := NewFakeClock(epoch)
+	count := 0
+	var tick func()
+	tick = func() {
+		count++
+		c.AfterFunc(time.Second, tick)
+	}
+	c.AfterFunc(time.Second, tick)
+
+	c.Advance(5 * time.Second)
+	assert.Equal(t, 5, count)
+}
+
+// 测试周期定时器
+func TestFakeClock_Ticker(t *testing.T) {
+	c := NewFakeClock(epoch)
+	ticker := c.NewTicker(time.Second)
+
+	c.Advance(time.Second)
+	assert.Equal(t, epoch.Add(time.Second), <-ticker.C())
+
+	// 通道缓冲为1，消费不及时的触发会被丢弃
+	c.Advance(3 * time.Second)
+	assert.Equal(t, epoch.Add(2*time.Second), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+
+	ticker.Reset(10 * time.Second)
+	c.Advance(9 * time.Second)
+	assert.Len(t, ticker.C(), 0)
+	c.Advance(time.Second)
+	assert.Len(t, ticker.C(), 1)
+	<-ticker.C()
+
+	ticker.Stop()
+	c.Advance(time.Minute)
+	assert.Len(t, ticker.C(), 0)
+
+	assert.Panics(t, func() { c.NewTicker(0) })
+}
+
+// 测试 BlockUntil 等待协程进入 Sleep
+func TestFakeClock_Sleep(t *testing.T) {
+	c := NewFakeClock(epoch)
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "Sleep 没有返回")
+	}
+}
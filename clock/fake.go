@@ -0,0 +1,247 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现测试用的假时钟 FakeClock。时间只会在调用 Advance 或 Set 时前进，
+// 到期的定时器和周期定时器按到期时间先后依次触发，使依赖时间的逻辑可以被确定性地测试。
+
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ================== 假时钟 ==================
+
+var _ Clock = (*FakeClock)(nil)
+
+// FakeClock 手动推进的时钟，并发安全。
+// 通道类定时器的通道缓冲为1，触发时若通道已满则丢弃本次时间，与 time.Ticker 的行为一致；
+// AfterFunc 的回调在 Advance/Set 的调用协程中同步执行，回调内可以再次使用该时钟。
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock 创建一个当前时间为 now 的假时钟
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now 返回假时钟的当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since 返回从 t 到假时钟当前时间经过的时间
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until 返回从假时钟当前时间到 t 的时间
+func (c *FakeClock) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// Sleep 阻塞直到假时钟被推进 d
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// After 返回一个在假时钟推进 d 之后收到时间的通道
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer 创建一个假时钟推进 d 之后触发的定时器
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	w := &fakeWaiter{clock: c, ch: make(chan time.Time, 1)}
+	c.schedule(w, d)
+	return w
+}
+
+// AfterFunc 创建一个假时钟推进 d 之后执行 f 的定时器
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &fakeWaiter{clock: c, fn: f}
+	c.schedule(w, d)
+	return w
+}
+
+// NewTicker 创建一个周期为 d 的假周期定时器，d 必须大于0
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("ggu: 周期定时器的周期必须大于0")
+	}
+	w := &fakeWaiter{clock: c, ch: make(chan time.Time, 1), period: d}
+	c.schedule(w, d)
+	return &fakeTicker{w: w}
+}
+
+// Advance 把假时钟推进 d，期间到期的定时器按到期时间先后触发，
+// 触发时假时钟的当前时间等于该定时器的到期时间
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	c.advanceTo(target)
+}
+
+// Set 把假时钟设置到 t，t 早于当前时间时只修改时间，不触发任何定时器
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	if t.Before(c.now) {
+		c.now = t
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.advanceTo(t)
+}
+
+// WaiterCount 返回尚未触发的定时器和周期定时器数量
+func (c *FakeClock) WaiterCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil 阻塞直到尚未触发的定时器数量不少于 n，
+// 用于等待被测协程进入 Sleep/After 等待后再推进时间
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// advanceTo 逐个触发到期时间不晚于 target 的定时器，最后把时间设置为 target
+func (c *FakeClock) advanceTo(target time.Time) {
+	for {
+		c.mu.Lock()
+		if len(c.waiters) == 0 || c.waiters[0].deadline.After(target) {
+			if target.After(c.now) {
+				c.now = target
+			}
+			c.mu.Unlock()
+			return
+		}
+		w := c.waiters[0]
+		if w.deadline.After(c.now) {
+			c.now = w.deadline
+		}
+		now := c.now
+		c.removeLocked(w)
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			c.addLocked(w)
+		}
+		c.mu.Unlock()
+
+		w.fire(now)
+	}
+}
+
+// schedule 把定时器设置为 d 之后到期，返回定时器在设置前是否尚未触发
+func (c *FakeClock) schedule(w *fakeWaiter, d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.removeLocked(w)
+	w.deadline = c.now.Add(d)
+	c.addLocked(w)
+	return pending
+}
+
+// addLocked 按到期时间插入定时器，到期时间相同时先加入的先触发，调用方需持有锁
+func (c *FakeClock) addLocked(w *fakeWaiter) {
+	i := sort.Search(len(c.waiters), func(i int) bool {
+		return c.waiters[i].deadline.After(w.deadline)
+	})
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[i+1:], c.waiters[i:])
+	c.waiters[i] = w
+	w.active = true
+	c.cond.Broadcast()
+}
+
+// removeLocked 移除定时器，返回定时器是否在等待中，调用方需持有锁
+func (c *FakeClock) removeLocked(w *fakeWaiter) bool {
+	if !w.active {
+		return false
+	}
+	for i, x := range c.waiters {
+		if x == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			break
+		}
+	}
+	w.active = false
+	return true
+}
+
+// ================== 假定时器 ==================
+
+// fakeWaiter 假时钟中的一个等待者，period > 0 时为周期定时器
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration
+	ch       chan time.Time
+	fn       func()
+	active   bool // 是否在假时钟的等待列表中
+}
+
+func (w *fakeWaiter) fire(now time.Time) {
+	if w.fn != nil {
+		w.fn()
+		return
+	}
+	select {
+	case w.ch <- now:
+	default:
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.removeLocked(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	return w.clock.schedule(w, d)
+}
+
+// fakeTicker 假周期定时器
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.w.Stop()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("ggu: 周期定时器的周期必须大于0")
+	}
+	c := t.w.clock
+	c.mu.Lock()
+	t.w.period = d
+	c.mu.Unlock()
+	c.schedule(t.w, d)
+}
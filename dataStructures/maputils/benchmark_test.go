@@ -18,7 +18,10 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+
+	"github.com/Humphrey-He/go-generic-utils/syncx"
 )
 
 // RunBenchmarkAndPrintResults 运行基准测试并打印结果
@@ -574,3 +577,97 @@ func BenchmarkSpecialMapFeatures(b *testing.B) {
 		}
 	})
 }
+
+// mixedMap 混合负载基准测试中被比较的Map的公共操作
+type mixedMap interface {
+	Get(key int) (int, bool)
+	Set(key int, value int)
+	Delete(key int)
+}
+
+// syncxMapAdapter 将 syncx.Map 适配为 mixedMap
+type syncxMapAdapter struct {
+	m syncx.Map[int, int]
+}
+
+func (a *syncxMapAdapter) Get(key int) (int, bool) { return a.m.Load(key) }
+func (a *syncxMapAdapter) Set(key int, value int)  { a.m.Store(key, value) }
+func (a *syncxMapAdapter) Delete(key int)          { a.m.Delete(key) }
+
+// 基准测试: 分段并发Map与 SyncMap、syncx.Map 在混合读写负载下的对比
+func BenchmarkConcurrentHashMapMixed(b *testing.B) {
+	const keySpace = 10000
+	readRatios := []int{90, 50, 10}
+	impls := []struct {
+		name string
+		new  func() mixedMap
+	}{
+		{"ConcurrentHashMap", func() mixedMap { return NewConcurrentHashMap[int, int](0) }},
+		{"SyncMap", func() mixedMap { return NewSyncMap[int, int]() }},
+		{"syncx.Map", func() mixedMap { return &syncxMapAdapter{} }},
+	}
+
+	for _, ratio := range readRatios {
+		for _, impl := range impls {
+			b.Run(fmt.Sprintf("read=%d%%/%s", ratio, impl.name), func(b *testing.B) {
+				m := impl.new()
+				for i := 0; i < keySpace; i++ {
+					m.Set(i, i)
+				}
+				var seq atomic.Uint64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					// 每个goroutine使用不同的起点，避免所有goroutine访问同一组键
+					x := seq.Add(1) * 0x9e3779b97f4a7c15
+					for pb.Next() {
+						x = mix64(x)
+						key := int(x % keySpace)
+						switch op := int(x>>32) % 100; {
+						case op < ratio:
+							_, _ = m.Get(key)
+						case op < ratio+(100-ratio)*4/5:
+							m.Set(key, key)
+						default:
+							m.Delete(key)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// 基准测试: 原子累加，对比 Compute 与 SyncMap 上的"读-改-写"加锁方式
+func BenchmarkConcurrentHashMapCounter(b *testing.B) {
+	const keySpace = 1000
+
+	b.Run("ConcurrentHashMap.Merge", func(b *testing.B) {
+		m := NewConcurrentHashMap[int, int](0)
+		sum := func(old, value int) int { return old + value }
+		var seq atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			x := seq.Add(1) * 0x9e3779b97f4a7c15
+			for pb.Next() {
+				x = mix64(x)
+				m.Merge(int(x%keySpace), 1, sum)
+			}
+		})
+	})
+
+	b.Run("SyncMap+Mutex", func(b *testing.B) {
+		m := NewSyncMap[int, int]()
+		var mu sync.Mutex
+		var seq atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			x := seq.Add(1) * 0x9e3779b97f4a7c15
+			for pb.Next() {
+				x = mix64(x)
+				key := int(x % keySpace)
+				mu.Lock()
+				v, _ := m.Get(key)
+				m.Set(key, v+1)
+				mu.Unlock()
+			}
+		})
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现双向Map（BiMap），键和值一一对应，支持通过值反查键，
+// 典型场景如 SKU 与条码之间的互查。
+
+package maputils
+
+import "errors"
+
+// BiMap 相关错误定义
+var (
+	ErrBiMapValueExists = errors.New("ggu: 值已绑定到其他键")
+)
+
+// ================== 双向Map ==================
+
+// BiMap 双向Map，键和值都唯一，同一个值不能同时绑定到两个键。
+// Inverse 返回的反向视图与原Map共享底层数据，对任意一方的修改另一方立即可见。
+// BiMap 不是并发安全的，并发场景需要调用方自行加锁。
+type BiMap[K comparable, V comparable] struct {
+	forward  map[K]V
+	backward map[V]K
+	inverse  *BiMap[V, K]
+}
+
+// NewBiMap 创建一个空的双向Map
+func NewBiMap[K comparable, V comparable]() *BiMap[K, V] {
+	return &BiMap[K, V]{
+		forward:  make(map[K]V),
+		backward: make(map[V]K),
+	}
+}
+
+// Put 设置键值对。如果值已经绑定到其他键，返回 ErrBiMapValueExists 且不做任何修改；
+// 如果键已存在，旧值的反向映射会被移除。
+func (m *BiMap[K, V]) Put(key K, value V) error {
+	if existing, ok := m.backward[value]; ok && existing != key {
+		return ErrBiMapValueExists
+	}
+	m.put(key, value)
+	return nil
+}
+
+// ForcePut 强制设置键值对，如果值已经绑定到其他键，会先删除那个键
+func (m *BiMap[K, V]) ForcePut(key K, value V) {
+	if existing, ok := m.backward[value]; ok && existing != key {
+		delete(m.forward, existing)
+	}
+	m.put(key, value)
+}
+
+func (m *BiMap[K, V]) put(key K, value V) {
+	if old, ok := m.forward[key]; ok {
+		delete(m.backward, old)
+	}
+	m.forward[key] = value
+	m.backward[value] = key
+}
+
+// Get 根据键获取值
+func (m *BiMap[K, V]) Get(key K) (V, bool) {
+	val, ok := m.forward[key]
+	return val, ok
+}
+
+// GetKey 根据值反查键
+func (m *BiMap[K, V]) GetKey(value V) (K, bool) {
+	key, ok := m.backward[value]
+	return key, ok
+}
+
+// ContainsKey 判断键是否存在
+func (m *BiMap[K, V]) ContainsKey(key K) bool {
+	_, ok := m.forward[key]
+	return ok
+}
+
+// ContainsValue 判断值是否存在
+func (m *BiMap[K, V]) ContainsValue(value V) bool {
+	_, ok := m.backward[value]
+	return ok
+}
+
+// Delete 根据键删除键值对，返回被删除的值
+func (m *BiMap[K, V]) Delete(key K) (V, bool) {
+	val, ok := m.forward[key]
+	if !ok {
+		return val, false
+	}
+	delete(m.forward, key)
+	delete(m.backward, val)
+	return val, true
+}
+
+// DeleteValue 根据值删除键值对，返回被删除的键
+func (m *BiMap[K, V]) DeleteValue(value V) (K, bool) {
+	key, ok := m.backward[value]
+	if !ok {
+		return key, false
+	}
+	delete(m.backward, value)
+	delete(m.forward, key)
+	return key, true
+}
+
+// Inverse 返回值到键的反向视图，视图与原Map共享数据
+func (m *BiMap[K, V]) Inverse() *BiMap[V, K] {
+	if m.inverse == nil {
+		m.inverse = &BiMap[V, K]{
+			forward:  m.backward,
+			backward: m.forward,
+			inverse:  m,
+		}
+	}
+	return m.inverse
+}
+
+// Keys 返回所有键
+func (m *BiMap[K, V]) Keys() []K {
+	keys := make([]K, 0, len(m.forward))
+	for k := range m.forward {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Values 返回所有值
+func (m *BiMap[K, V]) Values() []V {
+	values := make([]V, 0, len(m.backward))
+	for v := range m.backward {
+		values = append(values, v)
+	}
+	return values
+}
+
+// ForEach 对每个键值对执行操作，fn 返回 false 时停止遍历
+func (m *BiMap[K, V]) ForEach(fn func(key K, value V) bool) {
+	for k, v := range m.forward {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// Len 返回键值对数量
+func (m *BiMap[K, V]) Len() int {
+	return len(m.forward)
+}
+
+// Clear 清空Map，反向视图同时被清空
+func (m *BiMap[K, V]) Clear() {
+	clear(m.forward)
+	clear(m.backward)
+}
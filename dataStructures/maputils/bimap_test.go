@@ -0,0 +1,91 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 bimap.go 的测试用例，覆盖唯一性约束、强制覆盖以及反向视图。
+
+package maputils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试双向Map基本功能
+func TestBiMap_Basic(t *testing.T) {
+	m := NewBiMap[string, string]()
+	assert.NoError(t, m.Put("SKU001", "6901234567890"))
+	assert.NoError(t, m.Put("SKU002", "6901234567891"))
+
+	v, ok := m.Get("SKU001")
+	assert.True(t, ok)
+	assert.Equal(t, "6901234567890", v)
+
+	k, ok := m.GetKey("6901234567891")
+	assert.True(t, ok)
+	assert.Equal(t, "SKU002", k)
+
+	// 同一个值不能绑定到两个键
+	assert.ErrorIs(t, m.Put("SKU003", "6901234567890"), ErrBiMapValueExists)
+	assert.False(t, m.ContainsKey("SKU003"))
+
+	// 重复设置相同的键值对不算冲突
+	assert.NoError(t, m.Put("SKU001", "6901234567890"))
+
+	// 更新键的值后，旧值的反向映射应被移除
+	assert.NoError(t, m.Put("SKU001", "6909999999999"))
+	assert.False(t, m.ContainsValue("6901234567890"))
+	k, _ = m.GetKey("6909999999999")
+	assert.Equal(t, "SKU001", k)
+	assert.Equal(t, 2, m.Len())
+}
+
+// 测试强制覆盖
+func TestBiMap_ForcePut(t *testing.T) {
+	m := NewBiMap[string, int]()
+	_ = m.Put("a", 1)
+	_ = m.Put("b", 2)
+
+	m.ForcePut("c", 1)
+	assert.False(t, m.ContainsKey("a"))
+	k, ok := m.GetKey(1)
+	assert.True(t, ok)
+	assert.Equal(t, "c", k)
+	assert.Equal(t, 2, m.Len())
+	assert.ElementsMatch(t, []string{"b", "c"}, m.Keys())
+	assert.ElementsMatch(t, []int{1, 2}, m.Values())
+}
+
+// 测试反向视图与原Map共享数据
+func TestBiMap_Inverse(t *testing.T) {
+	m := NewBiMap[string, int]()
+	_ = m.Put("a", 1)
+
+	inv := m.Inverse()
+	assert.Same(t, inv, m.Inverse())
+	assert.Same(t, m, inv.Inverse())
+
+	k, ok := inv.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "a", k)
+
+	// 通过反向视图修改，原Map可见
+	assert.NoError(t, inv.Put(2, "b"))
+	v, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.ErrorIs(t, inv.Put(3, "a"), ErrBiMapValueExists)
+
+	_, ok = inv.Delete(1)
+	assert.True(t, ok)
+	assert.False(t, m.ContainsKey("a"))
+
+	_, ok = m.DeleteValue(2)
+	assert.True(t, ok)
+	assert.Equal(t, 0, inv.Len())
+
+	_ = m.Put("x", 9)
+	m.Clear()
+	assert.Equal(t, 0, inv.Len())
+	_, ok = m.Delete("x")
+	assert.False(t, ok)
+}
@@ -0,0 +1,402 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现分段并发Map（ConcurrentHashMap），键按哈希分散到多个分段，
+// 每个分段独立加锁，适合高并发读写以及需要原子"读-改-写"的场景，如库存扣减计数。
+
+package maputils
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultShardCount 默认分段数量
+const defaultShardCount = 32
+
+// Hasher 键的哈希函数，相等的键必须返回相同的哈希值
+type Hasher[K comparable] func(key K) uint64
+
+// ================== 分段并发Map ==================
+
+// concurrentShard 并发Map的一个分段
+type concurrentShard[K comparable, V any] struct {
+	mu   sync.RWMutex
+	data map[K]V
+}
+
+// ConcurrentHashMap 分段加锁的并发安全Map。
+// Compute、ComputeIfAbsent、Merge 等复合操作在所属分段的写锁内执行，对同一个键是原子的；
+// 回调函数内不能再访问同一个Map，否则可能死锁。
+// Range、Keys 等遍历操作是弱一致的：逐个分段拍快照，不会阻塞其他分段的写入，
+// 遍历过程中的并发修改可能可见也可能不可见。
+type ConcurrentHashMap[K comparable, V any] struct {
+	shards []*concurrentShard[K, V]
+	mask   uint64
+	hasher Hasher[K]
+	count  atomic.Int64
+}
+
+// NewConcurrentHashMap 创建分段并发Map，shardCount 会向上取整到2的幂，<=0 时使用默认值32
+func NewConcurrentHashMap[K comparable, V any](shardCount int) *ConcurrentHashMap[K, V] {
+	return NewConcurrentHashMapWithHasher[K, V](shardCount, nil)
+}
+
+// NewConcurrentHashMapWithHasher 使用自定义哈希函数创建分段并发Map，hasher 为 nil 时使用默认哈希
+func NewConcurrentHashMapWithHasher[K comparable, V any](shardCount int, hasher Hasher[K]) *ConcurrentHashMap[K, V] {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	shardCount = 1 << bits.Len(uint(shardCount-1))
+	if hasher == nil {
+		hasher = defaultHasher[K]()
+	}
+	shards := make([]*concurrentShard[K, V], shardCount)
+	for i := range shards {
+		shards[i] = &concurrentShard[K, V]{data: make(map[K]V)}
+	}
+	return &ConcurrentHashMap[K, V]{
+		shards: shards,
+		mask:   uint64(shardCount - 1),
+		hasher: hasher,
+	}
+}
+
+func (m *ConcurrentHashMap[K, V]) shard(key K) *concurrentShard[K, V] {
+	return m.shards[m.hasher(key)&m.mask]
+}
+
+// Get 获取键对应的值
+func (m *ConcurrentHashMap[K, V]) Get(key K) (V, bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	val, ok := s.data[key]
+	return val, ok
+}
+
+// Set 设置键值对
+func (m *ConcurrentHashMap[K, V]) Set(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; !ok {
+		m.count.Add(1)
+	}
+	s.data[key] = value
+}
+
+// LoadOrStore 键存在时返回已有值，否则存储 value，loaded 表示是否已存在
+func (m *ConcurrentHashMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if val, ok := s.data[key]; ok {
+		return val, true
+	}
+	s.data[key] = value
+	m.count.Add(1)
+	return value, false
+}
+
+// Delete 删除键
+func (m *ConcurrentHashMap[K, V]) Delete(key K) {
+	m.LoadAndDelete(key)
+}
+
+// LoadAndDelete 删除键并返回被删除的值
+func (m *ConcurrentHashMap[K, V]) LoadAndDelete(key K) (V, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.data[key]
+	if ok {
+		delete(s.data, key)
+		m.count.Add(-1)
+	}
+	return val, ok
+}
+
+// Compute 原子地计算键的新值。fn 接收旧值及其是否存在，
+// 返回新值以及是否保留；keep 为 false 时删除该键。返回最终的值及其是否存在。
+func (m *ConcurrentHashMap[K, V]) Compute(key K, fn func(old V, exists bool) (newValue V, keep bool)) (V, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, exists := s.data[key]
+	newValue, keep := fn(old, exists)
+	if !keep {
+		if exists {
+			delete(s.data, key)
+			m.count.Add(-1)
+		}
+		var zero V
+		return zero, false
+	}
+	if !exists {
+		m.count.Add(1)
+	}
+	s.data[key] = newValue
+	return newValue, true
+}
+
+// ComputeIfAbsent 键不存在时原子地调用 fn 计算并存储值，返回最终的值。
+// 同一个键并发调用时 fn 只会执行一次。
+func (m *ConcurrentHashMap[K, V]) ComputeIfAbsent(key K, fn func() V) V {
+	s := m.shard(key)
+	// 快路径：大多数调用时键已存在，只需读锁
+	s.mu.RLock()
+	if val, ok := s.data[key]; ok {
+		s.mu.RUnlock()
+		return val
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if val, ok := s.data[key]; ok {
+		return val
+	}
+	val := fn()
+	s.data[key] = val
+	m.count.Add(1)
+	return val
+}
+
+// ComputeIfPresent 键存在时原子地计算新值，keep 为 false 时删除该键。返回最终的值及其是否存在。
+func (m *ConcurrentHashMap[K, V]) ComputeIfPresent(key K, fn func(old V) (newValue V, keep bool)) (V, bool) {
+	return m.Compute(key, func(old V, exists bool) (V, bool) {
+		if !exists {
+			return old, false
+		}
+		return fn(old)
+	})
+}
+
+// Merge 键不存在时存储 value，否则用 fn(旧值, value) 的结果替换旧值，返回最终的值
+func (m *ConcurrentHashMap[K, V]) Merge(key K, value V, fn func(old, value V) V) V {
+	v, _ := m.Compute(key, func(old V, exists bool) (V, bool) {
+		if !exists {
+			return value, true
+		}
+		return fn(old, value), true
+	})
+	return v
+}
+
+// Len 返回键值对数量
+func (m *ConcurrentHashMap[K, V]) Len() int {
+	return int(m.count.Load())
+}
+
+// Clear 清空Map，逐个分段清理
+func (m *ConcurrentHashMap[K, V]) Clear() {
+	for _, s := range m.shards {
+		s.mu.Lock()
+		m.count.Add(-int64(len(s.data)))
+		s.data = make(map[K]V)
+		s.mu.Unlock()
+	}
+}
+
+// Range 弱一致地遍历所有键值对，f 返回 false 时停止遍历。
+// f 在不持有锁的情况下执行，因此可以在 f 中读写当前Map。
+func (m *ConcurrentHashMap[K, V]) Range(f func(key K, value V) bool) {
+	for _, s := range m.shards {
+		s.mu.RLock()
+		keys := make([]K, 0, len(s.data))
+		values := make([]V, 0, len(s.data))
+		for k, v := range s.data {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+
+		for i := range keys {
+			if !f(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Keys 弱一致地返回所有键
+func (m *ConcurrentHashMap[K, V]) Keys() []K {
+	keys := make([]K, 0, m.Len())
+	for _, s := range m.shards {
+		s.mu.RLock()
+		for k := range s.data {
+			keys = append(keys, k)
+		}
+		s.mu.RUnlock()
+	}
+	return keys
+}
+
+// Values 弱一致地返回所有值
+func (m *ConcurrentHashMap[K, V]) Values() []V {
+	values := make([]V, 0, m.Len())
+	for _, s := range m.shards {
+		s.mu.RLock()
+		for _, v := range s.data {
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+	}
+	return values
+}
+
+// ================== 默认哈希函数 ==================
+
+// defaultHasher 根据键的具体类型选择哈希方式：字符串使用 maphash，
+// 整数使用 splitmix64 混淆，结构体、数组等其他类型按 == 的语义逐个字段写入 maphash
+func defaultHasher[K comparable]() Hasher[K] {
+	seed := maphash.MakeSeed()
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case int:
+			return mix64(uint64(k))
+		case int8:
+			return mix64(uint64(k))
+		case int16:
+			return mix64(uint64(k))
+		case int32:
+			return mix64(uint64(k))
+		case int64:
+			return mix64(uint64(k))
+		case uint:
+			return mix64(uint64(k))
+		case uint8:
+			return mix64(uint64(k))
+		case uint16:
+			return mix64(uint64(k))
+		case uint32:
+			return mix64(uint64(k))
+		case uint64:
+			return mix64(k)
+		case uintptr:
+			return mix64(uint64(k))
+		case float32:
+			return hashFloat(float64(k))
+		case float64:
+			return hashFloat(k)
+		case bool:
+			if k {
+				return 1
+			}
+			return 0
+		default:
+			return hashByKind(seed, key)
+		}
+	}
+}
+
+// hashByKind 处理自定义命名类型（如 type SKU string），按底层类型取哈希；
+// 结构体、数组、指针和接口等复合类型通过反射逐个字段取哈希
+func hashByKind(seed maphash.Seed, key any) uint64 {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return maphash.String(seed, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	default:
+		var h maphash.Hash
+		h.SetSeed(seed)
+		writeValue(&h, v)
+		return h.Sum64()
+	}
+}
+
+// writeValue 把可比较的值写入哈希，与 == 一致：相等的值写入相同的内容。
+// 浮点数的 +0 和 -0 写入相同的内容，NaN 与任何值都不相等，写入什么都不影响正确性
+func writeValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		writeUint64(h, uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			_ = h.WriteByte(1)
+		} else {
+			_ = h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeFloat(h, real(c))
+		writeFloat(h, imag(c))
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint64(h, uint64(v.Pointer()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeValue(h, v.Field(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			_ = h.WriteByte(0)
+			return
+		}
+		// 动态类型不同的值不相等，写入类型名只是为了减少碰撞
+		elem := v.Elem()
+		h.WriteString(elem.Type().String())
+		writeValue(h, elem)
+	default:
+		// 切片、Map、函数不可比较，不会作为键出现
+		panic(fmt.Sprintf("ggu: 类型 %s 不能作为并发Map的键", v.Type()))
+	}
+}
+
+// writeFloat 写入浮点数，-0 按 +0 写入
+func writeFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		f = 0
+	}
+	writeUint64(h, math.Float64bits(f))
+}
+
+// writeUint64 按小端序写入8字节
+func writeUint64(h *maphash.Hash, x uint64) {
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(x >> (8 * i))
+	}
+	_, _ = h.Write(buf[:])
+}
+
+// hashFloat 对浮点数取哈希，+0 和 -0 相等，需要得到相同的哈希
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return mix64(math.Float64bits(f))
+}
+
+// mix64 splitmix64 的混淆步骤，让连续整数均匀分布到各个分段
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
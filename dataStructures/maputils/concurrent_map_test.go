@@ -0,0 +1,203 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 concurrent_map.go 的测试用例，覆盖分段并发Map的基本操作、原子复合操作以及弱一致遍历。
+
+package maputils
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试分段并发Map基本功能
+func TestConcurrentHashMap_Basic(t *testing.T) {
+	m := NewConcurrentHashMap[string, int](5)
+	assert.Len(t, m.shards, 8, "分段数应向上取整到2的幂")
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("a", 10)
+	assert.Equal(t, 2, m.Len())
+
+	v, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+
+	actual, loaded := m.LoadOrStore("a", 100)
+	assert.True(t, loaded)
+	assert.Equal(t, 10, actual)
+	actual, loaded = m.LoadOrStore("c", 3)
+	assert.False(t, loaded)
+	assert.Equal(t, 3, actual)
+
+	v, ok = m.LoadAndDelete("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	m.Delete("b")
+	assert.Equal(t, 2, m.Len())
+	assert.ElementsMatch(t, []string{"a", "c"}, m.Keys())
+	assert.ElementsMatch(t, []int{10, 3}, m.Values())
+
+	m.Clear()
+	assert.Equal(t, 0, m.Len())
+	_, ok = m.Get("a")
+	assert.False(t, ok)
+}
+
+// 测试Compute系列操作
+func TestConcurrentHashMap_Compute(t *testing.T) {
+	m := NewConcurrentHashMap[string, int](0)
+	assert.Len(t, m.shards, defaultShardCount)
+
+	v, ok := m.Compute("stock", func(old int, exists bool) (int, bool) {
+		assert.False(t, exists)
+		return 10, true
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+
+	v, ok = m.ComputeIfPresent("stock", func(old int) (int, bool) {
+		return old - 3, true
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 7, v)
+
+	_, ok = m.ComputeIfPresent("missing", func(old int) (int, bool) {
+		t.Fatal("键不存在时不应调用")
+		return 0, true
+	})
+	assert.False(t, ok)
+	assert.Equal(t, 1, m.Len())
+
+	// keep 为 false 时删除键
+	_, ok = m.Compute("stock", func(old int, exists bool) (int, bool) {
+		return 0, false
+	})
+	assert.False(t, ok)
+	assert.Equal(t, 0, m.Len())
+
+	assert.Equal(t, 5, m.ComputeIfAbsent("x", func() int { return 5 }))
+	assert.Equal(t, 5, m.ComputeIfAbsent("x", func() int { return 6 }))
+
+	sum := func(old, value int) int { return old + value }
+	assert.Equal(t, 1, m.Merge("y", 1, sum))
+	assert.Equal(t, 3, m.Merge("y", 2, sum))
+	assert.Equal(t, 2, m.Len())
+}
+
+// 测试并发原子累加
+func TestConcurrentHashMap_ConcurrentMerge(t *testing.T) {
+	m := NewConcurrentHashMap[int, int](16)
+	var (
+		wg    sync.WaitGroup
+		calls sync.Map
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.Merge(i%50, 1, func(old, value int) int { return old + value })
+				m.ComputeIfAbsent(1000+i%10, func() int {
+					n, _ := calls.LoadOrStore(i%10, new(int))
+					*n.(*int)++
+					return i
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 60, m.Len())
+	for i := 0; i < 50; i++ {
+		v, _ := m.Get(i)
+		assert.Equal(t, 160, v)
+	}
+	calls.Range(func(_, n any) bool {
+		assert.Equal(t, 1, *n.(*int), "ComputeIfAbsent 的计算函数只应执行一次")
+		return true
+	})
+}
+
+// 测试遍历期间可以修改Map
+func TestConcurrentHashMap_Range(t *testing.T) {
+	m := NewConcurrentHashMap[string, int](4)
+	for i := 0; i < 100; i++ {
+		m.Set("k"+strconv.Itoa(i), i)
+	}
+
+	seen := 0
+	m.Range(func(key string, value int) bool {
+		seen++
+		m.Delete(key)
+		return true
+	})
+	assert.Equal(t, 100, seen)
+	assert.Equal(t, 0, m.Len())
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	seen = 0
+	m.Range(func(string, int) bool {
+		seen++
+		return false
+	})
+	assert.Equal(t, 1, seen)
+}
+
+type skuKey string
+
+type warehouseSKU struct {
+	Warehouse string
+	SKU       int
+}
+
+// 测试默认哈希和自定义哈希
+func TestConcurrentHashMap_Hasher(t *testing.T) {
+	h := defaultHasher[skuKey]()
+	assert.Equal(t, h("SKU1"), h("SKU1"))
+
+	hs := defaultHasher[warehouseSKU]()
+	assert.Equal(t, hs(warehouseSKU{"上海", 1}), hs(warehouseSKU{"上海", 1}))
+
+	hf := defaultHasher[float64]()
+	assert.Equal(t, hf(0), hf(math.Copysign(0, -1)))
+
+	// 复合键按字段取哈希，与 == 的结果一致
+	type price float64
+	type priceKey struct {
+		SKU   *string
+		Price price
+		Tags  [2]string
+		Extra any
+	}
+	sku := "SKU1"
+	hp := defaultHasher[priceKey]()
+	a := priceKey{SKU: &sku, Price: 0, Tags: [2]string{"a", "bc"}, Extra: warehouseSKU{"上海", 1}}
+	b := priceKey{SKU: &sku, Price: price(math.Copysign(0, -1)), Tags: [2]string{"a", "bc"}, Extra: warehouseSKU{"上海", 1}}
+	assert.True(t, a == b)
+	assert.Equal(t, hp(a), hp(b))
+	assert.NotEqual(t, hp(a), hp(priceKey{SKU: &sku, Tags: [2]string{"ab", "c"}, Extra: warehouseSKU{"上海", 1}}))
+	assert.Equal(t, defaultHasher[price]()(0), defaultHasher[price]()(price(math.Copysign(0, -1))))
+
+	pm := NewConcurrentHashMap[priceKey, int](8)
+	pm.Set(a, 1)
+	v, ok := pm.Get(b)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	m := NewConcurrentHashMapWithHasher[warehouseSKU, int](4, func(k warehouseSKU) uint64 {
+		return uint64(k.SKU)
+	})
+	m.Set(warehouseSKU{"上海", 1}, 10)
+	m.Set(warehouseSKU{"北京", 1}, 20)
+	m.Set(warehouseSKU{"北京", 2}, 30)
+	v, ok = m.Get(warehouseSKU{"北京", 1})
+	assert.True(t, ok)
+	assert.Equal(t, 20, v)
+	assert.Len(t, m.shards[1].data, 2)
+}
@@ -82,3 +82,42 @@ func ExampleMultiMap() {
 	// fruit: [1 2]
 	// veg: [3]
 }
+
+// ExampleBiMap 演示双向Map的反查与反向视图
+func ExampleBiMap() {
+	m := NewBiMap[string, string]()
+	_ = m.Put("SKU001", "6901234567890")
+	err := m.Put("SKU002", "6901234567890")
+	fmt.Println("duplicate:", err)
+	sku, _ := m.Inverse().Get("6901234567890")
+	fmt.Println("sku:", sku)
+	// Output:
+	// duplicate: ggu: 值已绑定到其他键
+	// sku: SKU001
+}
+
+// ExampleListMultimap 演示有序多值Map
+func ExampleListMultimap() {
+	m := NewListMultimap[string, int]()
+	m.Put("order-2", 30)
+	m.Put("order-1", 10)
+	m.Put("order-2", 30)
+	fmt.Println("keys:", m.Keys())
+	fmt.Println("order-2:", m.Get("order-2"))
+	// Output:
+	// keys: [order-2 order-1]
+	// order-2: [30 30]
+}
+
+// ExampleTable 演示二维键Map
+func ExampleTable() {
+	stock := NewTable[string, string, int]()
+	stock.Put("上海仓", "SKU1", 10)
+	stock.Put("北京仓", "SKU1", 5)
+	qty, _ := stock.Get("北京仓", "SKU1")
+	fmt.Println("北京仓 SKU1:", qty)
+	fmt.Println("SKU1 仓库数:", len(stock.Column("SKU1")))
+	// Output:
+	// 北京仓 SKU1: 5
+	// SKU1 仓库数: 2
+}
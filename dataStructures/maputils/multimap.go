@@ -0,0 +1,272 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现有序多值Map：ListMultimap 允许同一键下出现重复值，
+// SetMultimap 保证同一键下的值唯一。两者都保持键和值的插入顺序。
+
+package maputils
+
+// ================== 有序多值Map（列表语义） ==================
+
+// ListMultimap 有序多值Map，键按首次插入顺序排列，同一键下的值按插入顺序排列且允许重复。
+// ListMultimap 不是并发安全的。
+type ListMultimap[K comparable, V comparable] struct {
+	data *LinkedMap[K, []V]
+	size int
+}
+
+// NewListMultimap 创建有序多值Map
+func NewListMultimap[K comparable, V comparable]() *ListMultimap[K, V] {
+	return &ListMultimap[K, V]{data: NewLinkedMap[K, []V]()}
+}
+
+// Put 追加一个值到键
+func (m *ListMultimap[K, V]) Put(key K, value V) {
+	values, _ := m.data.Get(key)
+	m.data.Set(key, append(values, value))
+	m.size++
+}
+
+// PutAll 批量追加值到键
+func (m *ListMultimap[K, V]) PutAll(key K, values ...V) {
+	if len(values) == 0 {
+		return
+	}
+	existing, _ := m.data.Get(key)
+	m.data.Set(key, append(existing, values...))
+	m.size += len(values)
+}
+
+// Get 获取键对应的所有值，返回副本
+func (m *ListMultimap[K, V]) Get(key K) []V {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return nil
+	}
+	result := make([]V, len(values))
+	copy(result, values)
+	return result
+}
+
+// ContainsKey 判断键是否存在
+func (m *ListMultimap[K, V]) ContainsKey(key K) bool {
+	_, ok := m.data.Get(key)
+	return ok
+}
+
+// ContainsEntry 判断键下是否存在指定值
+func (m *ListMultimap[K, V]) ContainsEntry(key K, value V) bool {
+	values, _ := m.data.Get(key)
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove 删除键下第一个等于 value 的值，键下没有值时同时删除键
+func (m *ListMultimap[K, V]) Remove(key K, value V) bool {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return false
+	}
+	for i, v := range values {
+		if v == value {
+			values = append(values[:i], values[i+1:]...)
+			m.size--
+			if len(values) == 0 {
+				m.data.Delete(key)
+			} else {
+				m.data.Set(key, values)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveAll 删除键及其所有值，返回被删除的值
+func (m *ListMultimap[K, V]) RemoveAll(key K) []V {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return nil
+	}
+	m.data.Delete(key)
+	m.size -= len(values)
+	return values
+}
+
+// Keys 按插入顺序返回所有键
+func (m *ListMultimap[K, V]) Keys() []K {
+	return m.data.Keys()
+}
+
+// Values 按键顺序平铺返回所有值
+func (m *ListMultimap[K, V]) Values() []V {
+	result := make([]V, 0, m.size)
+	for e := m.data.head; e != nil; e = e.next {
+		result = append(result, e.Value...)
+	}
+	return result
+}
+
+// ForEach 按顺序遍历每个键值对，fn 返回 false 时停止遍历
+func (m *ListMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	for e := m.data.head; e != nil; e = e.next {
+		for _, v := range e.Value {
+			if !fn(e.Key, v) {
+				return
+			}
+		}
+	}
+}
+
+// Len 返回不同键的数量
+func (m *ListMultimap[K, V]) Len() int {
+	return m.data.Len()
+}
+
+// Size 返回键值对总数
+func (m *ListMultimap[K, V]) Size() int {
+	return m.size
+}
+
+// Clear 清空Map
+func (m *ListMultimap[K, V]) Clear() {
+	m.data = NewLinkedMap[K, []V]()
+	m.size = 0
+}
+
+// ================== 有序多值Map（集合语义） ==================
+
+// SetMultimap 有序多值Map，键按首次插入顺序排列，同一键下的值唯一且按插入顺序排列。
+// SetMultimap 不是并发安全的。
+type SetMultimap[K comparable, V comparable] struct {
+	data *LinkedMap[K, *LinkedMap[V, struct{}]]
+	size int
+}
+
+// NewSetMultimap 创建集合语义的有序多值Map
+func NewSetMultimap[K comparable, V comparable]() *SetMultimap[K, V] {
+	return &SetMultimap[K, V]{data: NewLinkedMap[K, *LinkedMap[V, struct{}]]()}
+}
+
+// Put 添加一个值到键，如果值已存在返回 false
+func (m *SetMultimap[K, V]) Put(key K, value V) bool {
+	values, ok := m.data.Get(key)
+	if !ok {
+		values = NewLinkedMap[V, struct{}]()
+		m.data.Set(key, values)
+	}
+	if _, exists := values.Get(value); exists {
+		return false
+	}
+	values.Set(value, struct{}{})
+	m.size++
+	return true
+}
+
+// PutAll 批量添加值到键，返回实际新增的数量
+func (m *SetMultimap[K, V]) PutAll(key K, values ...V) int {
+	added := 0
+	for _, v := range values {
+		if m.Put(key, v) {
+			added++
+		}
+	}
+	return added
+}
+
+// Get 获取键对应的所有值，按插入顺序返回
+func (m *SetMultimap[K, V]) Get(key K) []V {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return nil
+	}
+	return values.Keys()
+}
+
+// ContainsKey 判断键是否存在
+func (m *SetMultimap[K, V]) ContainsKey(key K) bool {
+	_, ok := m.data.Get(key)
+	return ok
+}
+
+// ContainsEntry 判断键下是否存在指定值
+func (m *SetMultimap[K, V]) ContainsEntry(key K, value V) bool {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return false
+	}
+	_, exists := values.Get(value)
+	return exists
+}
+
+// Remove 删除键下的指定值，键下没有值时同时删除键
+func (m *SetMultimap[K, V]) Remove(key K, value V) bool {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return false
+	}
+	if _, exists := values.Get(value); !exists {
+		return false
+	}
+	values.Delete(value)
+	m.size--
+	if values.Len() == 0 {
+		m.data.Delete(key)
+	}
+	return true
+}
+
+// RemoveAll 删除键及其所有值，返回被删除的值
+func (m *SetMultimap[K, V]) RemoveAll(key K) []V {
+	values, ok := m.data.Get(key)
+	if !ok {
+		return nil
+	}
+	m.data.Delete(key)
+	m.size -= values.Len()
+	return values.Keys()
+}
+
+// Keys 按插入顺序返回所有键
+func (m *SetMultimap[K, V]) Keys() []K {
+	return m.data.Keys()
+}
+
+// Values 按键顺序平铺返回所有值
+func (m *SetMultimap[K, V]) Values() []V {
+	result := make([]V, 0, m.size)
+	for e := m.data.head; e != nil; e = e.next {
+		result = append(result, e.Value.Keys()...)
+	}
+	return result
+}
+
+// ForEach 按顺序遍历每个键值对，fn 返回 false 时停止遍历
+func (m *SetMultimap[K, V]) ForEach(fn func(key K, value V) bool) {
+	for e := m.data.head; e != nil; e = e.next {
+		for ve := e.Value.head; ve != nil; ve = ve.next {
+			if !fn(e.Key, ve.Key) {
+				return
+			}
+		}
+	}
+}
+
+// Len 返回不同键的数量
+func (m *SetMultimap[K, V]) Len() int {
+	return m.data.Len()
+}
+
+// Size 返回键值对总数
+func (m *SetMultimap[K, V]) Size() int {
+	return m.size
+}
+
+// Clear 清空Map
+func (m *SetMultimap[K, V]) Clear() {
+	m.data = NewLinkedMap[K, *LinkedMap[V, struct{}]]()
+	m.size = 0
+}
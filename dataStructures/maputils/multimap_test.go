@@ -0,0 +1,95 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 multimap.go 的测试用例，覆盖有序多值Map的列表语义与集合语义。
+
+package maputils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试列表语义多值Map
+func TestListMultimap(t *testing.T) {
+	m := NewListMultimap[string, int]()
+	m.Put("b", 1)
+	m.Put("a", 2)
+	m.Put("b", 1)
+	m.PutAll("a", 3, 4)
+	m.PutAll("c")
+
+	assert.Equal(t, []string{"b", "a"}, m.Keys())
+	assert.Equal(t, []int{1, 1}, m.Get("b"))
+	assert.Equal(t, []int{2, 3, 4}, m.Get("a"))
+	assert.Nil(t, m.Get("c"))
+	assert.Equal(t, []int{1, 1, 2, 3, 4}, m.Values())
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, 5, m.Size())
+	assert.True(t, m.ContainsEntry("a", 3))
+	assert.False(t, m.ContainsEntry("a", 1))
+
+	// 返回的是副本
+	m.Get("a")[0] = 100
+	assert.Equal(t, []int{2, 3, 4}, m.Get("a"))
+
+	// 只删除第一个匹配的值
+	assert.True(t, m.Remove("b", 1))
+	assert.Equal(t, []int{1}, m.Get("b"))
+	assert.True(t, m.Remove("b", 1))
+	assert.False(t, m.ContainsKey("b"))
+	assert.False(t, m.Remove("b", 1))
+
+	assert.Equal(t, []int{2, 3, 4}, m.RemoveAll("a"))
+	assert.Equal(t, 0, m.Size())
+
+	m.Put("x", 1)
+	m.Put("y", 2)
+	var visited []string
+	m.ForEach(func(k string, v int) bool {
+		visited = append(visited, k)
+		return false
+	})
+	assert.Equal(t, []string{"x"}, visited)
+
+	m.Clear()
+	assert.Equal(t, 0, m.Len())
+	assert.Equal(t, 0, m.Size())
+}
+
+// 测试集合语义多值Map
+func TestSetMultimap(t *testing.T) {
+	m := NewSetMultimap[string, string]()
+	assert.True(t, m.Put("红色", "SKU3"))
+	assert.True(t, m.Put("红色", "SKU1"))
+	assert.False(t, m.Put("红色", "SKU3"))
+	assert.Equal(t, 1, m.PutAll("蓝色", "SKU2", "SKU2"))
+
+	assert.Equal(t, []string{"红色", "蓝色"}, m.Keys())
+	assert.Equal(t, []string{"SKU3", "SKU1"}, m.Get("红色"))
+	assert.Equal(t, []string{"SKU3", "SKU1", "SKU2"}, m.Values())
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, 3, m.Size())
+	assert.True(t, m.ContainsEntry("蓝色", "SKU2"))
+	assert.False(t, m.ContainsEntry("蓝色", "SKU1"))
+
+	// 删除后重新插入，值排到末尾
+	assert.True(t, m.Remove("红色", "SKU3"))
+	assert.False(t, m.Remove("红色", "SKU3"))
+	m.Put("红色", "SKU3")
+	assert.Equal(t, []string{"SKU1", "SKU3"}, m.Get("红色"))
+
+	var pairs []string
+	m.ForEach(func(k, v string) bool {
+		pairs = append(pairs, k+":"+v)
+		return true
+	})
+	assert.Equal(t, []string{"红色:SKU1", "红色:SKU3", "蓝色:SKU2"}, pairs)
+
+	assert.Equal(t, []string{"SKU2"}, m.RemoveAll("蓝色"))
+	assert.Nil(t, m.RemoveAll("蓝色"))
+	assert.True(t, m.Remove("红色", "SKU1"))
+	assert.True(t, m.Remove("红色", "SKU3"))
+	assert.False(t, m.ContainsKey("红色"))
+	assert.Equal(t, 0, m.Size())
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现二维键Map（Table），通过行键和列键共同定位一个单元格，
+// 典型场景如 仓库×SKU 的库存矩阵。
+
+package maputils
+
+// ================== 二维键Map ==================
+
+// Cell Table 中的一个单元格
+type Cell[R comparable, C comparable, V any] struct {
+	Row    R
+	Column C
+	Value  V
+}
+
+// Table 二维键Map，同时维护行索引和列索引，按行或按列查询都是 O(1) 定位。
+// Row、Column、Cells 返回的都是副本，修改它们不会影响 Table。
+// Table 不是并发安全的。
+type Table[R comparable, C comparable, V any] struct {
+	rows    map[R]map[C]V
+	columns map[C]map[R]V
+	size    int
+}
+
+// NewTable 创建一个空的二维键Map
+func NewTable[R comparable, C comparable, V any]() *Table[R, C, V] {
+	return &Table[R, C, V]{
+		rows:    make(map[R]map[C]V),
+		columns: make(map[C]map[R]V),
+	}
+}
+
+// Put 设置单元格的值，返回旧值和旧值是否存在
+func (t *Table[R, C, V]) Put(row R, column C, value V) (V, bool) {
+	cols, ok := t.rows[row]
+	if !ok {
+		cols = make(map[C]V)
+		t.rows[row] = cols
+	}
+	old, existed := cols[column]
+	cols[column] = value
+
+	rs, ok := t.columns[column]
+	if !ok {
+		rs = make(map[R]V)
+		t.columns[column] = rs
+	}
+	rs[row] = value
+
+	if !existed {
+		t.size++
+	}
+	return old, existed
+}
+
+// Get 获取单元格的值
+func (t *Table[R, C, V]) Get(row R, column C) (V, bool) {
+	val, ok := t.rows[row][column]
+	return val, ok
+}
+
+// Contains 判断单元格是否存在
+func (t *Table[R, C, V]) Contains(row R, column C) bool {
+	_, ok := t.rows[row][column]
+	return ok
+}
+
+// ContainsRow 判断行是否存在
+func (t *Table[R, C, V]) ContainsRow(row R) bool {
+	_, ok := t.rows[row]
+	return ok
+}
+
+// ContainsColumn 判断列是否存在
+func (t *Table[R, C, V]) ContainsColumn(column C) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// Remove 删除单元格，返回被删除的值
+func (t *Table[R, C, V]) Remove(row R, column C) (V, bool) {
+	cols, ok := t.rows[row]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	val, ok := cols[column]
+	if !ok {
+		return val, false
+	}
+
+	delete(cols, column)
+	if len(cols) == 0 {
+		delete(t.rows, row)
+	}
+	rs := t.columns[column]
+	delete(rs, row)
+	if len(rs) == 0 {
+		delete(t.columns, column)
+	}
+	t.size--
+	return val, true
+}
+
+// RemoveRow 删除整行，返回被删除的列到值的映射
+func (t *Table[R, C, V]) RemoveRow(row R) map[C]V {
+	cols, ok := t.rows[row]
+	if !ok {
+		return nil
+	}
+	for c := range cols {
+		rs := t.columns[c]
+		delete(rs, row)
+		if len(rs) == 0 {
+			delete(t.columns, c)
+		}
+	}
+	delete(t.rows, row)
+	t.size -= len(cols)
+	return cols
+}
+
+// RemoveColumn 删除整列，返回被删除的行到值的映射
+func (t *Table[R, C, V]) RemoveColumn(column C) map[R]V {
+	rs, ok := t.columns[column]
+	if !ok {
+		return nil
+	}
+	for r := range rs {
+		cols := t.rows[r]
+		delete(cols, column)
+		if len(cols) == 0 {
+			delete(t.rows, r)
+		}
+	}
+	delete(t.columns, column)
+	t.size -= len(rs)
+	return rs
+}
+
+// Row 返回指定行的 列->值 视图（副本）
+func (t *Table[R, C, V]) Row(row R) map[C]V {
+	cols := t.rows[row]
+	result := make(map[C]V, len(cols))
+	for c, v := range cols {
+		result[c] = v
+	}
+	return result
+}
+
+// Column 返回指定列的 行->值 视图（副本）
+func (t *Table[R, C, V]) Column(column C) map[R]V {
+	rs := t.columns[column]
+	result := make(map[R]V, len(rs))
+	for r, v := range rs {
+		result[r] = v
+	}
+	return result
+}
+
+// Cells 返回所有单元格
+func (t *Table[R, C, V]) Cells() []Cell[R, C, V] {
+	result := make([]Cell[R, C, V], 0, t.size)
+	for r, cols := range t.rows {
+		for c, v := range cols {
+			result = append(result, Cell[R, C, V]{Row: r, Column: c, Value: v})
+		}
+	}
+	return result
+}
+
+// RowKeys 返回所有行键
+func (t *Table[R, C, V]) RowKeys() []R {
+	keys := make([]R, 0, len(t.rows))
+	for r := range t.rows {
+		keys = append(keys, r)
+	}
+	return keys
+}
+
+// ColumnKeys 返回所有列键
+func (t *Table[R, C, V]) ColumnKeys() []C {
+	keys := make([]C, 0, len(t.columns))
+	for c := range t.columns {
+		keys = append(keys, c)
+	}
+	return keys
+}
+
+// ForEach 遍历所有单元格，fn 返回 false 时停止遍历
+func (t *Table[R, C, V]) ForEach(fn func(row R, column C, value V) bool) {
+	for r, cols := range t.rows {
+		for c, v := range cols {
+			if !fn(r, c, v) {
+				return
+			}
+		}
+	}
+}
+
+// Len 返回单元格数量
+func (t *Table[R, C, V]) Len() int {
+	return t.size
+}
+
+// Clear 清空Table
+func (t *Table[R, C, V]) Clear() {
+	t.rows = make(map[R]map[C]V)
+	t.columns = make(map[C]map[R]V)
+	t.size = 0
+}
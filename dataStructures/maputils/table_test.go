@@ -0,0 +1,75 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 table.go 的测试用例，以 仓库×SKU 库存矩阵为例覆盖行、列和单元格视图。
+
+package maputils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试二维键Map
+func TestTable(t *testing.T) {
+	stock := NewTable[string, string, int]()
+	_, existed := stock.Put("上海仓", "SKU1", 10)
+	assert.False(t, existed)
+	stock.Put("上海仓", "SKU2", 20)
+	stock.Put("北京仓", "SKU1", 5)
+	old, existed := stock.Put("北京仓", "SKU1", 8)
+	assert.True(t, existed)
+	assert.Equal(t, 5, old)
+
+	assert.Equal(t, 3, stock.Len())
+	v, ok := stock.Get("北京仓", "SKU1")
+	assert.True(t, ok)
+	assert.Equal(t, 8, v)
+	assert.False(t, stock.Contains("北京仓", "SKU2"))
+
+	assert.Equal(t, map[string]int{"SKU1": 10, "SKU2": 20}, stock.Row("上海仓"))
+	assert.Equal(t, map[string]int{"上海仓": 10, "北京仓": 8}, stock.Column("SKU1"))
+	assert.Empty(t, stock.Row("广州仓"))
+	assert.ElementsMatch(t, []string{"上海仓", "北京仓"}, stock.RowKeys())
+	assert.ElementsMatch(t, []string{"SKU1", "SKU2"}, stock.ColumnKeys())
+	assert.ElementsMatch(t, []Cell[string, string, int]{
+		{Row: "上海仓", Column: "SKU1", Value: 10},
+		{Row: "上海仓", Column: "SKU2", Value: 20},
+		{Row: "北京仓", Column: "SKU1", Value: 8},
+	}, stock.Cells())
+
+	// 修改视图不影响Table
+	stock.Row("上海仓")["SKU1"] = 0
+	v, _ = stock.Get("上海仓", "SKU1")
+	assert.Equal(t, 10, v)
+
+	total := 0
+	stock.ForEach(func(_, _ string, qty int) bool {
+		total += qty
+		return true
+	})
+	assert.Equal(t, 38, total)
+
+	// 删除单元格后，空行和空列应被清理
+	v, ok = stock.Remove("北京仓", "SKU1")
+	assert.True(t, ok)
+	assert.Equal(t, 8, v)
+	assert.False(t, stock.ContainsRow("北京仓"))
+	_, ok = stock.Remove("北京仓", "SKU1")
+	assert.False(t, ok)
+
+	stock.Put("北京仓", "SKU2", 1)
+	assert.Equal(t, map[string]int{"上海仓": 20, "北京仓": 1}, stock.RemoveColumn("SKU2"))
+	assert.False(t, stock.ContainsColumn("SKU2"))
+	assert.False(t, stock.ContainsRow("北京仓"))
+	assert.Equal(t, 1, stock.Len())
+
+	assert.Equal(t, map[string]int{"SKU1": 10}, stock.RemoveRow("上海仓"))
+	assert.False(t, stock.ContainsColumn("SKU1"))
+	assert.Equal(t, 0, stock.Len())
+
+	stock.Put("a", "b", 1)
+	stock.Clear()
+	assert.Equal(t, 0, stock.Len())
+	assert.Empty(t, stock.RowKeys())
+}
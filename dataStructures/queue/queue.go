@@ -5,6 +5,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
 )
 
 ///////////////////// 队列接口 /////////////////////
@@ -282,16 +284,35 @@ func (h *delayQueueHeap[T]) Pop() any {
 	return x
 }
 
+// DelayQueueOption 延迟队列配置选项
+type DelayQueueOption func(*delayQueueConfig)
+
+type delayQueueConfig struct {
+	clock clock.Clock
+}
+
+// WithClock 设置延迟队列判断到期使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) DelayQueueOption {
+	return func(c *delayQueueConfig) {
+		c.clock = clk
+	}
+}
+
 // DelayQueue 并发安全延迟队列
 type DelayQueue[T any] struct {
-	mu   sync.Mutex
-	cond *sync.Cond
-	pq   delayQueueHeap[T]
+	mu    sync.Mutex
+	cond  *sync.Cond
+	pq    delayQueueHeap[T]
+	clock clock.Clock
 }
 
 // NewDelayQueue 创建延迟队列
-func NewDelayQueue[T any]() *DelayQueue[T] {
-	q := &DelayQueue[T]{}
+func NewDelayQueue[T any](opts ...DelayQueueOption) *DelayQueue[T] {
+	var cfg delayQueueConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	q := &DelayQueue[T]{clock: clock.OrReal(cfg.clock)}
 	q.cond = sync.NewCond(&q.mu)
 	return q
 }
@@ -317,7 +338,7 @@ func (q *DelayQueue[T]) EnqueueWithDelay(val T, expireAt time.Time) error {
 // Enqueue 将一个元素添加到延迟队列中，使用默认的短延迟（实现Queue接口）
 func (q *DelayQueue[T]) Enqueue(val T) error {
 	// 默认使用10毫秒的延迟
-	return q.EnqueueWithDelay(val, time.Now().Add(10*time.Millisecond))
+	return q.EnqueueWithDelay(val, q.clock.Now().Add(10*time.Millisecond))
 }
 
 // Dequeue 从延迟队列中获取一个已到期的元素。
@@ -344,7 +365,7 @@ func (q *DelayQueue[T]) Dequeue() (T, error) {
 
 		// 队列非空，获取队首元素 (但不立即从堆中移除)
 		item := q.pq[0] // pq[0] 是堆顶元素，即最早到期的元素
-		now := time.Now()
+		now := q.clock.Now()
 
 		if now.Before(item.ExpireAt) {
 			// 队首元素尚未到期，需要等待
@@ -356,14 +377,17 @@ func (q *DelayQueue[T]) Dequeue() (T, error) {
 			// 这个辅助 goroutine 需要能够被取消，以避免泄漏。
 
 			done := make(chan struct{}) // 用于通知辅助 goroutine 停止
+			// 定时器在启动辅助 goroutine 之前创建，保证等待开始后推进时钟一定能唤醒本次等待
+			timer := q.clock.NewTimer(waitTime)
 			go func() {
 				// 这个 goroutine 的职责是在 waitTime 之后，或者在被取消之前，发出信号
 				select {
-				case <-time.After(waitTime): // 等待指定时间
+				case <-timer.C(): // 等待指定时间
 					q.mu.Lock()     // 在操作条件变量前获取锁
 					q.cond.Signal() // 超时后，发送信号唤醒等待的 Dequeue
 					q.mu.Unlock()   // 释放锁
 				case <-done: // Dequeue 被其他方式唤醒，或者队首元素已改变
+					timer.Stop()
 					return // 辅助 goroutine 退出
 				}
 			}()
@@ -8,6 +8,8 @@ import (
 	"sync"    // 导入 sync 包，提供同步原语
 	"testing" // 导入 testing 包，提供Go语言的测试功能
 	"time"    // 导入 time 包，用于处理时间相关的操作，如延迟队列
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
 )
 
 // TestConcurrentArrayBlockingQueue 对并发安全数组阻塞队列进行测试。
@@ -821,3 +823,35 @@ func TestDelayQueue(t *testing.T) {
 		}
 	})
 }
+
+// TestDelayQueue_WithClock 使用假时钟确定性地测试延迟队列的阻塞与唤醒。
+func TestDelayQueue_WithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	dq := NewDelayQueue[string](WithClock(clk))
+
+	_ = dq.EnqueueWithDelay("close-order", clk.Now().Add(30*time.Minute))
+
+	result := make(chan string, 1)
+	go func() {
+		val, _ := dq.Dequeue()
+		result <- val
+	}()
+
+	// 等待 Dequeue 开始等待队首元素到期
+	clk.BlockUntil(1)
+	select {
+	case val := <-result:
+		t.Fatalf("元素未到期时不应出队，实际出队 '%s'", val)
+	default:
+	}
+
+	clk.Advance(30 * time.Minute)
+	select {
+	case val := <-result:
+		if val != "close-order" {
+			t.Errorf("Dequeue应返回'close-order'，实际返回'%s'", val)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("时钟推进后 Dequeue 没有返回")
+	}
+}
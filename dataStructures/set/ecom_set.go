@@ -1,10 +1,13 @@
 package set
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/eventbus"
 )
 
 // ProductID 商品ID类型
@@ -445,25 +448,56 @@ const (
 	StockStatusPreOrder   = "pre_order"    // 预购
 )
 
+// ProductStockChanged 商品库存变化事件
+type ProductStockChanged struct {
+	ProductID ProductID
+	OldQty    int
+	NewQty    int
+}
+
+// TopicProductStockChanged 商品库存变化事件的主题
+var TopicProductStockChanged = eventbus.NewTopic[ProductStockChanged]("product.stock.changed")
+
 // ProductInventory 商品库存追踪器
+// 库存变化通过事件总线发布到 TopicProductStockChanged
 type ProductInventory struct {
 	products map[ProductID]int // 商品ID到库存数量
 	lock     sync.RWMutex
-	// 库存变化通知回调
-	onChange func(productID ProductID, oldQty, newQty int)
+	// 库存变化事件的发布目标
+	bus *eventbus.Bus
 	// 库存阈值（低于此值视为库存不足）
 	lowStockThreshold map[ProductID]int
 }
 
-// NewProductInventory 创建商品库存追踪器
+// NewProductInventory 创建商品库存追踪器，onChange 同步订阅库存变化事件，
+// 在持有库存锁时调用，不能回调库存追踪器的方法
 func NewProductInventory(onChange func(productID ProductID, oldQty, newQty int)) *ProductInventory {
+	bus := eventbus.New()
+	if onChange != nil {
+		// 保持回调原有的语义：同步执行，panic 直接抛给调用方
+		_, _ = eventbus.Subscribe(bus, TopicProductStockChanged, func(ctx context.Context, e ProductStockChanged) error {
+			onChange(e.ProductID, e.OldQty, e.NewQty)
+			return nil
+		}, eventbus.WithPanicPolicy(eventbus.PanicPropagate))
+	}
+	return NewProductInventoryWithBus(bus)
+}
+
+// NewProductInventoryWithBus 创建发布到指定事件总线的商品库存追踪器。
+// 事件在持有库存锁时发布，同步订阅者不能回调库存追踪器的方法。
+func NewProductInventoryWithBus(bus *eventbus.Bus) *ProductInventory {
 	return &ProductInventory{
 		products:          make(map[ProductID]int),
 		lowStockThreshold: make(map[ProductID]int),
-		onChange:          onChange,
+		bus:               bus,
 	}
 }
 
+// EventBus 返回库存追踪器使用的事件总线
+func (p *ProductInventory) EventBus() *eventbus.Bus {
+	return p.bus
+}
+
 // SetStock 设置商品库存
 func (p *ProductInventory) SetStock(productID ProductID, quantity int) {
 	p.lock.Lock()
@@ -472,9 +506,9 @@ func (p *ProductInventory) SetStock(productID ProductID, quantity int) {
 	oldQty := p.products[productID]
 	p.products[productID] = quantity
 
-	// 调用变化回调
-	if p.onChange != nil && oldQty != quantity {
-		p.onChange(productID, oldQty, quantity)
+	// 发布变化事件
+	if oldQty != quantity {
+		p.publishChange(productID, oldQty, quantity)
 	}
 }
 
@@ -503,10 +537,8 @@ func (p *ProductInventory) DecreaseStock(productID ProductID, quantity int) bool
 	newQty := currentQty - quantity
 	p.products[productID] = newQty
 
-	// 调用变化回调
-	if p.onChange != nil {
-		p.onChange(productID, currentQty, newQty)
-	}
+	// 发布变化事件
+	p.publishChange(productID, currentQty, newQty)
 
 	return true
 }
@@ -524,10 +556,14 @@ func (p *ProductInventory) IncreaseStock(productID ProductID, quantity int) {
 	newQty := currentQty + quantity
 	p.products[productID] = newQty
 
-	// 调用变化回调
-	if p.onChange != nil {
-		p.onChange(productID, currentQty, newQty)
-	}
+	// 发布变化事件
+	p.publishChange(productID, currentQty, newQty)
+}
+
+// publishChange 发布库存变化事件，订阅者的错误已记录为死信，这里不再处理
+func (p *ProductInventory) publishChange(productID ProductID, oldQty, newQty int) {
+	event := ProductStockChanged{ProductID: productID, OldQty: oldQty, NewQty: newQty}
+	_ = eventbus.Publish(context.Background(), p.bus, TopicProductStockChanged, event)
 }
 
 // SetLowStockThreshold 设置库存不足阈值
@@ -1,8 +1,10 @@
 package set
 
 import (
+	"context"
 	"testing"
 
+	"github.com/Humphrey-He/go-generic-utils/eventbus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -310,6 +312,27 @@ func TestProductInventory(t *testing.T) {
 		assert.Equal(t, 12, inventory.GetStock("product1"), "库存应保持不变")
 	})
 
+	t.Run("共享事件总线", func(t *testing.T) {
+		bus := eventbus.New()
+		defer bus.Close()
+		var events []ProductStockChanged
+		_, err := eventbus.Subscribe(bus, TopicProductStockChanged, func(ctx context.Context, e ProductStockChanged) error {
+			events = append(events, e)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		inventory := NewProductInventoryWithBus(bus)
+		assert.Same(t, bus, inventory.EventBus())
+		inventory.SetStock("product1", 10)
+		inventory.SetStock("product1", 10) // 未变化不发布
+		inventory.DecreaseStock("product1", 4)
+		assert.Equal(t, []ProductStockChanged{
+			{ProductID: "product1", OldQty: 0, NewQty: 10},
+			{ProductID: "product1", OldQty: 10, NewQty: 6},
+		}, events)
+	})
+
 	t.Run("库存状态", func(t *testing.T) {
 		inventory := NewProductInventory(nil) // 不需要回调
 
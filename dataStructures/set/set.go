@@ -5,6 +5,9 @@ import (
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/timer"
 )
 
 // 集合相关错误定义
@@ -352,36 +355,76 @@ func (s *ConcurrentSet[T]) ForEach(fn func(key T) (cont bool)) {
 	}
 }
 
+// ExpirableSetOption 带过期时间的集合的配置选项
+type ExpirableSetOption func(*expirableSetConfig)
+
+type expirableSetConfig struct {
+	clock clock.Clock
+}
+
+// WithClock 设置集合判断过期使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock。
+// 使用时间轮时默认与时间轮使用同一个时钟。
+func WithClock(clk clock.Clock) ExpirableSetOption {
+	return func(c *expirableSetConfig) {
+		c.clock = clk
+	}
+}
+
 // ExpirableSet 带过期时间的集合实现
 type ExpirableSet[T comparable] struct {
 	data     map[T]time.Time // 值到过期时间的映射
 	lock     sync.RWMutex
 	interval time.Duration // 清理间隔
 	stopCh   chan struct{}
+	clock    clock.Clock
+	// 使用时间轮时，每个带 TTL 的元素对应一个定时任务，不再定期全量扫描
+	wheel  *timer.TimingWheel
+	timers map[T]*timer.Timer
 }
 
 // NewExpirableSet 创建带过期时间的集合
-func NewExpirableSet[T comparable](cleanInterval time.Duration) *ExpirableSet[T] {
+func NewExpirableSet[T comparable](cleanInterval time.Duration, opts ...ExpirableSetOption) *ExpirableSet[T] {
+	var cfg expirableSetConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	es := &ExpirableSet[T]{
 		data:     make(map[T]time.Time),
 		interval: cleanInterval,
 		stopCh:   make(chan struct{}),
+		clock:    clock.OrReal(cfg.clock),
 	}
 
 	// 启动清理协程
-	go es.cleanExpired()
+	go es.cleanExpired(es.clock.NewTicker(cleanInterval))
 
 	return es
 }
 
+// NewExpirableSetWithWheel 创建由时间轮驱动过期的集合。
+// 每个元素在到期时被单独移除，适合元素多、TTL 差异大的场景；同一个时间轮可以被多个集合共享，
+// Close 只会取消本集合的定时任务，不会停止时间轮。
+func NewExpirableSetWithWheel[T comparable](w *timer.TimingWheel, opts ...ExpirableSetOption) *ExpirableSet[T] {
+	cfg := expirableSetConfig{clock: w.Clock()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &ExpirableSet[T]{
+		data:   make(map[T]time.Time),
+		stopCh: make(chan struct{}),
+		clock:  clock.OrReal(cfg.clock),
+		wheel:  w,
+		timers: make(map[T]*timer.Timer),
+	}
+}
+
 // cleanExpired 定期清理过期元素
-func (s *ExpirableSet[T]) cleanExpired() {
-	ticker := time.NewTicker(s.interval)
+func (s *ExpirableSet[T]) cleanExpired(ticker clock.Ticker) {
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			s.removeExpired()
 		case <-s.stopCh:
 			return
@@ -391,7 +434,7 @@ func (s *ExpirableSet[T]) cleanExpired() {
 
 // removeExpired 清理过期元素
 func (s *ExpirableSet[T]) removeExpired() {
-	now := time.Now()
+	now := s.clock.Now()
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -402,12 +445,43 @@ func (s *ExpirableSet[T]) removeExpired() {
 	}
 }
 
+// scheduleLocked 为元素安排到期任务，调用方需持有写锁。ttl 不大于0时元素已经过期，立即安排删除
+func (s *ExpirableSet[T]) scheduleLocked(key T, ttl time.Duration) {
+	if s.wheel == nil {
+		return
+	}
+	s.cancelLocked(key)
+	expireAt := s.data[key]
+	s.timers[key] = s.wheel.Add(ttl, func() {
+		s.expire(key, expireAt)
+	})
+}
+
+// cancelLocked 取消元素的到期任务，调用方需持有写锁
+func (s *ExpirableSet[T]) cancelLocked(key T) {
+	if t, ok := s.timers[key]; ok {
+		t.Cancel()
+		delete(s.timers, key)
+	}
+}
+
+// expire 时间轮回调，只有过期时间未被更新时才删除元素
+func (s *ExpirableSet[T]) expire(key T, expireAt time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if current, ok := s.data[key]; ok && current.Equal(expireAt) {
+		delete(s.data, key)
+		delete(s.timers, key)
+	}
+}
+
 // Add 添加元素，使用默认过期时间（永不过期）
 func (s *ExpirableSet[T]) Add(key T) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	// 使用最大时间表示永不过期
 	s.data[key] = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	s.cancelLocked(key)
 }
 
 // AddIfNotExist 仅当元素不存在时添加，返回是否添加成功
@@ -416,12 +490,13 @@ func (s *ExpirableSet[T]) AddIfNotExist(key T) bool {
 	defer s.lock.Unlock()
 
 	_, exists := s.data[key]
-	if exists && time.Now().Before(s.data[key]) {
+	if exists && s.clock.Now().Before(s.data[key]) {
 		return false
 	}
 
 	// 使用最大时间表示永不过期
 	s.data[key] = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	s.cancelLocked(key)
 	return true
 }
 
@@ -429,7 +504,8 @@ func (s *ExpirableSet[T]) AddIfNotExist(key T) bool {
 func (s *ExpirableSet[T]) AddWithTTL(key T, ttl time.Duration) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	s.data[key] = time.Now().Add(ttl)
+	s.data[key] = s.clock.Now().Add(ttl)
+	s.scheduleLocked(key, ttl)
 }
 
 // Delete 删除元素
@@ -437,6 +513,7 @@ func (s *ExpirableSet[T]) Delete(key T) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	delete(s.data, key)
+	s.cancelLocked(key)
 }
 
 // Exist 判断元素是否存在且未过期
@@ -444,7 +521,7 @@ func (s *ExpirableSet[T]) Exist(key T) bool {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 	expireTime, ok := s.data[key]
-	return ok && time.Now().Before(expireTime)
+	return ok && s.clock.Now().Before(expireTime)
 }
 
 // Keys 返回所有未过期的元素
@@ -452,7 +529,7 @@ func (s *ExpirableSet[T]) Keys() []T {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	now := time.Now()
+	now := s.clock.Now()
 	result := make([]T, 0, len(s.data))
 
 	for k, expireTime := range s.data {
@@ -475,6 +552,15 @@ func (s *ExpirableSet[T]) Clear() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.data = make(map[T]time.Time)
+	s.cancelAllLocked()
+}
+
+// cancelAllLocked 取消所有到期任务，调用方需持有写锁
+func (s *ExpirableSet[T]) cancelAllLocked() {
+	for key, t := range s.timers {
+		t.Cancel()
+		delete(s.timers, key)
+	}
 }
 
 // ForEach 遍历所有未过期的元素
@@ -487,9 +573,12 @@ func (s *ExpirableSet[T]) ForEach(fn func(key T) (cont bool)) {
 	}
 }
 
-// Close 关闭集合，停止清理协程
+// Close 关闭集合，停止清理协程；使用时间轮时取消本集合所有的到期任务
 func (s *ExpirableSet[T]) Close() {
 	close(s.stopCh)
+	s.lock.Lock()
+	s.cancelAllLocked()
+	s.lock.Unlock()
 }
 
 // GetTTL 获取元素的剩余生存时间，如果元素不存在或已过期，返回-1
@@ -502,7 +591,7 @@ func (s *ExpirableSet[T]) GetTTL(key T) time.Duration {
 		return -1
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	if now.After(expireTime) {
 		return -1
 	}
@@ -4,7 +4,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/timer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetx_Add(t *testing.T) {
@@ -509,6 +512,52 @@ func TestExpirableSet(t *testing.T) {
 	})
 }
 
+func TestExpirableSet_WithWheel(t *testing.T) {
+	w, err := timer.NewTimingWheel(5 * time.Millisecond)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	set := NewExpirableSetWithWheel[string](w)
+	other := NewExpirableSetWithWheel[string](w)
+	defer other.Close()
+
+	set.Add("a")
+	set.AddWithTTL("b", 30*time.Millisecond)
+	set.AddWithTTL("c", 30*time.Millisecond)
+	other.AddWithTTL("b", time.Hour)
+	assert.Equal(t, 3, w.Len(), "两个集合共享同一个时间轮")
+
+	// 续期后旧的到期任务被取消
+	set.AddWithTTL("c", time.Hour)
+	// 改为永不过期后到期任务被取消
+	set.AddWithTTL("d", 30*time.Millisecond)
+	set.Add("d")
+	assert.Equal(t, 3, w.Len())
+
+	assert.Eventually(t, func() bool {
+		set.lock.RLock()
+		defer set.lock.RUnlock()
+		_, ok := set.data["b"]
+		return !ok
+	}, time.Second, 5*time.Millisecond, "到期元素应被时间轮直接移除")
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, set.Keys())
+	assert.True(t, other.Exist("b"), "共享时间轮的其他集合不受影响")
+
+	set.Delete("c")
+	assert.Equal(t, 1, w.Len())
+	set.Close()
+	assert.Equal(t, 1, w.Len(), "Close 只取消本集合的到期任务")
+
+	// 负的有效期表示已经过期，立即安排删除
+	other.AddWithTTL("e", -time.Second)
+	assert.Eventually(t, func() bool {
+		other.lock.RLock()
+		defer other.lock.RUnlock()
+		_, ok := other.data["e"]
+		return !ok
+	}, time.Second, 5*time.Millisecond, "已过期的元素应被时间轮移除")
+}
+
 func equal(nums []int, m map[int]struct{}) bool {
 	for _, num := range nums {
 		_, ok := m[num]
@@ -621,3 +670,48 @@ func mapGet(s map[int]struct{}) {
 		_ = s[i]
 	}
 }
+
+// 测试使用假时钟判断过期与定期清理
+func TestExpirableSet_WithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewExpirableSet[string](time.Minute, WithClock(clk))
+	defer s.Close()
+
+	s.AddWithTTL("coupon", 30*time.Second)
+	s.Add("vip")
+	assert.True(t, s.Exist("coupon"))
+	assert.Equal(t, 30*time.Second, s.GetTTL("coupon"))
+
+	clk.Advance(31 * time.Second)
+	assert.False(t, s.Exist("coupon"))
+	assert.Equal(t, []string{"vip"}, s.Keys())
+
+	// 推进到清理周期，过期元素被清理协程移除
+	clk.Advance(30 * time.Second)
+	assert.Eventually(t, func() bool { return rawLen(s) == 1 }, time.Second, time.Millisecond)
+}
+
+// 测试时间轮与集合共享假时钟
+func TestExpirableSet_WithWheelClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w, err := timer.NewTimingWheel(time.Second, timer.WithClock(clk))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	s := NewExpirableSetWithWheel[string](w)
+	defer s.Close()
+	s.AddWithTTL("session", time.Hour)
+
+	clk.Advance(time.Hour - time.Second)
+	assert.True(t, s.Exist("session"))
+
+	clk.Advance(time.Second)
+	assert.Eventually(t, func() bool { return rawLen(s) == 0 }, time.Second, time.Millisecond)
+}
+
+// rawLen 返回集合内部保存的元素数量，包含已过期但尚未被移除的元素
+func rawLen[T comparable](s *ExpirableSet[T]) int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.data)
+}
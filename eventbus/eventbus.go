@@ -0,0 +1,504 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现进程内事件总线 Bus。事件按主题发布，主题通过泛型 Topic[E] 绑定事件类型；
+// 订阅者可以订阅单个主题，也可以用通配符模式订阅一组主题。同步订阅者在发布者的协程中执行，
+// 异步订阅者各自拥有有界队列和独立的处理协程；处理失败、panic 或队列已满的事件进入死信。
+
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// 事件总线相关错误定义
+var (
+	ErrBusClosed           = errors.New("ggu: 事件总线已关闭")
+	ErrInvalidTopic        = errors.New("ggu: 主题名不合法")
+	ErrQueueFull           = errors.New("ggu: 订阅者队列已满")
+	ErrPayloadTypeMismatch = errors.New("ggu: 事件类型与订阅的主题类型不一致")
+)
+
+const (
+	defaultQueueSize          = 256
+	defaultDeadLetterCapacity = 100
+)
+
+// ===================== Topic 主题 =====================
+
+// Topic 绑定了事件类型的主题，主题名按 "." 分段，不能包含通配符
+type Topic[E any] struct {
+	name string
+}
+
+// NewTopic 创建主题，主题名不合法时 panic，适合在包级变量中声明主题
+func NewTopic[E any](name string) Topic[E] {
+	if err := validateTopic(name); err != nil {
+		panic(fmt.Sprintf("%v: %q", err, name))
+	}
+	return Topic[E]{name: name}
+}
+
+// Name 返回主题名
+func (t Topic[E]) Name() string {
+	return t.name
+}
+
+func validateTopic(name string) error {
+	if name == "" || strings.Contains(name, wildcardOne) {
+		return ErrInvalidTopic
+	}
+	for _, seg := range strings.Split(name, segmentSep) {
+		if seg == "" {
+			return ErrInvalidTopic
+		}
+	}
+	return nil
+}
+
+// Message 投递给模式订阅者的事件，Payload 为发布时的事件值
+type Message struct {
+	Topic   string
+	Payload any
+	Time    time.Time
+}
+
+// ===================== 死信 =====================
+
+// DeadLetterReason 事件进入死信的原因
+type DeadLetterReason string
+
+const (
+	ReasonHandlerError DeadLetterReason = "handler_error" // 处理函数返回错误
+	ReasonPanic        DeadLetterReason = "panic"         // 处理函数 panic
+	ReasonQueueFull    DeadLetterReason = "queue_full"    // 异步队列已满
+	ReasonCanceled     DeadLetterReason = "canceled"      // 等待入队时发布者的 ctx 结束
+	ReasonEvicted      DeadLetterReason = "evicted"       // 订阅者因 panic 被移除，队列中剩余的事件
+)
+
+// DeadLetter 一条未能成功处理的事件
+type DeadLetter struct {
+	Message    Message
+	Subscriber string
+	Reason     DeadLetterReason
+	Err        error
+	Time       time.Time
+}
+
+// ===================== Bus 配置 =====================
+
+// Option 事件总线配置选项
+type Option func(*busConfig)
+
+type busConfig struct {
+	deadLetterHandler  func(DeadLetter)
+	deadLetterCapacity int
+	queueSize          int
+	clock              clock.Clock
+}
+
+// WithDeadLetterHandler 设置死信回调，在产生死信的协程中同步调用，不应长时间阻塞
+func WithDeadLetterHandler(fn func(DeadLetter)) Option {
+	return func(c *busConfig) {
+		c.deadLetterHandler = fn
+	}
+}
+
+// WithDeadLetterCapacity 设置保留的最近死信数量，默认100，传入0表示不保留
+func WithDeadLetterCapacity(n int) Option {
+	return func(c *busConfig) {
+		if n >= 0 {
+			c.deadLetterCapacity = n
+		}
+	}
+}
+
+// WithDefaultQueueSize 设置异步订阅者默认的队列容量，默认256
+func WithDefaultQueueSize(n int) Option {
+	return func(c *busConfig) {
+		if n > 0 {
+			c.queueSize = n
+		}
+	}
+}
+
+// WithClock 设置事件时间使用的时钟，默认使用系统时钟
+func WithClock(clk clock.Clock) Option {
+	return func(c *busConfig) {
+		c.clock = clk
+	}
+}
+
+// ===================== 订阅配置 =====================
+
+// PanicPolicy 订阅者处理函数 panic 时的处理策略
+type PanicPolicy int
+
+const (
+	// PanicRecover 恢复 panic 并记录死信，订阅者继续接收事件，默认策略
+	PanicRecover PanicPolicy = iota
+	// PanicUnsubscribe 恢复 panic 并记录死信，然后取消该订阅，队列中剩余的事件进入死信
+	PanicUnsubscribe
+	// PanicPropagate 记录死信后继续抛出 panic。同步订阅者的 panic 抛给发布者，
+	// 异步订阅者的 panic 发生在处理协程中，会导致进程退出
+	PanicPropagate
+)
+
+// SubscribeOption 订阅配置选项
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	name          string
+	async         bool
+	queueSize     int
+	blockWhenFull bool
+	panicPolicy   PanicPolicy
+}
+
+// WithName 设置订阅者名称，用于死信记录，默认为订阅模式加序号
+func WithName(name string) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.name = name
+	}
+}
+
+// WithAsync 异步接收事件，事件先进入容量为 queueSize 的队列，由订阅者自己的协程按顺序处理。
+// queueSize 不大于0时使用总线的默认容量。
+func WithAsync(queueSize int) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.async = true
+		c.queueSize = queueSize
+	}
+}
+
+// WithBlockWhenFull 异步队列已满时阻塞发布者直到入队或发布者的 ctx 结束，
+// 默认不阻塞，直接把事件记为死信
+func WithBlockWhenFull() SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.blockWhenFull = true
+	}
+}
+
+// WithPanicPolicy 设置处理函数 panic 时的处理策略，默认 PanicRecover
+func WithPanicPolicy(p PanicPolicy) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.panicPolicy = p
+	}
+}
+
+// ===================== Bus 事件总线 =====================
+
+// Bus 事件总线，并发安全
+type Bus struct {
+	cfg busConfig
+
+	mu     sync.RWMutex
+	subs   []*Subscription // 写时复制，发布时无需持锁遍历
+	nextID uint64
+	closed bool
+	wg     sync.WaitGroup
+
+	dlMu        sync.Mutex
+	deadLetters []DeadLetter // 环形缓冲区
+	dlNext      int
+	dlFull      bool
+}
+
+// New 创建事件总线
+func New(opts ...Option) *Bus {
+	cfg := busConfig{
+		deadLetterCapacity: defaultDeadLetterCapacity,
+		queueSize:          defaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.clock = clock.OrReal(cfg.clock)
+	return &Bus{
+		cfg:         cfg,
+		deadLetters: make([]DeadLetter, cfg.deadLetterCapacity),
+	}
+}
+
+// Subscribe 订阅一个主题，处理函数收到的是发布时的事件值
+func Subscribe[E any](b *Bus, topic Topic[E], handler func(ctx context.Context, event E) error, opts ...SubscribeOption) (*Subscription, error) {
+	return b.subscribe(topic.name, func(ctx context.Context, msg Message) error {
+		event, ok := msg.Payload.(E)
+		if !ok {
+			return fmt.Errorf("%w: 主题 %s 收到 %T", ErrPayloadTypeMismatch, msg.Topic, msg.Payload)
+		}
+		return handler(ctx, event)
+	}, opts...)
+}
+
+// SubscribePattern 按通配符模式订阅一组主题，"*" 匹配一段，"**" 匹配零段或多段，
+// 例如 "inventory.*" 匹配 "inventory.reserve"，"order.**" 匹配 "order" 下的所有主题
+func (b *Bus) SubscribePattern(pattern string, handler func(ctx context.Context, msg Message) error, opts ...SubscribeOption) (*Subscription, error) {
+	return b.subscribe(pattern, handler, opts...)
+}
+
+// Publish 发布事件。同步订阅者依次在当前协程中执行，它们返回的错误合并后返回；
+// 异步订阅者只负责入队，处理结果不影响返回值。总线关闭后返回 ErrBusClosed。
+func Publish[E any](ctx context.Context, b *Bus, topic Topic[E], event E) error {
+	return b.publish(ctx, topic.name, event)
+}
+
+// Close 关闭事件总线，取消所有订阅并等待异步订阅者处理完队列中的事件，可以重复调用
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.close(false)
+	}
+	b.wg.Wait()
+}
+
+// Subscribers 返回当前的订阅者数量
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// DeadLetters 返回保留的最近死信，按产生的先后排列
+func (b *Bus) DeadLetters() []DeadLetter {
+	b.dlMu.Lock()
+	defer b.dlMu.Unlock()
+	if !b.dlFull {
+		return append([]DeadLetter(nil), b.deadLetters[:b.dlNext]...)
+	}
+	res := make([]DeadLetter, 0, len(b.deadLetters))
+	res = append(res, b.deadLetters[b.dlNext:]...)
+	return append(res, b.deadLetters[:b.dlNext]...)
+}
+
+func (b *Bus) subscribe(raw string, handler func(ctx context.Context, msg Message) error, opts ...SubscribeOption) (*Subscription, error) {
+	p, err := parsePattern(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cfg subscribeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.async && cfg.queueSize <= 0 {
+		cfg.queueSize = b.cfg.queueSize
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	b.nextID++
+	if cfg.name == "" {
+		cfg.name = fmt.Sprintf("%s#%d", raw, b.nextID)
+	}
+	s := &Subscription{
+		bus:     b,
+		id:      b.nextID,
+		pattern: p,
+		handler: handler,
+		cfg:     cfg,
+		quit:    make(chan struct{}),
+	}
+	if cfg.async {
+		s.queue = make(chan envelope, cfg.queueSize)
+		b.wg.Add(1)
+		go s.run()
+	}
+	subs := make([]*Subscription, len(b.subs), len(b.subs)+1)
+	copy(subs, b.subs)
+	b.subs = append(subs, s)
+	return s, nil
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, cur := range b.subs {
+		if cur == s {
+			subs := make([]*Subscription, 0, len(b.subs)-1)
+			subs = append(subs, b.subs[:i]...)
+			b.subs = append(subs, b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Bus) publish(ctx context.Context, topic string, payload any) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBusClosed
+	}
+	subs := b.subs
+	b.mu.RUnlock()
+
+	msg := Message{Topic: topic, Payload: payload, Time: b.cfg.clock.Now()}
+	var errs []error
+	for _, s := range subs {
+		if !s.pattern.match(topic) {
+			continue
+		}
+		if s.cfg.async {
+			s.enqueue(ctx, msg)
+			continue
+		}
+		if err := s.deliver(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deadLetter 记录一条死信并通知死信回调
+func (b *Bus) deadLetter(s *Subscription, msg Message, reason DeadLetterReason, err error) {
+	dl := DeadLetter{
+		Message:    msg,
+		Subscriber: s.cfg.name,
+		Reason:     reason,
+		Err:        err,
+		Time:       b.cfg.clock.Now(),
+	}
+	if len(b.deadLetters) > 0 {
+		b.dlMu.Lock()
+		b.deadLetters[b.dlNext] = dl
+		b.dlNext++
+		if b.dlNext == len(b.deadLetters) {
+			b.dlNext = 0
+			b.dlFull = true
+		}
+		b.dlMu.Unlock()
+	}
+	if b.cfg.deadLetterHandler != nil {
+		b.cfg.deadLetterHandler(dl)
+	}
+}
+
+// ===================== Subscription 订阅 =====================
+
+// envelope 异步队列中的事件，保留发布者 ctx 中的值但不继承其取消
+type envelope struct {
+	ctx context.Context
+	msg Message
+}
+
+// Subscription 一个订阅，通过 Unsubscribe 取消
+type Subscription struct {
+	bus     *Bus
+	id      uint64
+	pattern *pattern
+	handler func(ctx context.Context, msg Message) error
+	cfg     subscribeConfig
+
+	queue   chan envelope
+	quit    chan struct{} // 取消订阅时关闭，唤醒阻塞在入队上的发布者
+	mu      sync.RWMutex  // 保护 closed，入队持读锁，关闭队列持写锁
+	closed  bool
+	evicted atomic.Bool // 因 panic 被移除，队列中剩余的事件直接进入死信
+	once    sync.Once
+}
+
+// Name 返回订阅者名称
+func (s *Subscription) Name() string {
+	return s.cfg.name
+}
+
+// Unsubscribe 取消订阅，之后发布的事件不再投递给该订阅者。
+// 异步订阅者会在后台处理完队列中已有的事件，可以重复调用。
+func (s *Subscription) Unsubscribe() {
+	s.close(false)
+}
+
+func (s *Subscription) close(evict bool) {
+	if evict {
+		// 即使订阅已经被 Close 取消，也不再处理剩余的事件
+		s.evicted.Store(true)
+	}
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+		close(s.quit)
+		if s.queue == nil {
+			return
+		}
+		s.mu.Lock()
+		s.closed = true
+		close(s.queue)
+		s.mu.Unlock()
+	})
+}
+
+// enqueue 把事件放入异步队列，队列已满时按配置阻塞或记为死信
+func (s *Subscription) enqueue(ctx context.Context, msg Message) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	env := envelope{ctx: context.WithoutCancel(ctx), msg: msg}
+	select {
+	case s.queue <- env:
+		return
+	default:
+	}
+	if !s.cfg.blockWhenFull {
+		s.bus.deadLetter(s, msg, ReasonQueueFull, ErrQueueFull)
+		return
+	}
+	select {
+	case s.queue <- env:
+	case <-ctx.Done():
+		s.bus.deadLetter(s, msg, ReasonCanceled, ctx.Err())
+	case <-s.quit:
+	}
+}
+
+// run 异步订阅者的处理协程，队列关闭后处理完剩余事件再退出
+func (s *Subscription) run() {
+	defer s.bus.wg.Done()
+	for env := range s.queue {
+		if s.evicted.Load() {
+			s.bus.deadLetter(s, env.msg, ReasonEvicted, nil)
+			continue
+		}
+		_ = s.deliver(env.ctx, env.msg)
+	}
+}
+
+// deliver 执行处理函数，失败时记录死信并按 panic 策略处理
+func (s *Subscription) deliver(ctx context.Context, msg Message) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		pe := &syncx.PanicError{Value: r, Stack: debug.Stack()}
+		s.bus.deadLetter(s, msg, ReasonPanic, pe)
+		switch s.cfg.panicPolicy {
+		case PanicPropagate:
+			panic(r)
+		case PanicUnsubscribe:
+			// 异步订阅者在自己的处理协程中关闭队列，range 会继续取出剩余事件
+			s.close(true)
+		}
+		err = pe
+	}()
+	if err = s.handler(ctx, msg); err != nil {
+		s.bus.deadLetter(s, msg, ReasonHandlerError, err)
+	}
+	return err
+}
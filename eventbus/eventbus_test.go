@@ -0,0 +1,275 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 eventbus 包的测试用例，覆盖通配符匹配、同步与异步投递、取消订阅、
+// 队列已满、panic 策略以及死信记录。
+
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+type orderCreated struct {
+	OrderID string
+	Amount  int
+}
+
+var topicOrderCreated = NewTopic[orderCreated]("order.created")
+
+// 测试通配符模式匹配
+func TestPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.paid", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order.created.v2", false},
+		{"order.*", "order", false},
+		{"*.created", "order.created", true},
+		{"order.**", "order", true},
+		{"order.**", "order.created.v2", true},
+		{"**", "inventory.reserve", true},
+		{"**.v2", "order.created.v2", true},
+		{"order.**.v2", "order.v2", true},
+		{"order.**.v2", "order.created.v3", false},
+	}
+	for _, c := range cases {
+		p, err := parsePattern(c.pattern)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, p.match(c.topic), "%s ~ %s", c.pattern, c.topic)
+	}
+
+	for _, bad := range []string{"", "order..created", "order.cre*", ".order"} {
+		_, err := parsePattern(bad)
+		assert.ErrorIs(t, err, ErrInvalidPattern, bad)
+	}
+	assert.Panics(t, func() { NewTopic[int]("order.*") })
+}
+
+// 测试同步订阅者按订阅顺序执行，错误合并返回
+func TestBus_Sync(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	var got []string
+	_, err := Subscribe(b, topicOrderCreated, func(ctx context.Context, e orderCreated) error {
+		got = append(got, "typed:"+e.OrderID)
+		return nil
+	})
+	require.NoError(t, err)
+	errRisk := errors.New("风控拒绝")
+	_, err = b.SubscribePattern("order.*", func(ctx context.Context, msg Message) error {
+		got = append(got, "pattern:"+msg.Topic)
+		return errRisk
+	}, WithName("risk"))
+	require.NoError(t, err)
+	_, err = b.SubscribePattern("inventory.*", func(ctx context.Context, msg Message) error {
+		got = append(got, "unexpected")
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = Publish(context.Background(), b, topicOrderCreated, orderCreated{OrderID: "o1"})
+	assert.ErrorIs(t, err, errRisk)
+	assert.Equal(t, []string{"typed:o1", "pattern:order.created"}, got)
+
+	dls := b.DeadLetters()
+	require.Len(t, dls, 1)
+	assert.Equal(t, "risk", dls[0].Subscriber)
+	assert.Equal(t, ReasonHandlerError, dls[0].Reason)
+}
+
+// 测试同名主题类型不一致时类型化订阅者收到错误
+func TestBus_TypeMismatch(t *testing.T) {
+	b := New()
+	defer b.Close()
+	_, err := Subscribe(b, topicOrderCreated, func(ctx context.Context, e orderCreated) error { return nil })
+	require.NoError(t, err)
+
+	other := NewTopic[string]("order.created")
+	err = Publish(context.Background(), b, other, "o1")
+	assert.ErrorIs(t, err, ErrPayloadTypeMismatch)
+}
+
+// 测试异步订阅者按发布顺序处理，Close 等待队列处理完成
+func TestBus_Async(t *testing.T) {
+	b := New()
+	var mu sync.Mutex
+	var got []int
+	_, err := Subscribe(b, NewTopic[int]("counter"), func(ctx context.Context, v int) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+		return nil
+	}, WithAsync(16))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, Publish(context.Background(), b, NewTopic[int]("counter"), i))
+	}
+	b.Close()
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, got)
+
+	assert.ErrorIs(t, Publish(context.Background(), b, NewTopic[int]("counter"), 1), ErrBusClosed)
+	_, err = b.SubscribePattern("**", func(ctx context.Context, msg Message) error { return nil })
+	assert.ErrorIs(t, err, ErrBusClosed)
+}
+
+// 测试异步队列已满时的丢弃与阻塞
+func TestBus_QueueFull(t *testing.T) {
+	topic := NewTopic[int]("counter")
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	handler := func(ctx context.Context, v int) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}
+
+	t.Run("丢弃", func(t *testing.T) {
+		var dropped []DeadLetter
+		b := New(WithDeadLetterHandler(func(dl DeadLetter) { dropped = append(dropped, dl) }))
+		_, err := Subscribe(b, topic, handler, WithAsync(1))
+		require.NoError(t, err)
+
+		require.NoError(t, Publish(context.Background(), b, topic, 1))
+		<-started // 第一个事件正在处理
+		require.NoError(t, Publish(context.Background(), b, topic, 2))
+		require.NoError(t, Publish(context.Background(), b, topic, 3))
+
+		require.Len(t, dropped, 1)
+		assert.Equal(t, ReasonQueueFull, dropped[0].Reason)
+		assert.Equal(t, 3, dropped[0].Message.Payload)
+		release <- struct{}{}
+		release <- struct{}{}
+		b.Close()
+	})
+
+	t.Run("阻塞", func(t *testing.T) {
+		b := New()
+		_, err := Subscribe(b, topic, handler, WithAsync(1), WithBlockWhenFull())
+		require.NoError(t, err)
+
+		require.NoError(t, Publish(context.Background(), b, topic, 1))
+		<-started
+		require.NoError(t, Publish(context.Background(), b, topic, 2))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.NoError(t, Publish(ctx, b, topic, 3))
+		dls := b.DeadLetters()
+		require.Len(t, dls, 1)
+		assert.Equal(t, ReasonCanceled, dls[0].Reason)
+
+		close(release)
+		b.Close()
+	})
+}
+
+// 测试取消订阅后不再收到事件
+func TestSubscription_Unsubscribe(t *testing.T) {
+	b := New()
+	defer b.Close()
+	count := 0
+	sub, err := b.SubscribePattern("order.**", func(ctx context.Context, msg Message) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.Subscribers())
+
+	require.NoError(t, Publish(context.Background(), b, topicOrderCreated, orderCreated{}))
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	require.NoError(t, Publish(context.Background(), b, topicOrderCreated, orderCreated{}))
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+// 测试 panic 策略
+func TestBus_PanicPolicy(t *testing.T) {
+	topic := NewTopic[int]("counter")
+	boom := func(ctx context.Context, v int) error {
+		if v < 0 {
+			panic("boom")
+		}
+		return nil
+	}
+
+	t.Run("恢复", func(t *testing.T) {
+		b := New()
+		defer b.Close()
+		_, err := Subscribe(b, topic, boom)
+		require.NoError(t, err)
+		var pe *syncx.PanicError
+		assert.ErrorAs(t, Publish(context.Background(), b, topic, -1), &pe)
+		assert.NoError(t, Publish(context.Background(), b, topic, 1))
+		assert.Equal(t, 1, b.Subscribers())
+		assert.Equal(t, ReasonPanic, b.DeadLetters()[0].Reason)
+	})
+
+	t.Run("取消订阅", func(t *testing.T) {
+		b := New()
+		gate := make(chan struct{})
+		_, err := Subscribe(b, topic, func(ctx context.Context, v int) error {
+			<-gate
+			return boom(ctx, v)
+		}, WithAsync(4), WithPanicPolicy(PanicUnsubscribe))
+		require.NoError(t, err)
+		for _, v := range []int{-1, 1, 2} {
+			require.NoError(t, Publish(context.Background(), b, topic, v))
+		}
+		close(gate)
+		b.Close()
+
+		reasons := make([]DeadLetterReason, 0)
+		for _, dl := range b.DeadLetters() {
+			reasons = append(reasons, dl.Reason)
+		}
+		assert.Equal(t, []DeadLetterReason{ReasonPanic, ReasonEvicted, ReasonEvicted}, reasons)
+		assert.Equal(t, 0, b.Subscribers())
+	})
+
+	t.Run("抛出", func(t *testing.T) {
+		b := New()
+		defer b.Close()
+		_, err := Subscribe(b, topic, boom, WithPanicPolicy(PanicPropagate))
+		require.NoError(t, err)
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = Publish(context.Background(), b, topic, -1)
+		})
+		assert.Len(t, b.DeadLetters(), 1)
+	})
+}
+
+// 测试死信只保留最近的若干条
+func TestBus_DeadLetterCapacity(t *testing.T) {
+	b := New(WithDeadLetterCapacity(2))
+	defer b.Close()
+	topic := NewTopic[int]("counter")
+	_, err := Subscribe(b, topic, func(ctx context.Context, v int) error { return errors.New("失败") })
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		_ = Publish(context.Background(), b, topic, i)
+	}
+	dls := b.DeadLetters()
+	require.Len(t, dls, 2)
+	assert.Equal(t, 2, dls[0].Message.Payload)
+	assert.Equal(t, 3, dls[1].Message.Payload)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现主题通配符匹配。主题名按 "." 分段，如 "inventory.stock.changed"，
+// 订阅模式中 "*" 匹配恰好一段，"**" 匹配零段或多段。
+
+package eventbus
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidPattern 订阅模式不合法
+var ErrInvalidPattern = errors.New("ggu: 订阅模式不合法")
+
+const (
+	segmentSep   = "."
+	wildcardOne  = "*"
+	wildcardMany = "**"
+)
+
+// pattern 解析后的订阅模式
+type pattern struct {
+	raw      string
+	segments []string
+	wildcard bool // 是否包含通配符，不包含时可以直接比较字符串
+}
+
+// parsePattern 解析订阅模式，段不能为空，通配符必须独占一段
+func parsePattern(raw string) (*pattern, error) {
+	if raw == "" {
+		return nil, ErrInvalidPattern
+	}
+	segments := strings.Split(raw, segmentSep)
+	p := &pattern{raw: raw, segments: segments}
+	for _, seg := range segments {
+		switch {
+		case seg == "":
+			return nil, ErrInvalidPattern
+		case seg == wildcardOne || seg == wildcardMany:
+			p.wildcard = true
+		case strings.Contains(seg, wildcardOne):
+			return nil, ErrInvalidPattern
+		}
+	}
+	return p, nil
+}
+
+// match 判断主题名是否匹配订阅模式
+func (p *pattern) match(topic string) bool {
+	if !p.wildcard {
+		return p.raw == topic
+	}
+	return matchSegments(p.segments, strings.Split(topic, segmentSep))
+}
+
+func matchSegments(pat, topic []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == wildcardMany {
+			// 合并连续的 "**"，然后尝试让它吞掉 0..n 段
+			for len(pat) > 0 && pat[0] == wildcardMany {
+				pat = pat[1:]
+			}
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(topic); i++ {
+				if matchSegments(pat, topic[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(topic) == 0 {
+			return false
+		}
+		if pat[0] != wildcardOne && pat[0] != topic[0] {
+			return false
+		}
+		pat, topic = pat[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
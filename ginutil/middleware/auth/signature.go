@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/dataStructures/set"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/ecode"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/response"
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 常见错误
+var (
+	// ErrSignatureMissing 表示请求缺少签名相关的请求头
+	ErrSignatureMissing = errors.New("缺少请求签名")
+
+	// ErrSignatureExpired 表示请求时间戳无效或超出允许的时钟偏差
+	ErrSignatureExpired = errors.New("请求签名已过期")
+
+	// ErrSignatureInvalid 表示签名校验失败
+	ErrSignatureInvalid = errors.New("无效的请求签名")
+
+	// ErrNonceReused 表示 nonce 已经被使用过，请求可能被重放
+	ErrNonceReused = errors.New("重复的请求")
+
+	// ErrSignatureBodyTooLarge 表示请求体超过签名校验允许的大小
+	ErrSignatureBodyTooLarge = errors.New("请求体过大")
+)
+
+// NonceStore 记录已使用的 nonce，用于防止请求重放
+type NonceStore interface {
+	// Add 记录 nonce 并在 ttl 后过期，nonce 已存在时返回 false
+	Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore 基于 set.ExpirableSet 的单机 nonce 存储
+type MemoryNonceStore struct {
+	mu  sync.Mutex
+	set *set.ExpirableSet[string]
+}
+
+// NewMemoryNonceStore 创建单机 nonce 存储，s 为 nil 时创建每分钟清理一次的集合
+func NewMemoryNonceStore(s *set.ExpirableSet[string]) *MemoryNonceStore {
+	if s == nil {
+		s = set.NewExpirableSet[string](time.Minute)
+	}
+	return &MemoryNonceStore{set: s}
+}
+
+// Add 实现 NonceStore 接口
+func (m *MemoryNonceStore) Add(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.set.Exist(nonce) {
+		return false, nil
+	}
+	m.set.AddWithTTL(nonce, ttl)
+	return true, nil
+}
+
+// RedisNonceStore 基于 Redis SETNX 的 nonce 存储，适合多实例部署
+type RedisNonceStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisNonceStore 创建 Redis nonce 存储，keyPrefix 为空时使用 "nonce:"
+func NewRedisNonceStore(client *redis.Client, keyPrefix string) *RedisNonceStore {
+	if keyPrefix == "" {
+		keyPrefix = "nonce:"
+	}
+	return &RedisNonceStore{client: client, keyPrefix: keyPrefix}
+}
+
+// Add 实现 NonceStore 接口
+func (r *RedisNonceStore) Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.keyPrefix+nonce, 1, ttl).Result()
+}
+
+// SignatureConfig 定义签名校验中间件的配置选项
+type SignatureConfig struct {
+	// SecretFunc 返回校验当前请求使用的密钥，例如按 X-API-Key 查找调用方的密钥
+	SecretFunc func(c *gin.Context) ([]byte, error)
+
+	// MaxSkew 允许的客户端与服务端时钟偏差，默认5分钟
+	MaxSkew time.Duration
+
+	// NonceStore 记录已使用的 nonce，默认使用 MemoryNonceStore
+	NonceStore NonceStore
+
+	// MaxBodySize 参与签名校验的请求体最大字节数，默认10MB
+	MaxBodySize int64
+
+	// AllowUnsignedPayload 是否接受请求体不参与签名的请求（X-Content-Sha256: UNSIGNED-PAYLOAD），默认不接受
+	AllowUnsignedPayload bool
+
+	// Clock 校验时间戳使用的时钟，默认使用系统时钟
+	Clock clock.Clock
+
+	// ErrorHandler 自定义错误处理函数
+	ErrorHandler func(c *gin.Context, err error)
+}
+
+// SignatureOption 是用于配置签名校验中间件的函数类型
+type SignatureOption func(*SignatureConfig)
+
+// WithMaxSkew 设置允许的时钟偏差，nonce 会被保留两倍于该时长
+func WithMaxSkew(d time.Duration) SignatureOption {
+	return func(config *SignatureConfig) {
+		config.MaxSkew = d
+	}
+}
+
+// WithNonceStore 设置 nonce 存储，多实例部署时应使用 RedisNonceStore
+func WithNonceStore(store NonceStore) SignatureOption {
+	return func(config *SignatureConfig) {
+		config.NonceStore = store
+	}
+}
+
+// WithMaxBodySize 设置参与签名校验的请求体最大字节数
+func WithMaxBodySize(n int64) SignatureOption {
+	return func(config *SignatureConfig) {
+		config.MaxBodySize = n
+	}
+}
+
+// WithUnsignedPayload 接受请求体不参与签名的请求，与 net.WithUnsignedPayload 配合用于大文件等流式上传
+// 这类请求的请求体不会被读入内存，签名只保护方法、路径、查询参数、时间戳和 nonce
+func WithUnsignedPayload() SignatureOption {
+	return func(config *SignatureConfig) {
+		config.AllowUnsignedPayload = true
+	}
+}
+
+// WithSignatureClock 设置校验时间戳使用的时钟，测试中可以传入 clock.FakeClock
+func WithSignatureClock(clk clock.Clock) SignatureOption {
+	return func(config *SignatureConfig) {
+		config.Clock = clk
+	}
+}
+
+// WithSignatureErrorHandler 设置错误处理函数
+func WithSignatureErrorHandler(handler func(c *gin.Context, err error)) SignatureOption {
+	return func(config *SignatureConfig) {
+		config.ErrorHandler = handler
+	}
+}
+
+// StaticSecret 返回使用固定密钥的 SecretFunc
+func StaticSecret(secret string) func(c *gin.Context) ([]byte, error) {
+	return func(*gin.Context) ([]byte, error) {
+		return []byte(secret), nil
+	}
+}
+
+// 默认签名校验错误处理器
+func defaultSignatureErrorHandler(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrSignatureMissing),
+		errors.Is(err, ErrSignatureExpired),
+		errors.Is(err, ErrSignatureInvalid),
+		errors.Is(err, ErrNonceReused):
+		response.Fail(c, ecode.AccessUnauthorized, err.Error())
+	case errors.Is(err, ErrSignatureBodyTooLarge):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, "签名校验失败")
+	}
+}
+
+// NewSignatureMiddleware 创建一个校验 HMAC-SHA256 请求签名的中间件
+// 签名规则与 net.RequestSigner 一致：先检查时间戳是否在允许的偏差内，
+// 再校验签名，最后记录 nonce 拒绝重放的请求。请求体校验后会被还原，后续处理函数可以正常读取
+func NewSignatureMiddleware(secretFunc func(c *gin.Context) ([]byte, error), options ...SignatureOption) gin.HandlerFunc {
+	// 创建默认配置
+	config := &SignatureConfig{
+		SecretFunc:   secretFunc,
+		MaxSkew:      5 * time.Minute,
+		MaxBodySize:  10 << 20,
+		ErrorHandler: defaultSignatureErrorHandler,
+	}
+
+	// 应用选项
+	for _, option := range options {
+		option(config)
+	}
+	config.Clock = clock.OrReal(config.Clock)
+	if config.NonceStore == nil {
+		config.NonceStore = NewMemoryNonceStore(set.NewExpirableSet[string](time.Minute, set.WithClock(config.Clock)))
+	}
+
+	return func(c *gin.Context) {
+		if err := verifySignature(c, config); err != nil {
+			config.ErrorHandler(c, err)
+			c.Abort()
+			return
+		}
+
+		// 继续处理请求
+		c.Next()
+	}
+}
+
+// verifySignature 校验请求的签名
+func verifySignature(c *gin.Context, config *SignatureConfig) error {
+	timestamp := c.GetHeader(ggunet.HeaderTimestamp)
+	nonce := c.GetHeader(ggunet.HeaderNonce)
+	signature := c.GetHeader(ggunet.HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+
+	// 检查时间戳
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureExpired
+	}
+	skew := config.Clock.Since(time.Unix(ts, 0))
+	if skew > config.MaxSkew || skew < -config.MaxSkew {
+		return ErrSignatureExpired
+	}
+
+	// 读取请求体计算摘要并还原，请求体不参与签名时不读取
+	var bodyHash string
+	if c.GetHeader(ggunet.HeaderContentSHA256) == ggunet.UnsignedPayload {
+		if !config.AllowUnsignedPayload {
+			return ErrSignatureInvalid
+		}
+		bodyHash = ggunet.UnsignedPayload
+	} else {
+		var body []byte
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body, err = io.ReadAll(io.LimitReader(c.Request.Body, config.MaxBodySize+1))
+			c.Request.Body.Close()
+			if err != nil {
+				return err
+			}
+			if int64(len(body)) > config.MaxBodySize {
+				return ErrSignatureBodyTooLarge
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		sum := sha256.Sum256(body)
+		bodyHash = hex.EncodeToString(sum[:])
+	}
+
+	// 校验签名
+	secret, err := config.SecretFunc(c)
+	if err != nil {
+		return errors.Join(ErrSignatureInvalid, err)
+	}
+	expected := ggunet.SignCanonical(secret, ggunet.CanonicalRequestWithHash(c.Request.Method, c.Request.URL, timestamp, nonce, bodyHash))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+
+	// 签名通过后才记录 nonce，避免伪造的请求占用 nonce
+	ok, err := config.NonceStore.Add(c.Request.Context(), nonce, 2*config.MaxSkew)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNonceReused
+	}
+	return nil
+}
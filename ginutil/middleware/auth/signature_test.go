@@ -0,0 +1,169 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/middleware/auth"
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSignedRouter 创建使用签名校验中间件的路由，处理函数回显请求体
+func newSignedRouter(clk clock.Clock, opts ...auth.SignatureOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(auth.NewSignatureMiddleware(auth.StaticSecret("secret-1"), append([]auth.SignatureOption{auth.WithSignatureClock(clk)}, opts...)...))
+	r.POST("/orders", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+// signedRequest 创建使用 net.RequestSigner 签名的请求
+func signedRequest(t *testing.T, clk clock.Clock, secret, target, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	require.NoError(t, ggunet.NewRequestSigner(secret, ggunet.WithSignerClock(clk)).Sign(req))
+	return req
+}
+
+func serveRequest(r *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 测试签名校验通过、重放和篡改
+func TestSignatureMiddleware(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	r := newSignedRouter(clk)
+
+	req := signedRequest(t, clk, "secret-1", "/orders?b=2&a=1", `{"qty":2}`)
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(strings.NewReader(`{"qty":2}`))
+
+	w := serveRequest(r, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"qty":2}`, w.Body.String(), "校验后处理函数仍能读取请求体")
+
+	w = serveRequest(r, replay)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), auth.ErrNonceReused.Error())
+
+	tampered := signedRequest(t, clk, "secret-1", "/orders", `{"qty":2}`)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"qty":200}`))
+	assert.Equal(t, http.StatusUnauthorized, serveRequest(r, tampered).Code)
+
+	tampered = signedRequest(t, clk, "secret-1", "/orders?qty=2", "")
+	tampered.URL.RawQuery = "qty=200"
+	assert.Equal(t, http.StatusUnauthorized, serveRequest(r, tampered).Code)
+
+	wrongKey := signedRequest(t, clk, "secret-2", "/orders", "")
+	w = serveRequest(r, wrongKey)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), auth.ErrSignatureInvalid.Error())
+
+	w = serveRequest(r, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), auth.ErrSignatureMissing.Error())
+}
+
+// 测试时钟偏差和请求体大小限制
+func TestSignatureMiddleware_SkewAndBodySize(t *testing.T) {
+	serverClock := clock.NewFakeClock(time.Unix(1700000000, 0))
+	var errs []error
+	r := newSignedRouter(serverClock,
+		auth.WithMaxSkew(time.Minute),
+		auth.WithMaxBodySize(8),
+		auth.WithSignatureErrorHandler(func(c *gin.Context, err error) {
+			errs = append(errs, err)
+			c.Status(http.StatusTeapot)
+		}),
+	)
+
+	early := clock.NewFakeClock(serverClock.Now().Add(-61 * time.Second))
+	late := clock.NewFakeClock(serverClock.Now().Add(59 * time.Second))
+	assert.Equal(t, http.StatusTeapot, serveRequest(r, signedRequest(t, early, "secret-1", "/orders", "")).Code)
+	assert.Equal(t, http.StatusOK, serveRequest(r, signedRequest(t, late, "secret-1", "/orders", "")).Code)
+	assert.Equal(t, http.StatusTeapot, serveRequest(r, signedRequest(t, serverClock, "secret-1", "/orders", "123456789")).Code)
+
+	require.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[0], auth.ErrSignatureExpired)
+	assert.ErrorIs(t, errs[1], auth.ErrSignatureBodyTooLarge)
+}
+
+// 测试请求体不参与签名的请求只在开启 WithUnsignedPayload 时通过，请求体不会被中间件读取
+func TestSignatureMiddleware_UnsignedPayload(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	signer := ggunet.NewRequestSigner("secret-1", ggunet.WithSignerClock(clk), ggunet.WithUnsignedPayload())
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders", io.NopCloser(strings.NewReader(`{"qty":2}`)))
+		require.NoError(t, signer.Sign(req))
+		return req
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serveRequest(newSignedRouter(clk), newRequest()).Code, "默认不接受")
+
+	r := newSignedRouter(clk, auth.WithUnsignedPayload(), auth.WithMaxBodySize(4))
+	w := serveRequest(r, newRequest())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"qty":2}`, w.Body.String(), "请求体不受 MaxBodySize 限制")
+
+	// 把签名了请求体的请求改为不签名请求体时签名不匹配
+	req := signedRequest(t, clk, "secret-1", "/orders", `{"qty":2}`)
+	req.Header.Set(ggunet.HeaderContentSHA256, ggunet.UnsignedPayload)
+	assert.Equal(t, http.StatusUnauthorized, serveRequest(r, req).Code)
+}
+
+// failingNonceStore 总是返回错误的 nonce 存储
+type failingNonceStore struct{}
+
+func (failingNonceStore) Add(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("redis 不可用")
+}
+
+// 测试 nonce 存储出错时返回500
+func TestSignatureMiddleware_NonceStoreError(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	r := newSignedRouter(clk, auth.WithNonceStore(failingNonceStore{}))
+	assert.Equal(t, http.StatusInternalServerError, serveRequest(r, signedRequest(t, clk, "secret-1", "/orders", "")).Code)
+}
+
+// 测试 ECommerceAPIClient 发出的请求可以通过校验
+func TestSignatureMiddleware_ECommerceClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(auth.NewSignatureMiddleware(func(c *gin.Context) ([]byte, error) {
+		if c.GetHeader("X-API-Key") != "key-1" {
+			return nil, errors.New("未知的 API Key")
+		}
+		return []byte("secret-1"), nil
+	}))
+	r.PUT("/products/:id/inventory", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := ggunet.NewECommerceAPIClient(srv.URL, "key-1", "secret-1", time.Second)
+	result, err := client.UpdateInventory(context.Background(), "p 1", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "p 1", result["id"])
+
+	client = ggunet.NewECommerceAPIClient(srv.URL, "key-1", "secret-2", time.Second)
+	_, err = client.UpdateInventory(context.Background(), "p 1", 3)
+	var httpErr *ggunet.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+}
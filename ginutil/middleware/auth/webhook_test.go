@@ -0,0 +1,71 @@
+package auth_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/middleware/auth"
+	"github.com/Humphrey-He/go-generic-utils/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookRequest 创建按 webhook.Dispatcher 的规则签名的请求
+func webhookRequest(clk clock.Clock, secret, id, body string) *http.Request {
+	ts := clk.Now().Unix()
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	req.Header.Set(webhook.HeaderID, id)
+	req.Header.Set(webhook.HeaderEvent, "order.paid")
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(secret, id, ts, []byte(body)))
+	return req
+}
+
+// 测试 webhook 签名校验通过、篡改、过期和重放
+func TestWebhookMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	r := gin.New()
+	r.Use(auth.NewWebhookMiddleware(auth.StaticSecret("whsec-1"), auth.WithSignatureClock(clk)))
+	r.POST("/hooks", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	w := serveRequest(r, webhookRequest(clk, "whsec-1", "dlv_1", `{"id":"evt_1"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"id":"evt_1"}`, w.Body.String(), "校验后处理函数仍能读取请求体")
+
+	// 截获的请求原样重放被拒绝
+	w = serveRequest(r, webhookRequest(clk, "whsec-1", "dlv_1", `{"id":"evt_1"}`))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 发送方重试同一投递时使用新的时间戳
+	clk.Advance(10 * time.Second)
+	w = serveRequest(r, webhookRequest(clk, "whsec-1", "dlv_1", `{"id":"evt_1"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req := webhookRequest(clk, "whsec-1", "dlv_2", `{"id":"evt_2"}`)
+	req.Body = io.NopCloser(strings.NewReader(`{"id":"evt_3"}`))
+	w = serveRequest(r, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serveRequest(r, webhookRequest(clk, "whsec-2", "dlv_3", `{}`))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = webhookRequest(clk, "whsec-1", "dlv_4", `{}`)
+	clk.Advance(6 * time.Minute)
+	w = serveRequest(r, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(`{}`))
+	w = serveRequest(r, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
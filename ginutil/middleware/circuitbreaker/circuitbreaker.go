@@ -0,0 +1,154 @@
+// Package circuitbreaker 提供了一个用于 Gin 框架的熔断中间件。
+//
+// 该中间件用 breaker.Breaker 保护依赖下游服务的路由：处理失败（5xx 响应或 c.Errors 非空）
+// 计入熔断器统计，熔断器打开后直接返回错误响应，不再执行后续处理函数，避免故障向上游蔓延。
+package circuitbreaker
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/ecode"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BreakerFunc 是为请求选择熔断器的函数类型，例如按路由或下游服务区分熔断器。
+type BreakerFunc func(c *gin.Context) *breaker.Breaker
+
+// FailureFunc 是判断请求处理是否失败的函数类型，在后续处理函数执行完后调用。
+type FailureFunc func(c *gin.Context) bool
+
+// ErrorHandler 是处理熔断器拒绝请求的函数类型，err 为 breaker.ErrOpen 或 breaker.ErrTooManyRequests。
+type ErrorHandler func(c *gin.Context, err error)
+
+// SkipperFunc 是判断是否跳过熔断的函数类型。
+type SkipperFunc func(c *gin.Context) bool
+
+// Config 定义了熔断中间件的配置选项。
+type Config struct {
+	// BreakerFunc 是为请求选择熔断器的函数，默认所有请求共用一个熔断器。
+	BreakerFunc BreakerFunc
+
+	// IsFailure 是判断请求处理是否失败的函数，默认 5xx 响应或 c.Errors 非空视为失败。
+	IsFailure FailureFunc
+
+	// ErrorHandler 是处理熔断器拒绝请求的函数。
+	// 如果不提供，将使用默认的错误处理函数。
+	ErrorHandler ErrorHandler
+
+	// Skipper 是判断是否跳过熔断的函数。
+	// 如果不提供，将对所有请求进行熔断保护。
+	Skipper SkipperFunc
+}
+
+// Option 是配置熔断中间件的函数选项。
+type Option func(*Config)
+
+// WithBreaker 设置所有请求共用的熔断器。
+func WithBreaker(b *breaker.Breaker) Option {
+	return func(c *Config) {
+		c.BreakerFunc = func(*gin.Context) *breaker.Breaker { return b }
+	}
+}
+
+// WithBreakerFunc 设置为请求选择熔断器的函数。
+func WithBreakerFunc(fn BreakerFunc) Option {
+	return func(c *Config) {
+		c.BreakerFunc = fn
+	}
+}
+
+// WithFailureFunc 设置判断请求处理是否失败的函数。
+func WithFailureFunc(fn FailureFunc) Option {
+	return func(c *Config) {
+		c.IsFailure = fn
+	}
+}
+
+// WithErrorHandler 设置处理熔断器拒绝请求的函数。
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(c *Config) {
+		c.ErrorHandler = handler
+	}
+}
+
+// WithSkipper 设置判断是否跳过熔断的函数。
+func WithSkipper(skipper SkipperFunc) Option {
+	return func(c *Config) {
+		c.Skipper = skipper
+	}
+}
+
+// DefaultConfig 返回默认的熔断中间件配置。
+func DefaultConfig() *Config {
+	b := breaker.New()
+	return &Config{
+		BreakerFunc:  func(*gin.Context) *breaker.Breaker { return b },
+		IsFailure:    defaultIsFailure,
+		ErrorHandler: defaultErrorHandler,
+		Skipper:      nil,
+	}
+}
+
+// 默认的判断请求处理是否失败的函数
+func defaultIsFailure(c *gin.Context) bool {
+	return c.Writer.Status() >= http.StatusInternalServerError || len(c.Errors) > 0
+}
+
+// 默认的处理熔断器拒绝请求的函数
+func defaultErrorHandler(c *gin.Context, err error) {
+	if !c.IsAborted() {
+		response.Fail(c, ecode.ErrorCodeThirdPartyUnavailable, "下游服务暂时不可用，请稍后重试")
+	}
+}
+
+// New 使用指定的熔断器创建一个新的熔断中间件。
+func New(b *breaker.Breaker) gin.HandlerFunc {
+	return NewWithConfig(WithBreaker(b))
+}
+
+// NewWithConfig 使用自定义配置创建一个新的熔断中间件。
+func NewWithConfig(options ...Option) gin.HandlerFunc {
+	config := DefaultConfig()
+	for _, option := range options {
+		option(config)
+	}
+	return newBreakerHandler(config)
+}
+
+// newBreakerHandler 创建一个处理熔断的 Gin 中间件。
+func newBreakerHandler(config *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 检查是否跳过熔断
+		if config.Skipper != nil && config.Skipper(c) {
+			c.Next()
+			return
+		}
+
+		// 申请调用，熔断器拒绝时直接返回
+		done, err := config.BreakerFunc(c).Allow()
+		if err != nil {
+			config.ErrorHandler(c, err)
+			return
+		}
+
+		// 后续处理函数 panic 时计为失败，panic 继续交给 recovery 中间件处理
+		defer func() {
+			if r := recover(); r != nil {
+				done(fmt.Errorf("ggu: panic: %v", r))
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		if config.IsFailure(c) {
+			done(fmt.Errorf("ggu: 请求处理失败，状态码 %d", c.Writer.Status()))
+			return
+		}
+		done(nil)
+	}
+}
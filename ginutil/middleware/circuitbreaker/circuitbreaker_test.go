@@ -0,0 +1,89 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+	"github.com/Humphrey-He/go-generic-utils/ginutil/middleware/circuitbreaker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// serve 发送一个 GET 请求并返回状态码
+func serve(r *gin.Engine, path string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// 测试下游连续失败后熔断，熔断期间不再执行处理函数
+func TestCircuitBreakerOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	b := breaker.New(breaker.WithCountWindow(2))
+	r := gin.New()
+	r.Use(circuitbreaker.New(b))
+
+	calls := 0
+	r.GET("/inventory", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(r, "/inventory"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(r, "/inventory"))
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	// 熔断后返回第三方服务不可用
+	assert.Equal(t, http.StatusBadGateway, serve(r, "/inventory"))
+	assert.Equal(t, 2, calls)
+}
+
+// 测试 c.Errors 计为失败、自定义错误处理和跳过
+func TestCircuitBreakerConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	b := breaker.New(breaker.WithCountWindow(1))
+	var rejected error
+	r := gin.New()
+	r.Use(circuitbreaker.NewWithConfig(
+		circuitbreaker.WithBreakerFunc(func(c *gin.Context) *breaker.Breaker { return b }),
+		circuitbreaker.WithErrorHandler(func(c *gin.Context, err error) {
+			rejected = err
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+		}),
+		circuitbreaker.WithSkipper(func(c *gin.Context) bool { return c.Request.URL.Path == "/health" }),
+	))
+	r.GET("/orders", func(c *gin.Context) {
+		_ = c.Error(errors.New("下游超时"))
+		c.Status(http.StatusOK)
+	})
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusOK, serve(r, "/orders"))
+	assert.Equal(t, breaker.StateOpen, b.State(), "c.Errors 非空应计为失败")
+	assert.Equal(t, http.StatusServiceUnavailable, serve(r, "/orders"))
+	assert.ErrorIs(t, rejected, breaker.ErrOpen)
+	assert.Equal(t, http.StatusOK, serve(r, "/health"), "跳过的请求不受熔断影响")
+}
+
+// 测试处理函数 panic 计为失败
+func TestCircuitBreakerPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	b := breaker.New(breaker.WithCountWindow(1))
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	r.Use(circuitbreaker.New(b))
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	assert.Equal(t, http.StatusInternalServerError, serve(r, "/panic"))
+	assert.Equal(t, breaker.StateOpen, b.State())
+}
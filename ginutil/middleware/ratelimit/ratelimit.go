@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
 	"github.com/Humphrey-He/go-generic-utils/ginutil/response"
 
 	"github.com/gin-gonic/gin"
@@ -67,6 +68,10 @@ type Config struct {
 
 	// DisableHeaders 表示是否禁用限流相关的响应头，默认为 false。
 	DisableHeaders bool
+
+	// Clock 是计算 X-RateLimit-Reset 头部使用的时钟，默认使用系统时钟。
+	// 使用默认的内存存储时，存储也会使用这个时钟。
+	Clock clock.Clock
 }
 
 // Option 是配置限流中间件的函数选项。
@@ -128,6 +133,13 @@ func WithDisableHeaders(disable bool) Option {
 	}
 }
 
+// WithClock 设置限流中间件使用的时钟，测试中可以传入 clock.FakeClock。
+func WithClock(clk clock.Clock) Option {
+	return func(c *Config) {
+		c.Clock = clk
+	}
+}
+
 // DefaultConfig 返回默认的限流中间件配置。
 func DefaultConfig() *Config {
 	return &Config{
@@ -168,9 +180,16 @@ func New() gin.HandlerFunc {
 // NewWithConfig 使用自定义配置创建一个新的限流中间件。
 func NewWithConfig(options ...Option) gin.HandlerFunc {
 	config := DefaultConfig()
+	defaultStore := config.Store
 	for _, option := range options {
 		option(config)
 	}
+	if config.Clock != nil && config.Store == defaultStore {
+		// 默认的内存存储同样使用配置的时钟
+		_ = defaultStore.Close()
+		config.Store = NewMemoryStore(time.Minute*5, WithMemoryStoreClock(config.Clock))
+	}
+	config.Clock = clock.OrReal(config.Clock)
 	return newRateLimitHandler(config)
 }
 
@@ -200,7 +219,7 @@ func newRateLimitHandler(config *Config) gin.HandlerFunc {
 		if !config.DisableHeaders {
 			c.Header("X-RateLimit-Limit", formatRateLimit(config.Limit))
 			c.Header("X-RateLimit-Remaining", formatRateLimitRemaining(config.Limit, config.Burst, config.TokensPerRequest))
-			c.Header("X-RateLimit-Reset", formatRateLimitReset(config.Limit))
+			c.Header("X-RateLimit-Reset", formatRateLimitReset(config.Clock, config.Limit))
 		}
 
 		// 继续处理请求
@@ -224,9 +243,9 @@ func formatRateLimitRemaining(limit rate.Limit, burst, tokens int) string {
 }
 
 // formatRateLimitReset 格式化 RateLimit-Reset 头部的值。
-func formatRateLimitReset(limit rate.Limit) string {
+func formatRateLimitReset(clk clock.Clock, limit rate.Limit) string {
 	// 计算令牌桶重新填满的时间（秒）
-	resetTime := time.Now().Add(time.Second * time.Duration(1/float64(limit)))
+	resetTime := clk.Now().Add(time.Second * time.Duration(1/float64(limit)))
 	return formatInt(int(resetTime.Unix()))
 }
 
@@ -245,21 +264,36 @@ type memoryStoreEntry struct {
 type MemoryStore struct {
 	entries  map[string]*memoryStoreEntry
 	mu       sync.Mutex
-	janitor  *time.Ticker
+	janitor  clock.Ticker
 	stopChan chan struct{}
 	ttl      time.Duration
+	clock    clock.Clock
+}
+
+// MemoryStoreOption 是配置内存存储的函数选项。
+type MemoryStoreOption func(*MemoryStore)
+
+// WithMemoryStoreClock 设置内存存储使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock。
+func WithMemoryStoreClock(clk clock.Clock) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.clock = clk
+	}
 }
 
 // NewMemoryStore 创建一个新的内存存储。
-func NewMemoryStore(ttl time.Duration) *MemoryStore {
+func NewMemoryStore(ttl time.Duration, opts ...MemoryStoreOption) *MemoryStore {
 	store := &MemoryStore{
 		entries:  make(map[string]*memoryStoreEntry),
 		ttl:      ttl,
 		stopChan: make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	store.clock = clock.OrReal(store.clock)
 
 	// 启动清理 goroutine
-	store.janitor = time.NewTicker(ttl)
+	store.janitor = store.clock.NewTicker(ttl)
 	go store.cleanupLoop()
 
 	return store
@@ -270,24 +304,25 @@ func (s *MemoryStore) AllowN(key string, limit rate.Limit, burst int, n int) (bo
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.clock.Now()
+
 	// 获取或创建限流器
 	entry, exists := s.entries[key]
 	if !exists || entry.limiter.Limit() != limit || entry.limiter.Burst() != burst {
 		entry = &memoryStoreEntry{
 			limiter:  rate.NewLimiter(limit, burst),
-			lastSeen: time.Now(),
+			lastSeen: now,
 		}
 		s.entries[key] = entry
 	} else {
-		entry.lastSeen = time.Now()
+		entry.lastSeen = now
 	}
 
 	// 检查是否允许请求
-	now := time.Now()
 	reservation := entry.limiter.ReserveN(now, n)
 	if !reservation.OK() {
 		// 不允许请求，计算需要等待的时间
-		retryAfter := entry.limiter.Reserve().Delay()
+		retryAfter := entry.limiter.ReserveN(now, 1).DelayFrom(now)
 		return false, retryAfter
 	}
 
@@ -295,7 +330,7 @@ func (s *MemoryStore) AllowN(key string, limit rate.Limit, burst int, n int) (bo
 	delay := reservation.DelayFrom(now)
 	if delay > 0 {
 		// 需要等待，取消预约并返回需要等待的时间
-		reservation.Cancel()
+		reservation.CancelAt(now)
 		return false, delay
 	}
 
@@ -314,7 +349,7 @@ func (s *MemoryStore) Close() error {
 func (s *MemoryStore) cleanupLoop() {
 	for {
 		select {
-		case <-s.janitor.C:
+		case <-s.janitor.C():
 			s.cleanup()
 		case <-s.stopChan:
 			return
@@ -327,7 +362,7 @@ func (s *MemoryStore) cleanup() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
+	now := s.clock.Now()
 	for key, entry := range s.entries {
 		if now.Sub(entry.lastSeen) > s.ttl {
 			delete(s.entries, key)
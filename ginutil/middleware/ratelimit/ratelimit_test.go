@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
 	"github.com/Humphrey-He/go-generic-utils/ginutil/middleware/ratelimit"
 
 	"github.com/gin-gonic/gin"
@@ -76,6 +77,30 @@ func TestMemoryStoreCleanup(t *testing.T) {
 	assert.True(t, allowed)
 }
 
+// 测试使用假时钟控制令牌恢复
+func TestMemoryStoreWithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := ratelimit.NewMemoryStore(time.Minute, ratelimit.WithMemoryStoreClock(clk))
+	defer store.Close()
+
+	// 每秒恢复 1 个令牌，突发 2 个
+	for i := 0; i < 2; i++ {
+		allowed, _ := store.AllowN("clock-test", 1, 2, 1)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := store.AllowN("clock-test", 1, 2, 1)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// 时钟未推进时令牌不会恢复
+	allowed, _ = store.AllowN("clock-test", 1, 2, 1)
+	assert.False(t, allowed)
+
+	clk.Advance(time.Second)
+	allowed, _ = store.AllowN("clock-test", 1, 2, 1)
+	assert.True(t, allowed)
+}
+
 // 测试基本的限流中间件功能
 func TestRateLimitMiddleware(t *testing.T) {
 	// 设置为测试模式
@@ -111,6 +136,36 @@ func TestRateLimitMiddleware(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "请求过于频繁")
 }
 
+// 测试中间件的时钟同样用于默认的内存存储
+func TestRateLimitMiddlewareWithClock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	r := gin.New()
+	r.Use(ratelimit.NewWithConfig(
+		ratelimit.WithLimit(1),
+		ratelimit.WithBurst(1),
+		ratelimit.WithClock(clk),
+	))
+	r.GET("/api/clock", func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+
+	do := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/clock", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, do())
+	// 被限流的请求由 ErrorHandler 处理，这里只关心请求没有放行
+	assert.NotEqual(t, http.StatusOK, do())
+	assert.NotEqual(t, http.StatusOK, do())
+
+	// 推进时钟后令牌恢复
+	clk.Advance(time.Second)
+	assert.Equal(t, http.StatusOK, do())
+}
+
 // 测试基于 IP 的限流
 func TestRateLimitPerIP(t *testing.T) {
 	// 设置为测试模式
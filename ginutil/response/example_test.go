@@ -3,7 +3,9 @@ package response_test
 import (
 	"fmt"
 	"net/http/httptest"
+	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
 	"github.com/Humphrey-He/go-generic-utils/ginutil/ecode"
 	"github.com/Humphrey-He/go-generic-utils/ginutil/response"
 
@@ -209,3 +211,25 @@ func Example_customErrorCode() {
 	// Status Code: 400
 	// Response Body: {"code":40050,"message":"商品库存不足","trace_id":"test-trace-id","server_time":1620000000000}
 }
+
+// 这个示例展示如何使用假时钟控制响应中的服务器时间
+func ExampleSetClock() {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ping", func(c *gin.Context) {
+		response.OK(c, "pong")
+	})
+
+	clk := clock.NewFakeClock(time.UnixMilli(1620000000000))
+	response.SetClock(clk)
+	// 恢复其他示例使用的固定时间
+	defer response.SetFixedServerTimeForTest(1620000000000)
+
+	clk.Advance(time.Second)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+	fmt.Println(w.Body.String())
+
+	// Output:
+	// {"code":0,"message":"操作成功","data":"pong","server_time":1620000001000}
+}
@@ -3,8 +3,10 @@ package response
 import (
 	// 引入错误码包
 
+	"sync/atomic"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,19 +23,34 @@ type StandardResponse[T any] struct {
 // GinTraceIDKey 是从Gin Context中获取TraceID时使用的键名。
 const GinTraceIDKey = "X-Trace-ID" // 假设由中间件设置
 
-// 用于测试的固定时间戳
-var fixedServerTime int64 = 0
+// serverClock 生成 ServerTime 使用的时钟
+var serverClock atomic.Pointer[clockHolder]
 
-// SetFixedServerTimeForTest 设置一个固定的服务器时间戳，用于测试。
+// clockHolder 包装接口值，便于原子替换
+type clockHolder struct {
+	clock clock.Clock
+}
+
+// SetClock 设置生成响应 ServerTime 使用的时钟，测试中可以传入 clock.FakeClock。
+// 传入 nil 将恢复使用系统时钟。
+func SetClock(clk clock.Clock) {
+	serverClock.Store(&clockHolder{clock: clock.OrReal(clk)})
+}
+
+// SetFixedServerTimeForTest 设置一个固定的服务器时间戳（毫秒），用于测试。
 // 设置为0将恢复使用实时时间戳。
 func SetFixedServerTimeForTest(timestamp int64) {
-	fixedServerTime = timestamp
+	if timestamp > 0 {
+		SetClock(clock.NewFakeClock(time.UnixMilli(timestamp)))
+		return
+	}
+	SetClock(nil)
 }
 
 // getServerTime 获取服务器时间戳
 func getServerTime() int64 {
-	if fixedServerTime > 0 {
-		return fixedServerTime
+	if h := serverClock.Load(); h != nil {
+		return h.clock.Now().UnixMilli()
 	}
 	return time.Now().UnixMilli()
 }
@@ -1,10 +1,14 @@
 package errs
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
+// ErrRetryExhausted 重试次数耗尽，NewErrRetryExhausted 返回的错误都包装了它
+var ErrRetryExhausted = errors.New("guu: 超过最大重试次数")
+
 // NewErrIndexOutOfRange 创建一个代表下标超出范围的错误
 func NewErrIndexOutOfRange(length int, index int) error {
 	return fmt.Errorf("guu: 下标超出范围，长度 %d, 下标 %d", length, index)
@@ -23,6 +27,7 @@ func NewErrInvalidMaxIntervalValue(maxInterval, initialInterval time.Duration) e
 	return fmt.Errorf("guu: 最大重试间隔的时间 [%d] 应大于等于初始重试的间隔时间 [%d] ", maxInterval, initialInterval)
 }
 
+// NewErrRetryExhausted 创建一个代表重试耗尽的错误，同时包装 ErrRetryExhausted 和业务返回的错误
 func NewErrRetryExhausted(lastErr error) error {
-	return fmt.Errorf("guu: 超过最大重试次数，业务返回的最后一个 error %w", lastErr)
+	return fmt.Errorf("%w，业务返回的最后一个 error %w", ErrRetryExhausted, lastErr)
 }
@@ -0,0 +1,255 @@
+package net
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend 负载均衡器中的一个后端及其运行状态，同一URL的后端在服务发现更新后保留状态
+type Backend struct {
+	Endpoint
+	base     *url.URL
+	inflight atomic.Int64
+
+	mu           sync.Mutex
+	failures     int       // 连续失败次数
+	ejectedUntil time.Time // 被剔除到该时间
+}
+
+// newBackend 创建后端，URL 无效时返回错误
+func newBackend(ep Endpoint) (*Backend, error) {
+	base, err := parseEndpointURL(ep.URL)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Weight <= 0 {
+		ep.Weight = 1
+	}
+	return &Backend{Endpoint: ep, base: base}, nil
+}
+
+// Inflight 返回正在进行的请求数，从发出请求到收到响应头为止
+func (b *Backend) Inflight() int64 {
+	return b.inflight.Load()
+}
+
+// EjectedUntil 返回后端被剔除到的时间，没有被剔除时返回零值或已经过去的时间
+func (b *Backend) EjectedUntil() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ejectedUntil
+}
+
+// Balancer 负载均衡策略
+type Balancer interface {
+	// Pick 从后端中选择一个，backends 非空且只包含健康的后端
+	Pick(req *http.Request, backends []*Backend) *Backend
+}
+
+// BalancerFunc 函数形式的负载均衡策略
+type BalancerFunc func(req *http.Request, backends []*Backend) *Backend
+
+// Pick 实现 Balancer 接口
+func (f BalancerFunc) Pick(req *http.Request, backends []*Backend) *Backend {
+	return f(req, backends)
+}
+
+// RoundRobinBalancer 轮询
+type RoundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobinBalancer 创建轮询均衡器，负载均衡器默认使用该策略
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+// Pick 实现 Balancer 接口
+func (b *RoundRobinBalancer) Pick(_ *http.Request, backends []*Backend) *Backend {
+	return backends[(b.next.Add(1)-1)%uint64(len(backends))]
+}
+
+// WeightedBalancer 平滑加权轮询，权重为 5、1、1 的后端按 a a b a c a a 的顺序选择，
+// 不会连续把请求集中到权重大的后端
+type WeightedBalancer struct {
+	mu      sync.Mutex
+	current map[*Backend]int
+}
+
+// NewWeightedBalancer 创建加权轮询均衡器，权重来自 Endpoint.Weight
+func NewWeightedBalancer() *WeightedBalancer {
+	return &WeightedBalancer{current: make(map[*Backend]int)}
+}
+
+// Pick 实现 Balancer 接口
+func (b *WeightedBalancer) Pick(_ *http.Request, backends []*Backend) *Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.current) > len(backends) {
+		// 后端列表变化，删除已经不存在的后端
+		for be := range b.current {
+			if !slices.Contains(backends, be) {
+				delete(b.current, be)
+			}
+		}
+	}
+
+	var best *Backend
+	total := 0
+	for _, be := range backends {
+		total += be.Weight
+		b.current[be] += be.Weight
+		if best == nil || b.current[be] > b.current[best] {
+			best = be
+		}
+	}
+	b.current[best] -= total
+	return best
+}
+
+// LeastOutstandingBalancer 选择正在进行的请求最少的后端，请求数相同时轮询
+// 适合各后端处理能力或请求耗时差异较大的场景
+type LeastOutstandingBalancer struct {
+	next atomic.Uint64
+}
+
+// NewLeastOutstandingBalancer 创建最少请求均衡器
+func NewLeastOutstandingBalancer() *LeastOutstandingBalancer {
+	return &LeastOutstandingBalancer{}
+}
+
+// Pick 实现 Balancer 接口
+func (b *LeastOutstandingBalancer) Pick(_ *http.Request, backends []*Backend) *Backend {
+	start := int((b.next.Add(1) - 1) % uint64(len(backends)))
+	best := backends[start]
+	for i := 1; i < len(backends); i++ {
+		be := backends[(start+i)%len(backends)]
+		if be.Inflight() < best.Inflight() {
+			best = be
+		}
+	}
+	return best
+}
+
+// P2CBalancer 两次随机选择（power of two choices），随机取两个后端，选择正在进行的请求较少的一个
+// 效果接近最少请求，但不需要遍历全部后端，后端数量多时开销更小
+type P2CBalancer struct{}
+
+// NewP2CBalancer 创建两次随机选择均衡器
+func NewP2CBalancer() *P2CBalancer {
+	return &P2CBalancer{}
+}
+
+// Pick 实现 Balancer 接口
+func (b *P2CBalancer) Pick(_ *http.Request, backends []*Backend) *Backend {
+	if len(backends) == 1 {
+		return backends[0]
+	}
+	i := rand.IntN(len(backends))
+	j := rand.IntN(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+	if backends[j].Inflight() < backends[i].Inflight() {
+		return backends[j]
+	}
+	return backends[i]
+}
+
+// hashKeyKey 一致性哈希请求键的 context key
+type hashKeyKey struct{}
+
+// ContextWithHashKey 返回携带一致性哈希请求键的 ctx，例如用户ID或购物车ID，
+// 相同请求键的请求在后端列表不变时发往同一后端
+func ContextWithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyKey{}, key)
+}
+
+// HashKeyFromContext 返回 ctx 中的一致性哈希请求键
+func HashKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(hashKeyKey{}).(string)
+	return key
+}
+
+// ConsistentHashBalancer 一致性哈希，按请求键把请求发往固定的后端，适合利用后端的本地缓存
+// 后端增减或被剔除时只有原本属于该后端的请求键会改变去向。请求键为空时轮询
+type ConsistentHashBalancer struct {
+	replicas int
+	key      func(req *http.Request) string
+	fallback RoundRobinBalancer
+
+	mu       sync.Mutex
+	backends []*Backend
+	ring     []ringNode
+}
+
+// ringNode 哈希环上的虚拟节点
+type ringNode struct {
+	hash    uint32
+	backend *Backend
+}
+
+// NewConsistentHashBalancer 创建一致性哈希均衡器
+// replicas 为权重为1的后端在哈希环上的虚拟节点数，不大于0时使用160，权重为 n 的后端有 n 倍的虚拟节点；
+// key 返回请求键，为 nil 时使用 ContextWithHashKey 设置的请求键
+func NewConsistentHashBalancer(replicas int, key func(req *http.Request) string) *ConsistentHashBalancer {
+	if replicas <= 0 {
+		replicas = 160
+	}
+	if key == nil {
+		key = func(req *http.Request) string {
+			return HashKeyFromContext(req.Context())
+		}
+	}
+	return &ConsistentHashBalancer{replicas: replicas, key: key}
+}
+
+// Pick 实现 Balancer 接口
+func (b *ConsistentHashBalancer) Pick(req *http.Request, backends []*Backend) *Backend {
+	key := b.key(req)
+	if key == "" {
+		return b.fallback.Pick(req, backends)
+	}
+
+	b.mu.Lock()
+	if !slices.Equal(b.backends, backends) {
+		b.backends = slices.Clone(backends)
+		b.ring = buildRing(backends, b.replicas)
+	}
+	ring := b.ring
+	b.mu.Unlock()
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].backend
+}
+
+// buildRing 创建哈希环，虚拟节点按后端URL计算，与后端在列表中的顺序无关
+func buildRing(backends []*Backend, replicas int) []ringNode {
+	var ring []ringNode
+	for _, be := range backends {
+		for i := 0; i < replicas*be.Weight; i++ {
+			h := crc32.ChecksumIEEE([]byte(be.URL + "#" + strconv.Itoa(i)))
+			ring = append(ring, ringNode{hash: h, backend: be})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		return ring[i].backend.URL < ring[j].backend.URL
+	})
+	return ring
+}
@@ -0,0 +1,150 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackends 创建测试用的后端，weights 为空时权重都为1
+func testBackends(t *testing.T, n int, weights ...int) []*Backend {
+	backends := make([]*Backend, n)
+	for i := range backends {
+		ep := Endpoint{URL: fmt.Sprintf("http://10.0.0.%d:8080", i+1)}
+		if i < len(weights) {
+			ep.Weight = weights[i]
+		}
+		be, err := newBackend(ep)
+		require.NoError(t, err)
+		backends[i] = be
+	}
+	return backends
+}
+
+// pickSequence 连续选择 n 次，返回选中后端的下标
+func pickSequence(b Balancer, req *http.Request, backends []*Backend, n int) []int {
+	res := make([]int, n)
+	for i := range res {
+		picked := b.Pick(req, backends)
+		for j, be := range backends {
+			if be == picked {
+				res[i] = j
+			}
+		}
+	}
+	return res
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	backends := testBackends(t, 3)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, []int{0, 1, 2, 0, 1, 2}, pickSequence(NewRoundRobinBalancer(), req, backends, 6))
+}
+
+// 测试平滑加权轮询的选择顺序，以及后端列表变化后的权重
+func TestWeightedBalancer(t *testing.T) {
+	backends := testBackends(t, 3, 5, 1, 1)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b := NewWeightedBalancer()
+	assert.Equal(t, []int{0, 0, 1, 0, 2, 0, 0}, pickSequence(b, req, backends, 7))
+
+	// 去掉权重为5的后端后，剩余两个后端轮流选择
+	seq := pickSequence(b, req, backends[1:], 4)
+	assert.ElementsMatch(t, []int{0, 0, 1, 1}, seq)
+	assert.Len(t, b.current, 2)
+}
+
+func TestLeastOutstandingBalancer(t *testing.T) {
+	backends := testBackends(t, 3)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	backends[0].inflight.Store(3)
+	backends[1].inflight.Store(1)
+	backends[2].inflight.Store(2)
+	b := NewLeastOutstandingBalancer()
+	for i := 0; i < 5; i++ {
+		assert.Same(t, backends[1], b.Pick(req, backends))
+	}
+
+	// 请求数相同的后端都会被选中
+	backends[0].inflight.Store(1)
+	seq := pickSequence(b, req, backends, 6)
+	assert.NotContains(t, seq, 2)
+	assert.Contains(t, seq, 0)
+	assert.Contains(t, seq, 1)
+}
+
+func TestP2CBalancer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b := NewP2CBalancer()
+
+	backends := testBackends(t, 1)
+	assert.Same(t, backends[0], b.Pick(req, backends))
+
+	// 两个后端时每次都比较两者，总是选择请求较少的一个
+	backends = testBackends(t, 2)
+	backends[0].inflight.Store(10)
+	for i := 0; i < 20; i++ {
+		assert.Same(t, backends[1], b.Pick(req, backends))
+	}
+
+	// 请求最多的后端永远不会被选中
+	backends = testBackends(t, 4)
+	backends[2].inflight.Store(10)
+	counts := make([]int, 4)
+	for _, i := range pickSequence(b, req, backends, 400) {
+		counts[i]++
+	}
+	assert.Zero(t, counts[2])
+	for _, i := range []int{0, 1, 3} {
+		assert.Greater(t, counts[i], 0)
+	}
+}
+
+// 测试一致性哈希：相同请求键发往同一后端，去掉一个后端只影响原本属于它的请求键
+func TestConsistentHashBalancer(t *testing.T) {
+	backends := testBackends(t, 4)
+	b := NewConsistentHashBalancer(0, nil)
+	requestFor := func(key string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		return req.WithContext(ContextWithHashKey(context.Background(), key))
+	}
+
+	assignment := make(map[string]*Backend)
+	used := make(map[*Backend]bool)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		assignment[key] = b.Pick(requestFor(key), backends)
+		used[assignment[key]] = true
+		assert.Same(t, assignment[key], b.Pick(requestFor(key), backends))
+	}
+	assert.Len(t, used, 4, "请求键分布到全部后端")
+
+	// 后端顺序不影响结果
+	reversed := []*Backend{backends[3], backends[2], backends[1], backends[0]}
+	for key, be := range assignment {
+		assert.Same(t, be, b.Pick(requestFor(key), reversed))
+	}
+
+	remaining := []*Backend{backends[0], backends[1], backends[3]}
+	for key, be := range assignment {
+		picked := b.Pick(requestFor(key), remaining)
+		if be != backends[2] {
+			assert.Same(t, be, picked, key)
+		} else {
+			assert.NotSame(t, backends[2], picked)
+		}
+	}
+
+	// 自定义请求键，请求键为空时轮询
+	b = NewConsistentHashBalancer(10, func(req *http.Request) string { return req.Header.Get("X-Cart-Id") })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, []int{0, 1, 2, 3}, pickSequence(b, req, backends, 4))
+	req.Header.Set("X-Cart-Id", "cart-1")
+	seq := pickSequence(b, req, backends, 4)
+	assert.Equal(t, []int{seq[0], seq[0], seq[0], seq[0]}, seq)
+}
@@ -0,0 +1,106 @@
+package net
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+)
+
+// BreakerTransport 熔断保护的HTTP传输
+// 熔断器打开时直接返回 breaker.ErrOpen，不再向下游发送请求；
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链
+type BreakerTransport struct {
+	delegate   http.RoundTripper
+	newBreaker func(host string) *breaker.Breaker
+	isFailure  func(resp *http.Response, err error) bool
+
+	mu       sync.Mutex
+	breakers map[string]*breaker.Breaker
+}
+
+// NewBreakerTransport 创建一个所有请求共用同一个熔断器的HTTP传输
+// 作为拦截器使用时 delegate 可以传 nil
+func NewBreakerTransport(delegate http.RoundTripper, b *breaker.Breaker) *BreakerTransport {
+	return NewHostBreakerTransport(delegate, func(string) *breaker.Breaker { return b })
+}
+
+// NewHostBreakerTransport 创建一个按目标主机分别熔断的HTTP传输
+// newBreaker 在某个主机第一次被请求时调用，返回该主机使用的熔断器
+func NewHostBreakerTransport(delegate http.RoundTripper, newBreaker func(host string) *breaker.Breaker) *BreakerTransport {
+	if delegate == nil {
+		delegate = http.DefaultTransport
+	}
+
+	return &BreakerTransport{
+		delegate:   delegate,
+		newBreaker: newBreaker,
+		isFailure: func(resp *http.Response, err error) bool {
+			// 默认失败条件：网络错误或5xx服务器错误
+			if err != nil {
+				return true
+			}
+			return resp.StatusCode >= 500
+		},
+		breakers: make(map[string]*breaker.Breaker),
+	}
+}
+
+// WithFailureCondition 设置判断请求是否失败的函数，返回传输本身以便链式调用
+func (bt *BreakerTransport) WithFailureCondition(fn func(resp *http.Response, err error) bool) *BreakerTransport {
+	if fn != nil {
+		bt.isFailure = fn
+	}
+	return bt
+}
+
+// Breaker 返回主机对应的熔断器
+func (bt *BreakerTransport) Breaker(host string) *breaker.Breaker {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	b, ok := bt.breakers[host]
+	if !ok {
+		b = bt.newBreaker(host)
+		bt.breakers[host] = b
+	}
+	return b
+}
+
+// RoundTrip 实现http.RoundTripper接口，支持熔断
+func (bt *BreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return bt.Intercept(req, bt.delegate)
+}
+
+// Intercept 实现Interceptor接口，熔断器放行时交给 next
+func (bt *BreakerTransport) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	done, err := bt.Breaker(req.URL.Host).Allow()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := next.RoundTrip(req)
+
+	// 上报给熔断器的结果，失败的响应也原样返回给调用方
+	var failure error
+	if bt.isFailure(resp, err) {
+		failure = err
+		if failure == nil {
+			failure = fmt.Errorf("%w: status code: %d", ErrInvalidResponse, resp.StatusCode)
+		}
+	}
+	done(failure)
+	return resp, err
+}
+
+// NewBreakerClient 创建一个按目标主机熔断的HTTP客户端
+func NewBreakerClient(opts ...breaker.Option) *http.Client {
+	return &http.Client{
+		Transport: NewHostBreakerTransport(http.DefaultTransport, func(host string) *breaker.Breaker {
+			return breaker.New(append([]breaker.Option{breaker.WithName(host)}, opts...)...)
+		}),
+	}
+}
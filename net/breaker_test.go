@@ -0,0 +1,81 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+)
+
+// 测试5xx响应触发熔断，熔断后不再请求下游，HTTPClient 不重试被拒绝的请求
+func TestBreakerTransport(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	b := breaker.New(breaker.WithCountWindow(2))
+	client := NewHTTPClient(WithCircuitBreaker(b), WithMaxRetries(3), WithRetryInterval(time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), srv.URL, nil)
+		require.NoError(t, err, "失败的响应原样返回")
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp.Body.Close()
+	}
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	_, err := client.Get(context.Background(), srv.URL, nil)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	assert.Equal(t, int32(2), hits.Load(), "熔断后不应请求下游，也不应重试")
+}
+
+// 测试按主机分别熔断
+func TestHostBreakerTransport(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	bt := NewHostBreakerTransport(nil, func(host string) *breaker.Breaker {
+		return breaker.New(breaker.WithName(host), breaker.WithCountWindow(1))
+	})
+	client := &http.Client{Transport: bt}
+
+	resp, err := client.Get(down.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get(down.URL)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+
+	resp, err = client.Get(up.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, breaker.StateClosed, bt.Breaker(resp.Request.URL.Host).State())
+
+	// 自定义失败条件：只有网络错误算失败
+	bt = NewBreakerTransport(nil, breaker.New(breaker.WithCountWindow(1))).
+		WithFailureCondition(func(resp *http.Response, err error) bool { return err != nil })
+	client = &http.Client{Transport: bt}
+	for i := 0; i < 2; i++ {
+		resp, err = client.Get(down.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err = client.Get("http://127.0.0.1:1")
+	require.Error(t, err)
+	_, err = client.Get(down.URL)
+	assert.True(t, errors.Is(err, breaker.ErrOpen))
+}
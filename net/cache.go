@@ -0,0 +1,457 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// HeaderCache 标记响应缓存状态的响应头，取值为 CacheHit、CacheStale、CacheRevalidated 或 CacheMiss
+const HeaderCache = "X-Cache"
+
+// 缓存状态
+const (
+	CacheHit         = "HIT"         // 缓存新鲜，直接返回
+	CacheStale       = "STALE"       // 缓存已过期但在 stale-while-revalidate 窗口内，返回缓存并在后台重新验证
+	CacheRevalidated = "REVALIDATED" // 条件请求返回 304，使用缓存的响应体
+	CacheMiss        = "MISS"        // 未使用缓存
+)
+
+// cacheableStatus 默认可以缓存的状态码
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// CacheTransport 遵循 RFC 9111 的私有HTTP缓存
+//   - 只缓存 GET 请求，按 Cache-Control 的 max-age（或 Expires）判断新鲜度，no-store 的响应不会保存
+//   - 过期的缓存在 stale-while-revalidate 窗口内直接返回，同时在后台重新验证
+//   - 带 ETag 或 Last-Modified 的缓存过期后发送条件请求，304 时继续使用缓存的响应体
+//   - POST、PUT 等不安全的请求成功后删除同一URL的缓存
+//   - 带认证头的请求只有在响应声明 public 或 s-maxage 时才缓存；存储为共享存储时不缓存 private 响应，
+//     并优先使用 s-maxage 判断新鲜度
+//
+// 存储出错时按未命中处理，不影响请求本身。
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链
+type CacheTransport struct {
+	delegate    http.RoundTripper
+	store       CacheStore
+	clock       clock.Clock
+	entryTTL    time.Duration
+	maxBodySize int64
+	shared      bool
+	authHeaders []string
+
+	revalidating sync.Map
+	wg           sync.WaitGroup
+}
+
+// CacheOption CacheTransport 的配置选项
+type CacheOption func(*CacheTransport)
+
+// WithCacheClock 设置判断新鲜度使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithCacheClock(clk clock.Clock) CacheOption {
+	return func(t *CacheTransport) {
+		t.clock = clock.OrReal(clk)
+	}
+}
+
+// WithCacheEntryTTL 设置带 ETag 或 Last-Modified 的响应过期后在存储中保留的时间，默认24小时
+// 保留期间可以用条件请求重新验证
+func WithCacheEntryTTL(d time.Duration) CacheOption {
+	return func(t *CacheTransport) {
+		if d > 0 {
+			t.entryTTL = d
+		}
+	}
+}
+
+// WithCacheMaxBodySize 设置可以缓存的响应体最大字节数，默认1MB，更大的响应不会被缓存
+func WithCacheMaxBodySize(n int64) CacheOption {
+	return func(t *CacheTransport) {
+		if n > 0 {
+			t.maxBodySize = n
+		}
+	}
+}
+
+// WithSharedCache 设置存储是否被多个客户端或实例共享，默认只有 RedisCacheStore 视为共享存储
+// 共享存储不保存 Cache-Control: private 的响应，新鲜度优先使用 s-maxage
+func WithSharedCache(shared bool) CacheOption {
+	return func(t *CacheTransport) {
+		t.shared = shared
+	}
+}
+
+// WithCacheAuthHeaders 追加表示请求带有用户凭据的请求头，默认只有 Authorization
+// 使用 APIKeyAuth 等自定义认证头时应把请求头名称加入这里，带这些头的请求的响应只在声明 public 或 s-maxage 时缓存
+func WithCacheAuthHeaders(headers ...string) CacheOption {
+	return func(t *CacheTransport) {
+		t.authHeaders = append(t.authHeaders, headers...)
+	}
+}
+
+// NewCacheTransport 创建缓存传输，store 为 nil 时使用最多1000个条目的 LRUCacheStore
+// 作为拦截器使用时 delegate 可以传 nil
+func NewCacheTransport(delegate http.RoundTripper, store CacheStore, opts ...CacheOption) *CacheTransport {
+	if delegate == nil {
+		delegate = http.DefaultTransport
+	}
+	if store == nil {
+		store = NewLRUCacheStore(1000)
+	}
+	_, shared := store.(*RedisCacheStore)
+	t := &CacheTransport{
+		delegate:    delegate,
+		store:       store,
+		clock:       clock.Real,
+		entryTTL:    24 * time.Hour,
+		maxBodySize: 1 << 20,
+		shared:      shared,
+		authHeaders: []string{"Authorization"},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// WithCache 为 HTTPClient 的 GET 请求启用缓存，缓存拦截器按选项的顺序加入拦截器链
+func WithCache(store CacheStore, opts ...CacheOption) HTTPClientOption {
+	return WithInterceptors(NewCacheTransport(nil, store, opts...))
+}
+
+// RoundTrip 实现http.RoundTripper接口
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.Intercept(req, t.delegate)
+}
+
+// Intercept 实现Interceptor接口，缓存未命中或需要重新验证时交给 next
+func (t *CacheTransport) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet {
+		resp, err := next.RoundTrip(req)
+		// 不安全的请求成功后，同一URL的缓存不再可信
+		if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < http.StatusBadRequest {
+			_ = t.store.Delete(req.Context(), key)
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok || req.Header.Get("Range") != "" {
+		return next.RoundTrip(req)
+	}
+
+	entry := t.load(req, key)
+	if entry == nil {
+		return t.fetch(req, next, key, nil)
+	}
+
+	// 请求带 no-cache 时必须重新验证
+	if _, noCache := reqCC["no-cache"]; !noCache {
+		age, freshness := entry.age(t.clock.Now()), entry.freshness(t.shared)
+		if age < freshness {
+			return entry.response(req, CacheHit, age), nil
+		}
+		if age < freshness+entry.staleWhileRevalidate() {
+			t.revalidate(req, next, key, entry)
+			return entry.response(req, CacheStale, age), nil
+		}
+	}
+	return t.fetch(req, next, key, entry)
+}
+
+// fetch 向下游发送请求，有缓存时带上条件请求头，并保存可以缓存的响应
+func (t *CacheTransport) fetch(req *http.Request, next http.RoundTripper, key string, entry *cacheEntry) (*http.Response, error) {
+	outReq := req
+	if entry != nil && entry.hasValidators() {
+		outReq = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			outReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := next.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && outReq != req && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		entry.update(resp.Header, t.clock.Now())
+		t.save(req.Context(), key, entry)
+		return entry.response(req, CacheRevalidated, 0), nil
+	}
+	return t.storeResponse(req, resp, key)
+}
+
+// storeResponse 保存可以缓存的响应，返回可以继续读取的响应
+func (t *CacheTransport) storeResponse(req *http.Request, resp *http.Response, key string) (*http.Response, error) {
+	resp.Header.Set(HeaderCache, CacheMiss)
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := respCC["no-store"]; ok || !cacheableStatus[resp.StatusCode] ||
+		resp.Header.Get("Vary") == "*" || resp.ContentLength > t.maxBodySize || !t.cacheAllowed(req, resp, respCC) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > t.maxBodySize {
+		// 响应体过大，不缓存，把已经读取的部分放回去
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		ResponseTime: t.clock.Now(),
+	}
+	entry.Header.Del(HeaderCache)
+	for _, name := range strings.Split(resp.Header.Get("Vary"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if entry.Vary == nil {
+				entry.Vary = make(map[string]string)
+			}
+			entry.Vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+		}
+	}
+	t.save(req.Context(), key, entry)
+	return resp, nil
+}
+
+// cacheAllowed 判断响应能否与其他请求共用：共享存储不保存 private 响应；
+// 带认证头的请求（包括下游拦截器添加的认证头）只有在响应声明 public 或 s-maxage 时才保存
+func (t *CacheTransport) cacheAllowed(req *http.Request, resp *http.Response, respCC map[string]string) bool {
+	if _, ok := respCC["private"]; ok && t.shared {
+		return false
+	}
+	if !t.hasAuth(req) && (resp.Request == nil || !t.hasAuth(resp.Request)) {
+		return true
+	}
+	_, public := respCC["public"]
+	_, sMaxAge := respCC["s-maxage"]
+	return public || sMaxAge
+}
+
+// hasAuth 请求是否带有认证头
+func (t *CacheTransport) hasAuth(req *http.Request) bool {
+	for _, name := range t.authHeaders {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// revalidate 在后台重新验证过期的缓存，同一个键同时只有一个重新验证
+func (t *CacheTransport) revalidate(req *http.Request, next http.RoundTripper, key string, entry *cacheEntry) {
+	if _, loaded := t.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	bgReq := req.Clone(context.WithoutCancel(req.Context()))
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer t.revalidating.Delete(key)
+		resp, err := t.fetch(bgReq, next, key, entry)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+}
+
+// load 读取缓存，Vary 指定的请求头不一致时视为未命中
+func (t *CacheTransport) load(req *http.Request, key string) *cacheEntry {
+	data, ok, err := t.store.Get(req.Context(), key)
+	if err != nil || !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if req.Header.Get(name) != value {
+			return nil
+		}
+	}
+	return &entry
+}
+
+// save 保存缓存，带校验器的条目在过期后继续保留 entryTTL 以便重新验证
+func (t *CacheTransport) save(ctx context.Context, key string, entry *cacheEntry) {
+	ttl := entry.freshness(t.shared) + entry.staleWhileRevalidate() - entry.age(entry.ResponseTime)
+	if entry.hasValidators() && ttl < t.entryTTL {
+		ttl = t.entryTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = t.store.Set(ctx, key, data, ttl)
+}
+
+// cacheEntry 缓存的响应
+type cacheEntry struct {
+	StatusCode   int               `json:"status_code"`
+	Status       string            `json:"status"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	ResponseTime time.Time         `json:"response_time"`  // 收到响应的时间
+	Vary         map[string]string `json:"vary,omitempty"` // Vary 指定的请求头在保存时的值
+}
+
+// freshness 返回响应的新鲜期，优先使用 max-age，其次使用 Expires；共享存储优先使用 s-maxage
+func (e *cacheEntry) freshness(shared bool) time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["s-maxage"]; ok && shared {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if v, ok := cc["max-age"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.ResponseTime
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+// staleWhileRevalidate 返回过期后仍然可以直接使用的时长，响应带 must-revalidate 时为 0
+func (e *cacheEntry) staleWhileRevalidate() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["must-revalidate"]; ok {
+		return 0
+	}
+	seconds, err := strconv.Atoi(cc["stale-while-revalidate"])
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// age 返回响应当前的年龄，包括响应中 Age 头的值
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	age := now.Sub(e.ResponseTime)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		age += time.Duration(seconds) * time.Second
+	}
+	return age
+}
+
+// hasValidators 是否带有 ETag 或 Last-Modified
+func (e *cacheEntry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// update 使用 304 响应的响应头更新缓存
+func (e *cacheEntry) update(header http.Header, now time.Time) {
+	for k, v := range header {
+		if k == "Content-Length" {
+			continue
+		}
+		e.Header[k] = v
+	}
+	if header.Get("Age") == "" {
+		e.Header.Del("Age")
+	}
+	e.ResponseTime = now
+}
+
+// response 使用缓存创建响应
+func (e *cacheEntry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set(HeaderCache, status)
+	header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// parseCacheControl 解析 Cache-Control 头，指令名转为小写，值去掉引号
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, line := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(line, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+// isSafeMethod 是否为安全的请求方法
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
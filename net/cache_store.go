@@ -0,0 +1,146 @@
+package net
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheStore CacheTransport 使用的缓存存储
+// 存储只负责按键保存字节，是否新鲜由 CacheTransport 判断，ttl 到期后存储可以删除条目
+type CacheStore interface {
+	// Get 返回键对应的值，不存在时返回 false
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set 保存键值，ttl 后过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除键
+	Delete(ctx context.Context, key string) error
+}
+
+// LRUCacheStore 内存中的 LRU 缓存存储，条目数超过上限时淘汰最久未使用的条目
+type LRUCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	clock      clock.Clock
+}
+
+// lruItem LRU 中的一个条目
+type lruItem struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// NewLRUCacheStore 创建最多保存 maxEntries 个条目的 LRU 缓存存储，maxEntries <= 0 时使用 1000
+func NewLRUCacheStore(maxEntries int) *LRUCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &LRUCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		clock:      clock.Real,
+	}
+}
+
+// Get 实现CacheStore接口
+func (s *LRUCacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := e.Value.(*lruItem)
+	if !s.clock.Now().Before(item.expireAt) {
+		s.removeElement(e)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(e)
+	return item.value, true, nil
+}
+
+// Set 实现CacheStore接口
+func (s *LRUCacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireAt := s.clock.Now().Add(ttl)
+	if e, ok := s.items[key]; ok {
+		item := e.Value.(*lruItem)
+		item.value, item.expireAt = value, expireAt
+		s.ll.MoveToFront(e)
+		return nil
+	}
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, value: value, expireAt: expireAt})
+	for s.ll.Len() > s.maxEntries {
+		s.removeElement(s.ll.Back())
+	}
+	return nil
+}
+
+// Delete 实现CacheStore接口
+func (s *LRUCacheStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.removeElement(e)
+	}
+	return nil
+}
+
+// Len 返回当前的条目数，包括已过期但尚未被访问到的条目
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// removeElement 删除条目，调用方需持有锁
+func (s *LRUCacheStore) removeElement(e *list.Element) {
+	s.ll.Remove(e)
+	delete(s.items, e.Value.(*lruItem).key)
+}
+
+// RedisCacheStore 基于 Redis 的缓存存储，适合多实例共享缓存
+type RedisCacheStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisCacheStore 创建 Redis 缓存存储，keyPrefix 为空时使用 "httpcache:"
+func NewRedisCacheStore(client *redis.Client, keyPrefix string) *RedisCacheStore {
+	if keyPrefix == "" {
+		keyPrefix = "httpcache:"
+	}
+	return &RedisCacheStore{client: client, keyPrefix: keyPrefix}
+}
+
+// Get 实现CacheStore接口
+func (s *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set 实现CacheStore接口
+func (s *RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.keyPrefix+key, value, ttl).Err()
+}
+
+// Delete 实现CacheStore接口
+func (s *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.keyPrefix+key).Err()
+}
@@ -0,0 +1,310 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheGet 发送 GET 请求，返回缓存状态和响应体
+func cacheGet(t *testing.T, rt http.RoundTripper, url string, header ...string) (string, string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.Header.Get(HeaderCache), string(body)
+}
+
+// 测试 max-age 内命中缓存，过期后用 ETag 重新验证
+func TestCacheTransport_MaxAgeAndETag(t *testing.T) {
+	var hits, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.Header().Set("X-Version", "checked")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, "catalog-v1")
+	}))
+	defer srv.Close()
+
+	clk := clock.NewFakeClock(time.Now())
+	rt := NewCacheTransport(nil, nil, WithCacheClock(clk))
+
+	status, body := cacheGet(t, rt, srv.URL+"/catalog")
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, "catalog-v1", body)
+
+	clk.Advance(30 * time.Second)
+	status, body = cacheGet(t, rt, srv.URL+"/catalog")
+	assert.Equal(t, CacheHit, status)
+	assert.Equal(t, "catalog-v1", body)
+	assert.EqualValues(t, 1, hits.Load())
+
+	clk.Advance(31 * time.Second)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/catalog", nil)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	body2, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, CacheRevalidated, resp.Header.Get(HeaderCache))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "catalog-v1", string(body2))
+	assert.Equal(t, "checked", resp.Header.Get("X-Version"), "304 的响应头应更新到缓存")
+	assert.EqualValues(t, 1, notModified.Load())
+
+	// 重新验证后重新计算新鲜期
+	status, _ = cacheGet(t, rt, srv.URL+"/catalog")
+	assert.Equal(t, CacheHit, status)
+
+	// 请求带 no-cache 时总是重新验证
+	status, _ = cacheGet(t, rt, srv.URL+"/catalog", "Cache-Control", "no-cache")
+	assert.Equal(t, CacheRevalidated, status)
+	assert.EqualValues(t, 3, hits.Load())
+}
+
+// 测试 Last-Modified 条件请求和修改后的新响应
+func TestCacheTransport_LastModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	var version atomic.Int32
+	version.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version.Load() == 1 && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = fmt.Fprintf(w, "v%d", version.Load())
+	}))
+	defer srv.Close()
+
+	rt := NewCacheTransport(nil, nil)
+	status, body := cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, "v1", body)
+
+	// 没有 max-age，每次都重新验证
+	status, body = cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheRevalidated, status)
+	assert.Equal(t, "v1", body)
+
+	version.Store(2)
+	status, body = cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, "v2", body)
+}
+
+// 测试 stale-while-revalidate 窗口内返回旧缓存并在后台刷新
+func TestCacheTransport_StaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=30")
+		_, _ = fmt.Fprintf(w, "v%d", version.Add(1))
+	}))
+	defer srv.Close()
+
+	clk := clock.NewFakeClock(time.Now())
+	rt := NewCacheTransport(nil, nil, WithCacheClock(clk))
+	_, body := cacheGet(t, rt, srv.URL)
+	assert.Equal(t, "v1", body)
+
+	clk.Advance(20 * time.Second)
+	status, body := cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheStale, status)
+	assert.Equal(t, "v1", body)
+	rt.wg.Wait()
+
+	status, body = cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheHit, status)
+	assert.Equal(t, "v2", body)
+
+	// 超过 stale-while-revalidate 窗口后同步请求
+	clk.Advance(41 * time.Second)
+	status, body = cacheGet(t, rt, srv.URL)
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, "v3", body)
+}
+
+// 测试不缓存的情况：no-store、非 GET、Vary 不一致、过大的响应体，以及不安全的请求使缓存失效
+func TestCacheTransport_NotCached(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/lang":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/large":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Content-Type", "text/plain")
+			w.(http.Flusher).Flush() // 不设置 Content-Length
+			_, _ = io.WriteString(w, strings.Repeat("x", 100))
+			return
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = io.WriteString(w, r.Method+" "+r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+
+	rt := NewCacheTransport(nil, NewLRUCacheStore(10), WithCacheMaxBodySize(50))
+
+	cacheGet(t, rt, srv.URL+"/private")
+	status, _ := cacheGet(t, rt, srv.URL+"/private")
+	assert.Equal(t, CacheMiss, status)
+
+	_, body := cacheGet(t, rt, srv.URL+"/lang", "Accept-Language", "zh")
+	assert.Equal(t, "GET zh", body)
+	status, body = cacheGet(t, rt, srv.URL+"/lang", "Accept-Language", "zh")
+	assert.Equal(t, CacheHit, status)
+	assert.Equal(t, "GET zh", body)
+	status, body = cacheGet(t, rt, srv.URL+"/lang", "Accept-Language", "en")
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, "GET en", body)
+
+	_, body = cacheGet(t, rt, srv.URL+"/large")
+	assert.Len(t, body, 100, "超过上限的响应体应完整返回")
+	status, _ = cacheGet(t, rt, srv.URL+"/large")
+	assert.Equal(t, CacheMiss, status)
+
+	cacheGet(t, rt, srv.URL+"/products")
+	status, _ = cacheGet(t, rt, srv.URL+"/products")
+	assert.Equal(t, CacheHit, status)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/products", strings.NewReader("{}"))
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	status, _ = cacheGet(t, rt, srv.URL+"/products")
+	assert.Equal(t, CacheMiss, status, "PUT 成功后应删除缓存")
+
+	status, _ = cacheGet(t, rt, srv.URL+"/products", "Cache-Control", "no-store")
+	assert.Empty(t, status, "请求带 no-store 时直接交给下游")
+	assert.EqualValues(t, 10, hits.Load())
+}
+
+// 测试带认证头的请求只缓存声明 public 或 s-maxage 的响应，共享存储不缓存 private 响应
+func TestCacheTransport_AuthAndShared(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/shared":
+			w.Header().Set("Cache-Control", "max-age=60, s-maxage=10")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = io.WriteString(w, r.Header.Get("Authorization")+r.Header.Get("X-Api-Key"))
+	}))
+	defer srv.Close()
+
+	clk := clock.NewFakeClock(time.Now())
+	rt := NewCacheTransport(nil, nil, WithCacheClock(clk), WithCacheAuthHeaders("X-Api-Key"))
+	for _, header := range []string{"Authorization", "X-Api-Key"} {
+		cacheGet(t, rt, srv.URL+"/orders", header, "user-1")
+		status, body := cacheGet(t, rt, srv.URL+"/orders", header, "user-2")
+		assert.Equal(t, CacheMiss, status, header)
+		assert.Equal(t, "user-2", body, "不应把一个用户的响应返回给另一个用户")
+	}
+	for _, path := range []string{"/public", "/shared"} {
+		cacheGet(t, rt, srv.URL+path, "Authorization", "user-1")
+		status, _ := cacheGet(t, rt, srv.URL+path, "Authorization", "user-2")
+		assert.Equal(t, CacheHit, status, path)
+	}
+
+	// 下游拦截器添加的认证头同样生效
+	client := NewHTTPClient(WithCache(NewLRUCacheStore(10)),
+		WithInterceptors(BearerAuth(func(context.Context) (string, error) { return "token", nil })))
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(context.Background(), srv.URL+"/orders", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, CacheMiss, resp.Header.Get(HeaderCache))
+	}
+
+	// 私有存储缓存 private 响应，共享存储不缓存，并优先使用 s-maxage
+	cacheGet(t, rt, srv.URL+"/private")
+	status, _ := cacheGet(t, rt, srv.URL+"/private")
+	assert.Equal(t, CacheHit, status)
+	shared := NewCacheTransport(nil, nil, WithCacheClock(clk), WithSharedCache(true))
+	cacheGet(t, shared, srv.URL+"/private")
+	status, _ = cacheGet(t, shared, srv.URL+"/private")
+	assert.Equal(t, CacheMiss, status)
+	cacheGet(t, shared, srv.URL+"/shared")
+	clk.Advance(11 * time.Second)
+	status, _ = cacheGet(t, shared, srv.URL+"/shared")
+	assert.Equal(t, CacheMiss, status)
+	status, _ = cacheGet(t, rt, srv.URL+"/shared")
+	assert.Equal(t, CacheHit, status, "私有存储使用 max-age")
+
+	assert.True(t, NewCacheTransport(nil, NewRedisCacheStore(nil, "")).shared)
+}
+
+// 测试 HTTPClient 启用缓存
+func TestHTTPClient_WithCache(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_, _ = io.WriteString(w, `{"id":"p-1","name":"跑鞋","price":299}`)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithCache(NewLRUCacheStore(100)))
+	for i := 0; i < 3; i++ {
+		p, err := Get[product](client, "/products/{id}").PathParam("id", "p-1").Do(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "跑鞋", p.Name)
+	}
+	assert.EqualValues(t, 1, hits.Load())
+}
+
+// 测试 LRU 存储的淘汰和过期
+func TestLRUCacheStore(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFakeClock(time.Now())
+	s := NewLRUCacheStore(2)
+	s.clock = clk
+
+	require.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, s.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := s.Get(ctx, "a")
+	assert.True(t, ok)
+	require.NoError(t, s.Set(ctx, "c", []byte("3"), time.Second))
+
+	_, ok, _ = s.Get(ctx, "b")
+	assert.False(t, ok, "最久未使用的条目应被淘汰")
+	v, ok, _ := s.Get(ctx, "c")
+	assert.True(t, ok)
+	assert.Equal(t, "3", string(v))
+
+	clk.Advance(time.Second)
+	_, ok, _ = s.Get(ctx, "c")
+	assert.False(t, ok)
+	assert.Equal(t, 1, s.Len())
+
+	require.NoError(t, s.Delete(ctx, "a"))
+	assert.Equal(t, 0, s.Len())
+}
@@ -0,0 +1,87 @@
+package net
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Codec 请求体和响应体的编解码器
+type Codec interface {
+	// ContentType 返回编码后内容的 MIME 类型，用于 Content-Type 和 Accept 头
+	ContentType() string
+	// Marshal 编码 v
+	Marshal(v any) ([]byte, error)
+	// Unmarshal 把 data 解码到 v
+	Unmarshal(data []byte, v any) error
+}
+
+// 内置的编解码器
+var (
+	JSONCodec    Codec = jsonCodec{}
+	XMLCodec     Codec = xmlCodec{}
+	MsgpackCodec Codec = newMsgpackCodec()
+)
+
+// codecs 按 MIME 类型选择响应的解码器
+var codecs = map[string]Codec{
+	"application/json":      JSONCodec,
+	"application/xml":       XMLCodec,
+	"text/xml":              XMLCodec,
+	"application/msgpack":   MsgpackCodec,
+	"application/x-msgpack": MsgpackCodec,
+}
+
+// codecFor 根据响应的 Content-Type 选择解码器，无法识别时使用 fallback
+func codecFor(contentType string, fallback Codec) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fallback
+	}
+	if c, ok := codecs[mediaType]; ok {
+		return c
+	}
+	return fallback
+}
+
+// jsonCodec JSON编解码器
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                { return "application/json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// xmlCodec XML编解码器
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string                { return "application/xml" }
+func (xmlCodec) Marshal(v any) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+// msgpackCodec MessagePack编解码器，结构体字段优先使用 codec 标签，其次使用 json 标签
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return msgpackCodec{handle: h}
+}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (c msgpackCodec) Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, c.handle).Encode(v)
+	return data, err
+}
+
+func (c msgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
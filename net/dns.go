@@ -0,0 +1,177 @@
+package net
+
+import (
+	"context"
+	"errors"
+	stdnet "net"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// ErrNoAddresses 域名没有解析到任何地址
+var ErrNoAddresses = errors.New("ggu: 域名没有解析到任何地址")
+
+// DNSCache 进程内的DNS缓存
+//   - 解析结果缓存 ttl 时间，同一域名的并发解析只会执行一次
+//   - 解析失败时如果有过期的缓存，继续使用过期的结果，避免DNS故障影响已知的下游
+//   - DialContext 在解析到的多个地址之间轮询，某个地址连接失败时尝试下一个
+type DNSCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+	clock  clock.Clock
+	group  syncx.Group[string, []string]
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+// dnsEntry 一个域名的解析结果
+type dnsEntry struct {
+	addrs    []string
+	expireAt time.Time
+	next     int // 下一次拨号的起始地址
+}
+
+// DNSCacheOption DNS缓存配置选项
+type DNSCacheOption func(*DNSCache)
+
+// WithDNSLookup 设置解析函数，默认使用 net.DefaultResolver.LookupHost
+func WithDNSLookup(fn func(ctx context.Context, host string) ([]string, error)) DNSCacheOption {
+	return func(c *DNSCache) {
+		if fn != nil {
+			c.lookup = fn
+		}
+	}
+}
+
+// WithDNSClock 设置判断缓存过期使用的时钟，测试中可以传入 clock.FakeClock
+func WithDNSClock(clk clock.Clock) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.clock = clock.OrReal(clk)
+	}
+}
+
+// NewDNSCache 创建DNS缓存，ttl 为解析结果的缓存时间，不大于0时使用1分钟
+func NewDNSCache(ttl time.Duration, opts ...DNSCacheOption) *DNSCache {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	c := &DNSCache{
+		ttl:     ttl,
+		lookup:  stdnet.DefaultResolver.LookupHost,
+		clock:   clock.Real,
+		entries: make(map[string]*dnsEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LookupHost 返回域名解析到的地址，host 本身是IP时直接返回
+func (c *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if stdnet.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	c.mu.Lock()
+	var stale []string
+	if e, ok := c.entries[host]; ok {
+		if c.clock.Now().Before(e.expireAt) {
+			c.mu.Unlock()
+			return e.addrs, nil
+		}
+		stale = e.addrs
+	}
+	c.mu.Unlock()
+
+	addrs, err, _ := c.group.DoContext(ctx, host, func() ([]string, error) {
+		// 解析结果由所有等待者共享，不受某一个调用者取消的影响
+		addrs, err := c.lookup(context.WithoutCancel(ctx), host)
+		if err == nil && len(addrs) == 0 {
+			err = ErrNoAddresses
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if e, ok := c.entries[host]; ok {
+			e.addrs, e.expireAt = addrs, c.clock.Now().Add(c.ttl)
+		} else {
+			c.entries[host] = &dnsEntry{addrs: addrs, expireAt: c.clock.Now().Add(c.ttl)}
+		}
+		c.mu.Unlock()
+		return addrs, nil
+	})
+	if err != nil {
+		if stale != nil {
+			// 使用过期的结果
+			return stale, nil
+		}
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// DialContext 返回使用缓存解析地址的拨号函数，可以设置为 http.Transport.DialContext
+// dialer 为 nil 时使用默认的 net.Dialer
+func (c *DNSCache) DialContext(dialer *stdnet.Dialer) func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
+	if dialer == nil {
+		dialer = &stdnet.Dialer{}
+	}
+	return func(ctx context.Context, network, addr string) (stdnet.Conn, error) {
+		host, port, err := stdnet.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := c.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		start := c.nextIndex(host, len(addrs))
+		var errs []error
+		for i := range addrs {
+			ip := addrs[(start+i)%len(addrs)]
+			conn, err := dialer.DialContext(ctx, network, stdnet.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// nextIndex 返回本次拨号的起始地址并轮转
+func (c *DNSCache) nextIndex(host string, n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[host]
+	if !ok {
+		return 0
+	}
+	i := e.next % n
+	e.next = i + 1
+	return i
+}
+
+// Remove 删除域名的缓存，下一次拨号时重新解析
+func (c *DNSCache) Remove(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, host)
+}
+
+// Clear 清空全部缓存
+func (c *DNSCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*dnsEntry)
+}
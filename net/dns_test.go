@@ -0,0 +1,129 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试解析结果按 TTL 缓存，过期后重新解析，解析失败时使用过期的结果
+func TestDNSCache_TTL(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	var lookups atomic.Int32
+	var fail atomic.Bool
+	cache := NewDNSCache(time.Minute, WithDNSClock(clk), WithDNSLookup(func(ctx context.Context, host string) ([]string, error) {
+		n := lookups.Add(1)
+		if fail.Load() {
+			return nil, errors.New("dns timeout")
+		}
+		if n == 1 {
+			return []string{"10.0.0.1"}, nil
+		}
+		return []string{"10.0.0.2"}, nil
+	}))
+	ctx := context.Background()
+
+	addrs, err := cache.LookupHost(ctx, "api.partner.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	addrs, _ = cache.LookupHost(ctx, "api.partner.com")
+	assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	assert.Equal(t, int32(1), lookups.Load())
+
+	clk.Advance(time.Minute)
+	addrs, _ = cache.LookupHost(ctx, "api.partner.com")
+	assert.Equal(t, []string{"10.0.0.2"}, addrs)
+	assert.Equal(t, int32(2), lookups.Load())
+
+	fail.Store(true)
+	clk.Advance(time.Minute)
+	addrs, err = cache.LookupHost(ctx, "api.partner.com")
+	require.NoError(t, err, "解析失败时使用过期的结果")
+	assert.Equal(t, []string{"10.0.0.2"}, addrs)
+
+	_, err = cache.LookupHost(ctx, "unknown.partner.com")
+	assert.Error(t, err)
+
+	cache.Remove("api.partner.com")
+	_, err = cache.LookupHost(ctx, "api.partner.com")
+	assert.Error(t, err)
+
+	// IP 不需要解析
+	addrs, err = cache.LookupHost(ctx, "192.168.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.1"}, addrs)
+}
+
+// 测试同一域名的并发解析只执行一次
+func TestDNSCache_Singleflight(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	cache := NewDNSCache(time.Minute, WithDNSLookup(func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		<-release
+		return []string{"10.0.0.1"}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := cache.LookupHost(context.Background(), "api.partner.com")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"10.0.0.1"}, addrs)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), lookups.Load())
+
+	_, err := NewDNSCache(time.Minute, WithDNSLookup(func(context.Context, string) ([]string, error) {
+		return nil, nil
+	})).LookupHost(context.Background(), "empty.partner.com")
+	assert.ErrorIs(t, err, ErrNoAddresses)
+}
+
+// 测试拨号时在解析到的地址之间轮询，连接失败时尝试下一个地址
+func TestDNSCache_DialContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	_, port, _ := stdnet.SplitHostPort(srv.Listener.Addr().String())
+
+	// 127.0.0.2 上没有监听，连接被拒绝
+	cache := NewDNSCache(time.Minute, WithDNSLookup(func(context.Context, string) ([]string, error) {
+		return []string{"127.0.0.2", "127.0.0.1"}, nil
+	}))
+	client := &http.Client{Transport: NewTransport(
+		WithDNSCache(cache),
+		WithDialer(&stdnet.Dialer{Timeout: time.Second}),
+		WithProxy(nil),
+	)}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://partner.test:" + port + "/")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+		client.CloseIdleConnections()
+	}
+
+	// 每次拨号的起始地址轮转
+	assert.Equal(t, 1, cache.nextIndex("partner.test", 2))
+	assert.Equal(t, 0, cache.nextIndex("partner.test", 2))
+	assert.Equal(t, 1, cache.nextIndex("partner.test", 2))
+}
@@ -0,0 +1,293 @@
+package net
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadConfig 下载配置
+type downloadConfig struct {
+	progress   ProgressFunc
+	newHash    func() hash.Hash
+	checksum   string
+	maxResumes int
+	headers    map[string]string
+}
+
+// DownloadOption 下载配置选项
+type DownloadOption func(*downloadConfig)
+
+// WithDownloadProgress 设置下载进度回调，断点续传时已下载的部分计入 transferred
+func WithDownloadProgress(fn ProgressFunc) DownloadOption {
+	return func(c *downloadConfig) {
+		c.progress = fn
+	}
+}
+
+// WithChecksum 下载完成后使用 newHash 计算文件的摘要，与十六进制的 expected 比较
+// 例如 WithChecksum(sha256.New, "9f86d081...")
+func WithChecksum(newHash func() hash.Hash, expected string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.newHash = newHash
+		c.checksum = strings.ToLower(expected)
+	}
+}
+
+// WithMaxResumes 设置连接中断后最多续传的次数，默认3次
+func WithMaxResumes(n int) DownloadOption {
+	return func(c *downloadConfig) {
+		if n >= 0 {
+			c.maxResumes = n
+		}
+	}
+}
+
+// WithDownloadHeader 设置下载请求的请求头
+func WithDownloadHeader(key, value string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.headers[key] = value
+	}
+}
+
+// Download 把 path 对应的资源下载到文件 filename，支持断点续传
+//   - 内容先写入 filename + ".part"，下载完成并通过校验后再重命名为 filename
+//   - .part 文件已存在时（例如上次下载中断）使用 Range 请求从已下载的位置继续，
+//     下载过程中连接中断时同样按重试间隔续传；服务器不支持 Range 或资源已变化时从头下载
+//   - 资源的 ETag 或 Last-Modified 保存在 filename + ".part.validator" 中，续传时通过 If-Range 确认资源没有变化，
+//     不知道校验值（例如服务器没有返回或文件丢失）时不续传，从头下载
+//   - 设置了 WithChecksum 时校验失败返回 ErrChecksumMismatch 并删除 .part 文件
+//
+// 下载不受 WithTimeout 设置的超时限制，应通过 ctx 控制下载时间
+func (c *HTTPClient) Download(ctx context.Context, path, filename string, opts ...DownloadOption) error {
+	cfg := &downloadConfig{maxResumes: 3, headers: make(map[string]string)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	fullURL, err := c.buildURL(path)
+	if err != nil {
+		return err
+	}
+
+	partName := filename + ".part"
+	validatorName := partName + ".validator"
+	f, err := os.OpenFile(partName, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	// 下载大文件时不使用客户端的整体超时和单次尝试的超时
+	client := *c.client
+	client.Timeout = 0
+	ctx = withoutAttemptTimeout(ctx)
+
+	// validator 是完整响应的 ETag 或 Last-Modified，续传时通过 If-Range 确认资源没有变化
+	validator, err := readValidator(validatorName)
+	if err != nil {
+		return err
+	}
+	for resumes := 0; ; resumes++ {
+		n, done, err := c.downloadOnce(ctx, &client, fullURL, f, offset, &validator, validatorName, cfg)
+		offset = n
+		if err == nil && done {
+			break
+		}
+		var httpErr *HTTPError
+		if ctx.Err() != nil || errors.As(err, &httpErr) || resumes >= cfg.maxResumes {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		timer := time.NewTimer(c.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if cfg.newHash != nil {
+		if err = verifyChecksum(partName, cfg.newHash(), cfg.checksum); err != nil {
+			_ = os.Remove(validatorName)
+			return err
+		}
+	}
+	if err = os.Rename(partName, filename); err != nil {
+		return err
+	}
+	return writeValidator(validatorName, "")
+}
+
+// downloadOnce 从 offset 开始下载一次，返回新的偏移量以及是否已经下载完成
+func (c *HTTPClient) downloadOnce(ctx context.Context, client *http.Client, fullURL string, f *os.File,
+	offset int64, validator *string, validatorName string, cfg *downloadConfig) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return offset, false, err
+	}
+	for k, v := range c.defaultHeaders {
+		req.Header.Set(k, v)
+	}
+	for k, v := range cfg.headers {
+		req.Header.Set(k, v)
+	}
+	// 没有校验值时无法确认已下载的部分仍然有效，不发送 Range，服务器返回完整内容后从头写入
+	if offset > 0 && *validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", *validator)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return offset, false, err
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// 服务器返回完整内容，从头写入
+		offset = 0
+		if err = f.Truncate(0); err != nil {
+			return offset, false, err
+		}
+		total = resp.ContentLength
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return offset, false, fmt.Errorf("%w: Content-Range 不匹配: %s", ErrInvalidResponse, resp.Header.Get("Content-Range"))
+		}
+		total = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 请求的起点等于文件大小，说明上次已经下载完成
+		if _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			return offset, true, nil
+		}
+		return offset, false, newHTTPError(resp)
+	default:
+		return offset, false, newHTTPError(resp)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		*validator = resp.Header.Get("ETag")
+		if *validator == "" || strings.HasPrefix(*validator, "W/") {
+			// 弱 ETag 不能用于 If-Range
+			*validator = resp.Header.Get("Last-Modified")
+		}
+		// 先保存校验值再写入内容，进程在下载中途退出时下次仍然可以续传
+		if err = writeValidator(validatorName, *validator); err != nil {
+			return offset, false, err
+		}
+	}
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return offset, false, err
+	}
+	var body io.Reader = resp.Body
+	if cfg.progress != nil {
+		body = &progressReader{ReadCloser: resp.Body, fn: cfg.progress, transferred: offset, total: total}
+	}
+	n, err := io.Copy(f, body)
+	offset += n
+	if err != nil {
+		return offset, false, err
+	}
+	if total >= 0 && offset < total {
+		return offset, false, io.ErrUnexpectedEOF
+	}
+	return offset, true, nil
+}
+
+// readValidator 读取保存的校验值，文件不存在时返回空字符串
+func readValidator(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// writeValidator 保存校验值，v 为空时删除校验值文件
+func writeValidator(name, v string) error {
+	if v == "" {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(name, []byte(v), 0o644)
+}
+
+// parseContentRange 解析 "bytes start-end/size" 或 "bytes */size"，size 未知时返回 -1
+func parseContentRange(v string) (start, size int64, ok bool) {
+	v, found := strings.CutPrefix(v, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, sizeStr, found := strings.Cut(v, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size = -1
+	if sizeStr != "*" {
+		var err error
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return 0, size, true
+	}
+	startStr, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+// verifyChecksum 计算文件的摘要并与 expected 比较，不一致时删除文件
+func verifyChecksum(name string, h hash.Hash, expected string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != expected {
+		_ = os.Remove(name)
+		return fmt.Errorf("%w: 期望 %s，实际 %s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
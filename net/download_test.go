@@ -0,0 +1,192 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fileServer 使用 http.ServeContent 提供文件，前 interrupts 次请求只写一半内容就断开连接
+type fileServer struct {
+	mu         sync.Mutex
+	content    []byte
+	interrupts int
+	ranges     []string
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data := s.content
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	interrupt := s.interrupts > 0
+	s.interrupts--
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etagOf(data))
+	if !interrupt {
+		http.ServeContent(w, r, "catalog.csv", time.Time{}, bytes.NewReader(data))
+		return
+	}
+
+	var start int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err == nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		data = data[start:]
+	} else {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	}
+	_, _ = w.Write(data[:len(data)/2])
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func (s *fileServer) setContent(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = data
+}
+
+func (s *fileServer) requestedRanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+// etagOf 返回 fileServer 为内容设置的 ETag
+func etagOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%x"`, sum[:4])
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 测试连接中断后续传，进度回调包括已下载的部分，并校验 SHA-256
+func TestHTTPClient_DownloadResume(t *testing.T) {
+	data := bytes.Repeat([]byte("sku,name,price\n"), 4000)
+	fs := &fileServer{content: data, interrupts: 2}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	filename := filepath.Join(t.TempDir(), "catalog.csv")
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithRetryInterval(time.Millisecond))
+
+	var last, total atomic.Int64
+	err := client.Download(context.Background(), "/catalog.csv", filename,
+		WithDownloadProgress(func(transferred, t int64) {
+			last.Store(transferred)
+			total.Store(t)
+		}),
+		WithChecksum(sha256.New, sha256Hex(data)),
+	)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.NoFileExists(t, filename+".part")
+	assert.Equal(t, int64(len(data)), last.Load())
+	assert.Equal(t, int64(len(data)), total.Load())
+
+	half := len(data) / 2
+	quarter := half + (len(data)-half)/2
+	assert.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", half), fmt.Sprintf("bytes=%d-", quarter)}, fs.requestedRanges())
+}
+
+// 测试从已有的 .part 文件继续下载，没有校验值或资源变化时从头下载，已完成时不再下载内容
+func TestHTTPClient_DownloadPartFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	fs := &fileServer{content: data}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	dir := t.TempDir()
+
+	// 上次下载了一部分
+	filename := filepath.Join(dir, "a.bin")
+	require.NoError(t, os.WriteFile(filename+".part", data[:3000], 0o644))
+	require.NoError(t, os.WriteFile(filename+".part.validator", []byte(etagOf(data)), 0o644))
+	require.NoError(t, client.Download(context.Background(), "/a.bin", filename))
+	got, _ := os.ReadFile(filename)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=3000-"}, fs.requestedRanges())
+	assert.NoFileExists(t, filename+".part.validator", "下载完成后删除校验值")
+
+	// 不知道校验值时无法确认已下载的部分有效，从头下载
+	filename = filepath.Join(dir, "stale.bin")
+	require.NoError(t, os.WriteFile(filename+".part", []byte("stale content"), 0o644))
+	require.NoError(t, client.Download(context.Background(), "/stale.bin", filename))
+	got, _ = os.ReadFile(filename)
+	assert.Equal(t, data, got)
+	assert.Equal(t, "", fs.requestedRanges()[1])
+
+	// 上次已经下载完成但没有重命名
+	filename = filepath.Join(dir, "b.bin")
+	require.NoError(t, os.WriteFile(filename+".part", data, 0o644))
+	require.NoError(t, os.WriteFile(filename+".part.validator", []byte(etagOf(data)), 0o644))
+	require.NoError(t, client.Download(context.Background(), "/b.bin", filename, WithChecksum(sha256.New, sha256Hex(data))))
+	got, _ = os.ReadFile(filename)
+	assert.Equal(t, data, got)
+
+	// 续传过程中资源发生变化，If-Range 不匹配时服务器返回完整内容
+	changed := bytes.Repeat([]byte("abcdefghij"), 800)
+	fs.setContent(data)
+	fs.interrupts = 1
+	filename = filepath.Join(dir, "c.bin")
+	var once sync.Once
+	err := client.Download(context.Background(), "/c.bin", filename, WithDownloadProgress(func(transferred, total int64) {
+		once.Do(func() { fs.setContent(changed) })
+	}))
+	require.NoError(t, err)
+	got, _ = os.ReadFile(filename)
+	assert.Equal(t, changed, got)
+}
+
+// 测试校验失败、HTTP错误和续传次数耗尽
+func TestHTTPClient_DownloadErrors(t *testing.T) {
+	data := []byte("product image")
+	fs := &fileServer{content: data}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithRetryInterval(time.Millisecond))
+	dir := t.TempDir()
+
+	filename := filepath.Join(dir, "a.png")
+	err := client.Download(context.Background(), "/a.png", filename, WithChecksum(sha256.New, sha256Hex([]byte("other"))))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.NoFileExists(t, filename)
+	assert.NoFileExists(t, filename+".part")
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	err = NewHTTPClient(WithBaseURL(notFound.URL)).Download(context.Background(), "/missing", filename)
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+
+	fs.interrupts = 5
+	err = client.Download(context.Background(), "/a.png", filename, WithMaxResumes(1))
+	assert.Error(t, err)
+	assert.Len(t, fs.requestedRanges(), 3, "第一次请求加一次续传")
+	assert.FileExists(t, filename+".part", "中断的下载应保留 .part 文件以便下次续传")
+	validator, err := os.ReadFile(filename + ".part.validator")
+	require.NoError(t, err)
+	assert.Equal(t, etagOf(data), string(validator), "校验值与 .part 文件一起保留")
+}
@@ -0,0 +1,87 @@
+package net
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/Humphrey-He/go-generic-utils/retry"
+)
+
+// maxHedgeDrain 关闭落败响应前最多读取的字节数，读完剩余的少量内容以便复用连接
+const maxHedgeDrain = 64 << 10
+
+// HedgeTransport 实现对冲请求的HTTP传输
+// 对幂等的读请求（GET、HEAD 且没有请求体），超过对冲延迟仍未收到响应时发起备份请求，
+// 使用第一个收到的响应，其余请求被取消，落败请求收到的响应体被读完并关闭；其他请求直接交给下层传输。
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链
+type HedgeTransport struct {
+	delegate  http.RoundTripper
+	delay     retry.HedgeDelay
+	maxHedges int
+}
+
+// NewHedgeTransport 创建一个支持对冲请求的HTTP传输
+// delay: 对冲延迟，可以使用 retry.FixedDelay 或 retry.NewPercentileDelay
+// maxHedges: 每个请求最多发起的备份请求数
+// 作为拦截器使用时 delegate 可以传 nil
+func NewHedgeTransport(delegate http.RoundTripper, delay retry.HedgeDelay, maxHedges int) *HedgeTransport {
+	if delegate == nil {
+		delegate = http.DefaultTransport
+	}
+
+	return &HedgeTransport{
+		delegate:  delegate,
+		delay:     delay,
+		maxHedges: maxHedges,
+	}
+}
+
+// RoundTrip 实现http.RoundTripper接口，支持对冲请求
+// 收到响应即视为成功，包括5xx响应；只有网络错误会立即触发下一次备份请求
+func (ht *HedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return ht.Intercept(req, ht.delegate)
+}
+
+// Intercept 实现Interceptor接口，对幂等的读请求并发调用 next
+func (ht *HedgeTransport) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if !isHedgeable(req) {
+		return next.RoundTrip(req)
+	}
+
+	// 多个请求几乎同时返回或者请求 ctx 先结束时，胜出以外的响应都交给 discardResponse
+	resp, cancel, err := retry.HedgeWithCancel(req.Context(), ht.delay, func(ctx context.Context) (*http.Response, error) {
+		return next.RoundTrip(req.Clone(ctx))
+	}, retry.WithMaxHedges(ht.maxHedges), retry.WithHedgeDiscard(discardResponse))
+	if err != nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+	// 胜出请求的 ctx 在关闭响应体时释放
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// discardResponse 读完并关闭落败请求的响应体
+func discardResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxHedgeDrain))
+	resp.Body.Close()
+}
+
+// isHedgeable 判断请求是否可以安全地重复发送
+func isHedgeable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// NewHedgeClient 创建一个支持对冲请求的HTTP客户端
+func NewHedgeClient(delay retry.HedgeDelay, maxHedges int) *http.Client {
+	return &http.Client{
+		Transport: NewHedgeTransport(http.DefaultTransport, delay, maxHedges),
+	}
+}
@@ -0,0 +1,141 @@
+package net
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/retry"
+)
+
+// 测试慢请求触发备份请求，备份请求的响应胜出，慢请求被取消
+func TestHedgeTransport(t *testing.T) {
+	var hits atomic.Int32
+	canceled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			<-r.Context().Done()
+			close(canceled)
+			return
+		}
+		_, _ = io.WriteString(w, "backup")
+	}))
+	defer srv.Close()
+
+	client := NewHedgeClient(retry.FixedDelay(20*time.Millisecond), 1)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(body))
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("落败的请求应被取消")
+	}
+	assert.Equal(t, int32(2), hits.Load())
+}
+
+// 测试非幂等请求不对冲
+func TestHedgeTransport_NonIdempotent(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(30 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client := NewHedgeClient(retry.FixedDelay(time.Millisecond), 2)
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"sku":"A1"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+// trackedBody 记录响应体是否被读完和关闭
+type trackedBody struct {
+	io.Reader
+	ctx     context.Context
+	drained atomic.Bool
+	closed  atomic.Bool
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.drained.Store(true)
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+// 测试落败请求收到的响应体被读完并关闭，胜出的响应体保持打开
+func TestHedgeTransport_DiscardLosers(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []*trackedBody
+	slow := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		// 不理会 ctx 的取消，每个请求都会收到响应
+		time.Sleep(20 * time.Millisecond)
+		body := &trackedBody{Reader: strings.NewReader("stock"), ctx: req.Context()}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+	})
+
+	client := &http.Client{Transport: NewHedgeTransport(slow, retry.FixedDelay(time.Millisecond), 2)}
+	resp, err := client.Get("http://inventory.example.com/sku/A1")
+	require.NoError(t, err)
+
+	waitFor := func(cond func() bool) {
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			require.True(t, time.Now().Before(deadline), "等待条件超时")
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		closed := 0
+		for _, b := range bodies {
+			if b.closed.Load() {
+				closed++
+			}
+		}
+		return len(bodies) == 3 && closed == 2
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	winner, ok := resp.Body.(*cancelOnCloseBody)
+	require.True(t, ok, "胜出的响应体应在关闭时释放 ctx")
+	for _, b := range bodies {
+		if b == winner.ReadCloser {
+			assert.False(t, b.closed.Load())
+			continue
+		}
+		assert.True(t, b.drained.Load())
+	}
+
+	// 胜出请求的 ctx 在关闭响应体后释放
+	ctx := winner.ReadCloser.(*trackedBody).ctx
+	assert.NoError(t, ctx.Err())
+	resp.Body.Close()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
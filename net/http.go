@@ -11,6 +11,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
 )
 
 // HTTP相关错误定义
@@ -20,6 +22,8 @@ var (
 	ErrMaxRetriesReached = errors.New("ggu: 达到最大重试次数")
 	ErrResponseDecode    = errors.New("ggu: 响应解码失败")
 	ErrInvalidResponse   = errors.New("ggu: 无效的响应")
+	ErrBodyNotReplayable = errors.New("ggu: 请求体无法重新发送")
+	ErrChecksumMismatch  = errors.New("ggu: 校验和不匹配")
 )
 
 // HTTPClient 封装HTTP客户端，适用于电商平台后端开发
@@ -36,6 +40,16 @@ type HTTPClient struct {
 	retryInterval time.Duration
 	// 请求超时时间
 	timeout time.Duration
+	// 拦截器，按添加顺序从外到内执行
+	interceptors []Interceptor
+	// 连接池配置，没有设置 WithTransport 时用于创建底层传输
+	transportOptions []TransportOption
+	// SSRF 防护，拨号检查在创建底层传输时加入
+	ssrfGuard *SSRFGuard
+	// 负载均衡的服务发现和配置
+	resolver            Resolver
+	loadBalancerOptions []LoadBalancerOption
+	loadBalancer        *LoadBalancer
 }
 
 // HTTPClientOption HTTP客户端配置选项
@@ -56,8 +70,36 @@ func NewHTTPClient(options ...HTTPClientOption) *HTTPClient {
 		opt(client)
 	}
 
-	// 设置默认请求超时
-	client.client.Timeout = client.timeout
+	if client.ssrfGuard != nil {
+		if client.client.Transport != nil {
+			panic("ggu: WithSSRFGuard 不能与 WithTransport 同时使用，自定义传输的拨号无法检查，请改用 WithTransportOptions")
+		}
+		client.transportOptions = append(client.transportOptions, WithSSRFDialer(client.ssrfGuard))
+	}
+	if client.client.Transport == nil && len(client.transportOptions) > 0 {
+		client.client.Transport = NewTransport(client.transportOptions...)
+	}
+
+	// 组装拦截器链：内置的重试在最外层，负载均衡紧随其后，每次重试都会重新选择后端并依次经过其余拦截器
+	interceptors := client.interceptors
+	if client.resolver != nil {
+		client.loadBalancer = NewLoadBalancer(client.resolver, client.loadBalancerOptions...)
+		interceptors = append([]Interceptor{client.loadBalancer}, interceptors...)
+	}
+	if client.maxRetries > 0 {
+		retryInterceptor := NewRetryableTransport(nil, client.maxRetries, client.retryInterval).
+			WithRetryCondition(func(resp *http.Response, err error) bool {
+				// 只重试网络错误，超时不重试
+				return err != nil && !errors.Is(err, context.DeadlineExceeded)
+			}).
+			// 超时作用于每次尝试，重试等待和失败的尝试不会占用后续尝试的时间
+			WithAttemptTimeout(client.timeout)
+		interceptors = append([]Interceptor{retryInterceptor}, interceptors...)
+	} else {
+		// 设置默认请求超时
+		client.client.Timeout = client.timeout
+	}
+	client.client.Transport = Chain(client.client.Transport, interceptors...)
 
 	return client
 }
@@ -69,17 +111,19 @@ func WithBaseURL(baseURL string) HTTPClientOption {
 	}
 }
 
-// WithTimeout 设置请求超时时间
+// WithTimeout 设置请求超时时间，包括读取响应体的时间
+// 开启内置重试时超时作用于每一次尝试，整个请求最长可能耗时约 (maxRetries+1)*timeout 加上重试间隔，
+// 需要限制总时间时通过 ctx 设置截止时间
 func WithTimeout(timeout time.Duration) HTTPClientOption {
 	return func(c *HTTPClient) {
 		c.timeout = timeout
 	}
 }
 
-// WithMaxRetries 设置最大重试次数
+// WithMaxRetries 设置最大重试次数，设置为 0 时关闭内置的重试
 func WithMaxRetries(maxRetries int) HTTPClientOption {
 	return func(c *HTTPClient) {
-		if maxRetries > 0 {
+		if maxRetries >= 0 {
 			c.maxRetries = maxRetries
 		}
 	}
@@ -110,6 +154,29 @@ func WithDefaultHeaders(headers map[string]string) HTTPClientOption {
 	}
 }
 
+// WithTransport 设置底层的 http.RoundTripper，拦截器链建立在它之上，默认使用 http.DefaultTransport
+// 测试中可以传入 nettest.MockTransport 或 nettest.Recorder
+func WithTransport(rt http.RoundTripper) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.client.Transport = rt
+	}
+}
+
+// WithInterceptors 添加拦截器，可以多次调用
+// 拦截器按添加顺序从外到内执行，内置的重试始终在最外层，
+// 因此每次重试都会重新经过全部拦截器，拦截器可以通过 AttemptFromContext 获取尝试次数
+func WithInterceptors(interceptors ...Interceptor) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// WithCircuitBreaker 使用熔断器保护所有请求，熔断器拒绝的请求不会重试
+// 熔断拦截器按选项的顺序加入拦截器链
+func WithCircuitBreaker(b *breaker.Breaker) HTTPClientOption {
+	return WithInterceptors(NewBreakerTransport(nil, b))
+}
+
 // buildURL 构建完整URL
 func (c *HTTPClient) buildURL(path string) (string, error) {
 	if path == "" {
@@ -121,8 +188,14 @@ func (c *HTTPClient) buildURL(path string) (string, error) {
 		return path, nil
 	}
 
+	// 使用负载均衡时拼接到占位主机上，由负载均衡器改写为选中的后端
+	baseURL := c.baseURL
+	if c.loadBalancer != nil {
+		baseURL = "http://" + loadBalancedHost
+	}
+
 	// 确保baseURL非空
-	if c.baseURL == "" {
+	if baseURL == "" {
 		return "", ErrInvalidURL
 	}
 
@@ -132,7 +205,7 @@ func (c *HTTPClient) buildURL(path string) (string, error) {
 	}
 
 	// 处理baseURL后缀的斜杠
-	baseURL := strings.TrimSuffix(c.baseURL, "/")
+	baseURL = strings.TrimSuffix(baseURL, "/")
 
 	return baseURL + path, nil
 }
@@ -182,6 +255,10 @@ func (c *HTTPClient) doRequest(ctx context.Context, method, path string, body in
 			if _, exists := headers["Content-Type"]; !exists {
 				headers["Content-Type"] = "application/x-www-form-urlencoded"
 			}
+		case io.Reader:
+			// 流式发送，除 bytes.Reader、strings.Reader 等可以重复读取的类型外，
+			// 超过重试缓冲上限的请求体不会重试
+			bodyReader = v
 		default:
 			// 默认为JSON
 			jsonData, err := json.Marshal(body)
@@ -215,38 +292,21 @@ func (c *HTTPClient) doRequest(ctx context.Context, method, path string, body in
 		req.Header.Set(k, v)
 	}
 
-	// 执行请求并重试
-	return c.doRequestWithRetry(req)
+	// 执行请求，重试由拦截器链完成
+	return c.do(req)
 }
 
-// doRequestWithRetry 执行HTTP请求并在失败时重试
-func (c *HTTPClient) doRequestWithRetry(req *http.Request) (*http.Response, error) {
-	var (
-		resp *http.Response
-		err  error
-	)
-
-	for i := 0; i <= c.maxRetries; i++ {
-		resp, err = c.client.Do(req)
-		if err == nil {
-			return resp, nil
-		}
-
+// do 执行HTTP请求
+func (c *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
 		// 检查是否超时
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, ErrRequestTimeout
 		}
-
-		// 检查是否已经是最后一次尝试
-		if i == c.maxRetries {
-			return nil, fmt.Errorf("%w: %v", ErrMaxRetriesReached, err)
-		}
-
-		// 等待重试
-		time.Sleep(c.retryInterval)
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("unexpected error: %w", err)
+	return resp, nil
 }
 
 // GetJSON 发送GET请求并解析JSON响应
@@ -297,8 +357,7 @@ func (c *HTTPClient) DeleteJSON(ctx context.Context, path string, result interfa
 func (c *HTTPClient) parseJSONResponse(resp *http.Response, result interface{}) error {
 	// 检查响应状态
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("%w: status code: %d, body: %s", ErrInvalidResponse, resp.StatusCode, string(bodyBytes))
+		return newHTTPError(resp)
 	}
 
 	// 解析JSON响应
@@ -336,15 +395,17 @@ type ECommerceAPIClient struct {
 	secretKey  string
 }
 
-// NewECommerceAPIClient 创建电商平台API客户端
-func NewECommerceAPIClient(baseURL, apiKey, secretKey string, timeout time.Duration) *ECommerceAPIClient {
+// NewECommerceAPIClient 创建电商平台API客户端，opts 在默认配置之后应用
+func NewECommerceAPIClient(baseURL, apiKey, secretKey string, timeout time.Duration, opts ...HTTPClientOption) *ECommerceAPIClient {
 	return &ECommerceAPIClient{
-		httpClient: NewHTTPClient(
+		httpClient: NewHTTPClient(append([]HTTPClientOption{
 			WithBaseURL(baseURL),
 			WithTimeout(timeout),
 			WithMaxRetries(3),
 			WithDefaultHeader("User-Agent", "ECommerceSDK/1.0"),
-		),
+			// 每次请求（包括重试）都设置认证头并重新签名
+			WithInterceptors(APIKeyAuth("X-API-Key", apiKey), NewRequestSigner(secretKey)),
+		}, opts...)...),
 		apiKey:    apiKey,
 		secretKey: secretKey,
 	}
@@ -352,53 +413,32 @@ func NewECommerceAPIClient(baseURL, apiKey, secretKey string, timeout time.Durat
 
 // GetProduct 获取商品信息
 func (c *ECommerceAPIClient) GetProduct(ctx context.Context, productID string) (map[string]interface{}, error) {
-	// 设置认证头
-	headers := map[string]string{
-		"X-API-Key": c.apiKey,
-	}
-
-	var result map[string]interface{}
-	err := c.httpClient.GetJSON(ctx, fmt.Sprintf("/products/%s", productID), &result, headers)
-	return result, err
+	return Get[map[string]interface{}](c.httpClient, "/products/{id}").
+		PathParam("id", productID).
+		Do(ctx)
 }
 
 // CreateOrder 创建订单
 func (c *ECommerceAPIClient) CreateOrder(ctx context.Context, orderData map[string]interface{}) (map[string]interface{}, error) {
-	// 设置认证头
-	headers := map[string]string{
-		"X-API-Key": c.apiKey,
-	}
-
-	var result map[string]interface{}
-	err := c.httpClient.PostJSON(ctx, "/orders", orderData, &result, headers)
-	return result, err
+	return Post[map[string]interface{}](c.httpClient, "/orders").
+		Body(orderData).
+		Do(ctx)
 }
 
 // GetOrders 获取订单列表
 func (c *ECommerceAPIClient) GetOrders(ctx context.Context, page, pageSize int) (map[string]interface{}, error) {
-	// 设置认证头
-	headers := map[string]string{
-		"X-API-Key": c.apiKey,
-	}
-
-	path := fmt.Sprintf("/orders?page=%d&pageSize=%d", page, pageSize)
-	var result map[string]interface{}
-	err := c.httpClient.GetJSON(ctx, path, &result, headers)
-	return result, err
+	return Get[map[string]interface{}](c.httpClient, "/orders").
+		QueryStruct(struct {
+			Page     int `query:"page"`
+			PageSize int `query:"pageSize"`
+		}{page, pageSize}).
+		Do(ctx)
 }
 
 // UpdateInventory 更新库存
 func (c *ECommerceAPIClient) UpdateInventory(ctx context.Context, productID string, quantity int) (map[string]interface{}, error) {
-	// 设置认证头
-	headers := map[string]string{
-		"X-API-Key": c.apiKey,
-	}
-
-	data := map[string]interface{}{
-		"quantity": quantity,
-	}
-
-	var result map[string]interface{}
-	err := c.httpClient.PutJSON(ctx, fmt.Sprintf("/products/%s/inventory", productID), data, &result, headers)
-	return result, err
+	return Put[map[string]interface{}](c.httpClient, "/products/{id}/inventory").
+		PathParam("id", productID).
+		Body(map[string]interface{}{"quantity": quantity}).
+		Do(ctx)
 }
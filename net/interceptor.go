@@ -0,0 +1,145 @@
+package net
+
+import (
+	"context"
+	"net/http"
+)
+
+// Interceptor HTTP请求拦截器
+// 拦截器可以在调用 next 之前修改请求、在之后处理响应，也可以不调用 next 直接返回，
+// 修改请求前应先 Clone，不要修改调用方传入的请求
+type Interceptor interface {
+	Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error)
+}
+
+// InterceptorFunc 函数形式的拦截器
+type InterceptorFunc func(req *http.Request, next http.RoundTripper) (*http.Response, error)
+
+// Intercept 实现Interceptor接口
+func (f InterceptorFunc) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	return f(req, next)
+}
+
+// RoundTripFunc 函数形式的http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现http.RoundTripper接口
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain 把拦截器按顺序串联到 base 之上，第一个拦截器在最外层，最先看到请求、最后看到响应
+// base 为 nil 时使用 http.DefaultTransport
+func Chain(base http.RoundTripper, interceptors ...Interceptor) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := base
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		rt = &chainLink{interceptor: interceptors[i], next: rt}
+	}
+	return rt
+}
+
+// chainLink 拦截器链中的一环
+type chainLink struct {
+	interceptor Interceptor
+	next        http.RoundTripper
+}
+
+func (l *chainLink) RoundTrip(req *http.Request) (*http.Response, error) {
+	return l.interceptor.Intercept(req, l.next)
+}
+
+// CloseIdleConnections 转发给下层传输，使 http.Client.CloseIdleConnections 能够关闭底层连接池的空闲连接
+func (l *chainLink) CloseIdleConnections() {
+	if c, ok := l.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// attemptKey 请求上下文中保存尝试次数的键
+type attemptKey struct{}
+
+// withAttempt 在上下文中记录当前的尝试次数
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// noAttemptTimeoutKey 请求上下文中关闭单次尝试超时的键
+type noAttemptTimeoutKey struct{}
+
+// withoutAttemptTimeout 让重试拦截器不为这个请求的每次尝试设置超时，用于下载等耗时较长的请求
+func withoutAttemptTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noAttemptTimeoutKey{}, true)
+}
+
+// AttemptFromContext 返回请求当前的尝试次数，从 1 开始
+// 重试拦截器会为每次尝试设置次数，位于重试拦截器内层的拦截器可以看到重试的次数
+func AttemptFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// RequestHook 请求发出前调用的钩子，req 是可以修改的副本，attempt 为尝试次数
+// 返回错误时中止请求
+type RequestHook func(req *http.Request, attempt int) error
+
+// ResponseHook 收到响应或错误后调用的钩子，attempt 为尝试次数
+type ResponseHook func(req *http.Request, resp *http.Response, err error, attempt int)
+
+// OnRequest 创建一个在请求发出前调用钩子的拦截器
+func OnRequest(hook RequestHook) Interceptor {
+	return InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		if err := hook(req, AttemptFromContext(req.Context())); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// OnResponse 创建一个在收到响应或错误后调用钩子的拦截器
+func OnResponse(hook ResponseHook) Interceptor {
+	return InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		hook(req, resp, err, AttemptFromContext(req.Context()))
+		return resp, err
+	})
+}
+
+// 认证拦截器
+
+// BearerAuth 创建一个设置 Authorization: Bearer 头的拦截器
+// token 在每次尝试时调用，可以返回刷新后的令牌
+func BearerAuth(token func(ctx context.Context) (string, error)) Interceptor {
+	return OnRequest(func(req *http.Request, attempt int) error {
+		t, err := token(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+t)
+		return nil
+	})
+}
+
+// BasicAuth 创建一个设置 HTTP Basic 认证的拦截器
+func BasicAuth(username, password string) Interceptor {
+	return OnRequest(func(req *http.Request, attempt int) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// APIKeyAuth 创建一个在指定请求头中设置 API Key 的拦截器
+func APIKeyAuth(header, key string) Interceptor {
+	return OnRequest(func(req *http.Request, attempt int) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
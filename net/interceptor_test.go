@@ -0,0 +1,268 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer 作为拦截器链的最后一环，不发送真实请求，依次返回预设的结果
+type fakeServer struct {
+	mu      sync.Mutex
+	results []error
+	bodies  []string
+	headers []http.Header
+}
+
+func (f *fakeServer) Intercept(req *http.Request, _ http.RoundTripper) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body string
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		req.Body.Close()
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+	f.headers = append(f.headers, req.Header.Clone())
+
+	var err error
+	if len(f.results) > 0 {
+		err, f.results = f.results[0], f.results[1:]
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"id":"order-1"}`)),
+		Request:    req,
+	}, nil
+}
+
+// 测试拦截器按顺序执行，每次重试都重新经过拦截器并能看到尝试次数
+func TestHTTPClient_Interceptors(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, errConn, nil}}
+
+	var order []string
+	var attempts []int
+	var logs []LogEntry
+	client := NewHTTPClient(
+		WithBaseURL("http://api.example.com"),
+		WithMaxRetries(3),
+		WithRetryInterval(time.Millisecond),
+		WithInterceptors(
+			OnRequest(func(req *http.Request, attempt int) error {
+				order = append(order, "outer")
+				attempts = append(attempts, attempt)
+				req.Header.Set("X-Attempt", string(rune('0'+attempt)))
+				return nil
+			}),
+			APIKeyAuth("X-API-Key", "key-1"),
+			InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+				order = append(order, "inner")
+				return next.RoundTrip(req)
+			}),
+		),
+		WithInterceptors(NewHttpLogger(nil, func(l LogEntry, err error) { logs = append(logs, l) }), server),
+	)
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	err := client.PostJSON(context.Background(), "/orders", map[string]int{"qty": 2}, &result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "order-1", result.ID)
+
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	assert.Equal(t, []string{"outer", "inner", "outer", "inner", "outer", "inner"}, order)
+	assert.Equal(t, []string{`{"qty":2}`, `{"qty":2}`, `{"qty":2}`}, server.bodies, "每次重试都应重新发送请求体")
+	for i, h := range server.headers {
+		assert.Equal(t, "key-1", h.Get("X-API-Key"))
+		assert.Equal(t, string(rune('1'+i)), h.Get("X-Attempt"))
+	}
+	require.Len(t, logs, 3)
+	assert.Equal(t, 3, logs[2].Attempt)
+	assert.Equal(t, `{"id":"order-1"}`, logs[2].RespBody)
+}
+
+// 测试重试耗尽和关闭内置重试
+func TestHTTPClient_RetryExhausted(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, errConn, errConn}}
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(2),
+		WithRetryInterval(time.Millisecond), WithInterceptors(server))
+	_, err := client.Get(context.Background(), "/products/1", nil)
+	assert.ErrorIs(t, err, ErrMaxRetriesReached)
+	assert.ErrorIs(t, err, errConn)
+	assert.Len(t, server.bodies, 3)
+
+	server = &fakeServer{results: []error{errConn}}
+	client = NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(0), WithInterceptors(server))
+	_, err = client.Get(context.Background(), "/products/1", nil)
+	assert.ErrorIs(t, err, errConn)
+	assert.NotErrorIs(t, err, ErrMaxRetriesReached)
+	assert.Len(t, server.bodies, 1)
+}
+
+// 测试开启重试时超时作用于每一次尝试，读取响应体同样受单次超时的限制
+func TestHTTPClient_AttemptTimeout(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	var deadlines []time.Time
+	slow := InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		deadline, ok := req.Context().Deadline()
+		require.True(t, ok)
+		deadlines = append(deadlines, deadline)
+		select {
+		case <-time.After(60 * time.Millisecond):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if AttemptFromContext(req.Context()) == 1 {
+			return nil, errConn
+		}
+		return next.RoundTrip(req)
+	})
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithTimeout(100*time.Millisecond),
+		WithRetryInterval(50*time.Millisecond), WithInterceptors(slow, &fakeServer{}))
+	resp, err := client.Get(context.Background(), "/products/1", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Len(t, deadlines, 2)
+	assert.True(t, deadlines[1].After(deadlines[0]))
+
+	// 单次尝试超过超时时间时返回超时错误，不重试
+	stall := InterceptorFunc(func(req *http.Request, _ http.RoundTripper) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	client = NewHTTPClient(WithBaseURL("http://api.example.com"), WithTimeout(20*time.Millisecond),
+		WithRetryInterval(time.Millisecond), WithInterceptors(stall))
+	_, err = client.Get(context.Background(), "/products/1", nil)
+	assert.ErrorIs(t, err, ErrRequestTimeout)
+
+	// 响应体关闭前上下文保持有效，关闭后释放
+	var attemptCtx context.Context
+	capture := InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+		attemptCtx = req.Context()
+		return next.RoundTrip(req)
+	})
+	client = NewHTTPClient(WithBaseURL("http://api.example.com"), WithTimeout(time.Second),
+		WithInterceptors(capture, &fakeServer{}))
+	resp, err = client.Get(context.Background(), "/products/1", nil)
+	require.NoError(t, err)
+	assert.NoError(t, attemptCtx.Err())
+	resp.Body.Close()
+	assert.ErrorIs(t, attemptCtx.Err(), context.Canceled)
+}
+
+// idleCloser 记录 CloseIdleConnections 的调用次数
+type idleCloser struct {
+	http.RoundTripper
+	closed int
+}
+
+func (c *idleCloser) CloseIdleConnections() { c.closed++ }
+
+// 测试拦截器链把 CloseIdleConnections 转发给底层传输
+func TestChain_CloseIdleConnections(t *testing.T) {
+	base := &idleCloser{RoundTripper: http.DefaultTransport}
+	client := NewHTTPClient(WithTransport(base), WithInterceptors(APIKeyAuth("X-Api-Key", "key-1")))
+	client.client.CloseIdleConnections()
+	assert.Equal(t, 1, base.closed)
+}
+
+// 测试请求钩子返回错误时中止请求，响应钩子收到结果
+func TestInterceptor_Hooks(t *testing.T) {
+	errDenied := errors.New("未登录")
+	server := &fakeServer{}
+	var statuses []int
+	rt := Chain(nil,
+		OnResponse(func(req *http.Request, resp *http.Response, err error, attempt int) {
+			if resp != nil {
+				statuses = append(statuses, resp.StatusCode)
+			}
+		}),
+		BearerAuth(func(ctx context.Context) (string, error) {
+			if ctx.Value(attemptKey{}) == nil && len(statuses) > 0 {
+				return "", errDenied
+			}
+			return "token-1", nil
+		}),
+		BasicAuth("ops", "secret"),
+		server,
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com/orders", nil)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []int{http.StatusOK}, statuses)
+	user, pass, ok := (&http.Request{Header: server.headers[0]}).BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "ops:secret", user+":"+pass)
+	assert.Empty(t, req.Header, "拦截器不应修改调用方的请求")
+
+	_, err = rt.RoundTrip(req)
+	assert.ErrorIs(t, err, errDenied)
+	assert.Len(t, server.bodies, 1, "请求钩子返回错误时不应发送请求")
+	assert.Equal(t, 1, AttemptFromContext(context.Background()))
+}
+
+// 测试限流拦截器在 ctx 结束时返回
+func TestRateLimitTransport_Context(t *testing.T) {
+	limiter := NewRateLimitTransport(nil, 1)
+	defer limiter.ticker.Stop()
+	server := &fakeServer{}
+	rt := Chain(nil, limiter, server)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com/orders", nil)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = rt.RoundTrip(req.WithContext(ctx))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, server.bodies, 1)
+}
+
+// 测试日志只记录请求体和响应体的开头，完整内容仍然原样传输
+func TestHttpLogger_TruncatesBody(t *testing.T) {
+	large := strings.Repeat("x", 3*maxLoggedBodySize)
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+		_, _ = io.WriteString(w, large)
+	}))
+	defer srv.Close()
+
+	var entry LogEntry
+	logger := NewHttpLogger(nil, func(l LogEntry, err error) { entry = l })
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(large))
+	require.NoError(t, err)
+	resp, err := logger.RoundTrip(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, len(large), received)
+	assert.Equal(t, large, string(body))
+	assert.Len(t, entry.ReqBody, maxLoggedBodySize)
+	assert.Len(t, entry.RespBody, maxLoggedBodySize)
+}
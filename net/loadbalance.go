@@ -0,0 +1,228 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/retry"
+)
+
+// ErrNoEndpoints 负载均衡器没有可用的后端
+var ErrNoEndpoints = errors.New("ggu: 没有可用的后端")
+
+// loadBalancedHost 使用负载均衡时相对路径的占位主机，.invalid 是保留的顶级域名，不会被解析
+const loadBalancedHost = "ggu-lb.invalid"
+
+// LoadBalancer 客户端负载均衡，作为拦截器把发往占位主机的请求改写到选中的后端
+//   - 后端列表来自 Resolver，列表变化时同一URL的后端保留运行状态
+//   - 被动健康检查：后端连续失败达到次数后被剔除一段时间，到期后重新参与选择；
+//     全部后端都被剔除时忽略剔除状态，避免把局部故障放大为全部请求失败
+//   - 与 HTTPClient 的内置重试配合时，每次重试重新选择后端
+type LoadBalancer struct {
+	resolver      Resolver
+	balancer      Balancer
+	clock         clock.Clock
+	maxFailures   int
+	ejectDuration time.Duration
+	isFailure     func(resp *http.Response, err error) bool
+	cancelWatch   func()
+
+	mu       sync.Mutex // 保护后端列表的更新
+	backends atomic.Pointer[[]*Backend]
+}
+
+// LoadBalancerOption 负载均衡器配置选项
+type LoadBalancerOption func(*LoadBalancer)
+
+// WithBalancer 设置负载均衡策略，默认轮询
+func WithBalancer(b Balancer) LoadBalancerOption {
+	return func(lb *LoadBalancer) {
+		if b != nil {
+			lb.balancer = b
+		}
+	}
+}
+
+// WithEjection 设置被动健康检查：连续失败 maxFailures 次后剔除 duration，默认连续失败5次剔除30秒
+// maxFailures 不大于0时关闭剔除
+func WithEjection(maxFailures int, duration time.Duration) LoadBalancerOption {
+	return func(lb *LoadBalancer) {
+		lb.maxFailures = maxFailures
+		if duration > 0 {
+			lb.ejectDuration = duration
+		}
+	}
+}
+
+// WithFailureCondition 设置判断一次请求是否失败的函数，默认网络错误和 5xx 响应为失败，
+// 调用方取消请求不计为失败
+func WithFailureCondition(fn func(resp *http.Response, err error) bool) LoadBalancerOption {
+	return func(lb *LoadBalancer) {
+		if fn != nil {
+			lb.isFailure = fn
+		}
+	}
+}
+
+// WithLoadBalancerClock 设置判断剔除时间使用的时钟，测试中可以传入 clock.FakeClock
+func WithLoadBalancerClock(clk clock.Clock) LoadBalancerOption {
+	return func(lb *LoadBalancer) {
+		lb.clock = clock.OrReal(clk)
+	}
+}
+
+// NewLoadBalancer 创建负载均衡器并监听 resolver 的变化，地址无效的后端会被忽略
+func NewLoadBalancer(resolver Resolver, opts ...LoadBalancerOption) *LoadBalancer {
+	lb := &LoadBalancer{
+		resolver:      resolver,
+		balancer:      NewRoundRobinBalancer(),
+		clock:         clock.Real,
+		maxFailures:   5,
+		ejectDuration: 30 * time.Second,
+		isFailure: func(resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+	}
+	for _, opt := range opts {
+		opt(lb)
+	}
+	lb.update(resolver.Endpoints())
+	lb.cancelWatch = resolver.Watch(lb.update)
+	return lb
+}
+
+// Backends 返回当前的全部后端，包括被剔除的后端
+func (lb *LoadBalancer) Backends() []*Backend {
+	return append([]*Backend(nil), *lb.backends.Load()...)
+}
+
+// Close 停止监听 Resolver 的变化
+func (lb *LoadBalancer) Close() {
+	lb.cancelWatch()
+}
+
+// update 更新后端列表，保留同一URL后端的状态
+func (lb *LoadBalancer) update(endpoints []Endpoint) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	old := make(map[string]*Backend)
+	if p := lb.backends.Load(); p != nil {
+		for _, be := range *p {
+			old[be.URL] = be
+		}
+	}
+
+	backends := make([]*Backend, 0, len(endpoints))
+	for _, ep := range endpoints {
+		be, err := newBackend(ep)
+		if err != nil {
+			continue
+		}
+		if prev, ok := old[be.URL]; ok && prev.Weight == be.Weight {
+			be = prev
+		}
+		backends = append(backends, be)
+	}
+	lb.backends.Store(&backends)
+}
+
+// Pick 选择一个后端，没有后端时返回 ErrNoEndpoints
+func (lb *LoadBalancer) Pick(req *http.Request) (*Backend, error) {
+	all := *lb.backends.Load()
+	if len(all) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	now := lb.clock.Now()
+	healthy := make([]*Backend, 0, len(all))
+	for _, be := range all {
+		if !now.Before(be.EjectedUntil()) {
+			healthy = append(healthy, be)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = all
+	}
+	return lb.balancer.Pick(req, healthy), nil
+}
+
+// Intercept 实现 Interceptor 接口，只改写发往占位主机的请求，完整URL的请求直接发送
+func (lb *LoadBalancer) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if req.URL.Host != loadBalancedHost {
+		return next.RoundTrip(req)
+	}
+	be, err := lb.Pick(req)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, retry.Permanent(err)
+	}
+
+	target := req.Clone(req.Context())
+	target.URL.Scheme = be.base.Scheme
+	target.URL.Host = be.base.Host
+	target.URL.Path = strings.TrimSuffix(be.base.Path, "/") + req.URL.Path
+	if req.URL.RawPath != "" {
+		target.URL.RawPath = strings.TrimSuffix(be.base.EscapedPath(), "/") + req.URL.RawPath
+	}
+	target.Host = ""
+
+	be.inflight.Add(1)
+	resp, err := next.RoundTrip(target)
+	be.inflight.Add(-1)
+	lb.report(req.Context(), be, resp, err)
+	return resp, err
+}
+
+// report 记录请求结果，连续失败达到次数时剔除后端
+func (lb *LoadBalancer) report(ctx context.Context, be *Backend, resp *http.Response, err error) {
+	if lb.maxFailures <= 0 || (err != nil && ctx.Err() != nil) {
+		return
+	}
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if !lb.isFailure(resp, err) {
+		be.failures = 0
+		return
+	}
+	be.failures++
+	if be.failures >= lb.maxFailures {
+		be.failures = 0
+		be.ejectedUntil = lb.clock.Now().Add(lb.ejectDuration)
+	}
+}
+
+// WithEndpoints 在多个后端之间负载均衡，相对路径的请求依次拼接到选中后端的基础URL上，优先于 WithBaseURL
+// 负载均衡策略和健康检查通过 WithLoadBalancerOptions 设置
+func WithEndpoints(urls ...string) HTTPClientOption {
+	endpoints := make([]Endpoint, 0, len(urls))
+	for _, u := range urls {
+		endpoints = append(endpoints, Endpoint{URL: u})
+	}
+	return WithResolver(NewStaticResolver(endpoints...))
+}
+
+// WithResolver 使用服务发现提供的后端列表负载均衡，参见 WithEndpoints
+func WithResolver(r Resolver) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.resolver = r
+	}
+}
+
+// WithLoadBalancerOptions 设置负载均衡器的选项，只在设置了 WithEndpoints 或 WithResolver 时生效
+func WithLoadBalancerOptions(opts ...LoadBalancerOption) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.loadBalancerOptions = append(c.loadBalancerOptions, opts...)
+	}
+}
+
+// LoadBalancer 返回客户端的负载均衡器，没有设置 WithEndpoints 或 WithResolver 时返回 nil
+func (c *HTTPClient) LoadBalancer() *LoadBalancer {
+	return c.loadBalancer
+}
@@ -0,0 +1,214 @@
+package net
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lbServer 记录请求路径的测试后端，返回 status 指定的状态码
+type lbServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	status int
+}
+
+func newLBServer(t *testing.T) *lbServer {
+	s := &lbServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.RequestURI())
+		status := s.status
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *lbServer) hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.paths)
+}
+
+func (s *lbServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// getStatus 发送GET请求并返回状态码
+func getStatus(t *testing.T, client *HTTPClient, path string) int {
+	resp, err := client.Get(context.Background(), path, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// 测试相对路径拼接到后端的路径前缀上，完整URL不经过负载均衡
+func TestHTTPClient_WithEndpoints(t *testing.T) {
+	a, b := newLBServer(t), newLBServer(t)
+	client := NewHTTPClient(WithEndpoints(a.URL+"/api/", b.URL+"/api"), WithBaseURL("http://unused.invalid"))
+	require.NotNil(t, client.LoadBalancer())
+	assert.Len(t, client.LoadBalancer().Backends(), 2)
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, getStatus(t, client, "/orders?page=1"))
+	}
+	assert.Equal(t, []string{"/api/orders?page=1", "/api/orders?page=1"}, a.paths)
+	assert.Equal(t, []string{"/api/orders?page=1", "/api/orders?page=1"}, b.paths)
+
+	// 请求构建器同样经过负载均衡，转义的路径参数保持不变
+	resp, err := Get[any](client, "/orders/{id}").PathParam("id", "a/b").Send(context.Background())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "/api/orders/a%2Fb", a.paths[2])
+
+	assert.Equal(t, http.StatusOK, getStatus(t, client, a.URL+"/direct"))
+	assert.Equal(t, "/direct", a.paths[len(a.paths)-1])
+
+	assert.Nil(t, NewHTTPClient().LoadBalancer())
+}
+
+// 测试后端连续失败后被剔除，到期后恢复；全部后端被剔除时仍然发送
+func TestLoadBalancer_Ejection(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	healthy, broken := newLBServer(t), newLBServer(t)
+	broken.setStatus(http.StatusBadGateway)
+	client := NewHTTPClient(WithEndpoints(healthy.URL, broken.URL),
+		WithLoadBalancerOptions(WithEjection(2, 30*time.Second), WithLoadBalancerClock(clk)))
+
+	for i := 0; i < 4; i++ {
+		getStatus(t, client, "/ping")
+	}
+	assert.Equal(t, 2, broken.hits())
+	backends := client.LoadBalancer().Backends()
+	assert.Equal(t, clk.Now().Add(30*time.Second), backends[1].EjectedUntil())
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, getStatus(t, client, "/ping"))
+	}
+	assert.Equal(t, 2, broken.hits(), "被剔除的后端不接收请求")
+
+	// 剔除到期后重新参与选择，恢复后连续失败次数重新计算
+	broken.setStatus(http.StatusOK)
+	clk.Advance(30 * time.Second)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusOK, getStatus(t, client, "/ping"))
+	}
+	assert.Equal(t, 4, broken.hits())
+
+	// 全部后端都被剔除
+	healthy.setStatus(http.StatusServiceUnavailable)
+	broken.setStatus(http.StatusServiceUnavailable)
+	for i := 0; i < 4; i++ {
+		getStatus(t, client, "/ping")
+	}
+	for _, be := range client.LoadBalancer().Backends() {
+		assert.True(t, clk.Now().Before(be.EjectedUntil()))
+	}
+	total := healthy.hits() + broken.hits()
+	assert.Equal(t, http.StatusServiceUnavailable, getStatus(t, client, "/ping"))
+	assert.Equal(t, total+1, healthy.hits()+broken.hits())
+}
+
+// 测试内置重试时重新选择后端，连接失败的后端被剔除
+func TestLoadBalancer_RetryPicksAnotherBackend(t *testing.T) {
+	alive := newLBServer(t)
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	client := NewHTTPClient(WithEndpoints(dead.URL, alive.URL), WithRetryInterval(time.Millisecond),
+		WithLoadBalancerOptions(WithEjection(1, time.Minute)))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, getStatus(t, client, "/ping"))
+	}
+	assert.Equal(t, 3, alive.hits())
+	assert.False(t, client.LoadBalancer().Backends()[0].EjectedUntil().IsZero())
+}
+
+// 测试 Resolver 更新后端列表后，同一URL的后端保留状态
+func TestLoadBalancer_ResolverUpdate(t *testing.T) {
+	a, b := newLBServer(t), newLBServer(t)
+	r := &watchResolver{endpoints: []Endpoint{{URL: a.URL}, {URL: "://invalid"}}}
+	lb := NewLoadBalancer(r, WithEjection(1, time.Minute))
+	client := NewHTTPClient(WithTransport(Chain(http.DefaultTransport, lb)), WithBaseURL("http://"+loadBalancedHost))
+
+	require.Len(t, lb.Backends(), 1, "无效的地址被忽略")
+	first := lb.Backends()[0]
+	a.setStatus(http.StatusInternalServerError)
+	getStatus(t, client, "/ping")
+	assert.False(t, first.EjectedUntil().IsZero())
+
+	r.set([]Endpoint{{URL: b.URL}, {URL: a.URL}})
+	require.Len(t, lb.Backends(), 2)
+	assert.Same(t, first, lb.Backends()[1])
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, getStatus(t, client, "/ping"))
+	}
+	assert.Equal(t, 1, a.hits())
+
+	r.set(nil)
+	_, err := client.Get(context.Background(), "/ping", nil)
+	assert.ErrorIs(t, err, ErrNoEndpoints)
+
+	lb.Close()
+	r.set([]Endpoint{{URL: a.URL}})
+	assert.Empty(t, lb.Backends(), "Close 后不再更新")
+}
+
+// watchResolver 可以手动更新后端列表的 Resolver
+type watchResolver struct {
+	mu        sync.Mutex
+	endpoints []Endpoint
+	watcher   func([]Endpoint)
+}
+
+func (r *watchResolver) Endpoints() []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.endpoints
+}
+
+func (r *watchResolver) Watch(fn func([]Endpoint)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watcher = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.watcher = nil
+	}
+}
+
+func (r *watchResolver) set(endpoints []Endpoint) {
+	r.mu.Lock()
+	r.endpoints = endpoints
+	fn := r.watcher
+	r.mu.Unlock()
+	if fn != nil {
+		fn(endpoints)
+	}
+}
+
+// 测试没有后端时关闭请求体
+func TestLoadBalancer_ClosesBodyWithoutBackends(t *testing.T) {
+	lb := NewLoadBalancer(NewStaticResolver())
+	body := &closeTracker{Reader: strings.NewReader("data")}
+	req, err := http.NewRequest(http.MethodPost, "http://"+loadBalancedHost+"/upload", body)
+	require.NoError(t, err)
+	_, err = lb.Intercept(req, http.DefaultTransport)
+	assert.ErrorIs(t, err, ErrNoEndpoints)
+	assert.True(t, body.closed.Load())
+}
@@ -2,12 +2,23 @@ package net
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+	"github.com/Humphrey-He/go-generic-utils/retry"
 )
 
+// maxLoggedBodySize 日志中记录的请求体和响应体的最大字节数
+const maxLoggedBodySize = 4 << 10
+
 // HttpLogger 用于记录HTTP请求和响应的中间件
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链。
+// 请求体和响应体只记录开头的 4KB，其余内容仍然流式传输，不会因为记录日志而整体读入内存
 type HttpLogger struct {
 	delegate http.RoundTripper
 	log      func(l LogEntry, err error)
@@ -18,15 +29,17 @@ type LogEntry struct {
 	URL         string        // 请求URL
 	Method      string        // 请求方法
 	ReqHeaders  http.Header   // 请求头
-	ReqBody     string        // 请求体
+	ReqBody     string        // 请求体，最多记录开头的 4KB
 	RespStatus  string        // 响应状态
 	RespHeaders http.Header   // 响应头
-	RespBody    string        // 响应体
+	RespBody    string        // 响应体，最多记录开头的 4KB
 	StartTime   time.Time     // 请求开始时间
 	Duration    time.Duration // 请求持续时间
+	Attempt     int           // 尝试次数，从 1 开始
 }
 
 // NewHttpLogger 创建一个新的日志记录中间件
+// 作为拦截器使用时 rp 可以传 nil
 func NewHttpLogger(rp http.RoundTripper, log func(l LogEntry, err error)) *HttpLogger {
 	if rp == nil {
 		rp = http.DefaultTransport
@@ -38,12 +51,18 @@ func NewHttpLogger(rp http.RoundTripper, log func(l LogEntry, err error)) *HttpL
 }
 
 // RoundTrip 实现http.RoundTripper接口
-func (l *HttpLogger) RoundTrip(request *http.Request) (resp *http.Response, err error) {
+func (l *HttpLogger) RoundTrip(request *http.Request) (*http.Response, error) {
+	return l.Intercept(request, l.delegate)
+}
+
+// Intercept 实现Interceptor接口，记录请求和响应后交给 next
+func (l *HttpLogger) Intercept(request *http.Request, next http.RoundTripper) (resp *http.Response, err error) {
 	logEntry := LogEntry{
 		URL:        request.URL.String(),
 		Method:     request.Method,
 		ReqHeaders: request.Header.Clone(),
 		StartTime:  time.Now(),
+		Attempt:    AttemptFromContext(request.Context()),
 	}
 
 	defer func() {
@@ -54,25 +73,34 @@ func (l *HttpLogger) RoundTrip(request *http.Request) (resp *http.Response, err
 			logEntry.RespHeaders = resp.Header.Clone()
 
 			if resp.Body != nil {
-				body, _ := io.ReadAll(resp.Body)
-				resp.Body = io.NopCloser(bytes.NewReader(body))
-				logEntry.RespBody = string(body)
+				logEntry.RespBody, resp.Body = peekBody(resp.Body)
 			}
 		}
 
 		l.log(logEntry, err)
 	}()
 
-	if request.Body != nil {
-		body, _ := io.ReadAll(request.Body)
-		request.Body = io.NopCloser(bytes.NewReader(body))
-		logEntry.ReqBody = string(body)
+	if request.Body != nil && request.Body != http.NoBody {
+		// 读取请求体开头后换成新的请求，不修改调用方的请求
+		var body io.ReadCloser
+		logEntry.ReqBody, body = peekBody(request.Body)
+		request = request.Clone(request.Context())
+		request.Body = body
 	}
 
-	resp, err = l.delegate.RoundTrip(request)
+	resp, err = next.RoundTrip(request)
 	return
 }
 
+// peekBody 读取 body 开头最多 maxLoggedBodySize 字节用于记录，返回的 ReadCloser 仍然能读到完整内容
+func peekBody(body io.ReadCloser) (string, io.ReadCloser) {
+	head, _ := io.ReadAll(io.LimitReader(body, maxLoggedBodySize))
+	return string(head), struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}
+}
+
 // NewLoggingClient 创建一个支持日志记录的HTTP客户端
 func NewLoggingClient(logger func(l LogEntry, err error)) *http.Client {
 	return &http.Client{
@@ -92,23 +120,31 @@ func NewEcommerceClient(logger func(l LogEntry, err error)) *http.Client {
 }
 
 // RetryableTransport 实现可重试的HTTP传输
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链，
+// 每次尝试都会在请求上下文中设置尝试次数，内层的拦截器可以通过 AttemptFromContext 获取。
+// 熔断器拒绝的请求和 retry.Permanent 标记的错误不会重试
 type RetryableTransport struct {
-	delegate    http.RoundTripper
-	maxRetries  int
-	retryDelay  time.Duration
-	shouldRetry func(resp *http.Response, err error) bool
+	delegate      http.RoundTripper
+	maxRetries    int
+	retryDelay    time.Duration
+	maxBufferSize int64
+	// attemptTimeout 单次尝试的超时时间，包括读取响应体的时间，0 表示不限制
+	attemptTimeout time.Duration
+	shouldRetry    func(resp *http.Response, err error) bool
 }
 
 // NewRetryableTransport 创建一个支持重试的HTTP传输
+// 作为拦截器使用时 delegate 可以传 nil
 func NewRetryableTransport(delegate http.RoundTripper, maxRetries int, retryDelay time.Duration) *RetryableTransport {
 	if delegate == nil {
 		delegate = http.DefaultTransport
 	}
 
 	return &RetryableTransport{
-		delegate:   delegate,
-		maxRetries: maxRetries,
-		retryDelay: retryDelay,
+		delegate:      delegate,
+		maxRetries:    maxRetries,
+		retryDelay:    retryDelay,
+		maxBufferSize: 1 << 20,
 		shouldRetry: func(resp *http.Response, err error) bool {
 			// 默认重试条件：网络错误或5xx服务器错误
 			if err != nil {
@@ -119,45 +155,149 @@ func NewRetryableTransport(delegate http.RoundTripper, maxRetries int, retryDela
 	}
 }
 
+// WithRetryCondition 设置判断是否需要重试的函数，返回传输本身以便链式调用
+func (rt *RetryableTransport) WithRetryCondition(fn func(resp *http.Response, err error) bool) *RetryableTransport {
+	if fn != nil {
+		rt.shouldRetry = fn
+	}
+	return rt
+}
+
+// WithMaxBufferSize 设置没有 GetBody 的请求体最多缓存的字节数，默认1MB，返回传输本身以便链式调用
+// 超过上限的请求体直接流式发送且不重试；设置了 GetBody 的请求体不会被缓存
+func (rt *RetryableTransport) WithMaxBufferSize(n int64) *RetryableTransport {
+	if n >= 0 {
+		rt.maxBufferSize = n
+	}
+	return rt
+}
+
+// WithAttemptTimeout 设置单次尝试的超时时间，返回传输本身以便链式调用
+// 每次尝试使用独立的 ctx 截止时间，从发出请求一直到关闭响应体，重试等待的时间不计入；
+// 整个请求的时间仍然受请求 ctx 的限制。d <= 0 表示不限制
+func (rt *RetryableTransport) WithAttemptTimeout(d time.Duration) *RetryableTransport {
+	rt.attemptTimeout = d
+	return rt
+}
+
 // RoundTrip 实现http.RoundTripper接口，支持重试
 func (rt *RetryableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	var resp *http.Response
-	var err error
+	return rt.Intercept(req, rt.delegate)
+}
 
-	// 保存请求体以便重试时使用
-	var reqBody []byte
-	if req.Body != nil {
-		reqBody, _ = io.ReadAll(req.Body)
-		req.Body.Close()
+// Intercept 实现Interceptor接口，失败时按间隔重试 next
+// 熔断器拒绝的请求和 ctx 已经结束的请求不会重试；请求体通过 GetBody 重新获取，
+// GetBody 返回错误（例如 ErrBodyNotReplayable）时直接返回上一次的结果；
+// 重试耗尽且最后一次是错误时，返回的错误同时包装 ErrMaxRetriesReached
+func (rt *RetryableTransport) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	ctx := req.Context()
+
+	// 保存请求体以便重试时使用，请求自带 GetBody 时第一次尝试使用原请求体，重试时通过 GetBody 获取
+	var getBody func() (io.ReadCloser, error)
+	firstBody := req.Body
+	if req.Body != nil && req.Body != http.NoBody {
+		getBody = req.GetBody
+		if getBody == nil {
+			reqBody, readErr := io.ReadAll(io.LimitReader(req.Body, rt.maxBufferSize+1))
+			if readErr != nil {
+				req.Body.Close()
+				return nil, readErr
+			}
+			if int64(len(reqBody)) > rt.maxBufferSize {
+				// 请求体过大，把已经读取的部分放回去，只发送一次
+				attemptReq := req.Clone(withAttempt(ctx, 1))
+				attemptReq.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(reqBody), req.Body), req.Body}
+				return next.RoundTrip(attemptReq)
+			}
+			getBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(reqBody)), nil
+			}
+			req.Body.Close()
+			firstBody, _ = getBody()
+		}
 	}
 
-	for attempt := 0; attempt <= rt.maxRetries; attempt++ {
-		// 如果不是第一次尝试，等待一段时间后再重试
-		if attempt > 0 {
-			time.Sleep(rt.retryDelay)
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		// 每次尝试使用新的请求副本，重新设置请求体
+		attemptReq := req.Clone(withAttempt(ctx, attempt+1))
+		if attempt == 0 {
+			attemptReq.Body = firstBody
+		} else if getBody != nil {
+			body, bodyErr := getBody()
+			if bodyErr != nil {
+				// 无法重新发送请求体，返回上一次的结果
+				return resp, err
+			}
+			attemptReq.Body = body
 		}
 
-		// 重新设置请求体
-		if reqBody != nil {
-			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+		// 关闭上一次的响应体以避免资源泄漏
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
 		}
 
-		resp, err = rt.delegate.RoundTrip(req)
+		resp, err = rt.roundTripAttempt(attemptReq, next)
 
 		// 检查是否需要重试
-		if !rt.shouldRetry(resp, err) || attempt == rt.maxRetries {
+		if !rt.shouldRetry(resp, err) || !breaker.Retryable(err) || retry.IsPermanent(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if attempt == rt.maxRetries {
 			break
 		}
 
-		// 关闭响应体以避免资源泄漏
-		if resp != nil && resp.Body != nil {
-			resp.Body.Close()
+		// 等待一段时间后再重试
+		timer := time.NewTimer(rt.retryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if resp != nil && resp.Body != nil {
+				resp.Body.Close()
+			}
+			return nil, errors.Join(ctx.Err(), err)
+		case <-timer.C:
 		}
 	}
 
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMaxRetriesReached, err)
+	}
+	return resp, nil
+}
+
+// roundTripAttempt 执行一次尝试，设置了单次超时时在请求上下文上加上截止时间，
+// 截止时间在响应体关闭时才释放，保证读取响应体同样受超时限制
+func (rt *RetryableTransport) roundTripAttempt(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if rt.attemptTimeout <= 0 || req.Context().Value(noAttemptTimeoutKey{}) != nil {
+		return next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), rt.attemptTimeout)
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
 	return resp, err
 }
 
+// cancelOnCloseBody 关闭响应体时释放单次尝试的上下文
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // NewRetryableClient 创建一个支持重试的HTTP客户端
 func NewRetryableClient(maxRetries int, retryDelay time.Duration) *http.Client {
 	return &http.Client{
@@ -175,6 +315,7 @@ func WithRetryAndLogging(maxRetries int, retryDelay time.Duration, logger func(l
 }
 
 // RateLimitTransport 实现限流的HTTP传输
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放入 HTTPClient 的拦截器链
 type RateLimitTransport struct {
 	delegate   http.RoundTripper
 	ticker     *time.Ticker
@@ -183,6 +324,7 @@ type RateLimitTransport struct {
 
 // NewRateLimitTransport 创建一个支持限流的HTTP传输
 // maxQPS: 每秒最大请求数
+// 作为拦截器使用时 delegate 可以传 nil
 func NewRateLimitTransport(delegate http.RoundTripper, maxQPS int) *RateLimitTransport {
 	if delegate == nil {
 		delegate = http.DefaultTransport
@@ -225,11 +367,23 @@ func NewRateLimitTransport(delegate http.RoundTripper, maxQPS int) *RateLimitTra
 
 // RoundTrip 实现http.RoundTripper接口，支持限流
 func (rt *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// 获取令牌，如果没有令牌可用，会阻塞
-	<-rt.reqChannel
+	return rt.Intercept(req, rt.delegate)
+}
+
+// Intercept 实现Interceptor接口，获取令牌后交给 next
+func (rt *RateLimitTransport) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	// 获取令牌，如果没有令牌可用，会阻塞直到 ctx 结束
+	select {
+	case <-rt.reqChannel:
+	case <-req.Context().Done():
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, req.Context().Err()
+	}
 
 	// 发送请求
-	return rt.delegate.RoundTrip(req)
+	return next.RoundTrip(req)
 }
 
 // Close 关闭限流传输
@@ -0,0 +1,443 @@
+package nettest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInteractionNotFound 回放时 cassette 中没有匹配请求的记录
+var ErrInteractionNotFound = errors.New("ggu: cassette 中没有匹配请求的记录")
+
+// Redacted 脱敏后替换敏感值使用的字符串
+const Redacted = "[REDACTED]"
+
+// Mode Recorder 的工作模式
+type Mode int
+
+const (
+	// ModeReplay 只回放，cassette 文件不存在时返回错误，不会发送真实请求
+	ModeReplay Mode = iota
+	// ModeRecord 发送真实请求并录制，Stop 时覆盖 cassette 文件
+	ModeRecord
+	// ModeReplayOrRecord cassette 文件存在时回放，否则录制
+	ModeReplayOrRecord
+)
+
+// RecordedRequest 录制的请求
+type RecordedRequest struct {
+	Method       string      `json:"method" yaml:"method"`
+	URL          string      `json:"url" yaml:"url"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"` // 二进制内容为 "base64"
+}
+
+// RecordedResponse 录制的响应
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code" yaml:"status_code"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// Interaction 一次请求和响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+// Cassette 录制的全部交互
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// RequestMatcher 回放时判断请求是否与录制的请求匹配
+type RequestMatcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// DefaultMatcher 默认的匹配规则：请求方法和URL（包括查询参数）相同
+// 被脱敏的查询参数只比较参数是否存在
+func DefaultMatcher(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	if req.URL.Scheme != u.Scheme || req.URL.Host != u.Host || req.URL.Path != u.Path {
+		return false
+	}
+	got, want := req.URL.Query(), u.Query()
+	if len(got) != len(want) {
+		return false
+	}
+	for k, vs := range want {
+		if len(vs) == 1 && vs[0] == Redacted {
+			if _, ok := got[k]; !ok {
+				return false
+			}
+			continue
+		}
+		if strings.Join(got[k], "\x00") != strings.Join(vs, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// Recorder 录制和回放HTTP交互
+// 录制时请求交给真实的传输，交互在脱敏后保存；回放时按录制的顺序查找第一个未使用且匹配的记录，
+// 不会发送任何真实请求。文件扩展名为 .yaml 或 .yml 时使用 YAML，否则使用 JSON。
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放在 HTTPClient 拦截器链的最后
+type Recorder struct {
+	path          string
+	mode          Mode
+	delegate      http.RoundTripper
+	matcher       RequestMatcher
+	redactHeaders map[string]bool
+	redactQuery   map[string]bool
+	redactJSON    map[string]bool
+	sanitizers    []func(*Interaction)
+	recording     bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// RecorderOption Recorder 的配置选项
+type RecorderOption func(*Recorder)
+
+// WithRealTransport 设置录制时使用的真实传输，默认使用 http.DefaultTransport
+func WithRealTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		if rt != nil {
+			r.delegate = rt
+		}
+	}
+}
+
+// WithMatcher 设置回放时匹配请求的规则，默认使用 DefaultMatcher
+func WithMatcher(m RequestMatcher) RecorderOption {
+	return func(r *Recorder) {
+		if m != nil {
+			r.matcher = m
+		}
+	}
+}
+
+// WithRedactHeaders 添加需要脱敏的请求头和响应头
+// 默认脱敏 Authorization、Cookie、Set-Cookie、X-API-Key 和 X-Signature
+func WithRedactHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithRedactQuery 添加需要脱敏的查询参数
+func WithRedactQuery(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactQuery[name] = true
+		}
+	}
+}
+
+// WithRedactJSONFields 添加需要脱敏的 JSON 字段，请求体和响应体中任意层级的同名字段都会被替换
+func WithRedactJSONFields(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, name := range names {
+			r.redactJSON[name] = true
+		}
+	}
+}
+
+// WithSanitizer 添加自定义的脱敏函数，在内置的脱敏规则之后调用
+func WithSanitizer(fn func(*Interaction)) RecorderOption {
+	return func(r *Recorder) {
+		r.sanitizers = append(r.sanitizers, fn)
+	}
+}
+
+// NewRecorder 创建录制器，回放模式下读取 cassette 文件
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		delegate: http.DefaultTransport,
+		matcher:  DefaultMatcher,
+		redactHeaders: map[string]bool{
+			"Authorization": true,
+			"Cookie":        true,
+			"Set-Cookie":    true,
+			"X-Api-Key":     true,
+			"X-Signature":   true,
+		},
+		redactQuery: make(map[string]bool),
+		redactJSON:  make(map[string]bool),
+		cassette:    &Cassette{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	switch mode {
+	case ModeRecord:
+		r.recording = true
+	case ModeReplay, ModeReplayOrRecord:
+		c, err := loadCassette(path)
+		if errors.Is(err, os.ErrNotExist) && mode == ModeReplayOrRecord {
+			r.recording = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	default:
+		return nil, fmt.Errorf("ggu: 未知的录制模式: %d", mode)
+	}
+	return r, nil
+}
+
+// Recording 是否处于录制状态
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// RoundTrip 实现http.RoundTripper接口
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.Intercept(req, r.delegate)
+}
+
+// Intercept 实现 net.Interceptor 接口，录制时把请求交给 next，回放时不调用 next
+func (r *Recorder) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.recording {
+		return r.record(req, body, next)
+	}
+	return r.replay(req, body)
+}
+
+// record 发送真实请求并保存交互
+func (r *Recorder) record(req *http.Request, body []byte, next http.RoundTripper) (*http.Response, error) {
+	realReq := req.Clone(req.Context())
+	realReq.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := next.RoundTrip(realReq)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	it := &Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone()},
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+	it.Request.Body, it.Request.BodyEncoding = encodeBody(body)
+	it.Response.Body, it.Response.BodyEncoding = encodeBody(respBody)
+	r.sanitize(it)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay 返回第一个未使用且匹配的记录
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || !r.matcher(req, body, &it.Request) {
+			continue
+		}
+		r.used[i] = true
+		respBody, err := decodeBody(it.Response.Body, it.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		header := it.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return newResponse(req, it.Response.StatusCode, header, respBody), nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+}
+
+// Stop 结束录制，录制模式下把交互写入 cassette 文件
+func (r *Recorder) Stop() error {
+	if !r.recording {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var data []byte
+	var err error
+	if isYAML(r.path) {
+		data, err = yaml.Marshal(r.cassette)
+	} else {
+		data, err = json.MarshalIndent(r.cassette, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// Unused 返回回放模式下没有被使用的记录数
+func (r *Recorder) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// sanitize 按配置脱敏交互
+func (r *Recorder) sanitize(it *Interaction) {
+	for _, h := range []http.Header{it.Request.Header, it.Response.Header} {
+		for name := range h {
+			if r.redactHeaders[name] {
+				h[name] = []string{Redacted}
+			}
+		}
+	}
+
+	if len(r.redactQuery) > 0 {
+		if u, err := url.Parse(it.Request.URL); err == nil {
+			q := u.Query()
+			for name := range q {
+				if r.redactQuery[name] {
+					q[name] = []string{Redacted}
+				}
+			}
+			u.RawQuery = q.Encode()
+			it.Request.URL = u.String()
+		}
+	}
+
+	if len(r.redactJSON) > 0 {
+		it.Request.Body = r.redactJSONBody(it.Request.Body, it.Request.BodyEncoding)
+		it.Response.Body = r.redactJSONBody(it.Response.Body, it.Response.BodyEncoding)
+	}
+
+	for _, fn := range r.sanitizers {
+		fn(it)
+	}
+}
+
+// redactJSONBody 替换 JSON 中需要脱敏的字段，不是 JSON 时原样返回
+func (r *Recorder) redactJSONBody(body, encoding string) string {
+	if encoding != "" || body == "" {
+		return body
+	}
+	// 使用 json.Number 保留数字的原文，超过 2^53 的整数 ID 不会因转换为 float64 而失真
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil || dec.Decode(&struct{}{}) != io.EOF {
+		return body
+	}
+	data, err := json.Marshal(r.redactValue(v))
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+func (r *Recorder) redactValue(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, child := range x {
+			if r.redactJSON[k] {
+				x[k] = Redacted
+			} else {
+				x[k] = r.redactValue(child)
+			}
+		}
+	case []any:
+		for i, child := range x {
+			x[i] = r.redactValue(child)
+		}
+	}
+	return v
+}
+
+// loadCassette 读取 cassette 文件
+func loadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, c)
+	} else {
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ggu: 解析 cassette %s 失败: %w", path, err)
+	}
+	return c, nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// encodeBody 文本内容原样保存，二进制内容使用 base64
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return nil, fmt.Errorf("ggu: 未知的响应体编码: %s", encoding)
+}
@@ -0,0 +1,158 @@
+package nettest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShopServer 返回订单信息和一张二进制图片的测试服务器，hits 记录收到的请求数
+func newShopServer(hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/orders":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "session=abc")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"o-1","card":{"number":"4111111111111111"},"request":` + string(body) + `}`))
+		case "/image.png":
+			_, _ = w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+// 测试录制后离线回放，cassette 文件中的敏感信息已脱敏
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	for _, name := range []string{"orders.json", "orders.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := newShopServer(&hits)
+			path := filepath.Join(t.TempDir(), "testdata", name)
+
+			rec, err := NewRecorder(path, ModeRecord, WithRedactJSONFields("number"), WithRedactQuery("token"))
+			require.NoError(t, err)
+			assert.True(t, rec.Recording())
+			client := ggunet.NewECommerceAPIClient(srv.URL, "key-1", "secret", time.Second, ggunet.WithTransport(rec))
+
+			order, err := client.CreateOrder(context.Background(), map[string]any{"sku": "A", "qty": 1})
+			require.NoError(t, err)
+			assert.Equal(t, "4111111111111111", order["card"].(map[string]any)["number"], "录制时返回真实的响应")
+
+			resp, err := get(t, rec, srv.URL+"/image.png?token=t-1")
+			require.NoError(t, err)
+			image := readBody(t, resp)
+			require.NoError(t, rec.Stop())
+			srv.Close()
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			for _, secret := range []string{"key-1", "4111111111111111", "session=abc", "t-1"} {
+				assert.NotContains(t, string(data), secret)
+			}
+			assert.Contains(t, string(data), Redacted)
+
+			// 服务器已关闭，回放不发送请求
+			rec, err = NewRecorder(path, ModeReplay)
+			require.NoError(t, err)
+			assert.False(t, rec.Recording())
+			client = ggunet.NewECommerceAPIClient(srv.URL, "key-1", "secret", time.Second, ggunet.WithTransport(rec))
+
+			order, err = client.CreateOrder(context.Background(), map[string]any{"sku": "A", "qty": 1})
+			require.NoError(t, err)
+			assert.Equal(t, "o-1", order["id"])
+			assert.Equal(t, Redacted, order["card"].(map[string]any)["number"])
+
+			resp, err = get(t, rec, srv.URL+"/image.png?token=other")
+			require.NoError(t, err)
+			assert.Equal(t, image, readBody(t, resp), "二进制内容原样回放")
+			assert.Equal(t, 0, rec.Unused())
+			assert.Equal(t, int32(2), hits.Load())
+
+			// 每条记录只回放一次
+			_, err = get(t, rec, srv.URL+"/image.png?token=t-1")
+			assert.ErrorIs(t, err, ErrInteractionNotFound)
+		})
+	}
+}
+
+// 测试 ModeReplayOrRecord 在文件不存在时录制，存在时回放
+func TestRecorder_ReplayOrRecord(t *testing.T) {
+	var hits atomic.Int32
+	srv := newShopServer(&hits)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "image.json")
+
+	for i := 0; i < 2; i++ {
+		rec, err := NewRecorder(path, ModeReplayOrRecord)
+		require.NoError(t, err)
+		assert.Equal(t, i == 0, rec.Recording())
+		resp, err := get(t, rec, srv.URL+"/image.png")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, rec.Stop())
+	}
+	assert.Equal(t, int32(1), hits.Load())
+
+	_, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// 测试自定义匹配规则和脱敏函数，以及作为拦截器使用
+func TestRecorder_Options(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.yml")
+	upstream := NewMockTransport()
+	upstream.On(http.MethodGet, "/products/*").Reply(http.StatusOK, map[string]any{"id": "p1", "owner": "alice@example.com"})
+
+	rec, err := NewRecorder(path, ModeRecord,
+		WithRealTransport(upstream),
+		WithRedactHeaders("X-Tenant"),
+		WithSanitizer(func(it *Interaction) {
+			it.Request.URL = "https://api.example.com/products/p1"
+		}),
+	)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/products/p1", nil)
+	req.Header.Set("X-Tenant", "shop-1")
+	_, err = rec.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, rec.Stop())
+
+	data, _ := os.ReadFile(path)
+	assert.NotContains(t, string(data), "shop-1")
+	assert.NotContains(t, string(data), "localhost")
+
+	// 只按路径匹配，忽略主机
+	rec, err = NewRecorder(path, ModeReplay, WithMatcher(func(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+		return filepath.Base(recorded.URL) == filepath.Base(req.URL.Path)
+	}))
+	require.NoError(t, err)
+	client := ggunet.NewHTTPClient(ggunet.WithBaseURL("http://127.0.0.1:1"), ggunet.WithInterceptors(rec))
+	var product map[string]any
+	require.NoError(t, client.GetJSON(context.Background(), "/products/p1", &product, nil))
+	assert.Equal(t, "p1", product["id"])
+}
+
+// 测试脱敏时数字保持原文，超过 2^53 的整数 ID 不会失真
+func TestRecorder_RedactKeepsNumbers(t *testing.T) {
+	r := &Recorder{redactJSON: map[string]bool{"number": true}}
+	body := r.redactJSONBody(`{"order_id":9007199254740993,"price":12.50,"card":{"number":"4111111111111111"}}`, "")
+	assert.JSONEq(t, `{"order_id":9007199254740993,"price":12.50,"card":{"number":"[REDACTED]"}}`, body)
+	assert.Contains(t, body, "9007199254740993")
+
+	// 不是单个 JSON 值时原样返回
+	assert.Equal(t, `{"id":1} {"id":2}`, r.redactJSONBody(`{"id":1} {"id":2}`, ""))
+}
@@ -0,0 +1,375 @@
+// Package nettest 提供离线测试HTTP客户端的工具：
+//   - MockTransport：按脚本返回响应的 http.RoundTripper，支持请求匹配、调用次数断言、延迟和故障注入
+//   - Recorder：把真实的请求和响应录制到脱敏后的 JSON/YAML 文件（cassette），之后确定性地回放
+//
+// 两者都可以通过 net.WithTransport 交给 net.HTTPClient 使用。
+package nettest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// ErrNoRoute 没有路由匹配请求
+var ErrNoRoute = errors.New("ggu: 没有匹配请求的路由")
+
+// TestingT testing.T 中断言使用的方法
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// Matcher 请求匹配函数，body 为请求体的内容
+type Matcher func(req *http.Request, body []byte) bool
+
+// MatchHeader 匹配请求头的值
+func MatchHeader(key, value string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		return req.Header.Get(key) == value
+	}
+}
+
+// MatchQuery 匹配查询参数的值
+func MatchQuery(key, value string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		return req.URL.Query().Get(key) == value
+	}
+}
+
+// MatchJSONBody 匹配 JSON 请求体，比较语义而不是字节，字段顺序和空白不影响结果
+// v 可以是 JSON 字符串、[]byte 或任意可以编码为 JSON 的值
+func MatchJSONBody(v any) Matcher {
+	want, err := normalizeJSON(v)
+	return func(_ *http.Request, body []byte) bool {
+		if err != nil {
+			return false
+		}
+		var got any
+		if json.Unmarshal(body, &got) != nil {
+			return false
+		}
+		return reflect.DeepEqual(want, got)
+	}
+}
+
+// normalizeJSON 把 v 转换为 json.Unmarshal 到 any 的结果
+func normalizeJSON(v any) (any, error) {
+	var data []byte
+	switch b := v.(type) {
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var out any
+	err := json.Unmarshal(data, &out)
+	return out, err
+}
+
+// step 路由的一次响应
+type step struct {
+	respond func(req *http.Request) (*http.Response, error)
+}
+
+// Route 一条模拟路由，通过 MockTransport.On 创建，方法可以链式调用
+type Route struct {
+	method   string
+	pattern  string
+	matchers []Matcher
+	steps    []step
+	header   http.Header
+	latency  time.Duration
+	times    int // 期望的调用次数，0 表示至少一次
+	calls    int
+}
+
+// Match 添加自定义的匹配条件
+func (r *Route) Match(m Matcher) *Route {
+	r.matchers = append(r.matchers, m)
+	return r
+}
+
+// WithHeader 要求请求头等于 value
+func (r *Route) WithHeader(key, value string) *Route {
+	return r.Match(MatchHeader(key, value))
+}
+
+// WithQuery 要求查询参数等于 value
+func (r *Route) WithQuery(key, value string) *Route {
+	return r.Match(MatchQuery(key, value))
+}
+
+// WithJSONBody 要求 JSON 请求体与 v 语义相等
+func (r *Route) WithJSONBody(v any) *Route {
+	return r.Match(MatchJSONBody(v))
+}
+
+// Reply 添加一次响应，body 为 string 或 []byte 时原样返回，其他值编码为 JSON
+// 多次调用 Reply、Fail 或 ReplyFunc 时按顺序返回，用完后重复最后一个
+func (r *Route) Reply(status int, body any) *Route {
+	var data []byte
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			panic(fmt.Sprintf("nettest: 编码响应体失败: %v", err))
+		}
+		contentType = "application/json"
+	}
+	header := r.header.Clone()
+	return r.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		h := header.Clone()
+		if contentType != "" && h.Get("Content-Type") == "" {
+			h.Set("Content-Type", contentType)
+		}
+		return newResponse(req, status, h, data), nil
+	})
+}
+
+// ReplyHeader 设置之后添加的响应使用的响应头
+func (r *Route) ReplyHeader(key, value string) *Route {
+	r.header.Set(key, value)
+	return r
+}
+
+// ReplyFunc 添加一次由函数生成的响应
+func (r *Route) ReplyFunc(fn func(req *http.Request) (*http.Response, error)) *Route {
+	r.steps = append(r.steps, step{respond: fn})
+	return r
+}
+
+// Fail 添加一次返回 err 的响应，用于模拟网络错误
+func (r *Route) Fail(err error) *Route {
+	return r.ReplyFunc(func(*http.Request) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+// Delay 设置每次响应前等待的时间，等待期间请求的 ctx 结束时返回 ctx 的错误
+func (r *Route) Delay(d time.Duration) *Route {
+	r.latency = d
+	return r
+}
+
+// Times 设置路由期望被调用的次数，调用 n 次后不再匹配请求
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 等价于 Times(1)
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// String 返回路由的描述
+func (r *Route) String() string {
+	return r.method + " " + r.pattern
+}
+
+// matches 判断请求是否匹配路由，调用方需持有锁
+func (r *Route) matches(req *http.Request, body []byte) bool {
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != "*" && !strings.EqualFold(r.method, req.Method) {
+		return false
+	}
+	if ok, _ := path.Match(r.pattern, req.URL.Path); !ok {
+		return false
+	}
+	for _, m := range r.matchers {
+		if !m(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// Call 一次收到的请求
+type Call struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	Route  *Route // 匹配的路由，没有匹配时为 nil
+}
+
+// MockTransport 按脚本返回响应的 http.RoundTripper
+// 路由按添加顺序匹配，第一个匹配的路由生成响应；没有路由匹配时返回 ErrNoRoute。
+// 既可以作为 http.RoundTripper 使用，也可以作为拦截器放在 HTTPClient 拦截器链的最后
+type MockTransport struct {
+	mu     sync.Mutex
+	routes []*Route
+	calls  []Call
+	clock  clock.Clock
+}
+
+// MockOption MockTransport 的配置选项
+type MockOption func(*MockTransport)
+
+// WithClock 设置模拟延迟使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) MockOption {
+	return func(m *MockTransport) {
+		m.clock = clock.OrReal(clk)
+	}
+}
+
+// NewMockTransport 创建模拟传输
+func NewMockTransport(opts ...MockOption) *MockTransport {
+	m := &MockTransport{clock: clock.Real}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// On 添加一条路由，method 为空或 "*" 时匹配任意方法，pattern 使用 path.Match 的语法匹配URL路径，
+// 例如 "/products/*" 匹配 "/products/1"；没有设置响应的路由返回 200 和空响应体
+func (m *MockTransport) On(method, pattern string) *Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &Route{method: method, pattern: pattern, header: http.Header{}}
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// RoundTrip 实现http.RoundTripper接口
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	var route *Route
+	for _, r := range m.routes {
+		if r.matches(req, body) {
+			route = r
+			break
+		}
+	}
+	m.calls = append(m.calls, Call{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body, Route: route})
+	if route == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRoute, req.Method, req.URL)
+	}
+	route.calls++
+	var s *step
+	if len(route.steps) > 0 {
+		s = &route.steps[min(route.calls, len(route.steps))-1]
+	}
+	latency := route.latency
+	m.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-m.clock.After(latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	replayReq := req.Clone(req.Context())
+	replayReq.Body = io.NopCloser(bytes.NewReader(body))
+	if s == nil {
+		return newResponse(req, http.StatusOK, http.Header{}, nil), nil
+	}
+	return s.respond(replayReq)
+}
+
+// Intercept 实现 net.Interceptor 接口，不调用 next，直接返回模拟的响应
+func (m *MockTransport) Intercept(req *http.Request, _ http.RoundTripper) (*http.Response, error) {
+	return m.RoundTrip(req)
+}
+
+// Calls 返回收到的全部请求
+func (m *MockTransport) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount 返回路由被调用的次数
+func (m *MockTransport) CallCount(r *Route) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return r.calls
+}
+
+// AssertExpectations 检查每条路由的调用次数：设置了 Times 的路由必须恰好被调用 n 次，
+// 其他路由至少被调用一次；同时检查没有未匹配任何路由的请求
+func (m *MockTransport) AssertExpectations(t TestingT) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, r := range m.routes {
+		switch {
+		case r.times > 0 && r.calls != r.times:
+			t.Errorf("nettest: 路由 %s 期望调用 %d 次，实际 %d 次", r, r.times, r.calls)
+			ok = false
+		case r.times == 0 && r.calls == 0:
+			t.Errorf("nettest: 路由 %s 没有被调用", r)
+			ok = false
+		}
+	}
+	for _, c := range m.calls {
+		if c.Route == nil {
+			t.Errorf("nettest: 请求 %s %s 没有匹配的路由", c.Method, c.URL)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Reset 清除全部路由和调用记录
+func (m *MockTransport) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = nil
+	m.calls = nil
+}
+
+// newResponse 创建响应
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
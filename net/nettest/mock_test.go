@@ -0,0 +1,206 @@
+package nettest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT 记录断言失败的信息
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return rt.RoundTrip(req)
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// 测试按方法、路径模式和请求内容匹配路由
+func TestMockTransport_Routing(t *testing.T) {
+	m := NewMockTransport()
+	m.On(http.MethodGet, "/products/*").WithQuery("lang", "en").Reply(http.StatusOK, "english")
+	m.On(http.MethodGet, "/products/*").Reply(http.StatusOK, map[string]any{"id": "p1"})
+	m.On(http.MethodPost, "/orders").WithHeader("X-Tenant", "shop-1").WithJSONBody(`{"sku":"A","qty":2}`).Reply(http.StatusCreated, nil)
+
+	resp, err := get(t, m, "http://api.test/products/1?lang=en")
+	require.NoError(t, err)
+	assert.Equal(t, "english", readBody(t, resp))
+
+	resp, err = get(t, m, "http://api.test/products/1")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"p1"}`, readBody(t, resp))
+
+	// JSON 请求体按语义比较
+	req, _ := http.NewRequest(http.MethodPost, "http://api.test/orders", strings.NewReader(`{ "qty": 2, "sku": "A" }`))
+	req.Header.Set("X-Tenant", "shop-1")
+	resp, err = m.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	req, _ = http.NewRequest(http.MethodPost, "http://api.test/orders", strings.NewReader(`{"sku":"B","qty":2}`))
+	req.Header.Set("X-Tenant", "shop-1")
+	_, err = m.RoundTrip(req)
+	assert.ErrorIs(t, err, ErrNoRoute)
+
+	// 路径模式不跨越 "/"
+	_, err = get(t, m, "http://api.test/products/1/reviews")
+	assert.ErrorIs(t, err, ErrNoRoute)
+
+	calls := m.Calls()
+	require.Len(t, calls, 5)
+	assert.Equal(t, `{"sku":"B","qty":2}`, string(calls[3].Body))
+	assert.Nil(t, calls[3].Route)
+}
+
+// 测试响应序列、故障注入和调用次数
+func TestMockTransport_Sequence(t *testing.T) {
+	m := NewMockTransport()
+	boom := errors.New("connection reset")
+	r := m.On("*", "/stock").
+		Fail(boom).
+		Reply(http.StatusServiceUnavailable, "busy").
+		ReplyHeader("X-Stock", "5").
+		Reply(http.StatusOK, "5")
+
+	_, err := get(t, m, "http://api.test/stock")
+	assert.ErrorIs(t, err, boom)
+	resp, err := get(t, m, "http://api.test/stock")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Stock"), "ReplyHeader 只影响之后添加的响应")
+	for i := 0; i < 2; i++ {
+		resp, err = get(t, m, "http://api.test/stock")
+		require.NoError(t, err)
+		assert.Equal(t, "5", resp.Header.Get("X-Stock"))
+		assert.Equal(t, "5", readBody(t, resp), "用完后重复最后一个响应")
+	}
+	assert.Equal(t, 4, m.CallCount(r))
+
+	m.Reset()
+	assert.Empty(t, m.Calls())
+	_, err = get(t, m, "http://api.test/stock")
+	assert.ErrorIs(t, err, ErrNoRoute)
+}
+
+// 测试 Times 限制匹配次数以及 AssertExpectations 的报告
+func TestMockTransport_AssertExpectations(t *testing.T) {
+	m := NewMockTransport()
+	m.On(http.MethodGet, "/token").Once().Reply(http.StatusOK, "t1")
+	m.On(http.MethodGet, "/token").Reply(http.StatusOK, "t2")
+	m.On(http.MethodGet, "/never")
+
+	resp, _ := get(t, m, "http://api.test/token")
+	assert.Equal(t, "t1", readBody(t, resp))
+	resp, _ = get(t, m, "http://api.test/token")
+	assert.Equal(t, "t2", readBody(t, resp))
+	_, _ = get(t, m, "http://api.test/unknown")
+
+	rt := &recordingT{}
+	assert.False(t, m.AssertExpectations(rt))
+	require.Len(t, rt.errors, 2)
+	assert.Contains(t, rt.errors[0], "GET /never")
+	assert.Contains(t, rt.errors[1], "/unknown")
+
+	m = NewMockTransport()
+	m.On(http.MethodGet, "/a").Times(2)
+	_, _ = get(t, m, "http://api.test/a")
+	rt = &recordingT{}
+	assert.False(t, m.AssertExpectations(rt))
+	assert.Contains(t, rt.errors[0], "期望调用 2 次，实际 1 次")
+
+	_, _ = get(t, m, "http://api.test/a")
+	assert.True(t, m.AssertExpectations(t))
+}
+
+// 测试使用假时钟模拟延迟，以及延迟期间 ctx 取消
+func TestMockTransport_Delay(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	m := NewMockTransport(WithClock(clk))
+	m.On(http.MethodGet, "/slow").Delay(time.Second).Reply(http.StatusOK, "ok")
+
+	done := make(chan string)
+	go func() {
+		resp, err := get(t, m, "http://api.test/slow")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		done <- readBody(t, resp)
+	}()
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	assert.Equal(t, "ok", <-done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://api.test/slow", nil)
+	errc := make(chan error)
+	go func() {
+		_, err := m.RoundTrip(req)
+		errc <- err
+	}()
+	clk.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
+
+// 测试通过 net.WithTransport 把 MockTransport 交给 HTTPClient，重试和拦截器照常工作
+func TestMockTransport_WithHTTPClient(t *testing.T) {
+	m := NewMockTransport()
+	m.On(http.MethodGet, "/products/p1").
+		Fail(errors.New("connection reset")).
+		Reply(http.StatusOK, map[string]any{"id": "p1", "name": "Keyboard"})
+	m.On(http.MethodPut, "/products/p1/inventory").WithJSONBody(map[string]any{"quantity": 7}).Reply(http.StatusOK, map[string]any{"ok": true})
+
+	client := ggunet.NewECommerceAPIClient("https://shop.example.com", "key-1", "secret", time.Second,
+		ggunet.WithTransport(m), ggunet.WithRetryInterval(time.Millisecond))
+
+	product, err := client.GetProduct(context.Background(), "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "Keyboard", product["name"])
+
+	res, err := client.UpdateInventory(context.Background(), "p1", 7)
+	require.NoError(t, err)
+	assert.Equal(t, true, res["ok"])
+	m.AssertExpectations(t)
+
+	calls := m.Calls()
+	require.Len(t, calls, 3)
+	for _, c := range calls {
+		assert.Equal(t, "key-1", c.Header.Get("X-API-Key"))
+		assert.NotEmpty(t, c.Header.Get(ggunet.HeaderSignature), "每次尝试都重新签名")
+	}
+
+	// 作为拦截器使用
+	m2 := NewMockTransport()
+	m2.On(http.MethodGet, "/ping").Reply(http.StatusOK, "pong")
+	c2 := ggunet.NewHTTPClient(ggunet.WithBaseURL("https://shop.example.com"), ggunet.WithInterceptors(m2))
+	resp, err := c2.Get(context.Background(), "/ping", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", readBody(t, resp))
+}
@@ -0,0 +1,129 @@
+package net
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeQuery 把结构体按 query 标签编码为查询参数
+// 标签格式为 `query:"name,omitempty"`，"-" 表示忽略该字段，没有标签时使用字段名；
+// 支持字符串、整数、浮点数、布尔值、time.Time（RFC3339）、实现 encoding.TextMarshaler 或 fmt.Stringer 的类型，
+// 切片编码为重复的参数，nil 指针忽略，匿名嵌入的结构体展开
+func EncodeQuery(v any) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ggu: 查询参数必须是结构体，实际为 %s", rv.Kind())
+	}
+	if err := encodeStruct(values, rv); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func encodeStruct(values url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("query")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitempty := opts == "omitempty"
+		fv := rv.Field(i)
+
+		// 匿名嵌入且没有标签的结构体展开
+		if field.Anonymous && name == "" {
+			for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := encodeStruct(values, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitempty && fv.IsZero() {
+			continue
+		}
+		if err := encodeValue(values, name, fv); err != nil {
+			return fmt.Errorf("ggu: 编码查询参数 %s 失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func encodeValue(values url.Values, name string, fv reflect.Value) error {
+	for fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < fv.Len(); i++ {
+			if err := encodeValue(values, name, fv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := formatValue(fv)
+	if err != nil {
+		return err
+	}
+	values.Add(name, s)
+	return nil
+}
+
+func formatValue(fv reflect.Value) (string, error) {
+	if fv.CanInterface() {
+		switch v := fv.Interface().(type) {
+		case time.Time:
+			return v.Format(time.RFC3339), nil
+		case encoding.TextMarshaler:
+			b, err := v.MarshalText()
+			return string(b), err
+		case fmt.Stringer:
+			return v.String(), nil
+		}
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		// []byte 按字符串编码
+		return string(fv.Bytes()), nil
+	default:
+		return "", fmt.Errorf("不支持的类型 %s", fv.Type())
+	}
+}
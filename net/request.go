@@ -0,0 +1,537 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// bodySnippetLimit HTTPError 中保留的响应体最大字节数
+const bodySnippetLimit = 4 << 10
+
+// HTTPError 非2xx响应对应的错误，可以通过 errors.As 获取
+// errors.Is(err, ErrInvalidResponse) 同样成立
+type HTTPError struct {
+	StatusCode int         // 状态码
+	Status     string      // 状态行，例如 "404 Not Found"
+	Method     string      // 请求方法
+	URL        string      // 请求URL
+	Header     http.Header // 响应头
+	Body       []byte      // 响应体，最多保留 4KB
+}
+
+// Error 实现error接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("ggu: %s %s 返回 %s: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// Unwrap 使 errors.Is(err, ErrInvalidResponse) 成立
+func (e *HTTPError) Unwrap() error {
+	return ErrInvalidResponse
+}
+
+// newHTTPError 读取响应体的前 4KB 创建HTTPError
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, bodySnippetLimit))
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+	return e
+}
+
+// quoteEscaper 转义 Content-Disposition 中的引号和反斜杠
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// MultipartFile multipart 请求中的一个文件
+// Open 和 Reader 至少设置一个，两者都设置时优先使用 Open；
+// 流式上传时只有设置了 Open 的文件可以在重试时重新发送
+type MultipartFile struct {
+	FieldName   string                        // 表单字段名
+	FileName    string                        // 文件名
+	ContentType string                        // 文件的 MIME 类型，为空时使用 application/octet-stream
+	Reader      io.Reader                     // 文件内容
+	Open        func() (io.ReadCloser, error) // 每次调用返回一个新的文件内容读取器，例如 os.Open
+	Size        int64                         // 文件大小，流式上传时所有文件都设置了大小才会设置 Content-Length
+}
+
+// open 打开文件内容，replay 为 true 表示重新发送，此时只能使用 Open
+func (f MultipartFile) open(replay bool) (io.ReadCloser, error) {
+	if f.Open != nil {
+		return f.Open()
+	}
+	if replay || f.Reader == nil {
+		return nil, fmt.Errorf("%w: 文件 %s 没有设置 Open", ErrBodyNotReplayable, f.FileName)
+	}
+	return io.NopCloser(f.Reader), nil
+}
+
+// createPart 创建文件对应的 part
+func (f MultipartFile) createPart(w *multipart.Writer) (io.Writer, error) {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(f.FieldName), quoteEscaper.Replace(f.FileName)))
+	h.Set("Content-Type", contentType)
+	return w.CreatePart(h)
+}
+
+// ProgressFunc 传输进度回调，transferred 为已传输的字节数，total 为总字节数，未知时为 -1
+type ProgressFunc func(transferred, total int64)
+
+// progressReader 读取时报告进度
+type progressReader struct {
+	io.ReadCloser
+	fn          ProgressFunc
+	transferred int64
+	total       int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.transferred += int64(n)
+		p.fn(p.transferred, p.total)
+	}
+	return n, err
+}
+
+// requestBody 请求体，open 每次调用都返回新的读取器，用于设置 GetBody 以便重试时重新发送
+type requestBody struct {
+	contentType string
+	length      int64 // -1 表示未知
+	open        func() (io.ReadCloser, error)
+}
+
+// bytesBody 创建内容固定的请求体
+func bytesBody(data []byte, contentType string) *requestBody {
+	return &requestBody{
+		contentType: contentType,
+		length:      int64(len(data)),
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+// Request 类型化的请求构造器，T 为响应体解码后的类型
+// 构造过程中的错误会在 Do 或 Send 时返回
+type Request[T any] struct {
+	client           *HTTPClient
+	method           string
+	path             string
+	pathParams       map[string]string
+	query            url.Values
+	header           http.Header
+	codec            Codec
+	body             func() (*requestBody, error)
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
+	err              error
+}
+
+// NewRequest 创建类型化的请求，path 中可以包含 {name} 形式的路径参数
+func NewRequest[T any](c *HTTPClient, method, path string) *Request[T] {
+	return &Request[T]{
+		client:     c,
+		method:     method,
+		path:       path,
+		pathParams: make(map[string]string),
+		query:      url.Values{},
+		header:     http.Header{},
+		codec:      JSONCodec,
+	}
+}
+
+// Get 创建GET请求
+func Get[T any](c *HTTPClient, path string) *Request[T] {
+	return NewRequest[T](c, http.MethodGet, path)
+}
+
+// Post 创建POST请求
+func Post[T any](c *HTTPClient, path string) *Request[T] {
+	return NewRequest[T](c, http.MethodPost, path)
+}
+
+// Put 创建PUT请求
+func Put[T any](c *HTTPClient, path string) *Request[T] {
+	return NewRequest[T](c, http.MethodPut, path)
+}
+
+// Delete 创建DELETE请求
+func Delete[T any](c *HTTPClient, path string) *Request[T] {
+	return NewRequest[T](c, http.MethodDelete, path)
+}
+
+// PathParam 设置路径参数，替换 path 中的 {name}，值会被转义
+func (r *Request[T]) PathParam(name, value string) *Request[T] {
+	r.pathParams[name] = value
+	return r
+}
+
+// Query 添加查询参数
+func (r *Request[T]) Query(key, value string) *Request[T] {
+	r.query.Add(key, value)
+	return r
+}
+
+// QueryStruct 按 query 标签把结构体编码为查询参数，规则见 EncodeQuery
+func (r *Request[T]) QueryStruct(v any) *Request[T] {
+	values, err := EncodeQuery(v)
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return r
+	}
+	for k, vs := range values {
+		r.query[k] = append(r.query[k], vs...)
+	}
+	return r
+}
+
+// Header 设置请求头，覆盖客户端的默认请求头
+func (r *Request[T]) Header(key, value string) *Request[T] {
+	r.header.Set(key, value)
+	return r
+}
+
+// Codec 设置请求体和响应体的编解码器，默认为 JSONCodec
+// 响应的 Content-Type 为 JSON、XML 或 msgpack 时按 Content-Type 解码，否则使用该编解码器
+func (r *Request[T]) Codec(c Codec) *Request[T] {
+	if c != nil {
+		r.codec = c
+	}
+	return r
+}
+
+// Body 设置请求体，使用编解码器编码
+func (r *Request[T]) Body(v any) *Request[T] {
+	r.body = func() (*requestBody, error) {
+		data, err := r.codec.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("ggu: 编码请求体失败: %w", err)
+		}
+		return bytesBody(data, r.codec.ContentType()), nil
+	}
+	return r
+}
+
+// Form 设置表单请求体
+func (r *Request[T]) Form(values url.Values) *Request[T] {
+	r.body = func() (*requestBody, error) {
+		return bytesBody([]byte(values.Encode()), "application/x-www-form-urlencoded"), nil
+	}
+	return r
+}
+
+// Multipart 设置 multipart/form-data 请求体
+// 请求体会被完整读入内存，以便重试时重新发送，大文件应使用 StreamMultipart
+func (r *Request[T]) Multipart(fields map[string]string, files ...MultipartFile) *Request[T] {
+	r.body = func() (*requestBody, error) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range files {
+			part, err := f.createPart(w)
+			if err != nil {
+				return nil, err
+			}
+			if err = copyFile(part, f, false); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return bytesBody(buf.Bytes(), w.FormDataContentType()), nil
+	}
+	return r
+}
+
+// StreamMultipart 设置流式发送的 multipart/form-data 请求体，文件内容边读边发送，不会读入内存
+// 重试时通过 MultipartFile.Open 重新打开文件，有文件只设置了 Reader 时请求不会重试；
+// 所有文件都设置了 Size 时会计算并设置 Content-Length
+func (r *Request[T]) StreamMultipart(fields map[string]string, files ...MultipartFile) *Request[T] {
+	r.body = func() (*requestBody, error) {
+		boundary := multipart.NewWriter(io.Discard).Boundary()
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		// write 写入完整的请求体，files 为 nil 时只写入各个 part 的头部，用于计算长度
+		write := func(dst io.Writer, files []MultipartFile, replay bool) error {
+			w := multipart.NewWriter(dst)
+			if err := w.SetBoundary(boundary); err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if err := w.WriteField(k, fields[k]); err != nil {
+					return err
+				}
+			}
+			for _, f := range files {
+				part, err := f.createPart(w)
+				if err != nil {
+					return err
+				}
+				if f.Open == nil && f.Reader == nil {
+					continue
+				}
+				if err = copyFile(part, f, replay); err != nil {
+					return err
+				}
+			}
+			return w.Close()
+		}
+
+		length := int64(-1)
+		if sizes, ok := totalSize(files); ok {
+			var counter countingWriter
+			headers := make([]MultipartFile, len(files))
+			for i, f := range files {
+				headers[i] = MultipartFile{FieldName: f.FieldName, FileName: f.FileName, ContentType: f.ContentType}
+			}
+			if err := write(&counter, headers, false); err != nil {
+				return nil, err
+			}
+			length = counter.n + sizes
+		}
+
+		var mu sync.Mutex
+		opened := false
+		open := func() (io.ReadCloser, error) {
+			mu.Lock()
+			replay := opened
+			opened = true
+			mu.Unlock()
+			if replay {
+				for _, f := range files {
+					if f.Open == nil {
+						return nil, fmt.Errorf("%w: 文件 %s 没有设置 Open", ErrBodyNotReplayable, f.FileName)
+					}
+				}
+			}
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(write(pw, files, replay))
+			}()
+			return pr, nil
+		}
+		return &requestBody{contentType: "multipart/form-data; boundary=" + boundary, length: length, open: open}, nil
+	}
+	return r
+}
+
+// copyFile 把文件内容写入 part
+func copyFile(dst io.Writer, f MultipartFile, replay bool) error {
+	rc, err := f.open(replay)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if _, err = io.Copy(dst, rc); err != nil {
+		return fmt.Errorf("ggu: 读取文件 %s 失败: %w", f.FileName, err)
+	}
+	return nil
+}
+
+// totalSize 返回所有文件的大小之和，有文件未设置大小时返回 false
+func totalSize(files []MultipartFile) (int64, bool) {
+	var total int64
+	for _, f := range files {
+		if f.Size <= 0 {
+			return 0, false
+		}
+		total += f.Size
+	}
+	return total, true
+}
+
+// countingWriter 只统计写入的字节数
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// UploadProgress 设置上传进度回调，每次重试都从 0 开始报告
+func (r *Request[T]) UploadProgress(fn ProgressFunc) *Request[T] {
+	r.uploadProgress = fn
+	return r
+}
+
+// DownloadProgress 设置下载进度回调，在读取响应体时报告
+func (r *Request[T]) DownloadProgress(fn ProgressFunc) *Request[T] {
+	r.downloadProgress = fn
+	return r
+}
+
+// Send 发送请求并返回原始响应，不检查状态码，调用方负责关闭响应体
+func (r *Request[T]) Send(ctx context.Context) (*http.Response, error) {
+	req, err := r.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.downloadProgress != nil {
+		resp.Body = &progressReader{ReadCloser: resp.Body, fn: r.downloadProgress, total: resp.ContentLength}
+	}
+	return resp, nil
+}
+
+// Do 发送请求并把响应体解码为 T
+//   - 非2xx响应返回 *HTTPError
+//   - T 为 []byte 或 string 时直接返回响应体
+//   - 响应体为空时返回 T 的零值
+func (r *Request[T]) Do(ctx context.Context) (T, error) {
+	var result T
+	resp, err := r.Send(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return result, newHTTPError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("%w: %w", ErrResponseDecode, err)
+	}
+	switch p := any(&result).(type) {
+	case *[]byte:
+		*p = data
+		return result, nil
+	case *string:
+		*p = string(data)
+		return result, nil
+	}
+	if len(data) == 0 {
+		return result, nil
+	}
+	c := codecFor(resp.Header.Get("Content-Type"), r.codec)
+	if err = c.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("%w: %w", ErrResponseDecode, err)
+	}
+	return result, nil
+}
+
+// build 构建 http.Request
+func (r *Request[T]) build(ctx context.Context) (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	path := r.path
+	for name, value := range r.pathParams {
+		placeholder := "{" + name + "}"
+		if !strings.Contains(path, placeholder) {
+			return nil, fmt.Errorf("%w: 路径中没有参数 %s", ErrInvalidURL, placeholder)
+		}
+		path = strings.ReplaceAll(path, placeholder, url.PathEscape(value))
+	}
+	if i := strings.Index(path, "{"); i >= 0 && strings.Contains(path[i:], "}") {
+		return nil, fmt.Errorf("%w: 路径参数未设置: %s", ErrInvalidURL, path)
+	}
+
+	fullURL, err := r.client.buildURL(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(fullURL, "?") {
+			sep = "&"
+		}
+		fullURL += sep + r.query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentType string
+	if r.body != nil {
+		body, err := r.body()
+		if err != nil {
+			return nil, err
+		}
+		contentType = body.contentType
+		if err = r.setBody(req, body); err != nil {
+			return nil, err
+		}
+	}
+
+	// 设置默认头信息
+	for k, v := range r.client.defaultHeaders {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", r.codec.ContentType())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	// 设置自定义头信息
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+	return req, nil
+}
+
+// setBody 设置请求体和 GetBody，有上传进度回调时包装读取器
+func (r *Request[T]) setBody(req *http.Request, body *requestBody) error {
+	open := body.open
+	if r.uploadProgress != nil {
+		open = func() (io.ReadCloser, error) {
+			rc, err := body.open()
+			if err != nil {
+				return nil, err
+			}
+			return &progressReader{ReadCloser: rc, fn: r.uploadProgress, total: body.length}, nil
+		}
+	}
+	if body.length == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return nil
+	}
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	req.Body = rc
+	req.GetBody = open
+	req.ContentLength = body.length
+	return nil
+}
@@ -0,0 +1,320 @@
+package net
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type product struct {
+	ID    string  `json:"id" xml:"id"`
+	Name  string  `json:"name" xml:"name"`
+	Price float64 `json:"price" xml:"price"`
+}
+
+// 测试路径参数、查询结构体、JSON请求体和类型化解码
+func TestRequest_JSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/shops/a%2Fb/products", r.URL.EscapedPath())
+		assert.Equal(t, "category=shoes&page=2&since=2024-01-01T00%3A00%3A00Z&sku=A1&sku=B2", r.URL.RawQuery)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "trace-1", r.Header.Get("X-Trace-ID"))
+		assert.Equal(t, "SDK/1.0", r.Header.Get("User-Agent"))
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"id":"","name":"跑鞋","price":299}`, string(body))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = io.WriteString(w, `{"id":"p-1","name":"跑鞋","price":299}`)
+	}))
+	defer srv.Close()
+
+	type filter struct {
+		Category string    `query:"category"`
+		Page     int       `query:"page,omitempty"`
+		Size     int       `query:"size,omitempty"`
+		SKUs     []string  `query:"sku"`
+		Since    time.Time `query:"since"`
+		Internal string    `query:"-"`
+		Cursor   *string   `query:"cursor"`
+	}
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithDefaultHeader("User-Agent", "SDK/1.0"))
+	p, err := Post[product](client, "/shops/{shop}/products").
+		PathParam("shop", "a/b").
+		QueryStruct(filter{Category: "shoes", Page: 2, SKUs: []string{"A1", "B2"},
+			Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Internal: "x"}).
+		Header("X-Trace-ID", "trace-1").
+		Body(product{Name: "跑鞋", Price: 299}).
+		Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, product{ID: "p-1", Name: "跑鞋", Price: 299}, p)
+}
+
+// 测试非2xx响应返回 HTTPError
+func TestRequest_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-9")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":"商品不存在"}`+strings.Repeat(" ", 8<<10))
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	_, err := Get[product](client, "/products/{id}").PathParam("id", "404").Do(context.Background())
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, "req-9", httpErr.Header.Get("X-Request-ID"))
+	assert.Len(t, httpErr.Body, bodySnippetLimit)
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Contains(t, httpErr.Error(), "商品不存在")
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	// 旧接口同样返回 HTTPError
+	err = client.GetJSON(context.Background(), "/products/404", &map[string]any{}, nil)
+	require.ErrorAs(t, err, &httpErr)
+}
+
+// 测试 XML 和 msgpack 编解码，以及按响应的 Content-Type 选择解码器
+func TestRequest_Codecs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p product
+		switch r.Header.Get("Content-Type") {
+		case "application/xml":
+			require.NoError(t, XMLCodec.Unmarshal(body, &p))
+		case "application/msgpack":
+			require.NoError(t, MsgpackCodec.Unmarshal(body, &p))
+		}
+		p.ID = "p-2"
+		// 无论请求的编码，都以 Accept 指定的编码返回
+		c := codecFor(r.Header.Get("Accept"), JSONCodec)
+		data, _ := c.Marshal(p)
+		w.Header().Set("Content-Type", c.ContentType())
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	in := product{Name: "雨伞", Price: 59.5}
+
+	type xmlProduct struct {
+		XMLName xml.Name `xml:"product"`
+		product
+	}
+	got, err := Put[xmlProduct](client, "/products").Codec(XMLCodec).Body(xmlProduct{product: in}).Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "p-2", got.ID)
+	assert.Equal(t, 59.5, got.Price)
+
+	p, err := Put[product](client, "/products").Codec(MsgpackCodec).Body(in).Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, product{ID: "p-2", Name: "雨伞", Price: 59.5}, p)
+
+	m, err := Put[map[string]interface{}](client, "/products").Codec(MsgpackCodec).Body(in).Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "雨伞", m["name"])
+}
+
+// 测试表单、multipart 请求体以及原始响应体
+func TestRequest_Bodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			require.NoError(t, r.ParseForm())
+			_, _ = io.WriteString(w, r.PostForm.Get("user"))
+		case "/upload":
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			f, h, err := r.FormFile("image")
+			require.NoError(t, err)
+			data, _ := io.ReadAll(f)
+			_, _ = io.WriteString(w, r.FormValue("sku")+"|"+h.Filename+"|"+h.Header.Get("Content-Type")+"|"+string(data))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	client := NewHTTPClient(WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	s, err := Post[string](client, "/login").Form(map[string][]string{"user": {"alice"}}).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", s)
+
+	b, err := Post[[]byte](client, "/upload").Multipart(map[string]string{"sku": "A1"}, MultipartFile{
+		FieldName: "image", FileName: `a"1.png`, ContentType: "image/png", Reader: strings.NewReader("PNG"),
+	}).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `A1|a"1.png|image/png|PNG`, string(b))
+
+	p, err := Delete[*product](client, "/empty").Do(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	resp, err := Get[product](client, "/empty").Send(ctx)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+// 测试构造错误在发送时返回
+func TestRequest_BuildErrors(t *testing.T) {
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"))
+	ctx := context.Background()
+
+	_, err := Get[product](client, "/products/{id}").Do(ctx)
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	_, err = Get[product](client, "/products").PathParam("id", "1").Do(ctx)
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	_, err = Get[product](client, "/products").QueryStruct(42).Do(ctx)
+	assert.Error(t, err)
+	_, err = Post[product](client, "/products").Body(make(chan int)).Do(ctx)
+	assert.Error(t, err)
+
+	errRead := errors.New("磁盘错误")
+	_, err = Post[product](client, "/upload").Multipart(nil, MultipartFile{
+		FieldName: "f", FileName: "a.txt", Reader: io.MultiReader(strings.NewReader("x"), errReader{errRead}),
+	}).Do(ctx)
+	assert.ErrorIs(t, err, errRead)
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// 测试查询结构体的编码规则
+func TestEncodeQuery(t *testing.T) {
+	type Paging struct {
+		Page int `query:"page"`
+	}
+	type q struct {
+		Paging
+		Active  bool
+		Ratio   float64 `query:"ratio"`
+		Skipped string  `query:"skipped,omitempty"`
+		Status  *int    `query:"status"`
+		hidden  string
+	}
+	status := 1
+	values, err := EncodeQuery(&q{Paging: Paging{Page: 3}, Active: true, Ratio: 0.5, Status: &status, hidden: "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "Active=true&page=3&ratio=0.5&status=1", values.Encode())
+
+	values, err = EncodeQuery((*q)(nil))
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	_, err = EncodeQuery(struct {
+		M map[string]int `query:"m"`
+	}{M: map[string]int{}})
+	assert.Error(t, err)
+}
+
+// 测试流式 multipart 上传：设置 Content-Length、报告进度，并在重试时通过 Open 重新打开文件
+func TestRequest_StreamMultipart(t *testing.T) {
+	image := strings.Repeat("P", 64<<10)
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, nil}}
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(2),
+		WithRetryInterval(time.Millisecond), WithInterceptors(
+			server))
+
+	opens := 0
+	var progress []int64
+	var total int64
+	_, err := Post[map[string]any](client, "/images").
+		StreamMultipart(map[string]string{"sku": "A1", "alt": "跑鞋"}, MultipartFile{
+			FieldName: "image", FileName: "a.png", ContentType: "image/png", Size: int64(len(image)),
+			Open: func() (io.ReadCloser, error) {
+				opens++
+				return io.NopCloser(strings.NewReader(image)), nil
+			},
+		}).
+		UploadProgress(func(transferred, t int64) {
+			progress = append(progress, transferred)
+			total = t
+		}).
+		Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, opens)
+	require.Len(t, server.bodies, 2)
+	assert.Equal(t, server.bodies[0], server.bodies[1], "重试时应重新发送相同的请求体")
+	assert.Equal(t, int64(len(server.bodies[1])), total, "Content-Length 应与实际发送的字节数一致")
+	assert.Equal(t, total, progress[len(progress)-1])
+
+	// 解析请求体，字段按名称排序
+	contentType := server.headers[1].Get("Content-Type")
+	req := &http.Request{Method: http.MethodPost, Header: http.Header{"Content-Type": {contentType}},
+		Body: io.NopCloser(strings.NewReader(server.bodies[1]))}
+	require.NoError(t, req.ParseMultipartForm(1<<20))
+	assert.Equal(t, "跑鞋", req.FormValue("alt"))
+	f, h, err := req.FormFile("image")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(f)
+	assert.Equal(t, image, string(data))
+	assert.Equal(t, "image/png", h.Header.Get("Content-Type"))
+}
+
+// 测试只设置了 Reader 的流式上传不会重试，未设置大小时不设置 Content-Length
+func TestRequest_StreamMultipartNotReplayable(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, nil}}
+	var lengths []int64
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(2),
+		WithRetryInterval(time.Millisecond), WithInterceptors(
+			InterceptorFunc(func(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+				lengths = append(lengths, req.ContentLength)
+				return next.RoundTrip(req)
+			}), server))
+
+	_, err := Post[[]byte](client, "/import").
+		StreamMultipart(nil, MultipartFile{FieldName: "file", FileName: "products.csv", Reader: strings.NewReader("sku\nA1\n")}).
+		Do(context.Background())
+	assert.ErrorIs(t, err, errConn)
+	assert.NotErrorIs(t, err, ErrMaxRetriesReached)
+	assert.Len(t, server.bodies, 1)
+	assert.Contains(t, server.bodies[0], "sku\nA1\n")
+	assert.Equal(t, []int64{-1}, lengths)
+}
+
+// 测试没有 GetBody 的大请求体只发送一次，不会被完整读入内存
+func TestRetryableTransport_MaxBufferSize(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, errConn, nil}}
+	rt := Chain(nil, NewRetryableTransport(nil, 2, time.Millisecond).WithMaxBufferSize(4), server)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://api.example.com/import", io.MultiReader(strings.NewReader("0123456789")))
+	_, err := rt.RoundTrip(req)
+	assert.ErrorIs(t, err, errConn)
+	assert.Equal(t, []string{"0123456789"}, server.bodies)
+
+	req, _ = http.NewRequest(http.MethodPost, "http://api.example.com/import", io.MultiReader(strings.NewReader("0123")))
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"0123456789", "0123", "0123"}, server.bodies)
+}
+
+// 测试下载进度回调
+func TestRequest_DownloadProgress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10000")
+		_, _ = io.WriteString(w, strings.Repeat("x", 10000))
+	}))
+	defer srv.Close()
+
+	var last, total int64
+	data, err := Get[[]byte](NewHTTPClient(WithBaseURL(srv.URL)), "/file").
+		DownloadProgress(func(transferred, t int64) { last, total = transferred, t }).
+		Do(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, data, 10000)
+	assert.Equal(t, int64(10000), last)
+	assert.Equal(t, int64(10000), total)
+}
@@ -0,0 +1,240 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"gopkg.in/yaml.v3"
+)
+
+// Endpoint 负载均衡的一个后端
+type Endpoint struct {
+	// URL 后端的基础URL，可以带路径前缀，例如 "http://10.0.0.1:8080/api"
+	URL string `json:"url" yaml:"url"`
+	// Weight 权重，只有加权均衡器使用，不大于0时视为1
+	Weight int `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// Resolver 服务发现，提供负载均衡的后端列表
+// 接入注册中心时实现该接口，在后端列表变化时调用 Watch 注册的回调
+type Resolver interface {
+	// Endpoints 返回当前的后端列表
+	Endpoints() []Endpoint
+	// Watch 注册后端列表变化时的回调，返回取消注册的函数
+	Watch(fn func([]Endpoint)) (cancel func())
+}
+
+// parseEndpointURL 解析后端的基础URL
+func parseEndpointURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: 后端地址 %q", ErrInvalidURL, raw)
+	}
+	return u, nil
+}
+
+// StaticResolver 固定的后端列表
+type StaticResolver struct {
+	endpoints []Endpoint
+}
+
+// NewStaticResolver 创建固定后端列表的 Resolver
+func NewStaticResolver(endpoints ...Endpoint) *StaticResolver {
+	return &StaticResolver{endpoints: append([]Endpoint(nil), endpoints...)}
+}
+
+// Endpoints 实现 Resolver 接口
+func (r *StaticResolver) Endpoints() []Endpoint {
+	return append([]Endpoint(nil), r.endpoints...)
+}
+
+// Watch 实现 Resolver 接口，后端列表不会变化
+func (r *StaticResolver) Watch(func([]Endpoint)) func() {
+	return func() {}
+}
+
+// FileResolver 从文件读取后端列表，并定期检查文件内容的变化
+// 文件为 Endpoint 列表，扩展名为 .yaml 或 .yml 时按 YAML 解析，否则按 JSON 解析。
+// 适合配合配置中心或 Kubernetes ConfigMap 挂载的文件使用；
+// 文件读取或解析失败、列表为空或包含无效地址时保留上一次的列表
+type FileResolver struct {
+	path    string
+	clock   clock.Clock
+	onError func(err error)
+	ticker  clock.Ticker
+	done    chan struct{}
+	once    sync.Once
+
+	mu        sync.Mutex
+	data      []byte
+	endpoints []Endpoint
+	watchers  map[int]func([]Endpoint)
+	nextID    int
+}
+
+// FileResolverOption 文件 Resolver 配置选项
+type FileResolverOption func(*fileResolverConfig)
+
+type fileResolverConfig struct {
+	interval time.Duration
+	clock    clock.Clock
+	onError  func(err error)
+}
+
+// WithPollInterval 设置检查文件变化的间隔，默认5秒
+func WithPollInterval(d time.Duration) FileResolverOption {
+	return func(c *fileResolverConfig) {
+		if d > 0 {
+			c.interval = d
+		}
+	}
+}
+
+// WithResolverClock 设置检查文件使用的时钟，测试中可以传入 clock.FakeClock
+func WithResolverClock(clk clock.Clock) FileResolverOption {
+	return func(c *fileResolverConfig) {
+		c.clock = clock.OrReal(clk)
+	}
+}
+
+// WithResolverErrorHandler 设置重新加载文件失败时的回调，默认忽略
+func WithResolverErrorHandler(fn func(err error)) FileResolverOption {
+	return func(c *fileResolverConfig) {
+		c.onError = fn
+	}
+}
+
+// NewFileResolver 读取文件创建 Resolver，第一次读取失败时返回错误，不再使用时需要调用 Close
+func NewFileResolver(path string, opts ...FileResolverOption) (*FileResolver, error) {
+	cfg := &fileResolverConfig{interval: 5 * time.Second, clock: clock.Real, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	r := &FileResolver{
+		path:     path,
+		clock:    cfg.clock,
+		onError:  cfg.onError,
+		done:     make(chan struct{}),
+		watchers: make(map[int]func([]Endpoint)),
+	}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	r.ticker = r.clock.NewTicker(cfg.interval)
+	go r.poll()
+	return r, nil
+}
+
+// Endpoints 实现 Resolver 接口
+func (r *FileResolver) Endpoints() []Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Endpoint(nil), r.endpoints...)
+}
+
+// Watch 实现 Resolver 接口，回调在检查文件的协程中执行
+func (r *FileResolver) Watch(fn func([]Endpoint)) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.watchers[id] = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.watchers, id)
+	}
+}
+
+// Reload 立即重新读取文件，内容变化时通知回调
+func (r *FileResolver) Reload() error {
+	changed, err := r.reload()
+	if err != nil {
+		return err
+	}
+	if changed {
+		r.notify()
+	}
+	return nil
+}
+
+// Close 停止检查文件
+func (r *FileResolver) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+// poll 定期检查文件
+func (r *FileResolver) poll() {
+	defer r.ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.ticker.C():
+			if err := r.Reload(); err != nil {
+				r.onError(err)
+			}
+		}
+	}
+}
+
+// reload 读取文件，内容没有变化时返回 false
+func (r *FileResolver) reload() (bool, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	unchanged := r.endpoints != nil && bytes.Equal(data, r.data)
+	r.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var endpoints []Endpoint
+	if ext := strings.ToLower(filepath.Ext(r.path)); ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(data, &endpoints)
+	} else {
+		err = json.Unmarshal(data, &endpoints)
+	}
+	if err != nil {
+		return false, fmt.Errorf("ggu: 解析后端列表 %s 失败: %w", r.path, err)
+	}
+	if len(endpoints) == 0 {
+		return false, fmt.Errorf("ggu: 后端列表 %s 为空", r.path)
+	}
+	for _, ep := range endpoints {
+		if _, err = parseEndpointURL(ep.URL); err != nil {
+			return false, err
+		}
+	}
+
+	r.mu.Lock()
+	r.data, r.endpoints = data, endpoints
+	r.mu.Unlock()
+	return true, nil
+}
+
+// notify 通知回调后端列表已变化
+func (r *FileResolver) notify() {
+	r.mu.Lock()
+	endpoints := append([]Endpoint(nil), r.endpoints...)
+	watchers := make([]func([]Endpoint), 0, len(r.watchers))
+	for _, fn := range r.watchers {
+		watchers = append(watchers, fn)
+	}
+	r.mu.Unlock()
+	for _, fn := range watchers {
+		fn(endpoints)
+	}
+}
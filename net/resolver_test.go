@@ -0,0 +1,96 @@
+package net
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticResolver(t *testing.T) {
+	endpoints := []Endpoint{{URL: "http://10.0.0.1"}, {URL: "http://10.0.0.2", Weight: 3}}
+	r := NewStaticResolver(endpoints...)
+	endpoints[0].URL = "http://changed"
+	assert.Equal(t, "http://10.0.0.1", r.Endpoints()[0].URL)
+	r.Watch(func([]Endpoint) {})()
+}
+
+// 测试文件内容变化时通知回调，文件无效时保留上一次的列表
+func TestFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write("- url: http://10.0.0.1:8080\n- url: http://10.0.0.2:8080\n  weight: 3\n")
+
+	clk := clock.NewFakeClock(time.Now())
+	var mu sync.Mutex
+	var errs []error
+	r, err := NewFileResolver(path, WithPollInterval(time.Second), WithResolverClock(clk),
+		WithResolverErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}))
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, []Endpoint{{URL: "http://10.0.0.1:8080"}, {URL: "http://10.0.0.2:8080", Weight: 3}}, r.Endpoints())
+
+	updates := make(chan []Endpoint, 4)
+	r.Watch(func(endpoints []Endpoint) { updates <- endpoints })
+
+	write("- url: http://10.0.0.3:8080\n")
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	select {
+	case endpoints := <-updates:
+		assert.Equal(t, []Endpoint{{URL: "http://10.0.0.3:8080"}}, endpoints)
+	case <-time.After(2 * time.Second):
+		t.Fatal("文件变化后没有通知")
+	}
+
+	// 内容没有变化时不通知
+	require.NoError(t, r.Reload())
+	assert.Empty(t, updates)
+
+	for _, content := range []string{"- url: [", "[]", "- url: ftp://10.0.0.4\n"} {
+		write(content)
+		assert.Error(t, r.Reload(), content)
+	}
+	clk.Advance(time.Second)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) == 1
+	}, 2*time.Second, time.Millisecond)
+	assert.Equal(t, []Endpoint{{URL: "http://10.0.0.3:8080"}}, r.Endpoints())
+	assert.Empty(t, updates)
+
+	_, err = NewFileResolver(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+// 测试负载均衡器跟随文件更新后端列表
+func TestFileResolver_LoadBalancer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"url":"http://10.0.0.1"}]`), 0o644))
+	r, err := NewFileResolver(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	lb := NewLoadBalancer(r)
+	defer lb.Close()
+	require.Len(t, lb.Backends(), 1)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"url":"http://10.0.0.1"},{"url":"http://10.0.0.2","weight":2}]`), 0o644))
+	require.NoError(t, r.Reload())
+	backends := lb.Backends()
+	require.Len(t, backends, 2)
+	assert.Equal(t, 2, backends[1].Weight)
+}
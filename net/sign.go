@@ -0,0 +1,191 @@
+package net
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// 请求签名使用的请求头
+const (
+	HeaderTimestamp = "X-Timestamp" // Unix 时间戳，单位秒
+	HeaderNonce     = "X-Nonce"     // 每个请求唯一的随机串
+	HeaderSignature = "X-Signature" // 十六进制的 HMAC-SHA256 签名
+
+	// HeaderContentSHA256 请求体不参与签名时取值为 UnsignedPayload
+	HeaderContentSHA256 = "X-Content-Sha256"
+)
+
+// UnsignedPayload 请求体不参与签名时，规范请求串中代替请求体摘要的值
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// CanonicalRequest 返回参与签名的规范请求串，各部分以换行分隔：
+//
+//	METHOD
+//	转义后的路径
+//	按键排序的查询参数
+//	时间戳
+//	nonce
+//	请求体的十六进制 SHA-256
+//
+// 服务端使用同样的规则校验签名，参见 ginutil/middleware/auth 的签名校验中间件
+func CanonicalRequest(method string, u *url.URL, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return CanonicalRequestWithHash(method, u, timestamp, nonce, hex.EncodeToString(sum[:]))
+}
+
+// CanonicalRequestWithHash 与 CanonicalRequest 相同，但直接使用请求体的摘要，
+// 便于流式计算摘要，或者使用 UnsignedPayload 表示请求体不参与签名
+func CanonicalRequestWithHash(method string, u *url.URL, timestamp, nonce, bodyHash string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		u.Query().Encode(),
+		timestamp,
+		nonce,
+		bodyHash,
+	}, "\n")
+}
+
+// SignCanonical 使用 HMAC-SHA256 计算规范请求串的签名，返回十六进制字符串
+func SignCanonical(secret []byte, canonical string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequestSigner 使用 HMAC-SHA256 为请求签名
+// 作为拦截器放入 HTTPClient 时位于重试拦截器内层，每次重试都会使用新的时间戳和 nonce 重新签名
+type RequestSigner struct {
+	secret          []byte
+	clock           clock.Clock
+	nonce           func() string
+	unsignedPayload bool
+}
+
+// SignerOption RequestSigner 的配置选项
+type SignerOption func(*RequestSigner)
+
+// WithSignerClock 设置生成时间戳使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithSignerClock(clk clock.Clock) SignerOption {
+	return func(s *RequestSigner) {
+		s.clock = clock.OrReal(clk)
+	}
+}
+
+// WithNonceFunc 设置生成 nonce 的函数，默认生成 16 字节的随机十六进制串
+func WithNonceFunc(fn func() string) SignerOption {
+	return func(s *RequestSigner) {
+		if fn != nil {
+			s.nonce = fn
+		}
+	}
+}
+
+// WithUnsignedPayload 请求体不参与签名，也不会被读取，适合 StreamMultipart 等流式上传，
+// 签名中使用 UnsignedPayload 代替请求体摘要并设置 X-Content-Sha256 头；服务端需要开启 auth.WithUnsignedPayload
+func WithUnsignedPayload() SignerOption {
+	return func(s *RequestSigner) {
+		s.unsignedPayload = true
+	}
+}
+
+// NewRequestSigner 创建请求签名器
+func NewRequestSigner(secret string, opts ...SignerOption) *RequestSigner {
+	s := &RequestSigner{
+		secret: []byte(secret),
+		clock:  clock.Real,
+		nonce:  randomNonce,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sign 为请求设置时间戳、nonce 和签名头
+//   - 开启 WithUnsignedPayload 时请求体不参与签名
+//   - 设置了 GetBody 的请求从新的副本流式计算请求体摘要，不修改 req.Body；
+//     只设置了 Reader 的 StreamMultipart 请求体无法重新获取，返回 ErrBodyNotReplayable
+//   - 没有 GetBody 的请求体读入内存并替换为可以重复读取的副本
+func (s *RequestSigner) Sign(req *http.Request) error {
+	bodyHash, err := s.bodyHash(req)
+	if err != nil {
+		return err
+	}
+	if bodyHash == UnsignedPayload {
+		req.Header.Set(HeaderContentSHA256, UnsignedPayload)
+	}
+
+	timestamp := strconv.FormatInt(s.clock.Now().Unix(), 10)
+	nonce := s.nonce()
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, SignCanonical(s.secret, CanonicalRequestWithHash(req.Method, req.URL, timestamp, nonce, bodyHash)))
+	return nil
+}
+
+// bodyHash 返回请求体的十六进制 SHA-256，请求体不参与签名时返回 UnsignedPayload
+func (s *RequestSigner) bodyHash(req *http.Request) (string, error) {
+	h := sha256.New()
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case s.unsignedPayload:
+		// 先于 GetBody 判断，避免为了计算摘要把流式上传的内容再读一遍
+		return UnsignedPayload, nil
+	case req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, body)
+		body.Close()
+		if err != nil {
+			return "", err
+		}
+	default:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Intercept 实现Interceptor接口，在请求副本上签名后交给 next
+func (s *RequestSigner) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := s.Sign(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return next.RoundTrip(req)
+}
+
+// randomNonce 生成 16 字节的随机十六进制串
+func randomNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
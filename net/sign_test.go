@@ -0,0 +1,124 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试规范请求串的格式
+func TestCanonicalRequest(t *testing.T) {
+	u, _ := url.Parse("http://api.example.com/products/a%2Fb?size=10&page=2&page=1")
+	got := CanonicalRequest("post", u, "1700000000", "n-1", []byte("{}"))
+	assert.Equal(t, "POST\n/products/a%2Fb\npage=2&page=1&size=10\n1700000000\nn-1\n"+
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", got)
+
+	u, _ = url.Parse("http://api.example.com")
+	assert.Equal(t, "GET\n/\n\n1\nn\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		CanonicalRequest(http.MethodGet, u, "1", "n", nil))
+}
+
+// 测试每次重试都使用新的 nonce 重新签名，且不修改调用方的请求
+func TestRequestSigner_Retry(t *testing.T) {
+	errConn := errors.New("连接被重置")
+	server := &fakeServer{results: []error{errConn, nil}}
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	seq := 0
+	signer := NewRequestSigner("secret-1", WithSignerClock(clk), WithNonceFunc(func() string {
+		seq++
+		return fmt.Sprintf("n-%d", seq)
+	}))
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(1),
+		WithRetryInterval(time.Millisecond), WithInterceptors(signer, server))
+
+	err := client.PostJSON(context.Background(), "/orders?source=app", map[string]int{"qty": 2}, &map[string]any{}, nil)
+	require.NoError(t, err)
+	require.Len(t, server.headers, 2)
+
+	u, _ := url.Parse("http://api.example.com/orders?source=app")
+	for i, h := range server.headers {
+		nonce := fmt.Sprintf("n-%d", i+1)
+		assert.Equal(t, nonce, h.Get(HeaderNonce))
+		assert.Equal(t, "1700000000", h.Get(HeaderTimestamp))
+		expected := SignCanonical([]byte("secret-1"), CanonicalRequest(http.MethodPost, u, "1700000000", nonce, []byte(`{"qty":2}`)))
+		assert.Equal(t, expected, h.Get(HeaderSignature))
+		assert.Equal(t, `{"qty":2}`, server.bodies[i])
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://api.example.com/orders", nil)
+	_, err = Chain(nil, signer, server).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get(HeaderSignature), "签名不应修改调用方的请求")
+}
+
+// 测试有 GetBody 的请求流式计算摘要且不替换请求体，流式请求体可以不参与签名
+func TestRequestSigner_StreamedBody(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1700000000, 0))
+	nonce := WithNonceFunc(func() string { return "n-1" })
+	u, _ := url.Parse("http://api.example.com/upload")
+
+	req, _ := http.NewRequest(http.MethodPut, u.String(), strings.NewReader("catalog"))
+	body := req.Body
+	require.NoError(t, NewRequestSigner("secret-1", WithSignerClock(clk), nonce).Sign(req))
+	assert.True(t, body == req.Body, "有 GetBody 时不读取请求体")
+	assert.Equal(t, SignCanonical([]byte("secret-1"), CanonicalRequest(http.MethodPut, u, "1700000000", "n-1", []byte("catalog"))),
+		req.Header.Get(HeaderSignature))
+	assert.Empty(t, req.Header.Get(HeaderContentSHA256))
+
+	stream := &closeTracker{Reader: strings.NewReader("large file")}
+	req, _ = http.NewRequest(http.MethodPut, u.String(), stream)
+	require.Nil(t, req.GetBody)
+	require.NoError(t, NewRequestSigner("secret-1", WithSignerClock(clk), nonce, WithUnsignedPayload()).Sign(req))
+	assert.Equal(t, UnsignedPayload, req.Header.Get(HeaderContentSHA256))
+	assert.Equal(t, SignCanonical([]byte("secret-1"), CanonicalRequestWithHash(http.MethodPut, u, "1700000000", "n-1", UnsignedPayload)),
+		req.Header.Get(HeaderSignature))
+	rest, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "large file", string(rest), "流式请求体不应被读取")
+}
+
+// 测试开启 WithUnsignedPayload 时流式 multipart 上传不为计算摘要而读取文件
+func TestRequestSigner_StreamMultipartUnsigned(t *testing.T) {
+	server := &fakeServer{}
+	signer := NewRequestSigner("secret-1", WithUnsignedPayload())
+	client := NewHTTPClient(WithBaseURL("http://api.example.com"), WithInterceptors(signer, server))
+
+	_, err := Post[[]byte](client, "/import").
+		StreamMultipart(nil, MultipartFile{FieldName: "file", FileName: "products.csv", Reader: strings.NewReader("sku\nA1\n")}).
+		Do(context.Background())
+	require.NoError(t, err)
+	require.Len(t, server.bodies, 1)
+	assert.Contains(t, server.bodies[0], "sku\nA1\n")
+	assert.Equal(t, UnsignedPayload, server.headers[0].Get(HeaderContentSHA256))
+	assert.NotEmpty(t, server.headers[0].Get(HeaderSignature))
+
+	opens := 0
+	_, err = Post[[]byte](client, "/images").
+		StreamMultipart(nil, MultipartFile{FieldName: "image", FileName: "a.png", Size: 4,
+			Open: func() (io.ReadCloser, error) {
+				opens++
+				return io.NopCloser(strings.NewReader("PNG!")), nil
+			},
+		}).
+		Do(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, opens, "文件只应在发送时打开一次")
+	assert.Equal(t, UnsignedPayload, server.headers[1].Get(HeaderContentSHA256))
+
+	// 未开启时只设置了 Reader 的文件无法计算摘要
+	client = NewHTTPClient(WithBaseURL("http://api.example.com"), WithMaxRetries(0),
+		WithInterceptors(NewRequestSigner("secret-1"), server))
+	_, err = Post[[]byte](client, "/import").
+		StreamMultipart(nil, MultipartFile{FieldName: "file", FileName: "products.csv", Reader: strings.NewReader("sku\nA1\n")}).
+		Do(context.Background())
+	assert.ErrorIs(t, err, ErrBodyNotReplayable)
+}
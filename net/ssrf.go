@@ -0,0 +1,295 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/retry"
+)
+
+// SSRF 防护相关错误
+var (
+	ErrBlockedAddress   = errors.New("ggu: 禁止访问的地址")
+	ErrSchemeNotAllowed = errors.New("ggu: 不允许的URL协议")
+	ErrPortNotAllowed   = errors.New("ggu: 不允许的端口")
+	ErrTooManyRedirects = errors.New("ggu: 重定向次数过多")
+	ErrResponseTooLarge = errors.New("ggu: 响应体超过大小限制")
+)
+
+// defaultBlockedPrefixes 默认禁止访问的地址段：内网、回环、链路本地（包括云厂商的元数据地址）、
+// 运营商级NAT、文档和测试地址、组播以及保留地址
+var defaultBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级NAT，阿里云元数据地址 100.100.100.200 在此范围内
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"), // 链路本地，AWS/GCP/Azure 元数据地址 169.254.169.254 在此范围内
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64，可能映射到内网的IPv4地址
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fc00::/7"), // 唯一本地地址，AWS IPv6 元数据地址 fd00:ec2::254 在此范围内
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// SSRFGuard 访问用户提供的URL（例如商家填写的图片地址和回调地址）时防止服务端请求伪造
+//   - 请求前检查URL的协议和端口是否在允许列表中
+//   - 在拨号器的 Control 阶段检查实际连接的IP，DNS解析之后再检查，因此 DNS 重绑定无法绕过
+//   - 每次重定向都重新检查目标URL，并限制重定向次数
+//   - 限制响应体的大小
+type SSRFGuard struct {
+	schemes         map[string]bool
+	ports           map[int]bool // 为空时不限制端口
+	blocked         []netip.Prefix
+	allowed         []netip.Prefix
+	maxRedirects    int
+	maxResponseSize int64
+}
+
+// SSRFOption SSRF防护配置选项
+type SSRFOption func(*SSRFGuard)
+
+// WithAllowedSchemes 设置允许的URL协议，默认 http 和 https
+func WithAllowedSchemes(schemes ...string) SSRFOption {
+	return func(g *SSRFGuard) {
+		g.schemes = make(map[string]bool, len(schemes))
+		for _, s := range schemes {
+			g.schemes[strings.ToLower(s)] = true
+		}
+	}
+}
+
+// WithAllowedPorts 设置允许的端口，默认 80 和 443，不传参数表示不限制端口
+func WithAllowedPorts(ports ...int) SSRFOption {
+	return func(g *SSRFGuard) {
+		g.ports = make(map[int]bool, len(ports))
+		for _, p := range ports {
+			g.ports[p] = true
+		}
+	}
+}
+
+// WithBlockedPrefixes 在默认的禁止地址段之外追加禁止访问的地址段
+func WithBlockedPrefixes(prefixes ...netip.Prefix) SSRFOption {
+	return func(g *SSRFGuard) {
+		g.blocked = append(g.blocked, prefixes...)
+	}
+}
+
+// WithAllowedPrefixes 设置例外的地址段，即使在禁止的地址段中也允许访问，例如测试环境的内网服务
+func WithAllowedPrefixes(prefixes ...netip.Prefix) SSRFOption {
+	return func(g *SSRFGuard) {
+		g.allowed = append(g.allowed, prefixes...)
+	}
+}
+
+// WithMaxRedirects 设置最多跟随的重定向次数，默认5次，0 表示不跟随重定向
+func WithMaxRedirects(n int) SSRFOption {
+	return func(g *SSRFGuard) {
+		if n >= 0 {
+			g.maxRedirects = n
+		}
+	}
+}
+
+// WithMaxResponseSize 设置响应体的最大字节数，默认10MB，不大于0时不限制
+func WithMaxResponseSize(n int64) SSRFOption {
+	return func(g *SSRFGuard) {
+		g.maxResponseSize = n
+	}
+}
+
+// NewSSRFGuard 创建SSRF防护
+func NewSSRFGuard(opts ...SSRFOption) *SSRFGuard {
+	g := &SSRFGuard{
+		schemes:         map[string]bool{"http": true, "https": true},
+		ports:           map[int]bool{80: true, 443: true},
+		blocked:         append([]netip.Prefix(nil), defaultBlockedPrefixes...),
+		maxRedirects:    5,
+		maxResponseSize: 10 << 20,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// CheckURL 检查URL的协议和端口，主机是IP时同时检查IP；主机是域名时在拨号时检查解析到的IP
+func (g *SSRFGuard) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !g.schemes[scheme] {
+		return fmt.Errorf("%w: %s", ErrSchemeNotAllowed, u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, u)
+	}
+	port := 0
+	switch {
+	case u.Port() != "":
+		p, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidURL, u)
+		}
+		port = p
+	case scheme == "http":
+		port = 80
+	case scheme == "https":
+		port = 443
+	}
+	if err := g.checkPort(port); err != nil {
+		return err
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.CheckIP(ip)
+	}
+	return nil
+}
+
+// CheckIP 检查IP是否允许访问，IPv4映射的IPv6地址按IPv4检查
+func (g *SSRFGuard) CheckIP(ip netip.Addr) error {
+	ip = ip.Unmap().WithZone("")
+	for _, p := range g.allowed {
+		if p.Contains(ip) {
+			return nil
+		}
+	}
+	for _, p := range g.blocked {
+		if p.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+		}
+	}
+	return nil
+}
+
+func (g *SSRFGuard) checkPort(port int) error {
+	if len(g.ports) > 0 && !g.ports[port] {
+		return fmt.Errorf("%w: %d", ErrPortNotAllowed, port)
+	}
+	return nil
+}
+
+// Control 可以设置为 net.Dialer.Control，在建立连接之前检查实际连接的IP和端口
+// 返回的错误使用 retry.Permanent 标记，HTTPClient 不会重试被拒绝的请求
+func (g *SSRFGuard) Control(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("%w: %s", ErrBlockedAddress, address))
+	}
+	if err = g.checkPort(int(ap.Port())); err != nil {
+		return retry.Permanent(err)
+	}
+	return retry.Permanent(g.CheckIP(ap.Addr()))
+}
+
+// Dialer 返回在建立连接前检查地址的 net.Dialer，base 为 nil 时使用默认的拨号配置
+// base 已经设置了 Control 时两者都会执行
+func (g *SSRFGuard) Dialer(base *stdnet.Dialer) *stdnet.Dialer {
+	d := &stdnet.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if base != nil {
+		copied := *base
+		d = &copied
+	}
+	prev := d.Control
+	d.ControlContext = nil
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if err := g.Control(network, address, c); err != nil {
+			return err
+		}
+		if prev != nil {
+			return prev(network, address, c)
+		}
+		return nil
+	}
+	return d
+}
+
+// CheckRedirect 可以设置为 http.Client.CheckRedirect，检查重定向的目标并限制重定向次数
+func (g *SSRFGuard) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > g.maxRedirects {
+		return retry.Permanent(fmt.Errorf("%w: 超过 %d 次", ErrTooManyRedirects, g.maxRedirects))
+	}
+	return retry.Permanent(g.CheckURL(req.URL))
+}
+
+// Intercept 实现 Interceptor 接口，请求前检查URL，并限制响应体的大小
+// 响应的 Content-Length 超过限制时直接返回错误，否则在读取超过限制时返回 ErrResponseTooLarge
+func (g *SSRFGuard) Intercept(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if err := g.CheckURL(req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, retry.Permanent(err)
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || g.maxResponseSize <= 0 {
+		return resp, err
+	}
+	if resp.ContentLength > g.maxResponseSize {
+		resp.Body.Close()
+		return nil, retry.Permanent(fmt.Errorf("%w: %d 字节", ErrResponseTooLarge, resp.ContentLength))
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: g.maxResponseSize}
+	return resp, nil
+}
+
+// limitedBody 读取超过 remaining 字节时返回 ErrResponseTooLarge
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// 多读一个字节用于判断是否超过限制
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+// WithSSRFDialer 拨号时使用 g 检查实际连接的地址，同时禁用代理和自定义的 TLS 拨号，否则检查的是代理的地址
+// 无论与其他选项的先后顺序如何，都在 NewTransport 应用完全部选项之后生效
+func WithSSRFDialer(g *SSRFGuard) TransportOption {
+	return func(c *transportConfig) {
+		c.ssrf = g
+	}
+}
+
+// WithSSRFGuard 使用 g 保护客户端的所有请求：检查URL和重定向，拨号时检查解析后的IP，限制响应体大小
+// 拨号检查在 WithTransportOptions 的全部选项之后生效，后设置的拨号器或代理不会绕过检查；
+// 无法检查 WithTransport 设置的传输，两者同时使用时 NewHTTPClient 会 panic
+func WithSSRFGuard(g *SSRFGuard) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.ssrfGuard = g
+		c.client.CheckRedirect = g.CheckRedirect
+		c.interceptors = append(c.interceptors, g)
+	}
+}
@@ -0,0 +1,239 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdnet "net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试默认禁止的地址段，包括云厂商的元数据地址和IPv4映射的IPv6地址
+func TestSSRFGuard_CheckIP(t *testing.T) {
+	g := NewSSRFGuard()
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.100.100.200",
+		"0.0.0.0", "224.0.0.1", "::1", "fe80::1", "fd00:ec2::254", "::ffff:127.0.0.1", "64:ff9b::a00:1",
+	}
+	for _, s := range blocked {
+		assert.ErrorIs(t, g.CheckIP(netip.MustParseAddr(s)), ErrBlockedAddress, s)
+	}
+	for _, s := range []string{"8.8.8.8", "203.0.114.1", "2606:4700::1111"} {
+		assert.NoError(t, g.CheckIP(netip.MustParseAddr(s)), s)
+	}
+
+	g = NewSSRFGuard(
+		WithAllowedPrefixes(netip.MustParsePrefix("10.20.0.0/16")),
+		WithBlockedPrefixes(netip.MustParsePrefix("8.8.8.0/24")),
+	)
+	assert.NoError(t, g.CheckIP(netip.MustParseAddr("10.20.1.1")))
+	assert.ErrorIs(t, g.CheckIP(netip.MustParseAddr("10.21.1.1")), ErrBlockedAddress)
+	assert.ErrorIs(t, g.CheckIP(netip.MustParseAddr("8.8.8.8")), ErrBlockedAddress)
+}
+
+// 测试协议、端口和IP主机的检查
+func TestSSRFGuard_CheckURL(t *testing.T) {
+	g := NewSSRFGuard()
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://cdn.merchant.com/a.png", nil},
+		{"http://cdn.merchant.com:80/a.png", nil},
+		{"ftp://cdn.merchant.com/a.png", ErrSchemeNotAllowed},
+		{"file:///etc/passwd", ErrSchemeNotAllowed},
+		{"gopher://cdn.merchant.com:70/", ErrSchemeNotAllowed},
+		{"http://cdn.merchant.com:6379/", ErrPortNotAllowed},
+		{"http://169.254.169.254/latest/meta-data/", ErrBlockedAddress},
+		{"http://[::1]/", ErrBlockedAddress},
+		{"http:///path", ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		require.NoError(t, err)
+		err = g.CheckURL(u)
+		if tt.want == nil {
+			assert.NoError(t, err, tt.url)
+		} else {
+			assert.ErrorIs(t, err, tt.want, tt.url)
+		}
+	}
+
+	g = NewSSRFGuard(WithAllowedSchemes("https"), WithAllowedPorts())
+	u, _ := url.Parse("https://cdn.merchant.com:8443/a.png")
+	assert.NoError(t, g.CheckURL(u))
+	u, _ = url.Parse("http://cdn.merchant.com/a.png")
+	assert.ErrorIs(t, g.CheckURL(u), ErrSchemeNotAllowed)
+}
+
+// 测试域名解析到内网地址时在拨号阶段拒绝，即使请求前的检查通过（DNS 重绑定），且不重试
+func TestSSRFGuard_DNSRebinding(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+	_, port, _ := stdnet.SplitHostPort(srv.Listener.Addr().String())
+
+	dns := NewDNSCache(time.Minute, WithDNSLookup(func(context.Context, string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}))
+	g := NewSSRFGuard(WithAllowedPorts())
+	client := NewHTTPClient(WithSSRFGuard(g), WithTransportOptions(WithDNSCache(dns)), WithRetryInterval(time.Millisecond))
+
+	_, err := client.Get(context.Background(), "http://images.merchant.test:"+port+"/a.png", nil)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	assert.NotErrorIs(t, err, ErrMaxRetriesReached, "被拒绝的请求不重试")
+	assert.Equal(t, int32(0), hits.Load())
+
+	// 直接使用 IP 时请求前就被拒绝
+	_, err = client.Get(context.Background(), srv.URL, nil)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+
+	// 允许回环地址后可以访问
+	g = NewSSRFGuard(WithAllowedPorts(), WithAllowedPrefixes(netip.MustParsePrefix("127.0.0.0/8")))
+	client = NewHTTPClient(WithSSRFGuard(g), WithTransportOptions(WithDNSCache(dns)))
+	resp, err := client.Get(context.Background(), "http://images.merchant.test:"+port+"/a.png", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+// 测试重定向次数限制以及重定向目标的检查
+func TestSSRFGuard_Redirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			var n int
+			fmt.Sscanf(r.URL.Path, "/hop/%d", &n)
+			if n == 0 {
+				_, _ = w.Write([]byte("done"))
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+		case r.URL.Path == "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case r.URL.Path == "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	g := NewSSRFGuard(WithAllowedPorts(), WithAllowedPrefixes(netip.MustParsePrefix("127.0.0.0/8")), WithMaxRedirects(2))
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithSSRFGuard(g))
+
+	resp, err := client.Get(context.Background(), "/hop/2", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get(context.Background(), "/hop/3", nil)
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+	_, err = client.Get(context.Background(), "/loop", nil)
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+	_, err = client.Get(context.Background(), "/metadata", nil)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	_, err = client.Get(context.Background(), "/file", nil)
+	assert.ErrorIs(t, err, ErrSchemeNotAllowed)
+}
+
+// 测试响应体大小限制
+func TestSSRFGuard_ResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// 先发送响应头，响应没有 Content-Length
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer srv.Close()
+
+	g := NewSSRFGuard(WithAllowedPorts(), WithAllowedPrefixes(netip.MustParsePrefix("127.0.0.0/8")), WithMaxResponseSize(1024))
+	client := NewHTTPClient(WithBaseURL(srv.URL), WithSSRFGuard(g))
+
+	_, err := client.Get(context.Background(), "/fixed", nil)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+
+	resp, err := client.Get(context.Background(), "/chunked", nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	assert.Len(t, body, 1024)
+
+	// 恰好等于限制时可以读完
+	g = NewSSRFGuard(WithAllowedPorts(), WithAllowedPrefixes(netip.MustParsePrefix("127.0.0.0/8")), WithMaxResponseSize(2048))
+	client = NewHTTPClient(WithBaseURL(srv.URL), WithSSRFGuard(g))
+	resp, err = client.Get(context.Background(), "/chunked", nil)
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Len(t, body, 2048)
+}
+
+// 测试拨号检查与选项顺序无关：之后设置的拨号器和代理不能绕过检查
+func TestSSRFGuard_OptionOrder(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+	_, port, _ := stdnet.SplitHostPort(srv.Listener.Addr().String())
+
+	dns := NewDNSCache(time.Minute, WithDNSLookup(func(context.Context, string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}))
+	client := NewHTTPClient(
+		WithSSRFGuard(NewSSRFGuard(WithAllowedPorts())),
+		WithTransportOptions(
+			WithDNSCache(dns),
+			WithDialer(&stdnet.Dialer{Timeout: time.Second}),
+			// 代理指向测试服务器，如果代理生效，拨号检查只能看到代理的地址
+			WithProxyURL(srv.URL),
+			WithTransportFunc(func(tr *http.Transport) {
+				tr.DialTLSContext = (&stdnet.Dialer{}).DialContext
+			}),
+		),
+		WithMaxRetries(0),
+	)
+	_, err := client.Get(context.Background(), "http://images.merchant.test:"+port+"/a.png", nil)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	assert.Equal(t, int32(0), hits.Load())
+
+	assert.Panics(t, func() {
+		NewHTTPClient(WithSSRFGuard(NewSSRFGuard()), WithTransport(http.DefaultTransport))
+	})
+}
+
+// closeTracker 记录请求体是否被关闭
+type closeTracker struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// 测试被拒绝的请求关闭请求体，流式上传的写入协程不会阻塞
+func TestSSRFGuard_ClosesBodyOnReject(t *testing.T) {
+	body := &closeTracker{Reader: strings.NewReader("data")}
+	req, err := http.NewRequest(http.MethodPost, "http://169.254.169.254/upload", body)
+	require.NoError(t, err)
+	_, err = NewSSRFGuard().Intercept(req, http.DefaultTransport)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+	assert.True(t, body.closed.Load())
+}
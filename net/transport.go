@@ -0,0 +1,212 @@
+package net
+
+import (
+	"crypto/tls"
+	stdnet "net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transportConfig NewTransport 的配置
+type transportConfig struct {
+	transport *http.Transport
+	dialer    *stdnet.Dialer
+	dns       *DNSCache
+	ssrf      *SSRFGuard // 在所有选项之后生效，不会被后面的拨号器或代理选项覆盖
+}
+
+// TransportOption 连接池和拨号的配置选项，用于 NewTransport、WithTransportOptions 和 TransportRegistry
+type TransportOption func(*transportConfig)
+
+// WithMaxConnsPerHost 设置每个主机的最大连接数（包括正在使用和空闲的连接），0 表示不限制
+func WithMaxConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.MaxConnsPerHost = n
+	}
+}
+
+// WithMaxIdleConns 设置所有主机的最大空闲连接数，0 表示不限制
+func WithMaxIdleConns(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.MaxIdleConns = n
+	}
+}
+
+// WithMaxIdleConnsPerHost 设置每个主机的最大空闲连接数，标准库默认只有2个，高并发访问同一主机时应调大
+func WithMaxIdleConnsPerHost(n int) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.MaxIdleConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout 设置空闲连接保留的时间
+func WithIdleConnTimeout(d time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.IdleConnTimeout = d
+	}
+}
+
+// WithTLSHandshakeTimeout 设置TLS握手的超时时间
+func WithTLSHandshakeTimeout(d time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.TLSHandshakeTimeout = d
+	}
+}
+
+// WithResponseHeaderTimeout 设置发送请求后等待响应头的超时时间，不包括读取响应体
+func WithResponseHeaderTimeout(d time.Duration) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.ResponseHeaderTimeout = d
+	}
+}
+
+// WithTLSConfig 设置TLS配置，例如客户端证书、根证书和最低TLS版本
+func WithTLSConfig(cfg *tls.Config) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.TLSClientConfig = cfg
+	}
+}
+
+// WithProxy 设置选择代理的函数，默认使用环境变量 HTTP_PROXY/HTTPS_PROXY/NO_PROXY，传入 nil 不使用代理
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) TransportOption {
+	return func(c *transportConfig) {
+		c.transport.Proxy = proxy
+	}
+}
+
+// WithProxyURL 所有请求都通过 proxyURL 代理，例如 "http://proxy.internal:3128"
+// proxyURL 无法解析时请求返回 ErrInvalidURL
+func WithProxyURL(proxyURL string) TransportOption {
+	u, err := url.Parse(proxyURL)
+	return WithProxy(func(*http.Request) (*url.URL, error) {
+		if err != nil {
+			return nil, ErrInvalidURL
+		}
+		return u, nil
+	})
+}
+
+// WithDialer 设置建立连接使用的 net.Dialer，例如连接超时、keep-alive 和本地地址
+func WithDialer(d *stdnet.Dialer) TransportOption {
+	return func(c *transportConfig) {
+		if d != nil {
+			c.dialer = d
+		}
+	}
+}
+
+// WithDNSCache 使用DNS缓存解析域名，并在解析到的多个地址之间轮询
+// 同一个 DNSCache 可以被多个传输共享
+func WithDNSCache(cache *DNSCache) TransportOption {
+	return func(c *transportConfig) {
+		c.dns = cache
+	}
+}
+
+// WithTransportFunc 直接修改 http.Transport，用于设置上面的选项没有覆盖的字段
+func WithTransportFunc(fn func(*http.Transport)) TransportOption {
+	return func(c *transportConfig) {
+		fn(c.transport)
+	}
+}
+
+// NewTransport 以 http.DefaultTransport 的配置为基础创建新的 http.Transport 并应用选项
+func NewTransport(opts ...TransportOption) *http.Transport {
+	cfg := &transportConfig{
+		transport: http.DefaultTransport.(*http.Transport).Clone(),
+		// 与 http.DefaultTransport 使用的拨号配置相同
+		dialer: &stdnet.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.ssrf != nil {
+		// 代理和自定义的 TLS 拨号都会绕过拨号检查
+		cfg.dialer = cfg.ssrf.Dialer(cfg.dialer)
+		cfg.transport.Proxy = nil
+		cfg.transport.DialTLSContext = nil
+		cfg.transport.DialTLS = nil //nolint:staticcheck // 兼容仍在使用已弃用字段的调用方
+	}
+	if cfg.dns != nil {
+		cfg.transport.DialContext = cfg.dns.DialContext(cfg.dialer)
+	} else {
+		cfg.transport.DialContext = cfg.dialer.DialContext
+	}
+	return cfg.transport
+}
+
+// WithTransportOptions 使用 NewTransport(opts...) 创建的连接池发送请求，可以多次调用
+// 同时使用 WithTransport 时以 WithTransport 设置的传输为准，这些选项不生效
+func WithTransportOptions(opts ...TransportOption) HTTPClientOption {
+	return func(c *HTTPClient) {
+		c.transportOptions = append(c.transportOptions, opts...)
+	}
+}
+
+// TransportRegistry 按目标主机选择连接池的 http.RoundTripper
+// 每个下游合作方可以使用独立的连接数、超时、TLS 和代理配置，互不影响；
+// 没有注册的主机共用一个默认连接池。主机先按 "host:port" 匹配，再按不带端口的 host 匹配
+type TransportRegistry struct {
+	mu         sync.RWMutex
+	defaults   []TransportOption
+	transports map[string]*http.Transport
+	fallback   *http.Transport
+}
+
+// NewTransportRegistry 创建传输注册表，defaults 应用于所有主机，包括没有注册的主机
+func NewTransportRegistry(defaults ...TransportOption) *TransportRegistry {
+	return &TransportRegistry{
+		defaults:   defaults,
+		transports: make(map[string]*http.Transport),
+		fallback:   NewTransport(defaults...),
+	}
+}
+
+// Register 为主机注册连接池，opts 在默认选项之后应用；重复注册时替换原来的连接池并关闭其空闲连接
+func (r *TransportRegistry) Register(host string, opts ...TransportOption) {
+	all := append(append([]TransportOption(nil), r.defaults...), opts...)
+	t := NewTransport(all...)
+
+	host = strings.ToLower(host)
+	r.mu.Lock()
+	old := r.transports[host]
+	r.transports[host] = t
+	r.mu.Unlock()
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+// Transport 返回主机使用的连接池
+func (r *TransportRegistry) Transport(host string) *http.Transport {
+	host = strings.ToLower(host)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.transports[host]; ok {
+		return t
+	}
+	if h, _, err := stdnet.SplitHostPort(host); err == nil {
+		if t, ok := r.transports[h]; ok {
+			return t
+		}
+	}
+	return r.fallback
+}
+
+// RoundTrip 实现http.RoundTripper接口
+func (r *TransportRegistry) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.Transport(req.URL.Host).RoundTrip(req)
+}
+
+// CloseIdleConnections 关闭所有连接池的空闲连接，http.Client.CloseIdleConnections 会调用它
+func (r *TransportRegistry) CloseIdleConnections() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.transports {
+		t.CloseIdleConnections()
+	}
+	r.fallback.CloseIdleConnections()
+}
@@ -0,0 +1,132 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试 NewTransport 应用连接池选项且不修改 http.DefaultTransport
+func TestNewTransport(t *testing.T) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	tr := NewTransport(
+		WithMaxConnsPerHost(50),
+		WithMaxIdleConns(200),
+		WithMaxIdleConnsPerHost(20),
+		WithIdleConnTimeout(45*time.Second),
+		WithTLSHandshakeTimeout(3*time.Second),
+		WithResponseHeaderTimeout(2*time.Second),
+		WithTLSConfig(tlsCfg),
+		WithTransportFunc(func(t *http.Transport) { t.DisableCompression = true }),
+	)
+	assert.Equal(t, 50, tr.MaxConnsPerHost)
+	assert.Equal(t, 200, tr.MaxIdleConns)
+	assert.Equal(t, 20, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 45*time.Second, tr.IdleConnTimeout)
+	assert.Equal(t, 3*time.Second, tr.TLSHandshakeTimeout)
+	assert.Equal(t, 2*time.Second, tr.ResponseHeaderTimeout)
+	assert.Same(t, tlsCfg, tr.TLSClientConfig)
+	assert.True(t, tr.DisableCompression)
+	assert.NotNil(t, tr.DialContext)
+	assert.Equal(t, 0, http.DefaultTransport.(*http.Transport).MaxConnsPerHost)
+}
+
+// 测试 WithTransportOptions 为 HTTPClient 创建独立的连接池，WithTransport 优先
+func TestHTTPClient_TransportOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewHTTPClient(WithBaseURL(srv.URL), WithMaxRetries(0),
+		WithTransportOptions(WithMaxConnsPerHost(8)),
+		WithTransportOptions(WithIdleConnTimeout(time.Second)),
+	)
+	tr, ok := c.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, 8, tr.MaxConnsPerHost)
+	assert.Equal(t, time.Second, tr.IdleConnTimeout)
+	resp, err := c.Get(context.Background(), "/", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	registry := NewTransportRegistry()
+	c = NewHTTPClient(WithMaxRetries(0), WithTransportOptions(WithMaxConnsPerHost(8)), WithTransport(registry))
+	assert.Same(t, registry, c.client.Transport)
+}
+
+// 测试代理配置，WithProxyURL 无法解析时返回 ErrInvalidURL
+func TestTransport_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 代理收到的是完整的URL
+		_, _ = w.Write([]byte("via proxy: " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	c := NewHTTPClient(WithMaxRetries(0), WithTransportOptions(WithProxyURL(proxy.URL)))
+	resp, err := c.Get(context.Background(), "http://partner.test/orders", nil)
+	require.NoError(t, err)
+	body := readAll(t, resp)
+	assert.Equal(t, "via proxy: http://partner.test/orders", body)
+
+	c = NewHTTPClient(WithMaxRetries(0), WithTransportOptions(WithProxyURL("://bad")))
+	_, err = c.Get(context.Background(), "http://partner.test/orders", nil)
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+// 测试注册表按主机选择连接池
+func TestTransportRegistry(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxy"))
+	}))
+	defer proxy.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+	directURL, _ := url.Parse(direct.URL)
+
+	registry := NewTransportRegistry(WithMaxIdleConnsPerHost(10), WithProxy(nil))
+	registry.Register("Payments.Partner.test", WithMaxConnsPerHost(4), WithProxyURL(proxy.URL))
+	registry.Register("logistics.partner.test:8443", WithMaxConnsPerHost(16))
+
+	pay := registry.Transport("payments.partner.test:443")
+	assert.Equal(t, 4, pay.MaxConnsPerHost)
+	assert.Equal(t, 10, pay.MaxIdleConnsPerHost, "默认选项应用于所有主机")
+	assert.Equal(t, 16, registry.Transport("logistics.partner.test:8443").MaxConnsPerHost)
+	assert.Same(t, registry.Transport("other.test"), registry.Transport("logistics.partner.test:9000"))
+	assert.Equal(t, 10, registry.Transport("other.test").MaxIdleConnsPerHost)
+
+	client := &http.Client{Transport: registry}
+	resp, err := client.Get("http://payments.partner.test/charge")
+	require.NoError(t, err)
+	assert.Equal(t, "proxy", readAll(t, resp))
+	resp, err = client.Get(direct.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "direct", readAll(t, resp))
+
+	// 重新注册替换连接池
+	registry.Register(directURL.Host, WithMaxConnsPerHost(2))
+	assert.Equal(t, 2, registry.Transport(directURL.Host).MaxConnsPerHost)
+	resp, err = client.Get(direct.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "direct", readAll(t, resp))
+	client.CloseIdleConnections()
+}
+
+func readAll(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
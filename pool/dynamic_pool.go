@@ -0,0 +1,344 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现动态伸缩的协程池 DynamicPool。工作协程数量在最小值和最大值之间按负载增长，
+// 空闲超过一定时间的协程被回收；任务的 panic 被恢复为错误，任务结果通过 Future 返回。
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// 任务池相关错误定义
+var (
+	ErrPoolClosed = errors.New("ggu: 任务池已关闭")
+	ErrPoolFull   = errors.New("ggu: 任务池已满，任务被拒绝")
+)
+
+const (
+	defaultQueueSize   = 1024
+	defaultIdleTimeout = time.Minute
+)
+
+///////////////////// 动态协程池配置 /////////////////////
+
+// DynamicPoolOption 动态协程池配置选项
+type DynamicPoolOption func(*dynamicPoolConfig)
+
+type dynamicPoolConfig struct {
+	minWorkers   int
+	maxWorkers   int
+	queueSize    int
+	idleTimeout  time.Duration
+	panicHandler func(*syncx.PanicError)
+	clock        clock.Clock
+}
+
+// WithMinWorkers 设置常驻的最小工作协程数量，创建时即启动，不会被空闲回收，默认0
+func WithMinWorkers(n int) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		if n >= 0 {
+			c.minWorkers = n
+		}
+	}
+}
+
+// WithMaxWorkers 设置工作协程数量上限，默认为 CPU 核数
+func WithMaxWorkers(n int) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		if n > 0 {
+			c.maxWorkers = n
+		}
+	}
+}
+
+// WithQueueSize 设置等待队列容量，工作协程达到上限且队列已满时拒绝任务，默认1024
+func WithQueueSize(n int) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		if n >= 0 {
+			c.queueSize = n
+		}
+	}
+}
+
+// WithIdleTimeout 设置工作协程的空闲回收时间，默认1分钟
+func WithIdleTimeout(d time.Duration) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		if d > 0 {
+			c.idleTimeout = d
+		}
+	}
+}
+
+// WithPanicHandler 设置通过 Submit 提交的任务 panic 时的回调，
+// 通过 Go 提交的任务的 panic 会写入 Future，不会调用该回调
+func WithPanicHandler(fn func(*syncx.PanicError)) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		c.panicHandler = fn
+	}
+}
+
+// WithPoolClock 设置空闲回收使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithPoolClock(clk clock.Clock) DynamicPoolOption {
+	return func(c *dynamicPoolConfig) {
+		c.clock = clk
+	}
+}
+
+///////////////////// 动态协程池 /////////////////////
+
+// DynamicPoolStats 动态协程池的运行指标
+type DynamicPoolStats struct {
+	Workers     int   // 当前工作协程数量
+	IdleWorkers int   // 空闲的工作协程数量
+	BusyWorkers int   // 正在执行任务的工作协程数量
+	QueueLength int   // 等待执行的任务数量
+	Completed   int64 // 成功完成的任务数量
+	Failed      int64 // 返回错误或 panic 的任务数量
+	Rejected    int64 // 被拒绝的任务数量
+}
+
+// DynamicPool 动态伸缩的协程池，实现 TaskPool 接口，并发安全
+type DynamicPool struct {
+	cfg dynamicPoolConfig
+
+	mu      sync.Mutex
+	queue   chan func() error
+	workers int
+	closed  bool
+	wg      sync.WaitGroup
+
+	idle      atomic.Int32
+	busy      atomic.Int32
+	completed atomic.Int64
+	failed    atomic.Int64
+	rejected  atomic.Int64
+}
+
+// NewDynamicPool 创建动态协程池
+func NewDynamicPool(opts ...DynamicPoolOption) *DynamicPool {
+	cfg := dynamicPoolConfig{
+		maxWorkers:  runtime.NumCPU(),
+		queueSize:   defaultQueueSize,
+		idleTimeout: defaultIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.minWorkers > cfg.maxWorkers {
+		cfg.maxWorkers = cfg.minWorkers
+	}
+	cfg.clock = clock.OrReal(cfg.clock)
+
+	p := &DynamicPool{
+		cfg:   cfg,
+		queue: make(chan func() error, cfg.queueSize),
+	}
+	p.mu.Lock()
+	for i := 0; i < cfg.minWorkers; i++ {
+		p.spawnLocked(nil)
+	}
+	p.mu.Unlock()
+	return p
+}
+
+// Submit 提交任务，任务池已满时返回 ErrPoolFull，关闭后返回 ErrPoolClosed。
+// 任务的 panic 被恢复并计入失败数量，不会导致工作协程退出。
+func (p *DynamicPool) Submit(task Task) error {
+	return p.submit(func() error {
+		task()
+		return nil
+	})
+}
+
+// Go 向任务池提交一个有返回值的任务，结果、错误或 panic 转换成的 *syncx.PanicError 写入返回的 Future。
+// 任务被拒绝时 Future 立即完成，错误为 ErrPoolFull 或 ErrPoolClosed；
+// 任务开始执行前 ctx 已经结束时不再执行，Future 收到 ctx.Err()。
+func Go[T any](ctx context.Context, p *DynamicPool, fn func(ctx context.Context) (T, error)) *syncx.Future[T] {
+	future := syncx.NewFuture[T]()
+	err := p.submit(func() error {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			future.Complete(zero, err)
+			return err
+		}
+		val, err := callWithRecover(ctx, fn)
+		future.Complete(val, err)
+		return err
+	})
+	if err != nil {
+		var zero T
+		future.Complete(zero, err)
+	}
+	return future
+}
+
+// Running 返回正在执行任务的工作协程数量
+func (p *DynamicPool) Running() int {
+	return int(p.busy.Load())
+}
+
+// Cap 返回工作协程数量上限
+func (p *DynamicPool) Cap() int {
+	return p.cfg.maxWorkers
+}
+
+// Stats 返回任务池的运行指标
+func (p *DynamicPool) Stats() DynamicPoolStats {
+	p.mu.Lock()
+	workers := p.workers
+	p.mu.Unlock()
+	return DynamicPoolStats{
+		Workers:     workers,
+		IdleWorkers: int(p.idle.Load()),
+		BusyWorkers: int(p.busy.Load()),
+		QueueLength: len(p.queue),
+		Completed:   p.completed.Load(),
+		Failed:      p.failed.Load(),
+		Rejected:    p.rejected.Load(),
+	}
+}
+
+// Shutdown 关闭任务池，不再接受新任务，等待队列中的任务全部执行完成，可以重复调用
+func (p *DynamicPool) Shutdown() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *DynamicPool) submit(task func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.rejected.Add(1)
+		return ErrPoolClosed
+	}
+	// 没有空闲协程时优先扩容，减少任务在队列中的等待
+	if p.idle.Load() == 0 && p.workers < p.cfg.maxWorkers {
+		p.spawnLocked(task)
+		return nil
+	}
+	select {
+	case p.queue <- task:
+		return nil
+	default:
+	}
+	if p.workers < p.cfg.maxWorkers {
+		p.spawnLocked(task)
+		return nil
+	}
+	p.rejected.Add(1)
+	return ErrPoolFull
+}
+
+// spawnLocked 启动一个工作协程，first 不为 nil 时作为它的第一个任务，调用方需持有锁
+func (p *DynamicPool) spawnLocked(first func() error) {
+	p.workers++
+	p.wg.Add(1)
+	// 在启动协程前创建定时器，保证假时钟推进时定时器已经存在
+	timer := p.cfg.clock.NewTimer(p.cfg.idleTimeout)
+	go p.worker(first, timer)
+}
+
+func (p *DynamicPool) worker(task func() error, timer clock.Timer) {
+	defer p.wg.Done()
+	defer timer.Stop()
+	for {
+		if task != nil {
+			p.run(task)
+			task = nil
+			resetTimer(timer, p.cfg.idleTimeout)
+		}
+		p.idle.Add(1)
+		select {
+		case t, ok := <-p.queue:
+			p.idle.Add(-1)
+			if !ok {
+				p.exit()
+				return
+			}
+			task = t
+		case <-timer.C():
+			p.idle.Add(-1)
+			if p.reap() {
+				return
+			}
+			timer.Reset(p.cfg.idleTimeout)
+		}
+	}
+}
+
+// reap 空闲超时的工作协程在数量多于最小值时退出。
+// 提交任务时可能刚把任务放入队列并认为该协程空闲，因此队列不为空时不退出。
+func (p *DynamicPool) reap() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.workers <= p.cfg.minWorkers || len(p.queue) > 0 {
+		return false
+	}
+	p.workers--
+	return true
+}
+
+func (p *DynamicPool) exit() {
+	p.mu.Lock()
+	p.workers--
+	p.mu.Unlock()
+}
+
+// run 执行任务并统计结果
+func (p *DynamicPool) run(task func() error) {
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				pe := &syncx.PanicError{Value: r, Stack: debug.Stack()}
+				if p.cfg.panicHandler != nil {
+					p.cfg.panicHandler(pe)
+				}
+				err = pe
+			}
+		}()
+		return task()
+	}()
+	if err != nil {
+		p.failed.Add(1)
+		return
+	}
+	p.completed.Add(1)
+}
+
+// callWithRecover 执行任务函数，把 panic 转换为 *syncx.PanicError
+func callWithRecover[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &syncx.PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// resetTimer 停止定时器并清空未读取的到期时间后重新计时
+func resetTimer(t clock.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+	t.Reset(d)
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 dynamic_pool.go 的测试用例，覆盖 Future 结果、panic 恢复、扩容与拒绝、
+// 空闲回收以及运行指标。
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// 测试通过 Future 获取任务结果和错误
+func TestDynamicPool_Go(t *testing.T) {
+	p := NewDynamicPool(WithMaxWorkers(4))
+	defer p.Shutdown()
+
+	futures := make([]*syncx.Future[int], 20)
+	for i := range futures {
+		i := i
+		futures[i] = Go(context.Background(), p, func(ctx context.Context) (int, error) {
+			return i * i, nil
+		})
+	}
+	for i, f := range futures {
+		v, err := f.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, i*i, v)
+	}
+
+	errBiz := errors.New("业务错误")
+	_, err := Go(context.Background(), p, func(ctx context.Context) (int, error) {
+		return 0, errBiz
+	}).Get(context.Background())
+	assert.ErrorIs(t, err, errBiz)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Go(ctx, p, func(ctx context.Context) (int, error) {
+		t.Error("ctx 已结束的任务不应执行")
+		return 0, nil
+	}).Get(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// 测试 panic 被恢复为错误，工作协程继续工作
+func TestDynamicPool_Panic(t *testing.T) {
+	var handled atomic.Int32
+	p := NewDynamicPool(WithMaxWorkers(1), WithPanicHandler(func(pe *syncx.PanicError) {
+		handled.Add(1)
+	}))
+
+	_, err := Go(context.Background(), p, func(ctx context.Context) (string, error) {
+		panic("boom")
+	}).Get(context.Background())
+	var pe *syncx.PanicError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "boom", pe.Value)
+
+	require.NoError(t, p.Submit(func() { panic("boom") }))
+	v, err := Go(context.Background(), p, func(ctx context.Context) (string, error) {
+		return "ok", nil
+	}).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", v)
+
+	p.Shutdown()
+	assert.Equal(t, int32(1), handled.Load())
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.Completed)
+	assert.Equal(t, int64(2), stats.Failed)
+}
+
+// 测试按负载扩容到上限，队列满后拒绝
+func TestDynamicPool_GrowAndReject(t *testing.T) {
+	p := NewDynamicPool(WithMaxWorkers(2), WithQueueSize(1))
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(2)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, p.Submit(func() {
+			started.Done()
+			<-release
+		}))
+	}
+	started.Wait()
+	require.NoError(t, p.Submit(func() {}))
+
+	stats := p.Stats()
+	assert.Equal(t, 2, stats.Workers)
+	assert.Equal(t, 2, stats.BusyWorkers)
+	assert.Equal(t, 1, stats.QueueLength)
+	assert.Equal(t, 2, p.Running())
+	assert.Equal(t, 2, p.Cap())
+
+	assert.ErrorIs(t, p.Submit(func() {}), ErrPoolFull)
+	_, err := Go(context.Background(), p, func(ctx context.Context) (int, error) { return 1, nil }).Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolFull)
+
+	close(release)
+	p.Shutdown()
+	stats = p.Stats()
+	assert.Equal(t, 0, stats.Workers)
+	assert.Equal(t, int64(3), stats.Completed)
+	assert.Equal(t, int64(2), stats.Rejected)
+	assert.ErrorIs(t, p.Submit(func() {}), ErrPoolClosed)
+}
+
+// 测试空闲的工作协程被回收到最小数量
+func TestDynamicPool_IdleReap(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := NewDynamicPool(WithMinWorkers(1), WithMaxWorkers(3), WithIdleTimeout(time.Minute), WithPoolClock(clk))
+	defer p.Shutdown()
+	assert.Equal(t, 1, p.Stats().Workers)
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(3)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.Submit(func() {
+			started.Done()
+			<-release
+		}))
+	}
+	started.Wait()
+	assert.Equal(t, 3, p.Stats().Workers)
+	close(release)
+
+	// 等待所有工作协程回到空闲状态
+	waitFor(t, func() bool { return p.Stats().IdleWorkers == 3 })
+	clk.Advance(59 * time.Second)
+	assert.Equal(t, 3, p.Stats().Workers)
+	clk.Advance(time.Second)
+	waitFor(t, func() bool { return p.Stats().Workers == 1 })
+
+	// 回收后仍可以继续扩容
+	v, err := Go(context.Background(), p, func(ctx context.Context) (int, error) { return 7, nil }).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 7, v)
+}
+
+// 测试关闭时执行完队列中的任务
+func TestDynamicPool_ShutdownDrains(t *testing.T) {
+	p := NewDynamicPool(WithMaxWorkers(1), WithQueueSize(100))
+	var sum atomic.Int32
+	for i := 0; i < 50; i++ {
+		require.NoError(t, p.Submit(func() {
+			time.Sleep(100 * time.Microsecond)
+			sum.Add(1)
+		}))
+	}
+	p.Shutdown()
+	p.Shutdown()
+	assert.Equal(t, int32(50), sum.Load())
+}
+
+// 测试任务 panic 不会导致固定任务池的工作协程退出
+func TestFixedTaskPool_Panic(t *testing.T) {
+	pool := NewFixedTaskPool(1)
+	require.NoError(t, pool.Submit(func() { panic("boom") }))
+	var done atomic.Bool
+	require.NoError(t, pool.Submit(func() { done.Store(true) }))
+	pool.Shutdown()
+	assert.True(t, done.Load())
+}
+
+// 测试 SubmitContext 超时后取消任务的 ctx
+func TestTimeoutTaskPool_SubmitContext(t *testing.T) {
+	pool := NewTimeoutTaskPool(1, 10*time.Millisecond)
+	canceled := make(chan struct{})
+	err := pool.SubmitContext(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+	assert.ErrorIs(t, err, ErrTaskTimeout)
+	<-canceled
+	assert.NoError(t, pool.SubmitContext(func(ctx context.Context) {}))
+	pool.Shutdown()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待条件超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
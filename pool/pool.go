@@ -1,8 +1,10 @@
 package pool
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -61,8 +63,8 @@ type FixedTaskPool struct {
 	wg      sync.WaitGroup
 	cap     int
 	closed  chan struct{}
-	mu      sync.Mutex
-	running int
+	mu      sync.Mutex // 保护关闭状态与 wg.Add 的先后顺序
+	running atomic.Int32
 }
 
 // NewFixedTaskPool 创建固定容量的任务池
@@ -81,43 +83,50 @@ func NewFixedTaskPool(capacity int) *FixedTaskPool {
 func (p *FixedTaskPool) worker() {
 	for {
 		select {
-		case task, ok := <-p.tasks:
-			if !ok {
-				return
-			}
-			p.mu.Lock()
-			p.running++
-			p.mu.Unlock()
-			task()
-			p.mu.Lock()
-			p.running--
-			p.mu.Unlock()
-			p.wg.Done()
+		case task := <-p.tasks:
+			p.run(task)
 		case <-p.closed:
 			return
 		}
 	}
 }
 
-// Submit 提交任务到池
+// run 执行任务，任务 panic 时恢复，避免工作协程退出
+func (p *FixedTaskPool) run(task Task) {
+	p.running.Add(1)
+	defer func() {
+		_ = recover()
+		p.running.Add(-1)
+		p.wg.Done()
+	}()
+	task()
+}
+
+// Submit 提交任务到池，所有工作协程都忙时阻塞，任务池关闭后返回 ErrPoolClosed
 func (p *FixedTaskPool) Submit(task Task) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	select {
 	case <-p.closed:
-		return errors.New("任务池已关闭")
+		p.mu.Unlock()
+		return ErrPoolClosed
 	default:
 	}
 	p.wg.Add(1)
-	p.tasks <- task
-	return nil
+	p.mu.Unlock()
+
+	// 不持有锁等待空闲的工作协程，避免阻塞其他提交者和关闭
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-p.closed:
+		p.wg.Done()
+		return ErrPoolClosed
+	}
 }
 
 // Running 返回当前运行中的任务数
 func (p *FixedTaskPool) Running() int {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return p.running
+	return int(p.running.Load())
 }
 
 // Cap 返回池容量
@@ -130,11 +139,8 @@ func (p *FixedTaskPool) Shutdown() {
 	p.mu.Lock()
 	select {
 	case <-p.closed:
-		p.mu.Unlock()
-		return
 	default:
 		close(p.closed)
-		close(p.tasks)
 	}
 	p.mu.Unlock()
 	p.wg.Wait()
@@ -142,6 +148,9 @@ func (p *FixedTaskPool) Shutdown() {
 
 ///////////////////// 扩展：带超时的任务池 /////////////////////
 
+// ErrTaskTimeout 任务执行超时
+var ErrTaskTimeout = errors.New("ggu: 任务执行超时")
+
 // TimeoutTaskPool 支持任务超时的任务池
 type TimeoutTaskPool struct {
 	*FixedTaskPool
@@ -156,21 +165,36 @@ func NewTimeoutTaskPool(capacity int, timeout time.Duration) *TimeoutTaskPool {
 	}
 }
 
-// Submit 提交带超时的任务
+// Submit 提交带超时的任务并等待其完成，超时返回 ErrTaskTimeout。
+// 超时后任务仍会在工作协程中继续执行直到结束，需要提前结束的任务请使用 SubmitContext。
 func (p *TimeoutTaskPool) Submit(task Task) error {
+	return p.SubmitContext(func(ctx context.Context) {
+		task()
+	})
+}
+
+// SubmitContext 提交带超时的任务并等待其完成，超时返回 ErrTaskTimeout，
+// 同时取消传给任务的 ctx，任务应在 ctx 结束后尽快返回以释放工作协程
+func (p *TimeoutTaskPool) SubmitContext(task func(ctx context.Context)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	done := make(chan struct{})
 	wrapped := func() {
+		defer cancel()
 		defer close(done)
-		task()
+		task(ctx)
 	}
-	err := p.FixedTaskPool.Submit(wrapped)
-	if err != nil {
+	if err := p.FixedTaskPool.Submit(wrapped); err != nil {
+		cancel()
 		return err
 	}
 	select {
 	case <-done:
 		return nil
-	case <-time.After(p.timeout):
-		return errors.New("任务执行超时")
+	case <-ctx.Done():
+		// 任务结束时也会取消 ctx，只有到达截止时间才算超时
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTaskTimeout
+		}
+		return nil
 	}
 }
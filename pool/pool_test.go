@@ -68,6 +68,6 @@ func TestTimeoutTaskPool_Basic(t *testing.T) {
 	// 超时
 	err = pool.Submit(func() { time.Sleep(100 * time.Millisecond) })
 	assert.Error(t, err)
-	assert.True(t, errors.Is(err, errors.New("任务执行超时")))
+	assert.True(t, errors.Is(err, ErrTaskTimeout))
 	pool.Shutdown()
 }
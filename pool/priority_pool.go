@@ -0,0 +1,437 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现按优先级和租户公平调度的任务池 PriorityPool。每个租户拥有独立的队列，
+// 租户内按任务优先级出队，租户之间按权重做加权公平调度（stride scheduling），
+// 避免大批量的后台任务饿死面向用户的任务。队列已满时按拒绝策略处理新任务。
+
+package pool
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// ErrRejectTimeout 阻塞等待队列空位超时
+var ErrRejectTimeout = errors.New("ggu: 等待任务队列空位超时")
+
+// 常用的任务优先级，数值越大越先执行
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// DefaultTenant 通过 Submit 提交的任务所属的租户
+const DefaultTenant = "default"
+
+const (
+	defaultPriorityQueueCapacity = 1024
+	// strideScale 租户每执行一个任务，虚拟时间增加 strideScale/weight
+	strideScale = 1 << 20
+)
+
+///////////////////// 拒绝策略 /////////////////////
+
+// RejectPolicy 队列已满时对新任务的处理策略
+type RejectPolicy int
+
+const (
+	// RejectAbort 直接拒绝，返回 ErrPoolFull，默认策略
+	RejectAbort RejectPolicy = iota
+	// RejectCallerRuns 在提交者的协程中直接执行任务，对提交方形成背压
+	RejectCallerRuns
+	// RejectDropOldest 丢弃队列中最早提交的任务，再放入新任务
+	RejectDropOldest
+	// RejectBlock 阻塞等待队列空位，直到超过 WithBlockTimeout 设置的时间或 ctx 结束
+	RejectBlock
+)
+
+///////////////////// 优先级任务池配置 /////////////////////
+
+// PriorityPoolOption 优先级任务池配置选项
+type PriorityPoolOption func(*priorityPoolConfig)
+
+type tenantWeight struct {
+	name   string
+	weight int
+}
+
+type priorityPoolConfig struct {
+	capacity     int
+	tenants      []tenantWeight
+	rejectPolicy RejectPolicy
+	blockTimeout time.Duration
+	panicHandler func(*syncx.PanicError)
+	dropHandler  func(tenant string, task Task)
+}
+
+// WithTenant 声明租户及其权重，权重越大分到的执行份额越多，权重不大于0时按1处理。
+// 未声明的租户在第一次提交任务时以权重1创建。
+func WithTenant(name string, weight int) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		if weight <= 0 {
+			weight = 1
+		}
+		c.tenants = append(c.tenants, tenantWeight{name: name, weight: weight})
+	}
+}
+
+// WithPriorityQueueCapacity 设置所有租户队列中等待任务的总数上限，默认1024
+func WithPriorityQueueCapacity(n int) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		if n > 0 {
+			c.capacity = n
+		}
+	}
+}
+
+// WithRejectPolicy 设置队列已满时的拒绝策略，默认 RejectAbort
+func WithRejectPolicy(policy RejectPolicy) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		c.rejectPolicy = policy
+	}
+}
+
+// WithBlockTimeout 设置 RejectBlock 策略下等待队列空位的最长时间，不大于0时只受 ctx 限制
+func WithBlockTimeout(d time.Duration) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		c.blockTimeout = d
+	}
+}
+
+// WithPriorityPanicHandler 设置任务 panic 时的回调
+func WithPriorityPanicHandler(fn func(*syncx.PanicError)) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		c.panicHandler = fn
+	}
+}
+
+// WithDropHandler 设置 RejectDropOldest 策略下任务被丢弃时的回调，在提交者的协程中调用
+func WithDropHandler(fn func(tenant string, task Task)) PriorityPoolOption {
+	return func(c *priorityPoolConfig) {
+		c.dropHandler = fn
+	}
+}
+
+///////////////////// 租户队列 /////////////////////
+
+// priorityTask 队列中的任务
+type priorityTask struct {
+	task     Task
+	priority int
+	seq      uint64 // 提交顺序，相同优先级先进先出
+}
+
+// taskHeap 按优先级从高到低、提交顺序从早到晚排列的堆
+type taskHeap []*priorityTask
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x any)   { *h = append(*h, x.(*priorityTask)) }
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// tenantQueue 一个租户的队列
+type tenantQueue struct {
+	name   string
+	weight int
+	pass   uint64 // 虚拟时间，调度时选择 pass 最小的非空租户
+	tasks  taskHeap
+}
+
+///////////////////// 优先级任务池 /////////////////////
+
+// PriorityPoolStats 优先级任务池的运行指标
+type PriorityPoolStats struct {
+	Workers     int            // 工作协程数量
+	BusyWorkers int            // 正在执行任务的工作协程数量
+	QueueLength int            // 所有租户中等待执行的任务数量
+	Tenants     map[string]int // 每个租户等待执行的任务数量
+	Completed   int64          // 执行完成的任务数量
+	Failed      int64          // panic 的任务数量
+	Rejected    int64          // 被拒绝的任务数量
+	Dropped     int64          // 被 RejectDropOldest 丢弃的任务数量
+	CallerRuns  int64          // 由提交者执行的任务数量
+}
+
+// PriorityPool 按优先级和租户公平调度的任务池，实现 TaskPool 接口，并发安全
+type PriorityPool struct {
+	cfg     priorityPoolConfig
+	workers int
+
+	mu      sync.Mutex
+	cond    *sync.Cond    // 有新任务或关闭时唤醒工作协程
+	space   chan struct{} // 有任务出队时关闭并替换，唤醒等待空位的提交者
+	tenants map[string]*tenantQueue
+	order   []*tenantQueue // 租户的声明顺序，虚拟时间相同时按该顺序调度
+	queued  int
+	seq     uint64
+	vnow    uint64 // 最近一次出队的租户的虚拟时间
+	closed  bool
+	wg      sync.WaitGroup
+
+	busy       atomic.Int32
+	completed  atomic.Int64
+	failed     atomic.Int64
+	rejected   atomic.Int64
+	dropped    atomic.Int64
+	callerRuns atomic.Int64
+}
+
+// NewPriorityPool 创建优先级任务池，workers 为工作协程数量，不大于0时按1处理
+func NewPriorityPool(workers int, opts ...PriorityPoolOption) *PriorityPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	cfg := priorityPoolConfig{
+		capacity: defaultPriorityQueueCapacity,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	p := &PriorityPool{
+		cfg:     cfg,
+		workers: workers,
+		space:   make(chan struct{}),
+		tenants: make(map[string]*tenantQueue),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for _, t := range cfg.tenants {
+		if q, ok := p.tenants[t.name]; ok {
+			q.weight = t.weight
+			continue
+		}
+		p.tenantLocked(t.name, t.weight)
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Submit 以普通优先级向默认租户提交任务
+func (p *PriorityPool) Submit(task Task) error {
+	return p.SubmitTask(context.Background(), DefaultTenant, PriorityNormal, task)
+}
+
+// SubmitTask 向指定租户提交指定优先级的任务。队列已满时按拒绝策略处理：
+// RejectAbort 返回 ErrPoolFull；RejectCallerRuns 在当前协程中执行任务并返回 nil；
+// RejectDropOldest 丢弃最早提交的任务；RejectBlock 等待空位，超时返回 ErrRejectTimeout，ctx 结束返回 ctx.Err()。
+func (p *PriorityPool) SubmitTask(ctx context.Context, tenant string, priority int, task Task) error {
+	var deadline <-chan time.Time
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.rejected.Add(1)
+			return ErrPoolClosed
+		}
+		if p.queued < p.cfg.capacity {
+			p.pushLocked(tenant, priority, task)
+			p.mu.Unlock()
+			return nil
+		}
+
+		switch p.cfg.rejectPolicy {
+		case RejectCallerRuns:
+			p.mu.Unlock()
+			p.callerRuns.Add(1)
+			p.execute(task)
+			return nil
+		case RejectDropOldest:
+			victim, dropped := p.dropOldestLocked()
+			p.pushLocked(tenant, priority, task)
+			p.mu.Unlock()
+			p.dropped.Add(1)
+			if p.cfg.dropHandler != nil {
+				p.cfg.dropHandler(victim.name, dropped.task)
+			}
+			return nil
+		case RejectBlock:
+			space := p.space
+			p.mu.Unlock()
+			if deadline == nil && p.cfg.blockTimeout > 0 {
+				timer := time.NewTimer(p.cfg.blockTimeout)
+				defer timer.Stop()
+				deadline = timer.C
+			}
+			select {
+			case <-space:
+				continue
+			case <-deadline:
+				p.rejected.Add(1)
+				return ErrRejectTimeout
+			case <-ctx.Done():
+				p.rejected.Add(1)
+				return ctx.Err()
+			}
+		default:
+			p.mu.Unlock()
+			p.rejected.Add(1)
+			return ErrPoolFull
+		}
+	}
+}
+
+// Running 返回正在执行任务的工作协程数量
+func (p *PriorityPool) Running() int {
+	return int(p.busy.Load())
+}
+
+// Cap 返回工作协程数量
+func (p *PriorityPool) Cap() int {
+	return p.workers
+}
+
+// Stats 返回任务池的运行指标
+func (p *PriorityPool) Stats() PriorityPoolStats {
+	p.mu.Lock()
+	tenants := make(map[string]int, len(p.tenants))
+	for name, q := range p.tenants {
+		tenants[name] = q.tasks.Len()
+	}
+	queued := p.queued
+	p.mu.Unlock()
+	return PriorityPoolStats{
+		Workers:     p.workers,
+		BusyWorkers: int(p.busy.Load()),
+		QueueLength: queued,
+		Tenants:     tenants,
+		Completed:   p.completed.Load(),
+		Failed:      p.failed.Load(),
+		Rejected:    p.rejected.Load(),
+		Dropped:     p.dropped.Load(),
+		CallerRuns:  p.callerRuns.Load(),
+	}
+}
+
+// Shutdown 关闭任务池，不再接受新任务，等待队列中的任务全部执行完成，可以重复调用
+func (p *PriorityPool) Shutdown() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.cond.Broadcast()
+		p.notifySpaceLocked()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// tenantLocked 获取租户队列，不存在时以指定权重创建，调用方需持有锁
+func (p *PriorityPool) tenantLocked(name string, weight int) *tenantQueue {
+	q, ok := p.tenants[name]
+	if !ok {
+		q = &tenantQueue{name: name, weight: weight}
+		p.tenants[name] = q
+		p.order = append(p.order, q)
+	}
+	return q
+}
+
+func (p *PriorityPool) pushLocked(tenant string, priority int, task Task) {
+	q := p.tenantLocked(tenant, 1)
+	if q.tasks.Len() == 0 && q.pass < p.vnow {
+		// 空闲后重新活跃的租户从当前虚拟时间开始，不能用积攒的份额抢占其他租户
+		q.pass = p.vnow
+	}
+	p.seq++
+	heap.Push(&q.tasks, &priorityTask{task: task, priority: priority, seq: p.seq})
+	p.queued++
+	p.cond.Signal()
+}
+
+// popLocked 选出虚拟时间最小的非空租户，取出其优先级最高的任务，调用方需持有锁
+func (p *PriorityPool) popLocked() Task {
+	var next *tenantQueue
+	for _, q := range p.order {
+		if q.tasks.Len() > 0 && (next == nil || q.pass < next.pass) {
+			next = q
+		}
+	}
+	if next == nil {
+		return nil
+	}
+	item := heap.Pop(&next.tasks).(*priorityTask)
+	p.vnow = next.pass
+	next.pass += uint64(strideScale / next.weight)
+	p.queued--
+	p.notifySpaceLocked()
+	return item.task
+}
+
+// dropOldestLocked 丢弃所有租户中最早提交的任务，调用方需持有锁
+func (p *PriorityPool) dropOldestLocked() (*tenantQueue, *priorityTask) {
+	var victim *tenantQueue
+	idx := -1
+	for _, q := range p.order {
+		for i, item := range q.tasks {
+			if idx < 0 || item.seq < victim.tasks[idx].seq {
+				victim, idx = q, i
+			}
+		}
+	}
+	item := heap.Remove(&victim.tasks, idx).(*priorityTask)
+	p.queued--
+	return victim, item
+}
+
+// notifySpaceLocked 唤醒所有等待空位的提交者，调用方需持有锁
+func (p *PriorityPool) notifySpaceLocked() {
+	close(p.space)
+	p.space = make(chan struct{})
+}
+
+func (p *PriorityPool) worker() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for p.queued == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		task := p.popLocked()
+		p.mu.Unlock()
+		if task == nil {
+			// 已关闭且队列为空
+			return
+		}
+		p.busy.Add(1)
+		p.execute(task)
+		p.busy.Add(-1)
+	}
+}
+
+// execute 执行任务并统计结果，任务 panic 时恢复
+func (p *PriorityPool) execute(task Task) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.failed.Add(1)
+			if p.cfg.panicHandler != nil {
+				p.cfg.panicHandler(&syncx.PanicError{Value: r, Stack: debug.Stack()})
+			}
+			return
+		}
+		p.completed.Add(1)
+	}()
+	task()
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 priority_pool.go 的测试用例，覆盖优先级出队、租户加权公平调度、
+// 各拒绝策略以及 panic 恢复。
+
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// blockWorker 提交一个阻塞任务占住唯一的工作协程，返回释放函数
+func blockWorker(t *testing.T, p *PriorityPool) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	require.NoError(t, p.Submit(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	return func() { close(release) }
+}
+
+// recorder 记录任务的执行顺序
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) task(name string) Task {
+	return func() {
+		r.mu.Lock()
+		r.order = append(r.order, name)
+		r.mu.Unlock()
+	}
+}
+
+// 测试租户内按优先级出队，相同优先级先进先出
+func TestPriorityPool_Priority(t *testing.T) {
+	p := NewPriorityPool(1)
+	release := blockWorker(t, p)
+
+	var r recorder
+	ctx := context.Background()
+	require.NoError(t, p.SubmitTask(ctx, "orders", PriorityLow, r.task("export-1")))
+	require.NoError(t, p.SubmitTask(ctx, "orders", PriorityNormal, r.task("notify")))
+	require.NoError(t, p.SubmitTask(ctx, "orders", PriorityHigh, r.task("checkout-1")))
+	require.NoError(t, p.SubmitTask(ctx, "orders", PriorityLow, r.task("export-2")))
+	require.NoError(t, p.SubmitTask(ctx, "orders", PriorityHigh, r.task("checkout-2")))
+	assert.Equal(t, 5, p.Stats().Tenants["orders"])
+
+	release()
+	p.Shutdown()
+	assert.Equal(t, []string{"checkout-1", "checkout-2", "notify", "export-1", "export-2"}, r.order)
+	assert.Equal(t, int64(6), p.Stats().Completed)
+}
+
+// 测试租户之间按权重分配执行份额
+func TestPriorityPool_WeightedFair(t *testing.T) {
+	p := NewPriorityPool(1, WithTenant("checkout", 3), WithTenant("report", 1))
+	release := blockWorker(t, p)
+
+	var r recorder
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		require.NoError(t, p.SubmitTask(ctx, "report", PriorityNormal, r.task("R")))
+	}
+	for i := 0; i < 8; i++ {
+		require.NoError(t, p.SubmitTask(ctx, "checkout", PriorityNormal, r.task("C")))
+	}
+
+	release()
+	p.Shutdown()
+	require.Len(t, r.order, 16)
+	assert.Equal(t, []string{"C", "R", "C", "C", "C", "R", "C", "C"}, r.order[:8])
+
+	// 空闲后重新活跃的租户不能用积攒的份额抢占
+	p = NewPriorityPool(1, WithTenant("checkout", 1), WithTenant("report", 1))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, p.SubmitTask(ctx, "report", PriorityNormal, func() {}))
+	}
+	waitFor(t, func() bool { return p.Stats().Completed == 4 })
+	release = blockWorker(t, p)
+	r = recorder{}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, p.SubmitTask(ctx, "checkout", PriorityNormal, r.task("C")))
+		require.NoError(t, p.SubmitTask(ctx, "report", PriorityNormal, r.task("R")))
+	}
+	release()
+	p.Shutdown()
+	assert.Equal(t, []string{"C", "C", "R", "C", "R", "C", "R", "R"}, r.order)
+}
+
+// 测试各拒绝策略
+func TestPriorityPool_RejectPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("直接拒绝", func(t *testing.T) {
+		p := NewPriorityPool(1, WithPriorityQueueCapacity(1))
+		release := blockWorker(t, p)
+		require.NoError(t, p.Submit(func() {}))
+		assert.ErrorIs(t, p.Submit(func() {}), ErrPoolFull)
+		release()
+		p.Shutdown()
+		assert.Equal(t, int64(1), p.Stats().Rejected)
+		assert.ErrorIs(t, p.Submit(func() {}), ErrPoolClosed)
+	})
+
+	t.Run("调用者执行", func(t *testing.T) {
+		p := NewPriorityPool(1, WithPriorityQueueCapacity(1), WithRejectPolicy(RejectCallerRuns))
+		release := blockWorker(t, p)
+		require.NoError(t, p.Submit(func() {}))
+		ran := false
+		require.NoError(t, p.Submit(func() { ran = true }))
+		assert.True(t, ran, "队列已满时应在提交者协程中执行")
+		release()
+		p.Shutdown()
+		assert.Equal(t, int64(1), p.Stats().CallerRuns)
+	})
+
+	t.Run("丢弃最早", func(t *testing.T) {
+		var droppedTenant string
+		p := NewPriorityPool(1, WithPriorityQueueCapacity(2), WithRejectPolicy(RejectDropOldest),
+			WithDropHandler(func(tenant string, task Task) { droppedTenant = tenant }))
+		release := blockWorker(t, p)
+		var r recorder
+		require.NoError(t, p.SubmitTask(ctx, "report", PriorityHigh, r.task("old")))
+		require.NoError(t, p.SubmitTask(ctx, "checkout", PriorityNormal, r.task("mid")))
+		require.NoError(t, p.SubmitTask(ctx, "checkout", PriorityNormal, r.task("new")))
+		release()
+		p.Shutdown()
+		assert.Equal(t, "report", droppedTenant)
+		assert.Equal(t, []string{"mid", "new"}, r.order)
+		assert.Equal(t, int64(1), p.Stats().Dropped)
+	})
+
+	t.Run("阻塞等待", func(t *testing.T) {
+		p := NewPriorityPool(1, WithPriorityQueueCapacity(1), WithRejectPolicy(RejectBlock),
+			WithBlockTimeout(20*time.Millisecond))
+		release := blockWorker(t, p)
+		require.NoError(t, p.Submit(func() {}))
+
+		// 超时
+		assert.ErrorIs(t, p.Submit(func() {}), ErrRejectTimeout)
+
+		// ctx 结束
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.ErrorIs(t, p.SubmitTask(cctx, DefaultTenant, PriorityNormal, func() {}), context.Canceled)
+
+		// 等到空位后提交成功
+		errCh := make(chan error, 1)
+		go func() { errCh <- p.SubmitTask(ctx, DefaultTenant, PriorityNormal, func() {}) }()
+		time.Sleep(5 * time.Millisecond)
+		release()
+		assert.NoError(t, <-errCh)
+		p.Shutdown()
+		assert.Equal(t, int64(2), p.Stats().Rejected)
+	})
+}
+
+// 测试任务 panic 被恢复
+func TestPriorityPool_Panic(t *testing.T) {
+	var handled *syncx.PanicError
+	p := NewPriorityPool(1, WithPriorityPanicHandler(func(pe *syncx.PanicError) { handled = pe }))
+	require.NoError(t, p.Submit(func() { panic("boom") }))
+	require.NoError(t, p.Submit(func() {}))
+	p.Shutdown()
+	require.NotNil(t, handled)
+	assert.Equal(t, "boom", handled.Value)
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.Equal(t, int64(1), stats.Completed)
+	assert.Equal(t, 1, p.Cap())
+}
@@ -0,0 +1,482 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现有界的资源池 ResourcePool，适合复用 TCP 客户端、解析器等创建成本高的资源。
+// 借出的资源数量不超过上限，资源不足时按先进先出的顺序等待；借出前可以做健康检查，
+// 超过最长存活时间或空闲时间的资源会被销毁，后台按最小空闲数量预热资源。
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// ErrResourcePoolClosed 资源池已关闭
+var ErrResourcePoolClosed = errors.New("ggu: 资源池已关闭")
+
+const (
+	defaultResourcePoolSize = 8
+	defaultEvictionInterval = 30 * time.Second
+)
+
+///////////////////// 资源池配置 /////////////////////
+
+// ResourceFactory 资源的创建、健康检查与销毁函数，New 必须设置，其余可选
+type ResourceFactory[T any] struct {
+	// New 创建资源
+	New func(ctx context.Context) (T, error)
+	// Validate 借出前检查资源是否可用，返回错误时销毁该资源并换一个
+	Validate func(ctx context.Context, res T) error
+	// Destroy 销毁资源，例如关闭连接
+	Destroy func(res T) error
+}
+
+// ResourcePoolOption 资源池配置选项
+type ResourcePoolOption func(*resourcePoolConfig)
+
+type resourcePoolConfig struct {
+	maxSize          int
+	maxIdle          int
+	minIdle          int
+	maxLifetime      time.Duration
+	maxIdleTime      time.Duration
+	evictionInterval time.Duration
+	clock            clock.Clock
+}
+
+// WithMaxSize 设置同时存在的资源数量上限，默认8
+func WithMaxSize(n int) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		if n > 0 {
+			c.maxSize = n
+		}
+	}
+}
+
+// WithMaxIdle 设置保留的空闲资源数量上限，多余的资源归还时直接销毁，默认等于 WithMaxSize
+func WithMaxIdle(n int) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		if n >= 0 {
+			c.maxIdle = n
+		}
+	}
+}
+
+// WithMinIdle 设置后台预热的最小空闲资源数量，创建资源池和资源被销毁后在后台补足，默认0
+func WithMinIdle(n int) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		if n >= 0 {
+			c.minIdle = n
+		}
+	}
+}
+
+// WithMaxLifetime 设置资源从创建起的最长存活时间，超过后不再借出，默认不限制
+func WithMaxLifetime(d time.Duration) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		c.maxLifetime = d
+	}
+}
+
+// WithMaxIdleTime 设置资源的最长空闲时间，超过后被后台回收，默认不限制
+func WithMaxIdleTime(d time.Duration) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		c.maxIdleTime = d
+	}
+}
+
+// WithEvictionInterval 设置后台回收过期资源和预热的周期，默认30秒
+func WithEvictionInterval(d time.Duration) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		if d > 0 {
+			c.evictionInterval = d
+		}
+	}
+}
+
+// WithResourceClock 设置计算存活和空闲时间使用的时钟，默认使用系统时钟
+func WithResourceClock(clk clock.Clock) ResourcePoolOption {
+	return func(c *resourcePoolConfig) {
+		c.clock = clk
+	}
+}
+
+///////////////////// 资源池 /////////////////////
+
+// ResourcePoolStats 资源池的运行指标
+type ResourcePoolStats struct {
+	MaxSize            int           // 资源数量上限
+	InUse              int           // 借出的资源数量
+	Idle               int           // 空闲的资源数量
+	WaitCount          int64         // 需要等待才借到资源的次数
+	WaitDuration       time.Duration // 等待借出资源的累计时间
+	AcquireFailures    int64         // 等待超时、ctx 结束或创建失败导致借出失败的次数
+	Created            int64         // 创建的资源数量
+	Destroyed          int64         // 销毁的资源数量
+	ValidationFailures int64         // 借出前健康检查失败的次数
+}
+
+// idleResource 一个空闲的资源
+type idleResource[T any] struct {
+	value     T
+	createdAt time.Time
+	idleSince time.Time
+}
+
+// ResourcePool 有界的资源池，并发安全
+type ResourcePool[T any] struct {
+	factory ResourceFactory[T]
+	cfg     resourcePoolConfig
+	sem     *syncx.WeightedSemaphore
+
+	mu       sync.Mutex
+	idle     []idleResource[T] // 按归还时间排列，借出时取最近归还的
+	inUse    int
+	creating int // 后台预热中的资源数量
+	closed   bool
+
+	warm chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	waitCount          atomic.Int64
+	waitDuration       atomic.Int64
+	acquireFailures    atomic.Int64
+	created            atomic.Int64
+	destroyed          atomic.Int64
+	validationFailures atomic.Int64
+}
+
+// NewResourcePool 创建资源池，factory.New 为 nil 时 panic
+func NewResourcePool[T any](factory ResourceFactory[T], opts ...ResourcePoolOption) *ResourcePool[T] {
+	if factory.New == nil {
+		panic("ggu: 资源池的创建函数不能为 nil")
+	}
+	cfg := resourcePoolConfig{
+		maxSize:          defaultResourcePoolSize,
+		maxIdle:          -1,
+		evictionInterval: defaultEvictionInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxIdle < 0 || cfg.maxIdle > cfg.maxSize {
+		cfg.maxIdle = cfg.maxSize
+	}
+	if cfg.minIdle > cfg.maxIdle {
+		cfg.minIdle = cfg.maxIdle
+	}
+	cfg.clock = clock.OrReal(cfg.clock)
+
+	p := &ResourcePool[T]{
+		factory: factory,
+		cfg:     cfg,
+		sem:     syncx.NewWeightedSemaphore(int64(cfg.maxSize)),
+		warm:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	// 在启动协程前创建周期定时器，保证假时钟推进时定时器已经存在
+	ticker := cfg.clock.NewTicker(cfg.evictionInterval)
+	p.wg.Add(1)
+	go p.maintain(ticker)
+	p.requestWarmUp()
+	return p
+}
+
+// Resource 借出的资源，使用完毕后必须调用 Release 或 Invalidate 归还名额
+type Resource[T any] struct {
+	pool      *ResourcePool[T]
+	value     T
+	createdAt time.Time
+	returned  atomic.Bool
+}
+
+// Value 返回资源
+func (r *Resource[T]) Value() T {
+	return r.value
+}
+
+// CreatedAt 返回资源的创建时间
+func (r *Resource[T]) CreatedAt() time.Time {
+	return r.createdAt
+}
+
+// Release 把资源归还到资源池，重复调用无效
+func (r *Resource[T]) Release() {
+	if r.returned.CompareAndSwap(false, true) {
+		r.pool.release(r)
+	}
+}
+
+// Invalidate 销毁已损坏的资源并归还名额，重复调用或在 Release 之后调用无效
+func (r *Resource[T]) Invalidate() {
+	if r.returned.CompareAndSwap(false, true) {
+		r.pool.invalidate(r)
+	}
+}
+
+// Acquire 借出一个资源，优先复用空闲资源，没有空闲资源时创建新资源；
+// 资源数量达到上限时等待其他资源归还，直到 ctx 结束。
+func (p *ResourcePool[T]) Acquire(ctx context.Context) (*Resource[T], error) {
+	if p.isClosed() {
+		return nil, ErrResourcePoolClosed
+	}
+	if !p.sem.TryAcquire(1) {
+		p.waitCount.Add(1)
+		start := p.cfg.clock.Now()
+		err := p.sem.Acquire(ctx, 1)
+		p.waitDuration.Add(int64(p.cfg.clock.Since(start)))
+		if err != nil {
+			p.acquireFailures.Add(1)
+			return nil, err
+		}
+	}
+
+	res, err := p.take(ctx)
+	if err != nil {
+		p.sem.Release(1)
+		if !errors.Is(err, ErrResourcePoolClosed) {
+			p.acquireFailures.Add(1)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// Stats 返回资源池的运行指标
+func (p *ResourcePool[T]) Stats() ResourcePoolStats {
+	p.mu.Lock()
+	inUse, idle := p.inUse, len(p.idle)
+	p.mu.Unlock()
+	return ResourcePoolStats{
+		MaxSize:            p.cfg.maxSize,
+		InUse:              inUse,
+		Idle:               idle,
+		WaitCount:          p.waitCount.Load(),
+		WaitDuration:       time.Duration(p.waitDuration.Load()),
+		AcquireFailures:    p.acquireFailures.Load(),
+		Created:            p.created.Load(),
+		Destroyed:          p.destroyed.Load(),
+		ValidationFailures: p.validationFailures.Load(),
+	}
+}
+
+// Close 关闭资源池并销毁所有空闲资源，借出的资源在归还时销毁，可以重复调用。
+// 关闭后 Acquire 返回 ErrResourcePoolClosed，已经在等待的调用者在其他资源归还后返回该错误。
+func (p *ResourcePool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.done)
+	p.wg.Wait()
+	for _, r := range idle {
+		p.destroy(r.value)
+	}
+}
+
+// take 在已经获得名额的前提下取出一个可用的空闲资源，或者创建新资源
+func (p *ResourcePool[T]) take(ctx context.Context) (*Resource[T], error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrResourcePoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.inUse++
+			p.mu.Unlock()
+			return p.create(ctx)
+		}
+		r := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.inUse++
+		p.mu.Unlock()
+
+		if p.expired(r, p.cfg.clock.Now()) {
+			p.drop(r.value)
+			continue
+		}
+		if p.factory.Validate != nil {
+			if err := p.factory.Validate(ctx, r.value); err != nil {
+				p.validationFailures.Add(1)
+				p.drop(r.value)
+				continue
+			}
+		}
+		return &Resource[T]{pool: p, value: r.value, createdAt: r.createdAt}, nil
+	}
+}
+
+// create 创建一个借出的资源，调用方已经计入 inUse
+func (p *ResourcePool[T]) create(ctx context.Context) (*Resource[T], error) {
+	value, err := p.factory.New(ctx)
+	if err != nil {
+		p.mu.Lock()
+		p.inUse--
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.created.Add(1)
+	return &Resource[T]{pool: p, value: value, createdAt: p.cfg.clock.Now()}, nil
+}
+
+// drop 销毁一个已经计入 inUse 的资源
+func (p *ResourcePool[T]) drop(value T) {
+	p.mu.Lock()
+	p.inUse--
+	p.mu.Unlock()
+	p.destroy(value)
+	p.requestWarmUp()
+}
+
+func (p *ResourcePool[T]) release(r *Resource[T]) {
+	now := p.cfg.clock.Now()
+	idle := idleResource[T]{value: r.value, createdAt: r.createdAt, idleSince: now}
+	p.mu.Lock()
+	p.inUse--
+	keep := !p.closed && len(p.idle) < p.cfg.maxIdle && !p.expired(idle, now)
+	if keep {
+		p.idle = append(p.idle, idle)
+	}
+	p.mu.Unlock()
+	if !keep {
+		p.destroy(r.value)
+	}
+	p.sem.Release(1)
+}
+
+func (p *ResourcePool[T]) invalidate(r *Resource[T]) {
+	p.drop(r.value)
+	p.sem.Release(1)
+}
+
+func (p *ResourcePool[T]) destroy(value T) {
+	p.destroyed.Add(1)
+	if p.factory.Destroy != nil {
+		_ = p.factory.Destroy(value)
+	}
+}
+
+// expired 判断资源是否超过最长存活时间或最长空闲时间
+func (p *ResourcePool[T]) expired(r idleResource[T], now time.Time) bool {
+	if p.cfg.maxLifetime > 0 && now.Sub(r.createdAt) >= p.cfg.maxLifetime {
+		return true
+	}
+	return p.cfg.maxIdleTime > 0 && now.Sub(r.idleSince) >= p.cfg.maxIdleTime
+}
+
+func (p *ResourcePool[T]) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// requestWarmUp 通知后台协程补足最小空闲资源
+func (p *ResourcePool[T]) requestWarmUp() {
+	if p.cfg.minIdle == 0 {
+		return
+	}
+	select {
+	case p.warm <- struct{}{}:
+	default:
+	}
+}
+
+// maintain 后台协程，周期性回收过期的空闲资源并补足最小空闲资源
+func (p *ResourcePool[T]) maintain(ticker clock.Ticker) {
+	defer p.wg.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C():
+			p.evict()
+			p.warmUp()
+		case <-p.warm:
+			p.warmUp()
+		}
+	}
+}
+
+// evict 销毁过期的空闲资源
+func (p *ResourcePool[T]) evict() {
+	now := p.cfg.clock.Now()
+	var expired []T
+	p.mu.Lock()
+	live := p.idle[:0]
+	for _, r := range p.idle {
+		if p.expired(r, now) {
+			expired = append(expired, r.value)
+			continue
+		}
+		live = append(live, r)
+	}
+	clear(p.idle[len(live):])
+	p.idle = live
+	p.mu.Unlock()
+	for _, v := range expired {
+		p.destroy(v)
+	}
+}
+
+// warmUp 创建资源直到空闲资源达到最小数量，资源总数不超过上限
+func (p *ResourcePool[T]) warmUp() {
+	for {
+		p.mu.Lock()
+		need := !p.closed && len(p.idle)+p.creating < p.cfg.minIdle &&
+			len(p.idle)+p.inUse+p.creating < p.cfg.maxSize
+		if need {
+			p.creating++
+		}
+		p.mu.Unlock()
+		if !need {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-p.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		value, err := p.factory.New(ctx)
+		cancel()
+
+		p.mu.Lock()
+		p.creating--
+		// 预热期间可能有调用者新建了资源，放入前重新检查上限
+		keep := err == nil && !p.closed && len(p.idle) < p.cfg.maxIdle &&
+			len(p.idle)+p.inUse < p.cfg.maxSize
+		if keep {
+			now := p.cfg.clock.Now()
+			p.idle = append(p.idle, idleResource[T]{value: value, createdAt: now, idleSince: now})
+		}
+		p.mu.Unlock()
+		if err != nil {
+			// 创建失败时等待下一个周期再重试
+			return
+		}
+		p.created.Add(1)
+		if !keep {
+			p.destroy(value)
+			return
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 resource_pool.go 的测试用例，覆盖借出与归还、等待上限、健康检查、
+// 最长存活时间、空闲回收、后台预热以及关闭。
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// fakeConn 测试用的连接
+type fakeConn struct {
+	id     int64
+	broken atomic.Bool
+	closed atomic.Bool
+}
+
+func newConnFactory(nextID *atomic.Int64) ResourceFactory[*fakeConn] {
+	return ResourceFactory[*fakeConn]{
+		New: func(ctx context.Context) (*fakeConn, error) {
+			return &fakeConn{id: nextID.Add(1)}, nil
+		},
+		Validate: func(ctx context.Context, c *fakeConn) error {
+			if c.broken.Load() {
+				return errors.New("连接已断开")
+			}
+			return nil
+		},
+		Destroy: func(c *fakeConn) error {
+			c.closed.Store(true)
+			return nil
+		},
+	}
+}
+
+// 测试借出、归还后复用以及 Invalidate
+func TestResourcePool_AcquireRelease(t *testing.T) {
+	var ids atomic.Int64
+	p := NewResourcePool(newConnFactory(&ids), WithMaxSize(2))
+	defer p.Close()
+	ctx := context.Background()
+
+	r1, err := p.Acquire(ctx)
+	require.NoError(t, err)
+	r1.Release()
+	r1.Release() // 重复归还无效
+
+	r2, err := p.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Same(t, r1.Value(), r2.Value(), "应复用空闲资源")
+	stats := p.Stats()
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, 0, stats.Idle)
+
+	r2.Invalidate()
+	assert.True(t, r2.Value().closed.Load(), "Invalidate 应销毁资源")
+	r3, err := p.Acquire(ctx)
+	require.NoError(t, err)
+	assert.NotSame(t, r2.Value(), r3.Value())
+	r3.Release()
+
+	stats = p.Stats()
+	assert.Equal(t, int64(2), stats.Created)
+	assert.Equal(t, int64(1), stats.Destroyed)
+	assert.Equal(t, 1, stats.Idle)
+}
+
+// 测试资源达到上限时等待归还或 ctx 结束
+func TestResourcePool_Wait(t *testing.T) {
+	var ids atomic.Int64
+	p := NewResourcePool(newConnFactory(&ids), WithMaxSize(1))
+	defer p.Close()
+
+	r1, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	got := make(chan *Resource[*fakeConn])
+	go func() {
+		r, err := p.Acquire(context.Background())
+		assert.NoError(t, err)
+		got <- r
+	}()
+	waitFor(t, func() bool { return p.Stats().WaitCount == 2 })
+	r1.Release()
+	r2 := <-got
+	assert.Same(t, r1.Value(), r2.Value())
+	r2.Release()
+
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.AcquireFailures)
+	assert.Equal(t, int64(1), stats.Created)
+}
+
+// 测试借出前的健康检查
+func TestResourcePool_Validate(t *testing.T) {
+	var ids atomic.Int64
+	p := NewResourcePool(newConnFactory(&ids))
+	defer p.Close()
+	ctx := context.Background()
+
+	r1, _ := p.Acquire(ctx)
+	r2, _ := p.Acquire(ctx)
+	r1.Value().broken.Store(true)
+	r1.Release()
+	r2.Release()
+
+	// 最近归还的 r2 先借出，然后跳过已断开的 r1 并新建
+	a, _ := p.Acquire(ctx)
+	b, _ := p.Acquire(ctx)
+	assert.Same(t, r2.Value(), a.Value())
+	assert.Equal(t, int64(3), b.Value().id)
+	assert.True(t, r1.Value().closed.Load())
+	assert.Equal(t, int64(1), p.Stats().ValidationFailures)
+}
+
+// 测试最长存活时间、最长空闲时间和空闲数量上限
+func TestResourcePool_Expiry(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var ids atomic.Int64
+	ctx := context.Background()
+
+	t.Run("最长存活时间", func(t *testing.T) {
+		p := NewResourcePool(newConnFactory(&ids), WithMaxLifetime(time.Hour), WithResourceClock(clk))
+		defer p.Close()
+		r, _ := p.Acquire(ctx)
+		r.Release()
+		clk.Advance(time.Hour)
+		r2, _ := p.Acquire(ctx)
+		assert.NotSame(t, r.Value(), r2.Value(), "超过存活时间的资源不应再借出")
+		assert.True(t, r.Value().closed.Load())
+		r2.Release()
+	})
+
+	t.Run("空闲回收", func(t *testing.T) {
+		p := NewResourcePool(newConnFactory(&ids), WithMaxIdleTime(time.Minute),
+			WithEvictionInterval(30*time.Second), WithResourceClock(clk))
+		defer p.Close()
+		r, _ := p.Acquire(ctx)
+		r.Release()
+		clk.Advance(30 * time.Second)
+		waitFor(t, func() bool { return p.Stats().Destroyed == 0 && p.Stats().Idle == 1 })
+		clk.Advance(30 * time.Second)
+		waitFor(t, func() bool { return p.Stats().Idle == 0 })
+		assert.True(t, r.Value().closed.Load())
+	})
+
+	t.Run("空闲数量上限", func(t *testing.T) {
+		p := NewResourcePool(newConnFactory(&ids), WithMaxSize(3), WithMaxIdle(1))
+		defer p.Close()
+		rs := make([]*Resource[*fakeConn], 3)
+		for i := range rs {
+			rs[i], _ = p.Acquire(ctx)
+		}
+		for _, r := range rs {
+			r.Release()
+		}
+		stats := p.Stats()
+		assert.Equal(t, 1, stats.Idle)
+		assert.Equal(t, int64(2), stats.Destroyed)
+	})
+}
+
+// 测试后台预热和资源被销毁后补足
+func TestResourcePool_WarmUp(t *testing.T) {
+	var ids atomic.Int64
+	p := NewResourcePool(newConnFactory(&ids), WithMaxSize(4), WithMinIdle(2))
+	defer p.Close()
+	waitFor(t, func() bool { return p.Stats().Idle == 2 })
+
+	r, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+	r.Invalidate()
+	waitFor(t, func() bool { return p.Stats().Idle == 2 })
+	assert.Equal(t, int64(3), p.Stats().Created)
+
+	// 创建失败时 Acquire 返回错误并归还名额
+	errDial := errors.New("拨号失败")
+	failing := NewResourcePool(ResourceFactory[int]{
+		New: func(ctx context.Context) (int, error) { return 0, errDial },
+	}, WithMaxSize(1))
+	defer failing.Close()
+	for i := 0; i < 2; i++ {
+		_, err = failing.Acquire(context.Background())
+		assert.ErrorIs(t, err, errDial)
+	}
+	assert.Equal(t, int64(2), failing.Stats().AcquireFailures)
+}
+
+// 测试关闭后销毁空闲资源，借出的资源在归还时销毁
+func TestResourcePool_Close(t *testing.T) {
+	var ids atomic.Int64
+	p := NewResourcePool(newConnFactory(&ids))
+	ctx := context.Background()
+	r1, _ := p.Acquire(ctx)
+	r2, _ := p.Acquire(ctx)
+	r1.Release()
+
+	p.Close()
+	p.Close()
+	assert.True(t, r1.Value().closed.Load())
+	assert.False(t, r2.Value().closed.Load())
+	r2.Release()
+	assert.True(t, r2.Value().closed.Load())
+
+	_, err := p.Acquire(ctx)
+	assert.ErrorIs(t, err, ErrResourcePoolClosed)
+	assert.Panics(t, func() { NewResourcePool(ResourceFactory[int]{}) })
+}
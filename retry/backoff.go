@@ -0,0 +1,244 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现更多的退避策略：带随机抖动的指数退避、斐波那契退避、线性退避，
+// 以及限制总耗时的包装器。随机抖动可以打散故障恢复后大量客户端同时发起的重试。
+
+package retry
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/internal/errs"
+)
+
+// ================== 带抖动的指数退避 ==================
+
+// JitterMode 随机抖动的方式
+type JitterMode int
+
+const (
+	// FullJitter 在 [0, 指数退避间隔] 内随机等待
+	FullJitter JitterMode = iota
+	// EqualJitter 等待指数退避间隔的一半，再加上 [0, 间隔的一半] 内的随机时间
+	EqualJitter
+	// DecorrelatedJitter 在 [初始间隔, 上一次间隔的3倍] 内随机等待，不依赖重试次数
+	DecorrelatedJitter
+)
+
+// JitterBackoffRetryStrategy 带随机抖动的指数退避重试策略，线程安全
+type JitterBackoffRetryStrategy struct {
+	mode            JitterMode
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxRetries      int32
+
+	mu      sync.Mutex
+	retries int32
+	prev    time.Duration // DecorrelatedJitter 上一次的间隔
+	rand    func(n int64) int64
+}
+
+// NewJitterBackoffRetryStrategy 创建带随机抖动的指数退避重试策略，maxRetries <= 0 表示无限重试
+func NewJitterBackoffRetryStrategy(mode JitterMode, initialInterval, maxInterval time.Duration, maxRetries int32) (*JitterBackoffRetryStrategy, error) {
+	if initialInterval <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(initialInterval)
+	}
+	if initialInterval > maxInterval {
+		return nil, errs.NewErrInvalidMaxIntervalValue(maxInterval, initialInterval)
+	}
+	if mode < FullJitter || mode > DecorrelatedJitter {
+		return nil, errors.New("ggu: 未知的抖动方式")
+	}
+	return &JitterBackoffRetryStrategy{
+		mode:            mode,
+		initialInterval: initialInterval,
+		maxInterval:     maxInterval,
+		maxRetries:      maxRetries,
+		prev:            initialInterval,
+		rand:            rand.Int64N,
+	}, nil
+}
+
+func (s *JitterBackoffRetryStrategy) Next() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+	if s.maxRetries > 0 && s.retries > s.maxRetries {
+		return 0, false
+	}
+
+	switch s.mode {
+	case EqualJitter:
+		half := s.backoff() / 2
+		return half + s.between(0, half), true
+	case DecorrelatedJitter:
+		s.prev = min(s.maxInterval, s.between(s.initialInterval, s.prev*3))
+		return s.prev, true
+	default:
+		return s.between(0, s.backoff()), true
+	}
+}
+
+func (s *JitterBackoffRetryStrategy) Report(err error) Strategy {
+	return s
+}
+
+// backoff 返回本次重试不带抖动的指数退避间隔
+func (s *JitterBackoffRetryStrategy) backoff() time.Duration {
+	interval := s.initialInterval
+	for i := int32(1); i < s.retries; i++ {
+		interval *= 2
+		if interval <= 0 || interval >= s.maxInterval {
+			return s.maxInterval
+		}
+	}
+	return interval
+}
+
+// between 返回 [lo, hi] 内的随机时间
+func (s *JitterBackoffRetryStrategy) between(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(s.rand(int64(hi-lo)+1))
+}
+
+// ================== 斐波那契退避 ==================
+
+// FibonacciRetryStrategy 斐波那契退避重试策略，间隔依次为初始间隔的 1、1、2、3、5、8... 倍，线程安全
+type FibonacciRetryStrategy struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxRetries      int32
+	retries         int32
+}
+
+// NewFibonacciRetryStrategy 创建斐波那契退避重试策略，maxRetries <= 0 表示无限重试
+func NewFibonacciRetryStrategy(initialInterval, maxInterval time.Duration, maxRetries int32) (*FibonacciRetryStrategy, error) {
+	if initialInterval <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(initialInterval)
+	}
+	if initialInterval > maxInterval {
+		return nil, errs.NewErrInvalidMaxIntervalValue(maxInterval, initialInterval)
+	}
+	return &FibonacciRetryStrategy{
+		initialInterval: initialInterval,
+		maxInterval:     maxInterval,
+		maxRetries:      maxRetries,
+	}, nil
+}
+
+func (s *FibonacciRetryStrategy) Next() (time.Duration, bool) {
+	retries := atomic.AddInt32(&s.retries, 1)
+	if s.maxRetries > 0 && retries > s.maxRetries {
+		return 0, false
+	}
+	a, b := s.initialInterval, s.initialInterval
+	for i := int32(1); i < retries; i++ {
+		a, b = b, a+b
+		if a <= 0 || a >= s.maxInterval {
+			return s.maxInterval, true
+		}
+	}
+	return a, true
+}
+
+func (s *FibonacciRetryStrategy) Report(err error) Strategy {
+	return s
+}
+
+// ================== 线性退避 ==================
+
+// LinearRetryStrategy 线性退避重试策略，第 n 次重试的间隔为 初始间隔 + (n-1)*增量，线程安全
+type LinearRetryStrategy struct {
+	initialInterval time.Duration
+	increment       time.Duration
+	maxInterval     time.Duration
+	maxRetries      int32
+	retries         int32
+}
+
+// NewLinearRetryStrategy 创建线性退避重试策略，maxRetries <= 0 表示无限重试
+func NewLinearRetryStrategy(initialInterval, increment, maxInterval time.Duration, maxRetries int32) (*LinearRetryStrategy, error) {
+	if initialInterval <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(initialInterval)
+	}
+	if increment < 0 {
+		return nil, errs.NewErrInvalidIntervalValue(increment)
+	}
+	if initialInterval > maxInterval {
+		return nil, errs.NewErrInvalidMaxIntervalValue(maxInterval, initialInterval)
+	}
+	return &LinearRetryStrategy{
+		initialInterval: initialInterval,
+		increment:       increment,
+		maxInterval:     maxInterval,
+		maxRetries:      maxRetries,
+	}, nil
+}
+
+func (s *LinearRetryStrategy) Next() (time.Duration, bool) {
+	retries := atomic.AddInt32(&s.retries, 1)
+	if s.maxRetries > 0 && retries > s.maxRetries {
+		return 0, false
+	}
+	steps := time.Duration(retries - 1)
+	if s.increment > 0 && steps > (s.maxInterval-s.initialInterval)/s.increment {
+		return s.maxInterval, true
+	}
+	return s.initialInterval + steps*s.increment, true
+}
+
+func (s *LinearRetryStrategy) Report(err error) Strategy {
+	return s
+}
+
+// ================== 总耗时上限 ==================
+
+// MaxElapsedRetryStrategy 限制总耗时的包装器，从创建时开始计时，
+// 下一次重试的开始时间会超过上限时不再重试。内部策略在 Report 时可能被替换，并发使用时需要用 ThreadSafeStrategy 包装
+type MaxElapsedRetryStrategy struct {
+	strategy   Strategy
+	maxElapsed time.Duration
+	clock      clock.Clock
+	start      time.Time
+}
+
+// NewMaxElapsedRetryStrategy 为任意策略增加总耗时上限，clk 为 nil 时使用系统时钟
+func NewMaxElapsedRetryStrategy(s Strategy, maxElapsed time.Duration, clk clock.Clock) (*MaxElapsedRetryStrategy, error) {
+	if maxElapsed <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(maxElapsed)
+	}
+	clk = clock.OrReal(clk)
+	return &MaxElapsedRetryStrategy{
+		strategy:   s,
+		maxElapsed: maxElapsed,
+		clock:      clk,
+		start:      clk.Now(),
+	}, nil
+}
+
+func (s *MaxElapsedRetryStrategy) Next() (time.Duration, bool) {
+	remaining := s.maxElapsed - s.clock.Since(s.start)
+	if remaining <= 0 {
+		return 0, false
+	}
+	d, ok := s.strategy.Next()
+	if !ok || d > remaining {
+		return 0, false
+	}
+	return d, true
+}
+
+// Report 上报结果，内部策略返回的新实例替换原策略，返回包装器本身以保留总时长限制
+func (s *MaxElapsedRetryStrategy) Report(err error) Strategy {
+	if next := s.strategy.Report(err); next != nil {
+		s.strategy = next
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 backoff.go 和 budget.go 的测试用例，覆盖三种随机抖动、斐波那契退避、
+// 线性退避、总耗时上限以及跨协程共享的重试预算。
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// collect 取出策略给出的全部间隔
+func collect(s Strategy, limit int) []time.Duration {
+	var got []time.Duration
+	for i := 0; i < limit; i++ {
+		d, ok := s.Next()
+		if !ok {
+			break
+		}
+		got = append(got, d)
+	}
+	return got
+}
+
+func TestJitterBackoffRetryStrategy(t *testing.T) {
+	const initial, maxInterval = 10 * time.Millisecond, 80 * time.Millisecond
+
+	t.Run("全抖动", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			s, err := NewJitterBackoffRetryStrategy(FullJitter, initial, maxInterval, 6)
+			require.NoError(t, err)
+			got := collect(s, 10)
+			require.Len(t, got, 6)
+			for n, d := range got {
+				ceiling := min(maxInterval, initial<<n)
+				assert.True(t, d >= 0 && d <= ceiling, "第%d次间隔 %v 超出 [0, %v]", n+1, d, ceiling)
+			}
+		}
+	})
+
+	t.Run("等抖动", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			s, _ := NewJitterBackoffRetryStrategy(EqualJitter, initial, maxInterval, 6)
+			for n, d := range collect(s, 10) {
+				ceiling := min(maxInterval, initial<<n)
+				assert.True(t, d >= ceiling/2 && d <= ceiling, "第%d次间隔 %v 超出 [%v, %v]", n+1, d, ceiling/2, ceiling)
+			}
+		}
+	})
+
+	t.Run("去相关抖动", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			s, _ := NewJitterBackoffRetryStrategy(DecorrelatedJitter, initial, maxInterval, 0)
+			prev := initial
+			for _, d := range collect(s, 20) {
+				assert.True(t, d >= initial && d <= min(maxInterval, prev*3), "间隔 %v 超出 [%v, %v]", d, initial, prev*3)
+				prev = d
+			}
+		}
+	})
+
+	t.Run("固定随机数", func(t *testing.T) {
+		s, _ := NewJitterBackoffRetryStrategy(FullJitter, initial, maxInterval, 0)
+		s.rand = func(n int64) int64 { return n - 1 }
+		assert.Equal(t, []time.Duration{10, 20, 40, 80, 80}, scale(collect(s, 5), time.Millisecond))
+	})
+
+	_, err := NewJitterBackoffRetryStrategy(FullJitter, 0, maxInterval, 1)
+	assert.Error(t, err)
+	_, err = NewJitterBackoffRetryStrategy(FullJitter, maxInterval, initial, 1)
+	assert.Error(t, err)
+	_, err = NewJitterBackoffRetryStrategy(JitterMode(9), initial, maxInterval, 1)
+	assert.Error(t, err)
+}
+
+// scale 把间隔换算成 unit 的倍数，便于断言
+func scale(ds []time.Duration, unit time.Duration) []time.Duration {
+	res := make([]time.Duration, len(ds))
+	for i, d := range ds {
+		res[i] = d / unit
+	}
+	return res
+}
+
+func TestFibonacciRetryStrategy(t *testing.T) {
+	s, err := NewFibonacciRetryStrategy(time.Millisecond, 10*time.Millisecond, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{1, 1, 2, 3, 5, 8, 10}, scale(collect(s, 10), time.Millisecond))
+	assert.Same(t, s, s.Report(errors.New("失败")))
+
+	_, err = NewFibonacciRetryStrategy(0, time.Second, 1)
+	assert.Error(t, err)
+}
+
+func TestLinearRetryStrategy(t *testing.T) {
+	s, err := NewLinearRetryStrategy(10*time.Millisecond, 15*time.Millisecond, 50*time.Millisecond, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{10, 25, 40, 50, 50}, scale(collect(s, 10), time.Millisecond))
+
+	// 增量为 0 时退化为固定间隔
+	s, _ = NewLinearRetryStrategy(time.Millisecond, 0, time.Millisecond, 0)
+	assert.Equal(t, []time.Duration{1, 1, 1}, scale(collect(s, 3), time.Millisecond))
+
+	_, err = NewLinearRetryStrategy(time.Millisecond, -time.Millisecond, time.Second, 1)
+	assert.Error(t, err)
+}
+
+func TestMaxElapsedRetryStrategy(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	base, _ := NewFixedIntervalRetryStrategy(time.Second, 0)
+	s, err := NewMaxElapsedRetryStrategy(base, 3*time.Second, clk)
+	require.NoError(t, err)
+
+	d, ok := s.Next()
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+	clk.Advance(2 * time.Second)
+	_, ok = s.Next()
+	assert.True(t, ok, "剩余 1s，刚好允许再等 1s")
+	clk.Advance(500 * time.Millisecond)
+	_, ok = s.Next()
+	assert.False(t, ok, "等待后会超过总耗时上限")
+
+	_, err = NewMaxElapsedRetryStrategy(base, 0, nil)
+	assert.Error(t, err)
+
+	// 内部策略在 Report 时返回的新实例替换原策略
+	slow, _ := NewFixedIntervalRetryStrategy(2*time.Second, 0)
+	s, err = NewMaxElapsedRetryStrategy(&replacingStrategy{Strategy: base, next: slow}, time.Minute, clk)
+	require.NoError(t, err)
+	assert.Same(t, s, s.Report(errors.New("临时错误")))
+	d, ok = s.Next()
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+}
+
+// replacingStrategy 在 Report 时返回另一个策略
+type replacingStrategy struct {
+	Strategy
+	next Strategy
+}
+
+func (s *replacingStrategy) Report(error) Strategy {
+	return s.next
+}
+
+func TestRetryBudget(t *testing.T) {
+	b, err := NewRetryBudget(0.5, 2)
+	require.NoError(t, err)
+
+	// 初始令牌用完后拒绝重试
+	assert.True(t, b.TryRetry())
+	assert.True(t, b.TryRetry())
+	assert.False(t, b.TryRetry())
+
+	// 两次成功攒够一次重试
+	b.Success()
+	assert.False(t, b.TryRetry())
+	b.Success()
+	assert.True(t, b.TryRetry())
+
+	// 令牌不超过上限
+	for i := 0; i < 10; i++ {
+		b.Success()
+	}
+	stats := b.Stats()
+	assert.Equal(t, 2.0, stats.Tokens)
+	assert.Equal(t, int64(3), stats.Allowed)
+	assert.Equal(t, int64(2), stats.Rejected)
+
+	_, err = NewRetryBudget(0, 10)
+	assert.Error(t, err)
+	_, err = NewRetryBudget(0.1, 0.5)
+	assert.Error(t, err)
+}
+
+// 测试多个协程共享同一个预算，预算耗尽后 Do 不再重试
+func TestRetryBudget_Wrap(t *testing.T) {
+	b, _ := NewRetryBudget(0.1, 5)
+	errDown := errors.New("下游不可用")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	calls := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			base, _ := NewFixedIntervalRetryStrategy(time.Microsecond, 3)
+			_, err := Do(context.Background(), b.Wrap(base), func(ctx context.Context) (int, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				return 0, errDown
+			})
+			assert.ErrorIs(t, err, errDown)
+		}()
+	}
+	wg.Wait()
+	// 10 次首次调用 + 最多 5 次重试
+	assert.Equal(t, 15, calls)
+	assert.Equal(t, int64(5), b.Stats().Allowed)
+
+	// 成功调用补充预算
+	for i := 0; i < 10; i++ {
+		_, err := Do(context.Background(), b.Wrap(&spyStrategy{}), func(ctx context.Context) (int, error) { return 1, nil })
+		require.NoError(t, err)
+	}
+	assert.InDelta(t, 1.0, b.Stats().Tokens, 1e-9)
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现重试预算。重试预算在多个协程、多次调用之间共享，
+// 把重试次数限制在最近成功调用次数的一定比例之内，避免下游故障时重试流量放大。
+
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ================== 重试预算 ==================
+
+// RetryBudget 基于令牌桶的重试预算，线程安全。
+// 每次成功调用存入 ratio 个令牌，每次重试取出 1 个令牌，令牌不足 1 个时拒绝重试。
+// 令牌数量不超过 maxTokens，初始为满，因此冷启动时最多允许 maxTokens 次重试，
+// 之后重试次数约为成功次数的 ratio 倍。
+type RetryBudget struct {
+	ratio     float64
+	maxTokens float64
+
+	mu       sync.Mutex
+	tokens   float64
+	allowed  int64
+	rejected int64
+}
+
+// RetryBudgetStats 重试预算的统计信息
+type RetryBudgetStats struct {
+	Tokens   float64 // 当前令牌数
+	Allowed  int64   // 允许的重试次数
+	Rejected int64   // 因预算不足被拒绝的重试次数
+}
+
+// NewRetryBudget 创建重试预算，ratio 为每次成功调用存入的令牌数，取值 (0, 1]，
+// 例如 0.1 表示重试次数不超过成功次数的 10%；maxTokens 为令牌上限，必须大于等于 1
+func NewRetryBudget(ratio float64, maxTokens float64) (*RetryBudget, error) {
+	if ratio <= 0 || ratio > 1 {
+		return nil, errors.New("ggu: 重试预算比例必须在 (0, 1] 之间")
+	}
+	if maxTokens < 1 {
+		return nil, errors.New("ggu: 重试预算令牌上限必须大于等于1")
+	}
+	return &RetryBudget{
+		ratio:     ratio,
+		maxTokens: maxTokens,
+		tokens:    maxTokens,
+	}, nil
+}
+
+// Success 记录一次成功调用，存入 ratio 个令牌
+func (b *RetryBudget) Success() {
+	b.mu.Lock()
+	b.tokens = min(b.maxTokens, b.tokens+b.ratio)
+	b.mu.Unlock()
+}
+
+// TryRetry 尝试为一次重试取出令牌，预算不足时返回 false
+func (b *RetryBudget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		b.rejected++
+		return false
+	}
+	b.tokens--
+	b.allowed++
+	return true
+}
+
+// Stats 返回重试预算的统计信息
+func (b *RetryBudget) Stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Tokens:   b.tokens,
+		Allowed:  b.allowed,
+		Rejected: b.rejected,
+	}
+}
+
+// Wrap 用重试预算包装任意策略：Report(nil) 时存入令牌，
+// Next 在被包装的策略允许重试且预算充足时才返回 true。
+// 同一个 RetryBudget 可以包装多个策略，在它们之间共享预算。
+func (b *RetryBudget) Wrap(s Strategy) Strategy {
+	return &budgetStrategy{budget: b, strategy: s}
+}
+
+// budgetStrategy 受重试预算限制的策略
+type budgetStrategy struct {
+	budget   *RetryBudget
+	strategy Strategy
+}
+
+func (s *budgetStrategy) Next() (time.Duration, bool) {
+	d, ok := s.strategy.Next()
+	if !ok || !s.budget.TryRetry() {
+		return 0, false
+	}
+	return d, true
+}
+
+func (s *budgetStrategy) Report(err error) Strategy {
+	if err == nil {
+		s.budget.Success()
+	}
+	if next := s.strategy.Report(err); next != nil {
+		s.strategy = next
+	}
+	return s
+}
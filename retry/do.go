@@ -0,0 +1,118 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现带返回值的重试入口 Do。业务函数接收 ctx，每次调用的结果都会上报给重试策略；
+// 错误可以被分类为可重试或不可重试，重试耗尽时返回的错误包装了每一次调用的错误。
+
+package retry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/internal/errs"
+)
+
+// ErrRetryExhausted 重试耗尽，Do 和 Retry 在重试耗尽时返回的错误可以通过 errors.Is 判断
+var ErrRetryExhausted = errs.ErrRetryExhausted
+
+// ================== 不可重试错误 ==================
+
+// permanentError 不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 把错误标记为不可重试，Do 遇到该错误时立即返回被包装的错误。err 为 nil 时返回 nil。
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否被 Permanent 标记为不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// ================== 带返回值的重试入口 ==================
+
+// Do 按策略重试业务函数直到成功，返回业务函数的结果。
+//   - 每次调用后通过 Strategy.Report 上报结果，成功时上报 nil
+//   - Permanent 包装的错误或 WithRetryIf 判定不可重试的错误立即返回，不再重试
+//   - 重试耗尽时返回的错误同时包装 ErrRetryExhausted 和每一次调用的错误，可以通过 errors.Is/As 判断
+//   - ctx 在调用前或等待期间结束时，返回的错误同时包装 ctx.Err() 和最近一次调用的错误
+func Do[T any](ctx context.Context, s Strategy, fn func(ctx context.Context) (T, error), opts ...Option) (T, error) {
+	o := newOptions(opts)
+	var (
+		zero    T
+		attempt int
+		errList []error
+		lastErr error
+		timer   clock.Timer
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			// errors.Join 会忽略 nil，第一次调用前结束时只返回 ctx.Err()
+			return zero, errors.Join(err, lastErr)
+		}
+		attempt++
+		val, err := fn(ctx)
+		s = report(s, err)
+		if err == nil {
+			return val, nil
+		}
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			return zero, pe.err
+		}
+		if o.retryable != nil && !o.retryable(err) {
+			return zero, err
+		}
+		errList = append(errList, err)
+		lastErr = err
+
+		delay, ok := s.Next()
+		if !ok {
+			return zero, errs.NewErrRetryExhausted(errors.Join(errList...))
+		}
+		for _, hook := range o.onRetry {
+			hook(attempt, err, delay)
+		}
+
+		if timer == nil {
+			timer = o.clock.NewTimer(delay)
+		} else {
+			timer.Reset(delay)
+		}
+		select {
+		case <-ctx.Done():
+			return zero, errors.Join(ctx.Err(), err)
+		case <-timer.C():
+		}
+	}
+}
+
+// report 上报本次调用的结果，策略返回新的实例时使用新实例
+func report(s Strategy, err error) Strategy {
+	if next := s.Report(err); next != nil {
+		return next
+	}
+	return s
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 do.go 的测试用例，覆盖返回值、错误分类、重试钩子、策略上报、
+// 重试耗尽时的错误包装以及上下文取消。
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spyStrategy 记录上报结果的策略
+type spyStrategy struct {
+	Strategy
+	reports []error
+}
+
+func (s *spyStrategy) Report(err error) Strategy {
+	s.reports = append(s.reports, err)
+	return s
+}
+
+func newSpy(t *testing.T, maxRetries int32) *spyStrategy {
+	base, err := NewFixedIntervalRetryStrategy(time.Millisecond, maxRetries)
+	require.NoError(t, err)
+	return &spyStrategy{Strategy: base}
+}
+
+// 测试返回业务结果，每次调用都上报给策略
+func TestDo_Success(t *testing.T) {
+	s := newSpy(t, 5)
+	errTemp := errors.New("临时错误")
+	calls := 0
+	v, err := Do(context.Background(), s, func(ctx context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errTemp
+		}
+		return "order-1", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "order-1", v)
+	assert.Equal(t, []error{errTemp, errTemp, nil}, s.reports)
+}
+
+// 测试重试耗尽时包装每一次调用的错误
+func TestDo_Exhausted(t *testing.T) {
+	s := newSpy(t, 2)
+	errs := []error{errors.New("第1次"), io.ErrUnexpectedEOF, &net.OpError{Op: "dial", Err: errors.New("拒绝连接")}}
+	calls := 0
+	_, err := Do(context.Background(), s, func(ctx context.Context) (int, error) {
+		calls++
+		return 0, errs[calls-1]
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, calls)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	for _, e := range errs {
+		assert.ErrorIs(t, err, e)
+	}
+	var opErr *net.OpError
+	assert.ErrorAs(t, err, &opErr)
+	assert.Len(t, s.reports, 3)
+}
+
+// 测试不可重试的错误立即返回
+func TestDo_Classifier(t *testing.T) {
+	errInvalid := errors.New("参数错误")
+	calls := 0
+	_, err := Do(context.Background(), newSpy(t, 5), func(ctx context.Context) (int, error) {
+		calls++
+		return 0, Permanent(errInvalid)
+	})
+	assert.Equal(t, errInvalid, err, "应返回被 Permanent 包装的原始错误")
+	assert.Equal(t, 1, calls)
+	assert.True(t, IsPermanent(Permanent(errInvalid)))
+	assert.NoError(t, Permanent(nil))
+
+	calls = 0
+	_, err = Do(context.Background(), newSpy(t, 5), func(ctx context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, errInvalid
+	}, WithRetryIf(func(err error) bool { return !errors.Is(err, errInvalid) }))
+	assert.Equal(t, errInvalid, err)
+	assert.Equal(t, 2, calls)
+}
+
+// 测试重试钩子收到失败的次数、错误和等待时间
+func TestDo_OnRetry(t *testing.T) {
+	type event struct {
+		attempt int
+		err     error
+		delay   time.Duration
+	}
+	var events []event
+	var count int
+	errTemp := errors.New("临时错误")
+	_, err := Do(context.Background(), newSpy(t, 2), func(ctx context.Context) (int, error) {
+		return 0, errTemp
+	}, WithOnRetry(func(attempt int, err error, delay time.Duration) {
+		events = append(events, event{attempt, err, delay})
+	}), WithOnRetry(func(int, error, time.Duration) { count++ }))
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Equal(t, []event{{1, errTemp, time.Millisecond}, {2, errTemp, time.Millisecond}}, events)
+	assert.Equal(t, 2, count)
+}
+
+// 测试 ctx 传入业务函数，等待期间结束时返回 ctx.Err() 和最近一次的错误
+func TestDo_Context(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace-1")
+	_, _ = Do(ctx, newSpy(t, 0), func(ctx context.Context) (int, error) {
+		assert.Equal(t, "trace-1", ctx.Value(ctxKey{}))
+		return 1, nil
+	})
+
+	base, _ := NewFixedIntervalRetryStrategy(time.Hour, 10)
+	cctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	errTemp := errors.New("临时错误")
+	_, err := Do(cctx, base, func(ctx context.Context) (int, error) { return 0, errTemp })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, errTemp)
+
+	calls := 0
+	_, err = Do(cctx, base, func(ctx context.Context) (int, error) {
+		calls++
+		return 0, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, calls, "ctx 已经结束时不应调用业务函数")
+}
+
+// lockCheckStrategy 每次上报都返回新实例，Next 检查是否在 ThreadSafeStrategy 的锁内调用
+type lockCheckStrategy struct {
+	t       *testing.T
+	wrapper *ThreadSafeStrategy
+	nexts   *int
+}
+
+func (s *lockCheckStrategy) Next() (time.Duration, bool) {
+	*s.nexts++
+	assert.False(s.t, s.wrapper.mu.TryLock(), "Next 应在包装器的锁内执行")
+	return time.Millisecond, true
+}
+
+func (s *lockCheckStrategy) Report(error) Strategy {
+	copied := *s
+	return &copied
+}
+
+// 测试策略上报返回新实例后，Do 仍然通过 ThreadSafeStrategy 调用
+func TestDo_KeepsThreadSafeWrapper(t *testing.T) {
+	nexts := 0
+	ts := NewThreadSafeStrategy(nil)
+	first := &lockCheckStrategy{t: t, wrapper: ts, nexts: &nexts}
+	ts.strategy = first
+
+	assert.Same(t, ts, ts.Report(errors.New("失败")))
+	assert.NotSame(t, first, ts.strategy, "内部策略被替换为新实例")
+
+	calls := 0
+	_, err := Do(context.Background(), ts, func(ctx context.Context) (int, error) {
+		calls++
+		if calls < 4 {
+			return 0, errors.New("临时错误")
+		}
+		return calls, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, nexts)
+}
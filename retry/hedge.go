@@ -0,0 +1,295 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现对冲请求 Hedge 和竞速调用 Race，用于降低长尾延迟。
+// Hedge 先发起一次调用，超过对冲延迟仍未返回时再发起备份调用，第一个成功的结果胜出，
+// 其余调用通过 ctx 取消；对冲延迟可以固定，也可以从最近调用耗时的分位数中学习。
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// ================== 对冲延迟 ==================
+
+// HedgeDelay 决定发起备份调用前等待的时间，实现需要线程安全
+type HedgeDelay interface {
+	// Delay 返回本次对冲的延迟
+	Delay() time.Duration
+	// Observe 记录一次成功调用的耗时
+	Observe(latency time.Duration)
+}
+
+// fixedDelay 固定的对冲延迟
+type fixedDelay time.Duration
+
+func (d fixedDelay) Delay() time.Duration { return time.Duration(d) }
+func (d fixedDelay) Observe(time.Duration) {}
+
+// FixedDelay 返回固定的对冲延迟
+func FixedDelay(d time.Duration) HedgeDelay {
+	return fixedDelay(d)
+}
+
+// percentileMinSamples 分位数延迟开始生效所需的最少样本数
+const percentileMinSamples = 10
+
+// PercentileDelay 从最近调用耗时的分位数中学习对冲延迟，线程安全。
+// 例如取 p95 时，只有最慢的约 5% 的调用会触发备份调用。
+type PercentileDelay struct {
+	percentile float64
+	initial    time.Duration
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// NewPercentileDelay 创建分位数对冲延迟，percentile 取值 (0, 100]，window 为保留的最近样本数，
+// 样本不足 10 个时使用 initial
+func NewPercentileDelay(percentile float64, window int, initial time.Duration) (*PercentileDelay, error) {
+	if percentile <= 0 || percentile > 100 {
+		return nil, errors.New("ggu: 分位数必须在 (0, 100] 之间")
+	}
+	if window < percentileMinSamples {
+		return nil, fmt.Errorf("ggu: 样本窗口不能小于 %d", percentileMinSamples)
+	}
+	if initial < 0 {
+		return nil, errors.New("ggu: 初始对冲延迟不能为负数")
+	}
+	return &PercentileDelay{
+		percentile: percentile,
+		initial:    initial,
+		samples:    make([]time.Duration, window),
+	}, nil
+}
+
+func (p *PercentileDelay) Delay() time.Duration {
+	p.mu.Lock()
+	n := p.next
+	if p.full {
+		n = len(p.samples)
+	}
+	if n < percentileMinSamples {
+		p.mu.Unlock()
+		return p.initial
+	}
+	sorted := slices.Clone(p.samples[:n])
+	p.mu.Unlock()
+
+	slices.Sort(sorted)
+	idx := int(math.Ceil(p.percentile/100*float64(n))) - 1
+	return sorted[max(idx, 0)]
+}
+
+func (p *PercentileDelay) Observe(latency time.Duration) {
+	p.mu.Lock()
+	p.samples[p.next] = latency
+	p.next++
+	if p.next == len(p.samples) {
+		p.next = 0
+		p.full = true
+	}
+	p.mu.Unlock()
+}
+
+// ================== 对冲请求 ==================
+
+type hedgeOptions struct {
+	maxHedges int
+	clock     clock.Clock
+	discard   any
+}
+
+// HedgeOption Hedge 的配置选项
+type HedgeOption func(*hedgeOptions)
+
+// WithMaxHedges 设置最多发起的备份调用次数，默认 1 次
+func WithMaxHedges(n int) HedgeOption {
+	return func(o *hedgeOptions) {
+		if n > 0 {
+			o.maxHedges = n
+		}
+	}
+}
+
+// WithHedgeClock 设置对冲使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithHedgeClock(clk clock.Clock) HedgeOption {
+	return func(o *hedgeOptions) {
+		o.clock = clk
+	}
+}
+
+// WithHedgeDiscard 设置落败调用成功结果的释放函数，例如关闭 HTTP 响应体。
+// 胜出以外的每个成功结果（包括 Hedge 因 ctx 结束或错误返回后才完成的调用）都会在后台传给 discard，
+// discard 的参数类型必须与 Hedge 的结果类型相同，否则不会被调用
+func WithHedgeDiscard[T any](discard func(T)) HedgeOption {
+	return func(o *hedgeOptions) {
+		o.discard = discard
+	}
+}
+
+// Hedge 发起 fn，每隔对冲延迟仍没有成功结果时发起一次备份调用，返回第一个成功的结果。
+//   - 某次调用失败时立即发起下一次备份调用，不再等待对冲延迟
+//   - 胜出后其余调用的 ctx 被取消，胜出调用的 ctx 在 Hedge 返回时取消；
+//     返回后仍需使用胜出调用的 ctx（例如读取响应体）时使用 HedgeWithCancel
+//   - 胜出调用的耗时通过 HedgeDelay.Observe 记录
+//   - Permanent 包装的错误立即返回被包装的错误；全部失败时返回合并后的错误
+//   - fn 的 panic 被恢复并作为 *syncx.PanicError 计为失败
+//   - 落败调用的成功结果默认被丢弃，持有资源的结果通过 WithHedgeDiscard 释放
+func Hedge[T any](ctx context.Context, delay HedgeDelay, fn func(ctx context.Context) (T, error), opts ...HedgeOption) (T, error) {
+	val, cancel, err := HedgeWithCancel(ctx, delay, fn, opts...)
+	cancel()
+	return val, err
+}
+
+// HedgeWithCancel 与 Hedge 相同，但胜出调用的 ctx 在返回后仍然有效，直到调用返回的 cancel，
+// 例如在关闭响应体时调用。返回的 cancel 不为 nil，出错时调用它没有副作用，调用方必须调用它以释放 ctx
+func HedgeWithCancel[T any](ctx context.Context, delay HedgeDelay, fn func(ctx context.Context) (T, error), opts ...HedgeOption) (T, context.CancelFunc, error) {
+	o := hedgeOptions{maxHedges: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.clock = clock.OrReal(o.clock)
+
+	calls := make([]func(ctx context.Context) (T, error), o.maxHedges+1)
+	for i := range calls {
+		calls[i] = fn
+	}
+	discard, _ := o.discard.(func(T))
+	return race(ctx, calls, delay, o.clock, discard)
+}
+
+// Race 同时发起全部调用，返回第一个成功的结果，其余调用的 ctx 被取消，胜出调用的 ctx 在返回时取消。
+// 错误处理与 Hedge 相同，适合向多个副本或多个数据源并行查询
+func Race[T any](ctx context.Context, fns ...func(ctx context.Context) (T, error)) (T, error) {
+	val, cancel, err := race(ctx, fns, FixedDelay(0), clock.Real, nil)
+	cancel()
+	return val, err
+}
+
+// hedgeResult 一次调用的结果
+type hedgeResult[T any] struct {
+	idx     int
+	val     T
+	err     error
+	latency time.Duration
+}
+
+// race 按对冲延迟依次发起 calls，返回第一个成功的结果和胜出调用 ctx 的 cancel，
+// 出错时所有调用都已取消，返回空操作的 cancel；discard 不为 nil 时用来释放其余的成功结果
+func race[T any](ctx context.Context, calls []func(ctx context.Context) (T, error), delay HedgeDelay, clk clock.Clock, discard func(T)) (T, context.CancelFunc, error) {
+	var zero T
+	noop := func() {}
+	if len(calls) == 0 {
+		return zero, noop, errors.New("ggu: 没有可以执行的调用")
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, noop, err
+	}
+
+	// 结果通道的容量等于调用数，落败的调用返回时不会阻塞
+	results := make(chan hedgeResult[T], len(calls))
+	cancels := make([]context.CancelFunc, 0, len(calls))
+	launch := func() {
+		idx := len(cancels)
+		callCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			start := clk.Now()
+			val, err := callWithRecover(callCtx, calls[idx])
+			results <- hedgeResult[T]{idx: idx, val: val, err: err, latency: clk.Since(start)}
+		}()
+	}
+	// cancelExcept 取消除胜出调用以外的全部调用，winner 为 -1 时全部取消
+	cancelExcept := func(winner int) {
+		for i, cancel := range cancels {
+			if i != winner {
+				cancel()
+			}
+		}
+	}
+
+	d := delay.Delay()
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+	launch()
+
+	// 返回时仍在执行的调用在后台等待结果，成功的结果交给 discard 释放
+	pending := 1
+	defer func() {
+		if discard == nil || pending == 0 {
+			return
+		}
+		go func(n int) {
+			for ; n > 0; n-- {
+				if res := <-results; res.err == nil {
+					discard(res.val)
+				}
+			}
+		}(pending)
+	}()
+
+	var errList []error
+	for {
+		select {
+		case <-ctx.Done():
+			cancelExcept(-1)
+			return zero, noop, errors.Join(append([]error{ctx.Err()}, errList...)...)
+		case <-timer.C():
+			if len(cancels) < len(calls) {
+				launch()
+				pending++
+				timer.Reset(d)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				cancelExcept(res.idx)
+				delay.Observe(res.latency)
+				return res.val, cancels[res.idx], nil
+			}
+			var pe *permanentError
+			if errors.As(res.err, &pe) {
+				cancelExcept(-1)
+				return zero, noop, pe.err
+			}
+			errList = append(errList, res.err)
+			if len(cancels) < len(calls) {
+				launch()
+				pending++
+				if !timer.Stop() {
+					select {
+					case <-timer.C():
+					default:
+					}
+				}
+				timer.Reset(d)
+			} else if pending == 0 {
+				cancelExcept(-1)
+				return zero, noop, errors.Join(errList...)
+			}
+		}
+	}
+}
+
+// callWithRecover 执行调用，把 panic 转换为 *syncx.PanicError
+func callWithRecover[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) (val T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &syncx.PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 hedge.go 的测试用例，覆盖对冲延迟后的备份调用、失败后立即对冲、
+// 落败调用的取消、不可重试错误、panic 恢复、竞速调用以及分位数对冲延迟。
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+)
+
+// spyDelay 记录观测到的耗时
+type spyDelay struct {
+	d        time.Duration
+	mu       sync.Mutex
+	observed []time.Duration
+}
+
+func (s *spyDelay) Delay() time.Duration { return s.d }
+
+func (s *spyDelay) Observe(latency time.Duration) {
+	s.mu.Lock()
+	s.observed = append(s.observed, latency)
+	s.mu.Unlock()
+}
+
+// 测试首次调用超过对冲延迟时发起备份调用，备份胜出后首次调用被取消
+func TestHedge_Backup(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	delay := &spyDelay{d: 50 * time.Millisecond}
+	var calls atomic.Int32
+	canceled := make(chan struct{})
+
+	type result struct {
+		v   string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := Hedge(context.Background(), delay, func(ctx context.Context) (string, error) {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				close(canceled)
+				return "", ctx.Err()
+			}
+			return "price-backup", nil
+		}, WithHedgeClock(clk))
+		done <- result{v, err}
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(50 * time.Millisecond)
+	res := <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, "price-backup", res.v)
+	<-canceled
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Len(t, delay.observed, 1)
+}
+
+// 测试首次调用成功时不发起备份调用
+func TestHedge_FastPath(t *testing.T) {
+	var calls atomic.Int32
+	v, err := Hedge(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 7, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, v)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// 测试调用失败时立即发起备份调用，全部失败时返回合并后的错误
+func TestHedge_Failures(t *testing.T) {
+	errs := []error{errors.New("第1次"), errors.New("第2次"), errors.New("第3次")}
+	var calls atomic.Int32
+	_, err := Hedge(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		return 0, errs[calls.Add(1)-1]
+	}, WithMaxHedges(2))
+	require.Error(t, err)
+	for _, e := range errs {
+		assert.ErrorIs(t, err, e)
+	}
+
+	calls.Store(0)
+	v, err := Hedge(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			return 0, errs[0]
+		}
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	// 不可重试的错误立即返回
+	errInvalid := errors.New("商品不存在")
+	calls.Store(0)
+	_, err = Hedge(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 0, Permanent(errInvalid)
+	}, WithMaxHedges(3))
+	assert.Equal(t, errInvalid, err)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// panic 计为失败
+	_, err = Hedge(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	var pe *syncx.PanicError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "boom", pe.Value)
+}
+
+// 测试 ctx 结束时取消全部调用
+func TestHedge_Context(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := Hedge(ctx, FixedDelay(time.Millisecond), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WithMaxHedges(3))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = Hedge(ctx, FixedDelay(time.Millisecond), func(ctx context.Context) (int, error) { return 1, nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// 测试 Hedge 返回时释放胜出调用的 ctx，HedgeWithCancel 在调用 cancel 后释放
+func TestHedgeWithCancel(t *testing.T) {
+	var winner context.Context
+	fn := func(ctx context.Context) (int, error) {
+		winner = ctx
+		return 1, nil
+	}
+	_, err := Hedge(context.Background(), FixedDelay(time.Hour), fn)
+	require.NoError(t, err)
+	assert.ErrorIs(t, winner.Err(), context.Canceled)
+
+	v, cancel, err := HedgeWithCancel(context.Background(), FixedDelay(time.Hour), fn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.NoError(t, winner.Err(), "调用 cancel 前胜出调用的 ctx 仍然有效")
+	cancel()
+	assert.ErrorIs(t, winner.Err(), context.Canceled)
+
+	_, cancel, err = HedgeWithCancel(context.Background(), FixedDelay(time.Hour), func(ctx context.Context) (int, error) {
+		return 0, Permanent(errors.New("参数错误"))
+	})
+	assert.Error(t, err)
+	require.NotNil(t, cancel)
+	cancel()
+}
+
+// 测试落败调用的成功结果交给 discard 释放，包括 ctx 结束后才完成的调用
+func TestHedge_Discard(t *testing.T) {
+	var calls atomic.Int32
+	var mu sync.Mutex
+	var discarded []int32
+	discard := WithHedgeDiscard(func(v int32) {
+		mu.Lock()
+		discarded = append(discarded, v)
+		mu.Unlock()
+	})
+	discardedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(discarded)
+	}
+	// 调用不理会 ctx 的取消，全部成功
+	fn := func(ctx context.Context) (int32, error) {
+		n := calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return n, nil
+	}
+
+	v, err := Hedge(context.Background(), FixedDelay(time.Millisecond), fn, WithMaxHedges(2), discard)
+	require.NoError(t, err)
+	waitUntil(t, func() bool { return discardedCount() == 2 })
+	mu.Lock()
+	assert.NotContains(t, discarded, v)
+	mu.Unlock()
+
+	calls.Store(0)
+	discarded = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err = Hedge(ctx, FixedDelay(time.Millisecond), fn, WithMaxHedges(2), discard)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	waitUntil(t, func() bool { return discardedCount() == int(calls.Load()) })
+}
+
+// 测试竞速调用返回最快的成功结果，其余调用被取消
+func TestRace(t *testing.T) {
+	var canceled atomic.Int32
+	slow := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		canceled.Add(1)
+		return "", ctx.Err()
+	}
+	v, err := Race(context.Background(), slow, func(ctx context.Context) (string, error) {
+		return "replica-2", nil
+	}, slow)
+	require.NoError(t, err)
+	assert.Equal(t, "replica-2", v)
+	waitUntil(t, func() bool { return canceled.Load() == 2 })
+
+	_, err = Race[int](context.Background())
+	assert.Error(t, err)
+}
+
+// waitUntil 轮询等待条件成立
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待条件超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 测试分位数对冲延迟
+func TestPercentileDelay(t *testing.T) {
+	p, err := NewPercentileDelay(90, 20, 30*time.Millisecond)
+	require.NoError(t, err)
+	for i := 1; i < 10; i++ {
+		p.Observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 30*time.Millisecond, p.Delay(), "样本不足时使用初始延迟")
+
+	p.Observe(10 * time.Millisecond)
+	assert.Equal(t, 9*time.Millisecond, p.Delay())
+
+	// 窗口满后淘汰最早的样本
+	for i := 0; i < 20; i++ {
+		p.Observe(100 * time.Millisecond)
+	}
+	assert.Equal(t, 100*time.Millisecond, p.Delay())
+
+	_, err = NewPercentileDelay(0, 20, 0)
+	assert.Error(t, err)
+	_, err = NewPercentileDelay(99, 5, 0)
+	assert.Error(t, err)
+	assert.Equal(t, time.Second, FixedDelay(time.Second).Delay())
+}
@@ -13,6 +13,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
 )
 
 // ================== 重试策略接口 ==================
@@ -184,41 +186,64 @@ func (t *ThreadSafeStrategy) Next() (time.Duration, bool) {
 	return t.strategy.Next()
 }
 
+// Report 上报结果，内部策略返回的新实例替换原策略，返回包装器本身，调用方继续使用时仍然受锁保护
 func (t *ThreadSafeStrategy) Report(err error) Strategy {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	return t.strategy.Report(err)
+	if next := t.strategy.Report(err); next != nil {
+		t.strategy = next
+	}
+	return t
 }
 
 // ================== 通用重试入口 ==================
 
-// Retry 通用重试函数，支持上下文取消、超时、最大重试次数等
-// bizFunc 返回 nil 表示成功，否则会根据策略重试
-func Retry(ctx context.Context, s Strategy, bizFunc func() error) error {
-	var ticker *time.Ticker
-	defer func() {
-		if ticker != nil {
-			ticker.Stop()
-		}
-	}()
-	for {
-		err := bizFunc()
-		if err == nil {
-			return nil
-		}
-		duration, ok := s.Next()
-		if !ok {
-			return errors.New("重试耗尽")
-		}
-		if ticker == nil {
-			ticker = time.NewTicker(duration)
-		} else {
-			ticker.Reset(duration)
-		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
+// Option 重试配置选项
+type Option func(*options)
+
+type options struct {
+	clock     clock.Clock
+	retryable func(err error) bool
+	onRetry   []func(attempt int, err error, delay time.Duration)
+}
+
+// WithClock 设置等待重试间隔使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
 	}
 }
+
+// WithRetryIf 设置错误分类函数，返回 false 的错误视为不可重试，立即返回。
+// 默认除 Permanent 包装的错误外都可以重试。
+func WithRetryIf(retryable func(err error) bool) Option {
+	return func(o *options) {
+		o.retryable = retryable
+	}
+}
+
+// WithOnRetry 添加重试钩子，在每次失败后、等待下一次重试前调用，
+// attempt 为刚刚失败的是第几次调用（从1开始），delay 为即将等待的时间。可以添加多个。
+func WithOnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(o *options) {
+		o.onRetry = append(o.onRetry, fn)
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.clock = clock.OrReal(o.clock)
+	return o
+}
+
+// Retry 通用重试函数，支持上下文取消、超时、最大重试次数等
+// bizFunc 返回 nil 表示成功，否则会根据策略重试，重试耗尽时返回的错误包装了每一次调用的错误
+func Retry(ctx context.Context, s Strategy, bizFunc func() error, opts ...Option) error {
+	_, err := Do(ctx, s, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, bizFunc()
+	}, opts...)
+	return err
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -116,7 +117,7 @@ func TestRetry_SuccessAndExhausted(t *testing.T) {
 		callCount2++
 		return errors.New("fail")
 	})
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
 	assert.GreaterOrEqual(t, callCount2, 2)
 }
 
@@ -129,3 +130,37 @@ func TestRetry_ContextCancel(t *testing.T) {
 	assert.Error(t, err)
 	assert.Less(t, time.Since(start), 200*time.Millisecond)
 }
+
+func TestRetry_WithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	base, _ := NewFixedIntervalRetryStrategy(time.Hour, 2)
+
+	var mu sync.Mutex
+	var calls []time.Time
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(context.Background(), base, func() error {
+			mu.Lock()
+			calls = append(calls, clk.Now())
+			mu.Unlock()
+			return errors.New("fail")
+		}, WithClock(clk))
+	}()
+
+	// 每次进入等待后推进一个重试间隔，不需要真实等待一小时
+	for i := 0; i < 2; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(time.Hour)
+	}
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("重试没有结束")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}, calls)
+}
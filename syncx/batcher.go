@@ -0,0 +1,303 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现微批处理器 Batcher，把大量并发的单条调用合并成一次批量调用，
+// 例如合并库存扣减、价格查询、Redis 写入。元素在达到批量上限或等待时间上限时被提交，
+// 批量函数的结果按位置回传给每个调用者的 Future。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// 批处理相关错误定义
+var (
+	ErrBatcherClosed       = errors.New("ggu: 批处理器已关闭")
+	ErrBatchResultMismatch = errors.New("ggu: 批量函数返回的结果数量与元素数量不一致")
+)
+
+const (
+	defaultMaxBatchSize = 100
+	defaultMaxBatchWait = 10 * time.Millisecond
+)
+
+// BatchFunc 批量处理函数，返回的结果与 items 按位置一一对应。
+// 返回普通错误时该批所有元素都收到该错误；返回 *BatchError 时每个元素收到各自的错误。
+type BatchFunc[In, Out any] func(ctx context.Context, items []In) ([]Out, error)
+
+// BatchError 批量函数中单个元素的错误，Errs 与 items 按位置一一对应，nil 表示该元素成功
+type BatchError struct {
+	Errs []error
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	for _, err := range e.Errs {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("ggu: 批量处理中 %d/%d 个元素失败", failed, len(e.Errs))
+}
+
+// Unwrap 返回所有元素的错误，便于使用 errors.Is/As 判断
+func (e *BatchError) Unwrap() []error {
+	return e.Errs
+}
+
+// BatcherOption 批处理器配置选项
+type BatcherOption func(*batcherConfig)
+
+type batcherConfig struct {
+	maxSize    int
+	maxWait    time.Duration
+	maxFlushes int
+	clock      clock.Clock
+}
+
+// WithMaxBatchSize 设置每批的最大元素数量，达到后立即提交，默认100
+func WithMaxBatchSize(n int) BatcherOption {
+	return func(c *batcherConfig) {
+		if n > 0 {
+			c.maxSize = n
+		}
+	}
+}
+
+// WithMaxBatchWait 设置一批中第一个元素最多等待多久就提交，默认10ms
+func WithMaxBatchWait(d time.Duration) BatcherOption {
+	return func(c *batcherConfig) {
+		if d > 0 {
+			c.maxWait = d
+		}
+	}
+}
+
+// WithMaxConcurrentFlushes 设置同时执行的批量函数数量上限，默认1。
+// 达到上限时，填满一批的调用者会等待，从而对提交方形成背压。
+func WithMaxConcurrentFlushes(n int) BatcherOption {
+	return func(c *batcherConfig) {
+		if n > 0 {
+			c.maxFlushes = n
+		}
+	}
+}
+
+// WithBatchClock 设置等待时间使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithBatchClock(clk clock.Clock) BatcherOption {
+	return func(c *batcherConfig) {
+		c.clock = clk
+	}
+}
+
+// ===================== Batcher 微批处理器 =====================
+
+// batchItem 一个等待提交的元素
+type batchItem[In, Out any] struct {
+	ctx    context.Context
+	in     In
+	future *Future[Out]
+}
+
+// Batcher 微批处理器，并发安全
+type Batcher[In, Out any] struct {
+	fn  BatchFunc[In, Out]
+	cfg batcherConfig
+
+	mu      sync.Mutex
+	pending []batchItem[In, Out]
+	timer   clock.Timer
+	gen     uint64 // 每取出一批加一，用于识别过期的定时器回调
+	closed  bool
+
+	flushes chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewBatcher 创建微批处理器
+func NewBatcher[In, Out any](fn BatchFunc[In, Out], opts ...BatcherOption) *Batcher[In, Out] {
+	cfg := batcherConfig{
+		maxSize:    defaultMaxBatchSize,
+		maxWait:    defaultMaxBatchWait,
+		maxFlushes: 1,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.clock = clock.OrReal(cfg.clock)
+	return &Batcher[In, Out]{
+		fn:      fn,
+		cfg:     cfg,
+		flushes: make(chan struct{}, cfg.maxFlushes),
+	}
+}
+
+// Submit 提交一个元素，返回该元素结果的 Future。
+// ctx 在元素被提交前结束时，该元素不会进入批量函数，Future 收到 ctx.Err()。
+func (b *Batcher[In, Out]) Submit(ctx context.Context, item In) *Future[Out] {
+	future := NewFuture[Out]()
+	if err := ctx.Err(); err != nil {
+		var zero Out
+		future.Complete(zero, err)
+		return future
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		var zero Out
+		future.Complete(zero, ErrBatcherClosed)
+		return future
+	}
+	b.pending = append(b.pending, batchItem[In, Out]{ctx: ctx, in: item, future: future})
+	if len(b.pending) == 1 {
+		gen := b.gen
+		b.timer = b.cfg.clock.AfterFunc(b.cfg.maxWait, func() {
+			b.flushGen(gen)
+		})
+	}
+	var batch []batchItem[In, Out]
+	if len(b.pending) >= b.cfg.maxSize {
+		batch = b.takeLocked()
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.dispatch(batch)
+	}
+	return future
+}
+
+// Do 提交一个元素并等待结果
+func (b *Batcher[In, Out]) Do(ctx context.Context, item In) (Out, error) {
+	return b.Submit(ctx, item).Get(ctx)
+}
+
+// Flush 立即提交当前积攒的元素，不等待批量函数执行完成
+func (b *Batcher[In, Out]) Flush() {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if batch != nil {
+		b.dispatch(batch)
+	}
+}
+
+// Close 提交剩余的元素并等待所有批量函数执行完成，之后提交的元素收到 ErrBatcherClosed
+func (b *Batcher[In, Out]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if batch != nil {
+		b.dispatch(batch)
+	}
+	b.wg.Wait()
+}
+
+// flushGen 定时器回调，只提交定时器创建时的那一批
+func (b *Batcher[In, Out]) flushGen(gen uint64) {
+	b.mu.Lock()
+	if b.gen != gen {
+		b.mu.Unlock()
+		return
+	}
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if batch != nil {
+		b.dispatch(batch)
+	}
+}
+
+// takeLocked 取出当前积攒的元素并停止定时器，取出的批次必须交给 dispatch，调用方需持有锁
+func (b *Batcher[In, Out]) takeLocked() []batchItem[In, Out] {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	batch := b.pending
+	b.pending = nil
+	b.gen++
+	// 在锁内登记，保证 Close 一定会等待已经取出的批次
+	b.wg.Add(1)
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// dispatch 等待空闲的执行名额后在新协程中执行批量函数
+func (b *Batcher[In, Out]) dispatch(batch []batchItem[In, Out]) {
+	b.flushes <- struct{}{}
+	go func() {
+		defer func() {
+			<-b.flushes
+			b.wg.Done()
+		}()
+		b.run(batch)
+	}()
+}
+
+// run 执行批量函数并把结果回传给每个元素
+func (b *Batcher[In, Out]) run(batch []batchItem[In, Out]) {
+	var zero Out
+	// 跳过在等待期间已经取消的元素
+	live := batch[:0]
+	for _, item := range batch {
+		if err := item.ctx.Err(); err != nil {
+			item.future.Complete(zero, err)
+			continue
+		}
+		live = append(live, item)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	items := make([]In, len(live))
+	for i, item := range live {
+		items[i] = item.in
+	}
+	outs, err := b.call(items)
+
+	var batchErr *BatchError
+	switch {
+	case errors.As(err, &batchErr) && len(batchErr.Errs) == len(live):
+		// 部分失败时允许只返回错误，成功元素的结果为零值
+		for i, item := range live {
+			out := zero
+			if len(outs) == len(live) {
+				out = outs[i]
+			}
+			item.future.Complete(out, batchErr.Errs[i])
+		}
+	case err != nil:
+		for _, item := range live {
+			item.future.Complete(zero, err)
+		}
+	case len(outs) != len(live):
+		for _, item := range live {
+			item.future.Complete(zero, ErrBatchResultMismatch)
+		}
+	default:
+		for i, item := range live {
+			item.future.Complete(outs[i], nil)
+		}
+	}
+}
+
+// call 执行批量函数，把 panic 转换为错误
+func (b *Batcher[In, Out]) call(items []In) (outs []Out, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return b.fn(context.Background(), items)
+}
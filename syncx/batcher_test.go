@@ -0,0 +1,189 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 batcher.go 的测试用例，覆盖按数量和按时间提交、单元素错误回传、
+// 上下文取消、并发提交上限以及关闭。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+func TestBatcher_MaxSize(t *testing.T) {
+	var batches [][]int
+	var mu sync.Mutex
+	b := NewBatcher(func(ctx context.Context, items []int) ([]string, error) {
+		mu.Lock()
+		batches = append(batches, append([]int(nil), items...))
+		mu.Unlock()
+		outs := make([]string, len(items))
+		for i, v := range items {
+			outs[i] = strconv.Itoa(v * 10)
+		}
+		return outs, nil
+	}, WithMaxBatchSize(3), WithMaxBatchWait(time.Hour))
+	defer b.Close()
+
+	futures := make([]*Future[string], 3)
+	for i := range futures {
+		futures[i] = b.Submit(context.Background(), i+1)
+	}
+	for i, f := range futures {
+		v, err := f.Get(context.Background())
+		if err != nil || v != strconv.Itoa((i+1)*10) {
+			t.Errorf("元素 %d 结果错误, got (%q, %v)", i, v, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("应合并为一批, got %v", batches)
+	}
+}
+
+func TestBatcher_MaxWait(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	b := NewBatcher(func(ctx context.Context, items []string) ([]int, error) {
+		calls.Add(1)
+		outs := make([]int, len(items))
+		for i, s := range items {
+			outs[i] = len(s)
+		}
+		return outs, nil
+	}, WithMaxBatchSize(100), WithMaxBatchWait(50*time.Millisecond), WithBatchClock(clk))
+	defer b.Close()
+
+	f1 := b.Submit(context.Background(), "a")
+	f2 := b.Submit(context.Background(), "bb")
+	clk.Advance(49 * time.Millisecond)
+	if f1.IsDone() || calls.Load() != 0 {
+		t.Fatal("未到等待上限时不应提交")
+	}
+
+	clk.Advance(time.Millisecond)
+	v1, _ := f1.Get(context.Background())
+	v2, _ := f2.Get(context.Background())
+	if v1 != 1 || v2 != 2 || calls.Load() != 1 {
+		t.Errorf("到达等待上限时应提交一批, got (%d, %d), calls=%d", v1, v2, calls.Load())
+	}
+}
+
+func TestBatcher_Errors(t *testing.T) {
+	errOutOfStock := errors.New("库存不足")
+	b := NewBatcher(func(ctx context.Context, items []int) ([]int, error) {
+		errs := make([]error, len(items))
+		for i, v := range items {
+			if v < 0 {
+				errs[i] = errOutOfStock
+			}
+		}
+		return items, &BatchError{Errs: errs}
+	}, WithMaxBatchSize(2))
+	defer b.Close()
+
+	f1 := b.Submit(context.Background(), 1)
+	f2 := b.Submit(context.Background(), -1)
+	if v, err := f1.Get(context.Background()); v != 1 || err != nil {
+		t.Errorf("成功元素结果错误, got (%d, %v)", v, err)
+	}
+	if _, err := f2.Get(context.Background()); !errors.Is(err, errOutOfStock) {
+		t.Errorf("失败元素应收到自己的错误, got %v", err)
+	}
+
+	// 整批失败、结果数量不一致、panic
+	cases := []struct {
+		fn   BatchFunc[int, int]
+		want func(error) bool
+	}{
+		{func(ctx context.Context, items []int) ([]int, error) { return nil, errOutOfStock },
+			func(err error) bool { return errors.Is(err, errOutOfStock) }},
+		{func(ctx context.Context, items []int) ([]int, error) { return []int{1}, nil },
+			func(err error) bool { return errors.Is(err, ErrBatchResultMismatch) }},
+		{func(ctx context.Context, items []int) ([]int, error) { panic("boom") },
+			func(err error) bool { var pe *PanicError; return errors.As(err, &pe) }},
+	}
+	for i, c := range cases {
+		bb := NewBatcher(c.fn, WithMaxBatchSize(2))
+		fa := bb.Submit(context.Background(), 1)
+		fb := bb.Submit(context.Background(), 2)
+		for _, f := range []*Future[int]{fa, fb} {
+			if _, err := f.Get(context.Background()); !c.want(err) {
+				t.Errorf("用例 %d 错误不符合预期, got %v", i, err)
+			}
+		}
+		bb.Close()
+	}
+}
+
+func TestBatcher_ContextCancel(t *testing.T) {
+	var got []int
+	b := NewBatcher(func(ctx context.Context, items []int) ([]int, error) {
+		got = items
+		return items, nil
+	}, WithMaxBatchWait(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f1 := b.Submit(ctx, 1)
+	f2 := b.Submit(context.Background(), 2)
+	cancel()
+	// 调用者可以通过自己的 ctx 放弃等待
+	if _, err := f1.Get(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get 应在 ctx 结束时返回, got %v", err)
+	}
+	b.Close()
+
+	if _, err := f1.Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的元素应收到 ctx.Err(), got %v", err)
+	}
+	if v, err := f2.Get(context.Background()); v != 2 || err != nil {
+		t.Errorf("未取消的元素结果错误, got (%d, %v)", v, err)
+	}
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("已取消的元素不应进入批量函数, got %v", got)
+	}
+
+	if _, err := b.Submit(context.Background(), 3).Get(context.Background()); !errors.Is(err, ErrBatcherClosed) {
+		t.Errorf("关闭后提交应返回 ErrBatcherClosed, got %v", err)
+	}
+}
+
+func TestBatcher_MaxConcurrentFlushes(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	b := NewBatcher(func(ctx context.Context, items []int) ([]int, error) {
+		cur := running.Add(1)
+		for {
+			old := maxRunning.Load()
+			if cur <= old || maxRunning.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return items, nil
+	}, WithMaxBatchSize(1), WithMaxConcurrentFlushes(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if v, err := b.Do(context.Background(), i); v != i || err != nil {
+				t.Errorf("Do 结果错误, got (%d, %v)", v, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	b.Close()
+	if maxRunning.Load() > 2 {
+		t.Errorf("并发提交数超过上限, got %d", maxRunning.Load())
+	}
+}
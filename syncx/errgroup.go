@@ -0,0 +1,106 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现带并发上限的错误组 ErrGroup。一组协程中第一个返回错误的协程会取消共享的上下文，
+// Wait 返回这个错误，适合并行调用多个下游服务、任一失败即整体失败的场景。
+
+package syncx
+
+import (
+	"context"
+	"sync"
+)
+
+// ===================== ErrGroup 错误组 =====================
+
+// ErrGroup 等待一组协程完成并收集第一个错误，零值可用但不会取消任何上下文。
+// 协程发生 panic 时，panic 会被转换为 *PanicError 作为该协程的错误。
+type ErrGroup struct {
+	cancel context.CancelCauseFunc
+
+	wg  sync.WaitGroup
+	sem chan struct{}
+
+	errOnce sync.Once
+	err     error
+}
+
+// NewErrGroup 创建错误组，返回的上下文在第一个协程返回错误或 Wait 返回时被取消
+func NewErrGroup(ctx context.Context) (*ErrGroup, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &ErrGroup{cancel: cancel}, ctx
+}
+
+// SetLimit 设置同时运行的协程数量上限，n < 0 表示不限制。
+// 必须在调用 Go 之前设置，组内仍有协程运行时修改上限会 panic。
+func (g *ErrGroup) SetLimit(n int) {
+	if len(g.sem) != 0 {
+		panic("ggu: 错误组仍有协程运行时不能修改并发上限")
+	}
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go 在新协程中执行 f。达到并发上限时阻塞，直到有协程结束。
+func (g *ErrGroup) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(f)
+}
+
+// TryGo 在未达到并发上限时在新协程中执行 f 并返回 true，否则不执行并返回 false
+func (g *ErrGroup) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(f)
+	return true
+}
+
+// Wait 等待所有协程结束，返回第一个非 nil 的错误
+func (g *ErrGroup) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
+
+func (g *ErrGroup) start(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := g.run(f); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+// run 执行 f，把 panic 转换为错误
+func (g *ErrGroup) run(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return f()
+}
+
+func (g *ErrGroup) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
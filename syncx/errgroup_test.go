@@ -0,0 +1,91 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 errgroup.go 的测试用例，覆盖第一个错误取消上下文、并发上限、TryGo 以及 panic 转换。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrGroup_FirstErrorCancels(t *testing.T) {
+	g, ctx := NewErrGroup(context.Background())
+	wantErr := errors.New("库存服务不可用")
+
+	g.Go(func() error {
+		return wantErr
+	})
+	g.Go(func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("上下文没有被取消")
+		}
+	})
+
+	if err := g.Wait(); !errors.Is(err, wantErr) {
+		t.Errorf("Wait 应返回第一个错误, got %v", err)
+	}
+	if !errors.Is(context.Cause(ctx), wantErr) {
+		t.Errorf("上下文的取消原因应为第一个错误, got %v", context.Cause(ctx))
+	}
+}
+
+func TestErrGroup_Limit(t *testing.T) {
+	var g ErrGroup
+	g.SetLimit(2)
+
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 10; i++ {
+		g.Go(func() error {
+			cur := running.Add(1)
+			for {
+				old := maxRunning.Load()
+				if cur <= old || maxRunning.CompareAndSwap(old, cur) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Errorf("Wait 不应返回错误, got %v", err)
+	}
+	if maxRunning.Load() > 2 {
+		t.Errorf("并发数超过上限, got %d", maxRunning.Load())
+	}
+}
+
+func TestErrGroup_TryGo(t *testing.T) {
+	var g ErrGroup
+	g.SetLimit(1)
+	release := make(chan struct{})
+	if !g.TryGo(func() error { <-release; return nil }) {
+		t.Fatal("未达到上限时 TryGo 应成功")
+	}
+	if g.TryGo(func() error { return nil }) {
+		t.Error("达到上限时 TryGo 应失败")
+	}
+	close(release)
+	_ = g.Wait()
+	if !g.TryGo(func() error { return nil }) {
+		t.Error("协程结束后 TryGo 应成功")
+	}
+	_ = g.Wait()
+}
+
+func TestErrGroup_Panic(t *testing.T) {
+	g, _ := NewErrGroup(context.Background())
+	g.Go(func() error { panic("boom") })
+	var pe *PanicError
+	if err := g.Wait(); !errors.As(err, &pe) {
+		t.Errorf("panic 应转换为 *PanicError, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 syncx 包的使用示例。
+
+package syncx_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/syncx"
+	"github.com/Humphrey-He/go-generic-utils/tree"
+)
+
+// StockUpdate 一次库存变更
+type StockUpdate struct {
+	SKU   string
+	Delta int
+}
+
+// 把并发的单条入库合并为 InventoryManager.BatchUpdateStock 批量调用
+func ExampleBatcher() {
+	im := tree.NewInventoryManager()
+	_ = im.AddSku("SKU001", 10, 0, "WH-001")
+	_ = im.AddSku("SKU002", 10, 0, "WH-001")
+
+	b := syncx.NewBatcher(func(ctx context.Context, updates []StockUpdate) ([]struct{}, error) {
+		// 同一批中同一个SKU的变更先合并，BatchUpdateStock 按SKU返回错误
+		merged := make(map[string]int)
+		for _, u := range updates {
+			merged[u.SKU] += u.Delta
+		}
+		results := im.BatchUpdateStock(merged)
+
+		errs := make([]error, len(updates))
+		for i, u := range updates {
+			errs[i] = results[u.SKU]
+		}
+		return make([]struct{}, len(updates)), &syncx.BatchError{Errs: errs}
+	}, syncx.WithMaxBatchSize(50), syncx.WithMaxBatchWait(5*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sku := "SKU001"
+			if i%2 == 1 {
+				sku = "SKU002"
+			}
+			if _, err := b.Do(context.Background(), StockUpdate{SKU: sku, Delta: 1}); err != nil {
+				fmt.Println("入库失败:", err)
+			}
+		}(i)
+	}
+	_, err := b.Do(context.Background(), StockUpdate{SKU: "SKU404", Delta: 1})
+	fmt.Println("未知SKU:", err)
+
+	wg.Wait()
+	b.Close()
+
+	s1, _ := im.GetStock("SKU001")
+	s2, _ := im.GetStock("SKU002")
+	fmt.Println("SKU001:", s1, "SKU002:", s2)
+
+	// Output:
+	// 未知SKU: ggu: SKU不存在
+	// SKU001: 20 SKU002: 20
+}
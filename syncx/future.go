@@ -0,0 +1,68 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现 Future，表示一个将来才会得到的结果，由生产者调用 Complete 写入一次，
+// 消费者通过 Get 等待结果，适合在异步提交与结果回传之间传递单个值。
+
+package syncx
+
+import (
+	"context"
+	"sync"
+)
+
+// ===================== Future 异步结果 =====================
+
+// Future 异步结果，只能被完成一次，可以被多个协程并发等待
+type Future[T any] struct {
+	done chan struct{}
+	once sync.Once
+	val  T
+	err  error
+}
+
+// NewFuture 创建一个尚未完成的 Future
+func NewFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Complete 写入结果并唤醒所有等待者，只有第一次调用生效，生效时返回 true
+func (f *Future[T]) Complete(val T, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+// Get 等待结果，ctx 先结束时返回 ctx.Err()
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	default:
+	}
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 返回一个在结果写入后关闭的通道
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// IsDone 判断结果是否已经写入
+func (f *Future[T]) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 future.go 的测试用例。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFuture(t *testing.T) {
+	f := NewFuture[int]()
+	if f.IsDone() {
+		t.Error("新建的 Future 不应完成")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("未完成时 Get 应返回 ctx.Err(), got %v", err)
+	}
+
+	if !f.Complete(1, nil) {
+		t.Error("第一次 Complete 应生效")
+	}
+	if f.Complete(2, errors.New("ignored")) {
+		t.Error("第二次 Complete 不应生效")
+	}
+	<-f.Done()
+	// ctx 已结束但结果已就绪时仍返回结果
+	if v, err := f.Get(ctx); v != 1 || err != nil {
+		t.Errorf("Get 结果错误, got (%d, %v)", v, err)
+	}
+}
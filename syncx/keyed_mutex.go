@@ -0,0 +1,282 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现按键加锁的互斥锁 KeyedMutex。每个键按需创建独立的锁，不再使用时按引用计数回收，
+// 不同的键之间互不阻塞；同时锁定多个键时按统一的顺序加锁，避免相互等待造成死锁。
+
+package syncx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ===================== KeyedMutex 按键互斥锁 =====================
+
+// keyedLock 一个键对应的锁，refs 为持有或等待该锁的调用者数量
+type keyedLock struct {
+	ch   chan struct{} // 容量为1，写入即加锁，读出即解锁
+	refs int
+}
+
+// KeyedMutex 按键互斥锁，零值不可用，需要通过 NewKeyedMutex 创建。
+// 只有持有或等待某个键的调用者存在时才会为该键保留锁，键的数量不受限制。
+type KeyedMutex[K comparable] struct {
+	mu      sync.Mutex
+	locks   map[K]*keyedLock
+	compare func(a, b K) int
+}
+
+// NewKeyedMutex 创建按键互斥锁。字符串、整数、浮点数及以它们为底层类型的键按自然顺序排序，
+// 结构体和数组按字段或元素依次排序，指针按地址排序，需要特定的加锁顺序时使用 NewKeyedMutexWithCompare。
+func NewKeyedMutex[K comparable]() *KeyedMutex[K] {
+	return NewKeyedMutexWithCompare[K](nil)
+}
+
+// NewKeyedMutexWithCompare 使用自定义的比较函数创建按键互斥锁，比较函数决定 LockAll 的加锁顺序，
+// 对不相等的键必须返回非0值。compare 为 nil 时使用默认的比较函数。
+func NewKeyedMutexWithCompare[K comparable](compare func(a, b K) int) *KeyedMutex[K] {
+	if compare == nil {
+		compare = defaultCompare[K]
+	}
+	return &KeyedMutex[K]{
+		locks:   make(map[K]*keyedLock),
+		compare: compare,
+	}
+}
+
+// Lock 锁定键，键已被锁定时阻塞
+func (m *KeyedMutex[K]) Lock(key K) {
+	m.ref(key).ch <- struct{}{}
+}
+
+// TryLock 尝试锁定键，键已被锁定时立即返回 false
+func (m *KeyedMutex[K]) TryLock(key K) bool {
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+		m.unref(key, l)
+		return false
+	}
+}
+
+// LockCtx 锁定键，键已被锁定时阻塞直到获取成功或 ctx 结束，失败时返回 ctx.Err()
+func (m *KeyedMutex[K]) LockCtx(ctx context.Context, key K) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		m.unref(key, l)
+		return ctx.Err()
+	}
+}
+
+// TryLockFor 在 d 时间内尝试锁定键，超时返回 false
+func (m *KeyedMutex[K]) TryLockFor(key K, d time.Duration) bool {
+	l := m.ref(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	case <-t.C:
+		m.unref(key, l)
+		return false
+	}
+}
+
+// Unlock 解锁键，解锁未锁定的键会 panic
+func (m *KeyedMutex[K]) Unlock(key K) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if ok {
+		select {
+		case <-l.ch:
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		m.mu.Unlock()
+		panic(fmt.Sprintf("ggu: 解锁未锁定的键 %v", key))
+	}
+	m.releaseLocked(key, l)
+	m.mu.Unlock()
+}
+
+// LockAll 按统一的顺序锁定多个键，重复的键只锁定一次。
+// 所有调用方都按同一顺序加锁，因此同时锁定有交集的多组键不会死锁。
+func (m *KeyedMutex[K]) LockAll(keys ...K) {
+	for _, key := range m.canonical(keys) {
+		m.Lock(key)
+	}
+}
+
+// LockAllCtx 按统一的顺序锁定多个键，ctx 结束时释放已经锁定的键并返回 ctx.Err()
+func (m *KeyedMutex[K]) LockAllCtx(ctx context.Context, keys ...K) error {
+	ordered := m.canonical(keys)
+	for i, key := range ordered {
+		if err := m.LockCtx(ctx, key); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				m.Unlock(ordered[j])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// UnlockAll 解锁通过 LockAll 或 LockAllCtx 锁定的多个键
+func (m *KeyedMutex[K]) UnlockAll(keys ...K) {
+	ordered := m.canonical(keys)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		m.Unlock(ordered[i])
+	}
+}
+
+// Len 返回当前被锁定或等待锁定的键数量
+func (m *KeyedMutex[K]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
+
+// ref 获取键对应的锁并增加引用计数
+func (m *KeyedMutex[K]) ref(key K) *keyedLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// unref 放弃获取键对应的锁
+func (m *KeyedMutex[K]) unref(key K, l *keyedLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releaseLocked(key, l)
+}
+
+// releaseLocked 减少引用计数，没有引用时回收锁，调用方需持有 m.mu
+func (m *KeyedMutex[K]) releaseLocked(key K, l *keyedLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// canonical 对键去重并排序，得到统一的加锁顺序
+func (m *KeyedMutex[K]) canonical(keys []K) []K {
+	ordered := slices.Clone(keys)
+	slices.SortFunc(ordered, m.compare)
+	return slices.CompactFunc(ordered, func(a, b K) bool { return a == b })
+}
+
+// ===================== 默认比较函数 =====================
+
+// defaultCompare 按键的类型逐层比较，得到所有不相等的键之间的全序：
+// 字符串、整数、浮点数、布尔值和复数按值比较，结构体和数组按字段或元素依次比较，
+// 指针和通道按地址比较，接口先比较动态类型再比较动态值
+func defaultCompare[K comparable](a, b K) int {
+	switch x := any(a).(type) {
+	case string:
+		if y, ok := any(b).(string); ok {
+			return cmp.Compare(x, y)
+		}
+	case int:
+		if y, ok := any(b).(int); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	// 通过指针取得类型为 K 的值，K 为接口类型时保留接口本身
+	return compareValue(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+}
+
+// compareValue 比较两个类型相同的可比较值
+func compareValue(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		x, y := a.Complex(), b.Complex()
+		if c := cmp.Compare(real(x), real(y)); c != 0 {
+			return c
+		}
+		return cmp.Compare(imag(x), imag(y))
+	case reflect.Bool:
+		return compareBool(a.Bool(), b.Bool())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return cmp.Compare(a.Pointer(), b.Pointer())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if c := compareValue(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if c := compareValue(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return compareBool(!a.IsNil(), !b.IsNil())
+		}
+		ea, eb := a.Elem(), b.Elem()
+		if ta, tb := ea.Type(), eb.Type(); ta != tb {
+			// 动态类型不同时按类型排序，同名的类型再比较包路径，
+			// 仍然相同时（例如不同函数中同名的局部类型）按类型描述的地址排序，保证不相等的键不会比较为0
+			if c := cmp.Compare(ta.String(), tb.String()); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(ta.PkgPath(), tb.PkgPath()); c != 0 {
+				return c
+			}
+			return cmp.Compare(reflect.ValueOf(ta).Pointer(), reflect.ValueOf(tb).Pointer())
+		}
+		return compareValue(ea, eb)
+	default:
+		// 可比较的类型只有以上几类，接口中保存的不可比较的值在 map 查找时就会 panic
+		panic(fmt.Sprintf("ggu: 键的类型 %s 不可比较", a.Type()))
+	}
+}
+
+// compareBool false 排在 true 之前
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
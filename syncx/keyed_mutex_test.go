@@ -0,0 +1,188 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 keyed_mutex.go 的测试用例，覆盖按键互斥、引用计数回收、超时与上下文加锁以及多键加锁。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex_Basic(t *testing.T) {
+	km := NewKeyedMutex[string]()
+	km.Lock("a")
+	// 不同的键互不阻塞
+	if !km.TryLock("b") {
+		t.Error("不同的键应能同时锁定")
+	}
+	if km.TryLock("a") {
+		t.Error("已锁定的键 TryLock 应失败")
+	}
+	if km.Len() != 2 {
+		t.Errorf("锁数量错误, got %d, want 2", km.Len())
+	}
+
+	km.Unlock("a")
+	km.Unlock("b")
+	if km.Len() != 0 {
+		t.Errorf("解锁后锁应被回收, got %d", km.Len())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("解锁未锁定的键应 panic")
+		}
+	}()
+	km.Unlock("a")
+}
+
+func TestKeyedMutex_Exclusive(t *testing.T) {
+	km := NewKeyedMutex[int]()
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			km.Lock(1)
+			counter++
+			km.Unlock(1)
+		}()
+	}
+	wg.Wait()
+	if counter != 50 {
+		t.Errorf("计数错误, got %d, want 50", counter)
+	}
+	if km.Len() != 0 {
+		t.Errorf("锁应被回收, got %d", km.Len())
+	}
+}
+
+func TestKeyedMutex_Timeout(t *testing.T) {
+	km := NewKeyedMutex[string]()
+	km.Lock("sku")
+
+	if km.TryLockFor("sku", 10*time.Millisecond) {
+		t.Error("键被占用时 TryLockFor 应超时")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := km.LockCtx(ctx, "sku"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LockCtx 应返回 ctx.Err(), got %v", err)
+	}
+
+	// 放弃等待的调用者不应残留引用
+	km.Unlock("sku")
+	if km.Len() != 0 {
+		t.Errorf("超时的等待者不应残留引用, got %d", km.Len())
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		km.Unlock("sku")
+	}()
+	km.Lock("sku")
+	if !km.TryLockFor("sku", time.Second) {
+		t.Error("键释放后 TryLockFor 应成功")
+	}
+	km.Unlock("sku")
+}
+
+func TestKeyedMutex_LockAll(t *testing.T) {
+	km := NewKeyedMutex[string]()
+	stock := map[string]int{"A": 100, "B": 100}
+
+	// A->B 与 B->A 并发调拨，统一加锁顺序避免死锁
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		from, to := "A", "B"
+		if i%2 == 1 {
+			from, to = "B", "A"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			km.LockAll(from, to, from)
+			defer km.UnlockAll(from, to, from)
+			stock[from]--
+			stock[to]++
+		}()
+	}
+	wg.Wait()
+	if stock["A"]+stock["B"] != 200 {
+		t.Errorf("调拨后总库存错误, got %v", stock)
+	}
+	if km.Len() != 0 {
+		t.Errorf("锁应被回收, got %d", km.Len())
+	}
+}
+
+func TestKeyedMutex_LockAllCtx(t *testing.T) {
+	km := NewKeyedMutex[string]()
+	km.Lock("B")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := km.LockAllCtx(ctx, "B", "A"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LockAllCtx 应返回 ctx.Err(), got %v", err)
+	}
+	// 失败时已锁定的 A 应被释放
+	if !km.TryLock("A") {
+		t.Error("LockAllCtx 失败后应释放已锁定的键")
+	}
+	km.Unlock("A")
+	km.Unlock("B")
+}
+
+func TestDefaultCompare(t *testing.T) {
+	type sku string
+	if defaultCompare[sku]("a", "b") >= 0 {
+		t.Error("命名字符串类型应按字符串比较")
+	}
+	if defaultCompare[float64](1.5, 0.5) <= 0 {
+		t.Error("浮点数应按数值比较")
+	}
+	if defaultCompare[any]("a", 1) == 0 {
+		t.Error("不同动态类型的键不应相等")
+	}
+	type pair struct{ a, b int }
+	if defaultCompare(pair{1, 2}, pair{1, 3}) >= 0 {
+		t.Error("结构体键应按字段依次比较")
+	}
+	// 格式化结果相同但不相等的键
+	type boxed struct{ v any }
+	x, y := boxed{int32(1)}, boxed{int64(1)}
+	if c := defaultCompare(x, y); c == 0 || c != -defaultCompare(y, x) {
+		t.Error("字段动态类型不同的键应有确定的顺序")
+	}
+	// 不同函数中同名的局部类型名称和包路径都相同
+	k1, k2 := localKey(), otherLocalKey()
+	if c := defaultCompare(k1, k2); c == 0 || c != -defaultCompare(k2, k1) {
+		t.Error("名称相同的不同类型应有确定的顺序")
+	}
+	if defaultCompare(boxed{}, boxed{0}) >= 0 {
+		t.Error("nil 接口应排在前面")
+	}
+	a, b := new(int), new(int)
+	if defaultCompare(a, b) == 0 || defaultCompare(a, a) != 0 {
+		t.Error("指针键应按地址比较")
+	}
+	if defaultCompare([2]bool{false, true}, [2]bool{true, false}) >= 0 {
+		t.Error("数组键应按元素依次比较")
+	}
+}
+
+// localKey 和 otherLocalKey 返回名称和包路径都相同、但类型不同的键
+func localKey() any {
+	type key struct{ id int }
+	return key{1}
+}
+
+func otherLocalKey() any {
+	type key struct{ id int }
+	return key{1}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现带权重的信号量 WeightedSemaphore。每次获取可以占用多个单位的资源，
+// 等待者按先进先出的顺序获得资源，大请求不会被源源不断的小请求饿死。
+
+package syncx
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// ===================== WeightedSemaphore 带权重的信号量 =====================
+
+// semaphoreWaiter 一个等待获取资源的调用者
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{} // 获取成功时关闭
+}
+
+// WeightedSemaphore 带权重的信号量，按先进先出的顺序分配资源
+type WeightedSemaphore struct {
+	size    int64
+	cur     int64
+	mu      sync.Mutex
+	waiters list.List
+}
+
+// NewWeightedSemaphore 创建总量为 n 的带权重信号量
+func NewWeightedSemaphore(n int64) *WeightedSemaphore {
+	return &WeightedSemaphore{size: n}
+}
+
+// Acquire 获取 n 个单位的资源，资源不足时阻塞直到获取成功或 ctx 结束。
+// 失败时返回 ctx.Err() 且不占用任何资源；n 大于总量时只会在 ctx 结束时返回。
+func (s *WeightedSemaphore) Acquire(ctx context.Context, n int64) error {
+	done := ctx.Done()
+
+	s.mu.Lock()
+	select {
+	case <-done:
+		// ctx 已经结束时不再获取资源，即使资源充足
+		s.mu.Unlock()
+		return ctx.Err()
+	default:
+	}
+	// 有人在排队时即使资源充足也要排队，保证先进先出
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	if n > s.size {
+		// 永远无法满足，只等待 ctx 结束
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(semaphoreWaiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// 在 ctx 结束之后恰好获取成功，把资源还回去
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// 排在队首的等待者放弃后，后面的等待者可能已经可以获取
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	case <-ready:
+		return nil
+	}
+}
+
+// TryAcquire 不阻塞地获取 n 个单位的资源，成功返回 true
+func (s *WeightedSemaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release 释放 n 个单位的资源，释放超过已占用的数量会 panic
+func (s *WeightedSemaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("ggu: 信号量释放的数量超过了已占用的数量")
+	}
+	s.notifyWaiters()
+}
+
+// Available 返回当前可用的资源数量
+func (s *WeightedSemaphore) Available() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.cur
+}
+
+// notifyWaiters 按顺序唤醒资源足够的等待者，调用方需持有锁
+func (s *WeightedSemaphore) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			break
+		}
+		w := front.Value.(semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			// 队首的等待者资源不够时不唤醒后面的小请求，避免队首被饿死
+			break
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 semaphore.go 的测试用例，覆盖获取释放、上下文取消以及先进先出的公平性。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWeightedSemaphore_Basic(t *testing.T) {
+	s := NewWeightedSemaphore(10)
+	if err := s.Acquire(context.Background(), 6); err != nil {
+		t.Fatalf("Acquire 失败: %v", err)
+	}
+	if s.TryAcquire(5) {
+		t.Error("资源不足时 TryAcquire 应失败")
+	}
+	if !s.TryAcquire(4) {
+		t.Error("资源充足时 TryAcquire 应成功")
+	}
+	if s.Available() != 0 {
+		t.Errorf("可用资源错误, got %d", s.Available())
+	}
+	s.Release(10)
+	if s.Available() != 10 {
+		t.Errorf("释放后可用资源错误, got %d", s.Available())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("释放超过已占用的数量应 panic")
+		}
+	}()
+	s.Release(1)
+}
+
+func TestWeightedSemaphore_ContextCancel(t *testing.T) {
+	s := NewWeightedSemaphore(2)
+	_ = s.Acquire(context.Background(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx 结束时应返回 ctx.Err(), got %v", err)
+	}
+	// 超过总量的请求只会在 ctx 结束时返回
+	if err := s.Acquire(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("超过总量时应返回 ctx.Err(), got %v", err)
+	}
+
+	s.Release(2)
+	if s.Available() != 2 {
+		t.Errorf("取消的等待者不应占用资源, got %d", s.Available())
+	}
+}
+
+func TestWeightedSemaphore_FIFO(t *testing.T) {
+	s := NewWeightedSemaphore(3)
+	_ = s.Acquire(context.Background(), 3)
+
+	order := make(chan int, 2)
+	// 大请求先排队
+	go func() {
+		_ = s.Acquire(context.Background(), 3)
+		order <- 3
+	}()
+	waitForWaiters(t, s, 1)
+	// 小请求后排队，即使有资源释放也不能插队
+	go func() {
+		_ = s.Acquire(context.Background(), 1)
+		order <- 1
+	}()
+	waitForWaiters(t, s, 2)
+
+	if s.TryAcquire(1) {
+		t.Error("有等待者时 TryAcquire 不应插队")
+	}
+	s.Release(1)
+	select {
+	case got := <-order:
+		t.Fatalf("队首资源不足时不应唤醒后面的请求, got %d", got)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.Release(2)
+	if got := <-order; got != 3 {
+		t.Errorf("应先唤醒先排队的大请求, got %d", got)
+	}
+	s.Release(3)
+	if got := <-order; got != 1 {
+		t.Errorf("应再唤醒小请求, got %d", got)
+	}
+}
+
+func waitForWaiters(t *testing.T, s *WeightedSemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		l := s.waiters.Len()
+		s.mu.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("等待者数量没有达到 %d", n)
+}
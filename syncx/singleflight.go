@@ -0,0 +1,144 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现泛型的单飞（singleflight）调用组 Group。同一个键的并发调用只会真正执行一次，
+// 其余调用者等待并共享这一次的结果，常用于防止缓存击穿时大量请求同时回源。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit 执行函数调用了 runtime.Goexit，等待者无法拿到结果
+var errGoexit = errors.New("ggu: 执行函数调用了 runtime.Goexit")
+
+// PanicError 执行函数发生 panic 时返回给调用者的错误，保留 panic 值和发生时的调用栈
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func newPanicError(v any) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("ggu: 执行函数发生 panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap panic 值本身是 error 时返回它，便于使用 errors.Is/As 判断
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// ===================== Group 单飞调用组 =====================
+
+// Result DoChan 返回的调用结果
+type Result[V any] struct {
+	Val    V
+	Err    error
+	Shared bool // 结果是否被多个调用者共享
+}
+
+// call 一次正在执行或已经完成的调用
+type call[V any] struct {
+	done      chan struct{}
+	val       V
+	err       error
+	dups      int  // 共享结果的其他调用者数量
+	forgotten bool // 是否已被 Forget，完成时不再从 map 中删除
+}
+
+// Group 单飞调用组，零值可用。
+// 执行函数发生 panic 时，panic 会被转换为 *PanicError 返回给所有等待同一个键的调用者。
+type Group[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]*call[V]
+}
+
+// Do 执行并返回 fn 的结果。同一个键在执行期间的重复调用会等待第一次调用完成并共享其结果，
+// shared 表示结果是否被多个调用者共享。
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	c, leader := g.acquire(key)
+	if leader {
+		g.doCall(c, key, fn)
+	} else {
+		<-c.done
+	}
+	return c.val, c.err, c.dups > 0
+}
+
+// DoChan 与 Do 相同，但不阻塞，结果通过返回的通道送达
+func (g *Group[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	c, leader := g.acquire(key)
+	go func() {
+		if leader {
+			g.doCall(c, key, fn)
+		} else {
+			<-c.done
+		}
+		ch <- Result[V]{Val: c.val, Err: c.err, Shared: c.dups > 0}
+	}()
+	return ch
+}
+
+// DoContext 与 Do 相同，但调用者可以通过 ctx 放弃等待。
+// 放弃等待不会中断正在执行的 fn，fn 的结果仍会交给其他等待者。
+func (g *Group[K, V]) DoContext(ctx context.Context, key K, fn func() (V, error)) (v V, err error, shared bool) {
+	select {
+	case r := <-g.DoChan(key, fn):
+		return r.Val, r.Err, r.Shared
+	case <-ctx.Done():
+		return v, ctx.Err(), false
+	}
+}
+
+// Forget 让 Group 忘记键对应的调用，之后对该键的调用会重新执行 fn 而不是等待正在进行的调用
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.m[key]; ok {
+		c.forgotten = true
+		delete(g.m, key)
+	}
+}
+
+// acquire 获取键对应的调用，leader 为 true 表示需要由调用者执行
+func (g *Group[K, V]) acquire(key K) (c *call[V], leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		return c, false
+	}
+	c = &call[V]{done: make(chan struct{})}
+	g.m[key] = c
+	return c, true
+}
+
+// doCall 执行 fn 并唤醒所有等待者
+func (g *Group[K, V]) doCall(c *call[V], key K, fn func() (V, error)) {
+	// fn 正常返回时会覆盖该错误，只有调用了 runtime.Goexit 时才会保留
+	c.err = errGoexit
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = newPanicError(r)
+		}
+		g.mu.Lock()
+		if !c.forgotten {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+}
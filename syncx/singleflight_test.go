@@ -0,0 +1,150 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 singleflight.go 的测试用例，覆盖结果共享、Forget、DoChan、DoContext 以及 panic 转换。
+
+package syncx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[string, int]
+	v, err, shared := g.Do("key", func() (int, error) { return 42, nil })
+	if v != 42 || err != nil || shared {
+		t.Errorf("Do 结果错误, got (%v, %v, %v)", v, err, shared)
+	}
+
+	wantErr := errors.New("fail")
+	_, err, _ = g.Do("key", func() (int, error) { return 0, wantErr })
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Do 应返回执行函数的错误, got %v", err)
+	}
+}
+
+func TestGroup_DoShared(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]int, n)
+	sharedFlags := make([]bool, n)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, sharedFlags[0] = g.Do("sku", func() (int, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			return 7, nil
+		})
+	}()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, sharedFlags[i] = g.Do("sku", func() (int, error) {
+				calls.Add(1)
+				return 0, nil
+			})
+		}(i)
+	}
+	// 等待其余调用者加入等待
+	for {
+		g.mu.Lock()
+		dups := g.m["sku"].dups
+		g.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("执行函数应只执行一次, got %d", calls.Load())
+	}
+	for i := 0; i < n; i++ {
+		if results[i] != 7 || !sharedFlags[i] {
+			t.Errorf("调用者 %d 结果错误, got (%d, %v)", i, results[i], sharedFlags[i])
+		}
+	}
+}
+
+func TestGroup_Forget(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	first := g.DoChan("key", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	// 等待第一次调用开始执行
+	for {
+		g.mu.Lock()
+		_, ok := g.m["key"]
+		g.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	g.Forget("key")
+	v, _, shared := g.Do("key", func() (int, error) { return 2, nil })
+	if v != 2 || shared {
+		t.Errorf("Forget 后应重新执行, got (%d, %v)", v, shared)
+	}
+
+	close(release)
+	r := <-first
+	if r.Val != 1 || r.Err != nil {
+		t.Errorf("被 Forget 的调用仍应返回自己的结果, got %+v", r)
+	}
+}
+
+func TestGroup_DoContext(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err, _ := g.DoContext(ctx, "key", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DoContext 应在 ctx 结束时返回, got %v", err)
+	}
+
+	// 放弃等待不影响执行，后续调用者仍能共享结果
+	ch := g.DoChan("key", func() (int, error) { return 2, nil })
+	close(release)
+	if r := <-ch; r.Val != 1 || !r.Shared {
+		t.Errorf("应共享仍在执行的调用结果, got %+v", r)
+	}
+}
+
+func TestGroup_Panic(t *testing.T) {
+	var g Group[string, int]
+	_, err, _ := g.Do("key", func() (int, error) { panic("boom") })
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Errorf("panic 应转换为 *PanicError, got %v", err)
+	}
+
+	// panic 后键被清理，可以再次执行
+	v, err, _ := g.Do("key", func() (int, error) { return 1, nil })
+	if v != 1 || err != nil {
+		t.Errorf("panic 后应能再次执行, got (%d, %v)", v, err)
+	}
+}
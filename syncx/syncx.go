@@ -344,6 +344,10 @@ func (l *LimitPool[T]) Put(t T) {
 }
 
 // ===================== SegmentKeysLock 分段Key锁 =====================
+
+// SegmentKeysLock 把键哈希到固定数量的读写锁上，不相关的键可能落到同一个锁上互相阻塞。
+//
+// Deprecated: 使用 KeyedMutex，它为每个键单独加锁，并支持超时、上下文以及多键加锁。
 type SegmentKeysLock struct {
 	locks []*sync.RWMutex
 	size  uint32
@@ -0,0 +1,439 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现分层时间轮（Hierarchical Timing Wheel），用于管理大量定时任务。
+// 添加、取消、重置定时任务都是 O(1) 的，到期回调在有界的工作协程池中执行，
+// 适合作为 ExpirableSet、CacheTree 等带 TTL 的数据结构共享的过期引擎。
+
+package timer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// 时间轮相关错误定义
+var (
+	ErrInvalidTick      = errors.New("ggu: 时间轮刻度必须大于0")
+	ErrInvalidWheelSize = errors.New("ggu: 时间轮槽位数必须是大于1的2的幂")
+	ErrInvalidLevels    = errors.New("ggu: 时间轮层数超出范围")
+)
+
+const (
+	defaultWheelSize = 64
+	defaultLevels    = 4
+	defaultWorkers   = 4
+	defaultQueueSize = 1024
+	// maxRangeBits 所有层能表示的刻度总位数上限，避免移位溢出
+	maxRangeBits = 60
+)
+
+// Option 时间轮配置选项
+type Option func(*TimingWheel)
+
+// WithWheelSize 设置每层的槽位数，必须是2的幂
+func WithWheelSize(size int) Option {
+	return func(w *TimingWheel) {
+		w.wheelSize = size
+	}
+}
+
+// WithLevels 设置时间轮层数，层数越多能表示的最大延迟越长
+func WithLevels(levels int) Option {
+	return func(w *TimingWheel) {
+		w.levelCount = levels
+	}
+}
+
+// WithWorkers 设置执行到期回调的工作协程数量
+func WithWorkers(workers int) Option {
+	return func(w *TimingWheel) {
+		if workers > 0 {
+			w.workers = workers
+		}
+	}
+}
+
+// WithQueueSize 设置待执行回调的队列长度，队列满时时间轮的推进会等待工作协程消费
+func WithQueueSize(size int) Option {
+	return func(w *TimingWheel) {
+		if size >= 0 {
+			w.queueSize = size
+		}
+	}
+}
+
+// WithPanicHandler 设置回调发生 panic 时的处理函数，默认直接忽略
+func WithPanicHandler(handler func(r any)) Option {
+	return func(w *TimingWheel) {
+		w.panicHandler = handler
+	}
+}
+
+// WithClock 设置时间轮使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) Option {
+	return func(w *TimingWheel) {
+		w.clock = clk
+	}
+}
+
+// ================== 定时任务 ==================
+
+// Timer 时间轮中的一个定时任务
+type Timer struct {
+	w          *TimingWheel
+	expiration uint64 // 到期刻度
+	task       func()
+	bucket     *bucket // 所在的槽位，nil 表示不在时间轮中
+	prev, next *Timer
+}
+
+// Cancel 取消定时任务，如果任务尚未到期返回 true
+func (t *Timer) Cancel() bool {
+	w := t.w
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t.bucket == nil {
+		return false
+	}
+	t.bucket.remove(t)
+	w.count--
+	return true
+}
+
+// Reset 将定时任务重新设置为 d 之后到期，无论任务是否已经到期或被取消。
+// 如果任务在重置前尚未到期返回 true。d 不大于0时与 Add 一样异步执行，不会阻塞调用方。
+func (t *Timer) Reset(d time.Duration) bool {
+	w := t.w
+	w.mu.Lock()
+	pending := t.bucket != nil
+	if pending {
+		t.bucket.remove(t)
+		w.count--
+	}
+	if w.stopped {
+		w.mu.Unlock()
+		return pending
+	}
+	t.expiration = w.expirationOf(d)
+	fired := !w.place(t)
+	if fired {
+		w.fired = append(w.fired, t)
+	}
+	w.mu.Unlock()
+
+	if fired {
+		w.notify()
+	}
+	return pending
+}
+
+// bucket 槽位，带哨兵节点的双向链表
+type bucket struct {
+	root Timer
+}
+
+func (b *bucket) init() {
+	b.root.next = &b.root
+	b.root.prev = &b.root
+}
+
+func (b *bucket) push(t *Timer) {
+	t.prev = b.root.prev
+	t.next = &b.root
+	b.root.prev.next = t
+	b.root.prev = t
+	t.bucket = b
+}
+
+func (b *bucket) remove(t *Timer) {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.prev, t.next, t.bucket = nil, nil, nil
+}
+
+// drain 取出槽位中的所有任务
+func (b *bucket) drain() []*Timer {
+	var timers []*Timer
+	for t := b.root.next; t != &b.root; {
+		next := t.next
+		t.prev, t.next, t.bucket = nil, nil, nil
+		timers = append(timers, t)
+		t = next
+	}
+	b.init()
+	return timers
+}
+
+// ================== 分层时间轮 ==================
+
+// TimingWheel 分层时间轮。第0层每个槽位代表一个刻度，第 n 层每个槽位代表 wheelSize^n 个刻度，
+// 高层的任务在低层转完一圈时逐级下沉，最终在第0层到期。
+// 到期精度为一个刻度，回调在工作协程中执行，不能假设回调的执行顺序。
+type TimingWheel struct {
+	tick         time.Duration
+	wheelSize    int
+	levelCount   int
+	workers      int
+	queueSize    int
+	panicHandler func(r any)
+	clock        clock.Clock
+
+	bits    uint
+	mask    uint64
+	levels  [][]bucket
+	start   time.Time
+	current uint64 // 已经处理到的刻度
+	count   int
+
+	mu       sync.Mutex
+	stopped  bool
+	fired    []*Timer      // 添加或重置时已经到期的任务，由推进协程交给工作协程
+	wake     chan struct{} // 通知推进协程处理 fired
+	tasks    chan func()
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	loopDone chan struct{}
+	workerWg sync.WaitGroup
+}
+
+// NewTimingWheel 创建并启动时间轮，tick 为刻度时长，也是到期精度
+func NewTimingWheel(tick time.Duration, opts ...Option) (*TimingWheel, error) {
+	if tick <= 0 {
+		return nil, ErrInvalidTick
+	}
+	w := &TimingWheel{
+		tick:       tick,
+		wheelSize:  defaultWheelSize,
+		levelCount: defaultLevels,
+		workers:    defaultWorkers,
+		queueSize:  defaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.wheelSize <= 1 || w.wheelSize&(w.wheelSize-1) != 0 {
+		return nil, ErrInvalidWheelSize
+	}
+	for 1<<w.bits < w.wheelSize {
+		w.bits++
+	}
+	if w.levelCount <= 0 || int(w.bits)*w.levelCount > maxRangeBits {
+		return nil, ErrInvalidLevels
+	}
+	w.mask = uint64(w.wheelSize - 1)
+
+	w.levels = make([][]bucket, w.levelCount)
+	for i := range w.levels {
+		w.levels[i] = make([]bucket, w.wheelSize)
+		for j := range w.levels[i] {
+			w.levels[i][j].init()
+		}
+	}
+
+	w.clock = clock.OrReal(w.clock)
+	w.start = w.clock.Now()
+	w.tasks = make(chan func(), w.queueSize)
+	w.wake = make(chan struct{}, 1)
+	w.stopCh = make(chan struct{})
+	w.loopDone = make(chan struct{})
+
+	w.workerWg.Add(w.workers)
+	for i := 0; i < w.workers; i++ {
+		go w.worker()
+	}
+	// 在返回前创建周期定时器，保证之后推进时钟时时间轮一定能收到刻度
+	go w.run(w.clock.NewTicker(w.tick))
+	return w, nil
+}
+
+// Add 添加一个 d 之后到期的任务，到期时在工作协程中执行 fn。
+// d 不大于0时任务立即到期，由推进协程异步交给工作协程，Add 不会因为队列已满而阻塞，
+// 因此调用方可以在持有到期回调也需要的锁时调用 Add。时间轮停止后添加的任务不会被执行。
+func (w *TimingWheel) Add(d time.Duration, fn func()) *Timer {
+	t := &Timer{w: w, task: fn}
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return t
+	}
+	t.expiration = w.expirationOf(d)
+	fired := !w.place(t)
+	if fired {
+		w.fired = append(w.fired, t)
+	}
+	w.mu.Unlock()
+
+	if fired {
+		w.notify()
+	}
+	return t
+}
+
+// Tick 返回时间轮的刻度时长
+func (w *TimingWheel) Tick() time.Duration {
+	return w.tick
+}
+
+// Clock 返回时间轮使用的时钟
+func (w *TimingWheel) Clock() clock.Clock {
+	return w.clock
+}
+
+// Len 返回尚未到期的任务数量
+func (w *TimingWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Stop 停止时间轮，尚未到期的任务会被丢弃，已经到期的任务执行完后返回
+func (w *TimingWheel) Stop() {
+	w.stopOnce.Do(func() {
+		w.mu.Lock()
+		w.stopped = true
+		w.mu.Unlock()
+
+		close(w.stopCh)
+		<-w.loopDone
+		w.workerWg.Wait()
+	})
+}
+
+// expirationOf 计算 d 之后对应的到期刻度（向上取整），调用方需持有锁
+func (w *TimingWheel) expirationOf(d time.Duration) uint64 {
+	if d < 0 {
+		d = 0
+	}
+	elapsed := w.clock.Since(w.start) + d
+	return uint64((elapsed + w.tick - 1) / w.tick)
+}
+
+// place 把任务放入合适的层和槽位，任务已经到期时返回 false，调用方需持有锁
+func (w *TimingWheel) place(t *Timer) bool {
+	if t.expiration <= w.current {
+		return false
+	}
+	delta := t.expiration - w.current
+	for level := 0; level < w.levelCount; level++ {
+		shift := w.bits * uint(level)
+		if delta < uint64(1)<<(shift+w.bits) {
+			w.levels[level][(t.expiration>>shift)&w.mask].push(t)
+			w.count++
+			return true
+		}
+	}
+	// 超出最大范围，先放在最高层最远的槽位，下沉时会按真实到期刻度重新计算
+	top := w.levelCount - 1
+	shift := w.bits * uint(top)
+	far := w.current + uint64(1)<<(shift+w.bits) - 1
+	w.levels[top][(far>>shift)&w.mask].push(t)
+	w.count++
+	return true
+}
+
+// advance 推进一个刻度，返回到期的任务，调用方需持有锁
+func (w *TimingWheel) advance() []*Timer {
+	w.current++
+	var expired []*Timer
+
+	// 低层转完一圈时，把上一层对应槽位中的任务下沉
+	for level := 1; level < w.levelCount; level++ {
+		shift := w.bits * uint(level)
+		if w.current&(uint64(1)<<shift-1) != 0 {
+			break
+		}
+		for _, t := range w.levels[level][(w.current>>shift)&w.mask].drain() {
+			w.count--
+			if !w.place(t) {
+				expired = append(expired, t)
+			}
+		}
+	}
+
+	for _, t := range w.levels[0][w.current&w.mask].drain() {
+		w.count--
+		expired = append(expired, t)
+	}
+	return expired
+}
+
+// run 按刻度推进时间轮
+func (w *TimingWheel) run(ticker clock.Ticker) {
+	defer close(w.loopDone)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			w.mu.Lock()
+			// 协程调度延迟时一次追赶多个刻度
+			target := uint64(w.clock.Since(w.start) / w.tick)
+			var expired []*Timer
+			for w.current < target {
+				expired = append(expired, w.advance()...)
+			}
+			w.mu.Unlock()
+			w.dispatch(expired)
+		case <-w.wake:
+			w.mu.Lock()
+			expired := w.fired
+			w.fired = nil
+			w.mu.Unlock()
+			w.dispatch(expired)
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// notify 非阻塞地通知推进协程有已经到期的任务
+func (w *TimingWheel) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 把到期任务交给工作协程
+func (w *TimingWheel) dispatch(timers []*Timer) {
+	for _, t := range timers {
+		select {
+		case w.tasks <- t.task:
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// worker 执行到期回调，回调 panic 不会导致工作协程退出。
+// 停止时先把队列中已经到期的回调执行完再退出。
+func (w *TimingWheel) worker() {
+	defer w.workerWg.Done()
+	for {
+		select {
+		case task := <-w.tasks:
+			w.execute(task)
+		case <-w.stopCh:
+			for {
+				select {
+				case task := <-w.tasks:
+					w.execute(task)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *TimingWheel) execute(task func()) {
+	defer func() {
+		if r := recover(); r != nil && w.panicHandler != nil {
+			w.panicHandler(r)
+		}
+	}()
+	task()
+}
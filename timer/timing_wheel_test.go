@@ -0,0 +1,226 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件为 timing_wheel.go 的测试用例，覆盖任务到期、取消、重置、跨层下沉以及回调 panic 隔离。
+
+package timer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试参数校验
+func TestNewTimingWheel_InvalidArgs(t *testing.T) {
+	_, err := NewTimingWheel(0)
+	assert.ErrorIs(t, err, ErrInvalidTick)
+
+	_, err = NewTimingWheel(time.Millisecond, WithWheelSize(10))
+	assert.ErrorIs(t, err, ErrInvalidWheelSize)
+
+	_, err = NewTimingWheel(time.Millisecond, WithWheelSize(1))
+	assert.ErrorIs(t, err, ErrInvalidWheelSize)
+
+	_, err = NewTimingWheel(time.Millisecond, WithWheelSize(1024), WithLevels(7))
+	assert.ErrorIs(t, err, ErrInvalidLevels)
+}
+
+// 测试任务按时到期
+func TestTimingWheel_Add(t *testing.T) {
+	w, err := NewTimingWheel(5 * time.Millisecond)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	start := time.Now()
+	done := make(chan time.Duration, 1)
+	w.Add(30*time.Millisecond, func() {
+		done <- time.Since(start)
+	})
+	assert.Equal(t, 1, w.Len())
+
+	select {
+	case elapsed := <-done:
+		assert.GreaterOrEqual(t, elapsed, 30*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("任务没有按时到期")
+	}
+	assert.Equal(t, 0, w.Len())
+
+	// 非正数的延迟在下一个刻度内到期
+	fired := make(chan struct{})
+	w.Add(-time.Second, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("已过期的任务应立即执行")
+	}
+}
+
+// 测试跨层下沉以及超出最大范围的任务
+func TestTimingWheel_Cascade(t *testing.T) {
+	// 4个槽位×2层，最大范围 16 个刻度
+	w, err := NewTimingWheel(2*time.Millisecond, WithWheelSize(4), WithLevels(2))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	delays := []time.Duration{3, 9, 17, 40, 70}
+	var wg sync.WaitGroup
+	start := time.Now()
+	var mu sync.Mutex
+	elapsed := make(map[time.Duration]time.Duration)
+	for _, d := range delays {
+		d := d * time.Millisecond
+		wg.Add(1)
+		w.Add(d, func() {
+			mu.Lock()
+			elapsed[d] = time.Since(start)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	for _, d := range delays {
+		d := d * time.Millisecond
+		assert.GreaterOrEqual(t, elapsed[d], d, "任务不能提前到期: %v", d)
+	}
+}
+
+// 测试取消与重置
+func TestTimer_CancelAndReset(t *testing.T) {
+	w, err := NewTimingWheel(2 * time.Millisecond)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	var cancelled atomic.Bool
+	tm := w.Add(20*time.Millisecond, func() { cancelled.Store(true) })
+	assert.True(t, tm.Cancel())
+	assert.False(t, tm.Cancel())
+	assert.Equal(t, 0, w.Len())
+
+	fired := make(chan time.Time, 2)
+	start := time.Now()
+	tm = w.Add(10*time.Millisecond, func() { fired <- time.Now() })
+	// 推迟到期时间
+	assert.True(t, tm.Reset(40*time.Millisecond))
+	at := <-fired
+	assert.GreaterOrEqual(t, at.Sub(start), 40*time.Millisecond)
+
+	// 已到期的任务可以重新设置
+	assert.False(t, tm.Reset(5*time.Millisecond))
+	<-fired
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, cancelled.Load(), "已取消的任务不应执行")
+}
+
+// 测试回调 panic 不影响其他任务
+func TestTimingWheel_PanicIsolation(t *testing.T) {
+	var recovered atomic.Value
+	w, err := NewTimingWheel(time.Millisecond, WithWorkers(1), WithPanicHandler(func(r any) {
+		recovered.Store(r)
+	}))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	w.Add(time.Millisecond, func() { panic("boom") })
+	done := make(chan struct{})
+	w.Add(5*time.Millisecond, func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("panic 之后工作协程应继续执行任务")
+	}
+	assert.Equal(t, "boom", recovered.Load())
+}
+
+// 测试已到期的任务交给推进协程执行，工作协程阻塞时 Add 和 Reset 也不会阻塞调用方
+func TestTimingWheel_AddExpiredDoesNotBlock(t *testing.T) {
+	w, err := NewTimingWheel(time.Millisecond, WithWorkers(1), WithQueueSize(0))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	w.Add(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var mu sync.Mutex
+	var count atomic.Int32
+	returned := make(chan struct{})
+	go func() {
+		// 模拟调用方持有到期回调也需要的锁
+		mu.Lock()
+		defer mu.Unlock()
+		w.Add(0, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			count.Add(1)
+		})
+		w.Add(time.Hour, func() {}).Reset(-time.Second)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("工作协程阻塞时 Add 不应阻塞")
+	}
+
+	close(release)
+	assert.Eventually(t, func() bool { return count.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+// 测试停止后丢弃未到期任务
+func TestTimingWheel_Stop(t *testing.T) {
+	w, err := NewTimingWheel(time.Millisecond)
+	require.NoError(t, err)
+
+	var count atomic.Int32
+	w.Add(50*time.Millisecond, func() { count.Add(1) })
+	w.Stop()
+	w.Stop()
+
+	tm := w.Add(time.Millisecond, func() { count.Add(1) })
+	assert.False(t, tm.Cancel())
+	assert.False(t, tm.Reset(time.Millisecond))
+	time.Sleep(80 * time.Millisecond)
+	assert.Equal(t, int32(0), count.Load())
+}
+
+// 基准测试: 添加并取消任务
+func BenchmarkTimingWheel_AddCancel(b *testing.B) {
+	w, _ := NewTimingWheel(time.Millisecond)
+	defer w.Stop()
+	noop := func() {}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Add(time.Duration(i%10000)*time.Millisecond+time.Second, noop).Cancel()
+	}
+}
+
+// 测试使用假时钟确定性地推进时间轮
+func TestTimingWheel_WithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w, err := NewTimingWheel(time.Second, WithClock(clk))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	var fired atomic.Int32
+	w.Add(time.Hour, func() { fired.Add(1) })
+
+	clk.Advance(59 * time.Minute)
+	assert.Never(t, func() bool { return fired.Load() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	clk.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return fired.Load() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, w.Len())
+}
@@ -1,11 +1,15 @@
 package tree
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/eventbus"
+	"github.com/Humphrey-He/go-generic-utils/timer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -163,6 +167,111 @@ func TestInventoryManager_EventHandling(t *testing.T) {
 	}
 }
 
+// 测试库存事件通过共享的事件总线发布，订阅者收到的是事件发生时的快照
+func TestInventoryManager_EventBus(t *testing.T) {
+	bus := eventbus.New()
+	defer bus.Close()
+	im := NewInventoryManagerWithBus(bus)
+	assert.Same(t, bus, im.EventBus())
+
+	var reserved []InventoryEvent
+	_, err := eventbus.Subscribe(bus, TopicInventoryReserve, func(ctx context.Context, e InventoryEvent) error {
+		reserved = append(reserved, e)
+		return nil
+	})
+	require.NoError(t, err)
+	var actions []string
+	_, err = bus.SubscribePattern(InventoryTopicPattern, func(ctx context.Context, msg eventbus.Message) error {
+		actions = append(actions, msg.Payload.(InventoryEvent).Action)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, im.AddSku("SKU001", 50, 20, "WH-001"))
+	require.NoError(t, im.Reserve("SKU001", 30))
+	require.NoError(t, im.Reserve("SKU001", 10))
+	require.NoError(t, im.Commit("SKU001", 35))
+
+	assert.Equal(t, []string{"add", "reserve", "reserve", "commit", "low_stock"}, actions)
+	require.Len(t, reserved, 2)
+	assert.Equal(t, 30, reserved[0].Item.Reserved)
+	assert.Equal(t, 40, reserved[1].Item.Reserved)
+	assert.Equal(t, 10, reserved[1].Quantity)
+}
+
+// 测试同步处理器在修改库存的协程中执行并可以查询库存，Close 后不再发布事件
+func TestInventoryManager_SyncHandlerAndClose(t *testing.T) {
+	var errs []error
+	im := NewInventoryManager(WithInventoryErrorHandler(func(err error) { errs = append(errs, err) }))
+
+	var available []int
+	_, err := im.SubscribeEvents(func(item *InventoryItem, action string, quantity int) {
+		stock, err := im.GetStock(item.Sku)
+		require.NoError(t, err)
+		available = append(available, stock)
+	})
+	require.NoError(t, err)
+	require.NoError(t, im.AddSku("SKU001", 50, 20, "WH-001"))
+	require.NoError(t, im.Reserve("SKU001", 30))
+	assert.Equal(t, []int{50, 20}, available, "同步处理器在方法返回前执行")
+
+	// 未知动作报告给错误回调
+	im.publish(InventoryEvent{Action: "unknown"})
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrUnknownInventoryAction)
+
+	// 异步订阅者队列已满时报告丢弃的事件
+	block := make(chan struct{})
+	sub, err := im.SubscribeEvents(func(item *InventoryItem, action string, quantity int) { <-block },
+		eventbus.WithAsync(1))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, im.Restock("SKU001", 1))
+	}
+	close(block)
+	require.NotEmpty(t, errs[1:])
+	assert.ErrorIs(t, errs[1], ErrInventoryEventDropped)
+	assert.NotNil(t, sub)
+
+	im.Close()
+	assert.Zero(t, im.EventBus().Subscribers())
+	count := len(available)
+	require.NoError(t, im.Restock("SKU001", 1))
+	assert.Len(t, available, count)
+	_, err = im.SubscribeEvents(func(*InventoryItem, string, int) {})
+	assert.ErrorIs(t, err, eventbus.ErrBusClosed)
+}
+
+// 测试 AddEventHandler 的处理器异步执行，可以在处理事件时修改库存
+func TestInventoryManager_HandlerRestocks(t *testing.T) {
+	im := NewInventoryManager()
+	defer im.Close()
+
+	restocked := make(chan int, 1)
+	im.AddEventHandler(func(item *InventoryItem, action string, quantity int) {
+		switch action {
+		case "low_stock":
+			assert.NoError(t, im.Restock(item.Sku, 100))
+		case "restock":
+			restocked <- quantity
+		}
+	})
+
+	require.NoError(t, im.AddSku("SKU001", 30, 20, "WH-001"))
+	require.NoError(t, im.Reserve("SKU001", 20))
+	require.NoError(t, im.Commit("SKU001", 20))
+
+	select {
+	case quantity := <-restocked:
+		assert.Equal(t, 100, quantity)
+	case <-time.After(time.Second):
+		t.Fatal("处理器补货超时，可能发生死锁")
+	}
+	stock, err := im.GetStock("SKU001")
+	require.NoError(t, err)
+	assert.Equal(t, 110, stock)
+}
+
 // 测试库存管理器并发安全性
 func TestInventoryManager_ConcurrentSafety(t *testing.T) {
 	im := NewInventoryManager()
@@ -582,3 +691,74 @@ func TestECommerceIntegration(t *testing.T) {
 	assert.Equal(t, 899.99, updatedProduct.Price, "更新后价格应为899.99")
 	assert.Equal(t, 999.99, updatedProduct.OriginalPrice, "原价应为999.99")
 }
+
+// 测试由时间轮驱动过期的缓存树
+func TestCacheTree_WithWheel(t *testing.T) {
+	w, err := timer.NewTimingWheel(5 * time.Millisecond)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	cache := NewCacheTreeWithWheel[string](time.Hour, w)
+	cache.PutWithTTL("category/phone/apple", "iPhone", 30*time.Millisecond)
+	cache.PutWithTTL("category/phone/huawei", "Mate", 30*time.Millisecond)
+	cache.Put("category/laptop", "ThinkPad")
+	assert.Equal(t, 3, w.Len())
+
+	// 续期后旧任务被取消
+	cache.PutWithTTL("category/phone/huawei", "Mate", time.Hour)
+	assert.Equal(t, 3, w.Len())
+
+	assert.Eventually(t, func() bool {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		_, ok := cache.findNode("category/phone").Children["apple"]
+		return !ok
+	}, time.Second, 5*time.Millisecond, "到期缓存项应被时间轮直接移除")
+
+	v, err := cache.Get("category/phone/huawei")
+	assert.NoError(t, err)
+	assert.Equal(t, "Mate", v)
+
+	// 删除节点时取消其子树上的到期任务
+	assert.True(t, cache.Delete("category"))
+	assert.Equal(t, 0, w.Len())
+}
+
+// 测试读取时发现过期的缓存项会取消其子树上的到期任务
+func TestCacheTree_GetExpiredCancelsTimers(t *testing.T) {
+	w, err := timer.NewTimingWheel(time.Second)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCacheTreeWithWheel[string](time.Hour, w, WithClock(clk))
+	cache.PutWithTTL("promo/618", "满减", time.Minute)
+	cache.PutWithTTL("promo/618/coupon", "优惠券", time.Hour)
+	assert.Equal(t, 2, w.Len())
+
+	clk.Advance(2 * time.Minute)
+	_, err = cache.Get("promo/618")
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.Equal(t, 0, w.Len(), "删除过期节点时应取消子树上的到期任务")
+	_, err = cache.Get("promo/618/coupon")
+	assert.Error(t, err)
+}
+
+// 测试使用假时钟判断缓存过期
+func TestCacheTree_WithClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCacheTree[string](time.Hour, WithClock(clk))
+	cache.Put("region/cn/beijing", "北京")
+	cache.PutWithTTL("region/cn/shanghai", "上海", time.Minute)
+
+	clk.Advance(time.Minute + time.Second)
+	_, err := cache.Get("region/cn/shanghai")
+	assert.ErrorIs(t, err, ErrExpired, "过期的缓存项不应被读取")
+	v, err := cache.Get("region/cn/beijing")
+	assert.NoError(t, err)
+	assert.Equal(t, "北京", v)
+
+	// 上海已在读取时移除，只剩北京到期
+	clk.Advance(time.Hour)
+	assert.Equal(t, 1, cache.Cleanup())
+}
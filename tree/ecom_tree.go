@@ -16,11 +16,15 @@
 package tree
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/eventbus"
 )
 
 // ----- 电商系统专用错误 -----
@@ -35,6 +39,10 @@ var (
 	ErrStockShortage   = errors.New("ggu: 库存不足")
 	ErrOutOfStock      = errors.New("ggu: 商品库存不足")
 
+	// 库存事件相关错误
+	ErrUnknownInventoryAction = errors.New("ggu: 未知的库存事件动作")
+	ErrInventoryEventDropped  = errors.New("ggu: 库存事件被丢弃")
+
 	// 其他错误
 	ErrExpired      = errors.New("ggu: 数据已过期")
 	ErrNoPermission = errors.New("ggu: 无操作权限")
@@ -496,27 +504,128 @@ func (pc *ProductCatalog) Size() int {
 
 // ----- 库存管理 -----
 
+// InventoryEvent 库存变动事件，Item 为变动后的库存快照
+type InventoryEvent struct {
+	Item     InventoryItem
+	Action   string
+	Quantity int
+}
+
+// InventoryTopicPattern 匹配所有库存变动事件的订阅模式
+const InventoryTopicPattern = "inventory.*"
+
+// 库存变动事件的主题，主题名为 "inventory." 加动作名
+var (
+	TopicInventoryAdd      = eventbus.NewTopic[InventoryEvent]("inventory.add")
+	TopicInventoryUpdate   = eventbus.NewTopic[InventoryEvent]("inventory.update")
+	TopicInventoryReserve  = eventbus.NewTopic[InventoryEvent]("inventory.reserve")
+	TopicInventoryCommit   = eventbus.NewTopic[InventoryEvent]("inventory.commit")
+	TopicInventoryRelease  = eventbus.NewTopic[InventoryEvent]("inventory.release")
+	TopicInventoryRestock  = eventbus.NewTopic[InventoryEvent]("inventory.restock")
+	TopicInventoryLowStock = eventbus.NewTopic[InventoryEvent]("inventory.low_stock")
+
+	inventoryTopics = map[string]eventbus.Topic[InventoryEvent]{
+		"add":       TopicInventoryAdd,
+		"update":    TopicInventoryUpdate,
+		"reserve":   TopicInventoryReserve,
+		"commit":    TopicInventoryCommit,
+		"release":   TopicInventoryRelease,
+		"restock":   TopicInventoryRestock,
+		"low_stock": TopicInventoryLowStock,
+	}
+)
+
 // InventoryManager 库存管理器
-// 使用AVL树实现高效的库存查询和更新
+// 使用AVL树实现高效的库存查询和更新，库存变动事件通过事件总线发布。
+// 事件在释放库存写锁之后按发生顺序发布。AddEventHandler 添加的处理器异步执行，可以修改库存；
+// 通过 SubscribeEvents 同步订阅时处理器可以查询库存，但不能在处理事件时修改库存。
 type InventoryManager struct {
-	inventory     *AVLTree[string, *InventoryItem]
-	mu            sync.RWMutex
-	eventHandlers []func(item *InventoryItem, action string, quantity int)
+	inventory *AVLTree[string, *InventoryItem]
+	mu        sync.RWMutex
+	bus       *eventbus.Bus
+	ownsBus   bool
+	onError   func(err error)
+
+	publishMu sync.Mutex       // 保证事件按发生顺序发布，先于 mu 获取
+	pending   []InventoryEvent // 持有写锁时产生、等待发布的事件
+
+	subsMu sync.Mutex
+	subs   []*eventbus.Subscription // 通过库存管理器创建的订阅，Close 时取消
+	closed bool
 }
 
-// NewInventoryManager 创建新的库存管理器
-func NewInventoryManager() *InventoryManager {
+// InventoryOption 库存管理器配置选项
+type InventoryOption func(*InventoryManager)
+
+// WithInventoryErrorHandler 设置库存事件发布失败、订阅失败或事件被丢弃时的回调，默认使用 log 输出
+func WithInventoryErrorHandler(fn func(err error)) InventoryOption {
+	return func(im *InventoryManager) {
+		if fn != nil {
+			im.onError = fn
+		}
+	}
+}
+
+// NewInventoryManager 创建新的库存管理器，使用独立的事件总线，Close 时关闭该总线
+// 异步订阅者队列已满等原因丢弃的事件以 ErrInventoryEventDropped 报告给错误回调
+func NewInventoryManager(opts ...InventoryOption) *InventoryManager {
+	var im *InventoryManager
+	bus := eventbus.New(eventbus.WithDeadLetterHandler(func(dl eventbus.DeadLetter) {
+		// 处理函数的错误由订阅者自己负责，这里只报告没有送达订阅者的事件
+		switch dl.Reason {
+		case eventbus.ReasonQueueFull, eventbus.ReasonCanceled, eventbus.ReasonEvicted:
+			im.onError(fmt.Errorf("%w: 主题 %s，订阅者 %s，原因 %s", ErrInventoryEventDropped,
+				dl.Message.Topic, dl.Subscriber, dl.Reason))
+		}
+	}))
+	im = NewInventoryManagerWithBus(bus, opts...)
+	im.ownsBus = true
+	return im
+}
+
+// NewInventoryManagerWithBus 创建使用指定事件总线的库存管理器，便于与其他模块共享事件。
+// 总线由调用方管理，丢弃的事件通过总线的死信获取。
+func NewInventoryManagerWithBus(bus *eventbus.Bus, opts ...InventoryOption) *InventoryManager {
 	tree, _ := NewAVLTree[string, *InventoryItem](StringComparator)
-	return &InventoryManager{
-		inventory:     tree,
-		eventHandlers: make([]func(item *InventoryItem, action string, quantity int), 0),
+	im := &InventoryManager{
+		inventory: tree,
+		bus:       bus,
+		onError: func(err error) {
+			log.Printf("ggu: 库存事件处理失败: %v", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(im)
 	}
+	return im
+}
+
+// Close 取消通过 AddEventHandler 和 SubscribeEvents 创建的订阅；
+// 使用独立事件总线时关闭该总线，并等待异步订阅者处理完队列中的事件。之后的库存变动不再发布事件
+func (im *InventoryManager) Close() {
+	im.subsMu.Lock()
+	subs := im.subs
+	im.subs = nil
+	im.closed = true
+	im.subsMu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	if im.ownsBus {
+		im.bus.Close()
+	}
+}
+
+// EventBus 返回库存管理器使用的事件总线
+func (im *InventoryManager) EventBus() *eventbus.Bus {
+	return im.bus
 }
 
 // AddSku 添加新的SKU到库存
 func (im *InventoryManager) AddSku(sku string, initialStock int, safetyStock int, warehouseCode string) error {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	unlock := im.lockForUpdate()
+	defer unlock()
 
 	if initialStock < 0 {
 		return ErrInvalidQuantity
@@ -569,8 +678,8 @@ func (im *InventoryManager) GetStock(sku string) (int, error) {
 
 // Reserve 预留库存(下单未付款)
 func (im *InventoryManager) Reserve(sku string, quantity int) error {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	unlock := im.lockForUpdate()
+	defer unlock()
 
 	if quantity <= 0 {
 		return ErrInvalidQuantity
@@ -597,8 +706,8 @@ func (im *InventoryManager) Reserve(sku string, quantity int) error {
 
 // Commit 确认库存扣减(订单付款完成)
 func (im *InventoryManager) Commit(sku string, quantity int) error {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	unlock := im.lockForUpdate()
+	defer unlock()
 
 	if quantity <= 0 {
 		return ErrInvalidQuantity
@@ -632,8 +741,8 @@ func (im *InventoryManager) Commit(sku string, quantity int) error {
 
 // Release 释放预留库存(订单取消)
 func (im *InventoryManager) Release(sku string, quantity int) error {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	unlock := im.lockForUpdate()
+	defer unlock()
 
 	if quantity <= 0 {
 		return ErrInvalidQuantity
@@ -660,8 +769,8 @@ func (im *InventoryManager) Release(sku string, quantity int) error {
 
 // Restock 补充库存
 func (im *InventoryManager) Restock(sku string, quantity int) error {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	unlock := im.lockForUpdate()
+	defer unlock()
 
 	if quantity <= 0 {
 		return ErrInvalidQuantity
@@ -734,17 +843,73 @@ func (im *InventoryManager) GetItemsByWarehouse(warehouseCode string) []*Invento
 }
 
 // AddEventHandler 添加库存变动事件处理器
+// 处理器异步订阅所有库存事件，在独立的协程中按发生顺序执行，可以在处理事件时修改库存，
+// item 为事件发生时的库存快照。队列已满时丢弃的事件和订阅失败都报告给错误回调，
+// 需要取消订阅或同步处理时使用 SubscribeEvents
 func (im *InventoryManager) AddEventHandler(handler func(item *InventoryItem, action string, quantity int)) {
-	im.mu.Lock()
-	defer im.mu.Unlock()
+	if _, err := im.SubscribeEvents(handler, eventbus.WithAsync(0)); err != nil {
+		im.onError(err)
+	}
+}
 
-	im.eventHandlers = append(im.eventHandlers, handler)
+// SubscribeEvents 订阅所有库存变动事件并返回订阅，默认在修改库存的协程中同步执行，
+// 同步执行的处理器不能修改库存，否则会死锁；传入 eventbus.WithAsync 时在独立协程中按发生顺序执行
+func (im *InventoryManager) SubscribeEvents(handler func(item *InventoryItem, action string, quantity int), opts ...eventbus.SubscribeOption) (*eventbus.Subscription, error) {
+	im.subsMu.Lock()
+	defer im.subsMu.Unlock()
+	if im.closed {
+		return nil, eventbus.ErrBusClosed
+	}
+	sub, err := im.bus.SubscribePattern(InventoryTopicPattern, func(ctx context.Context, msg eventbus.Message) error {
+		event, ok := msg.Payload.(InventoryEvent)
+		if !ok {
+			return eventbus.ErrPayloadTypeMismatch
+		}
+		handler(&event.Item, event.Action, event.Quantity)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	im.subs = append(im.subs, sub)
+	return sub, nil
+}
+
+// lockForUpdate 获取库存写锁，返回的函数释放写锁后按发生顺序发布期间产生的事件
+func (im *InventoryManager) lockForUpdate() func() {
+	im.publishMu.Lock()
+	im.mu.Lock()
+	return func() {
+		events := im.pending
+		im.pending = nil
+		im.mu.Unlock()
+		defer im.publishMu.Unlock()
+		for _, event := range events {
+			im.publish(event)
+		}
+	}
 }
 
-// 触发事件处理
+// 记录库存变动事件，调用方需持有写锁以保证快照一致，事件在释放写锁后发布
 func (im *InventoryManager) triggerEvent(item *InventoryItem, action string, quantity int) {
-	for _, handler := range im.eventHandlers {
-		go handler(item, action, quantity)
+	im.pending = append(im.pending, InventoryEvent{Item: *item, Action: action, Quantity: quantity})
+}
+
+// publish 发布一个库存变动事件，失败时报告给错误回调
+func (im *InventoryManager) publish(event InventoryEvent) {
+	im.subsMu.Lock()
+	closed := im.closed
+	im.subsMu.Unlock()
+	if closed {
+		return
+	}
+	topic, ok := inventoryTopics[event.Action]
+	if !ok {
+		im.onError(fmt.Errorf("%w: %q", ErrUnknownInventoryAction, event.Action))
+		return
+	}
+	if err := eventbus.Publish(context.Background(), im.bus, topic, event); err != nil {
+		im.onError(fmt.Errorf("ggu: 发布库存事件 %s 失败: %w", topic.Name(), err))
 	}
 }
 
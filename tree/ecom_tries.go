@@ -19,6 +19,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/timer"
 )
 
 // -- 前缀树(Trie) --
@@ -224,6 +227,22 @@ type CacheNode[T any] struct {
 	Value     T
 	ExpiresAt time.Time
 	Children  map[string]*CacheNode[T]
+	expiry    *timer.Timer // 使用时间轮时的到期任务
+}
+
+// CacheTreeOption 缓存树配置选项
+type CacheTreeOption func(*cacheTreeConfig)
+
+type cacheTreeConfig struct {
+	clock clock.Clock
+}
+
+// WithClock 设置缓存树判断过期使用的时钟，默认使用系统时钟，测试中可以传入 clock.FakeClock。
+// 使用时间轮时默认与时间轮使用同一个时钟。
+func WithClock(clk clock.Clock) CacheTreeOption {
+	return func(c *cacheTreeConfig) {
+		c.clock = clk
+	}
 }
 
 // CacheTree 层次化缓存树
@@ -232,19 +251,34 @@ type CacheTree[T any] struct {
 	root       *CacheNode[T]
 	mu         sync.RWMutex
 	defaultTTL time.Duration
+	wheel      *timer.TimingWheel
+	clock      clock.Clock
 }
 
 // NewCacheTree 创建新的缓存树
-func NewCacheTree[T any](defaultTTL time.Duration) *CacheTree[T] {
+func NewCacheTree[T any](defaultTTL time.Duration, opts ...CacheTreeOption) *CacheTree[T] {
+	var cfg cacheTreeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	return &CacheTree[T]{
 		root: &CacheNode[T]{
 			Key:      "root",
 			Children: make(map[string]*CacheNode[T]),
 		},
 		defaultTTL: defaultTTL,
+		clock:      clock.OrReal(cfg.clock),
 	}
 }
 
+// NewCacheTreeWithWheel 创建由时间轮驱动过期的缓存树，缓存项到期时被单独移除，
+// 不再需要定期调用 Cleanup 遍历整棵树
+func NewCacheTreeWithWheel[T any](defaultTTL time.Duration, w *timer.TimingWheel, opts ...CacheTreeOption) *CacheTree[T] {
+	ct := NewCacheTree[T](defaultTTL, append([]CacheTreeOption{WithClock(w.Clock())}, opts...)...)
+	ct.wheel = w
+	return ct
+}
+
 // Put 将值放入缓存
 func (ct *CacheTree[T]) Put(path string, value T) {
 	ct.PutWithTTL(path, value, ct.defaultTTL)
@@ -282,22 +316,74 @@ func (ct *CacheTree[T]) PutWithTTL(path string, value T, ttl time.Duration) {
 	}
 
 	// 创建或更新节点
-	expiresAt := time.Now().Add(ttl)
-	if existing, exists := node.Children[lastPart]; exists {
-		existing.Value = value
-		existing.ExpiresAt = expiresAt
+	expiresAt := ct.clock.Now().Add(ttl)
+	child, exists := node.Children[lastPart]
+	if exists {
+		child.Value = value
+		child.ExpiresAt = expiresAt
 	} else {
-		node.Children[lastPart] = &CacheNode[T]{
+		child = &CacheNode[T]{
 			Key:       lastPart,
 			Value:     value,
 			ExpiresAt: expiresAt,
 			Children:  make(map[string]*CacheNode[T]),
 		}
+		node.Children[lastPart] = child
+	}
+
+	if ct.wheel != nil {
+		if child.expiry != nil {
+			child.expiry.Cancel()
+		}
+		child.expiry = ct.wheel.Add(ttl, func() {
+			ct.expire(path, child, expiresAt)
+		})
+	}
+}
+
+// expire 时间轮回调，只有节点仍在原位置且过期时间未被更新时才删除
+func (ct *CacheTree[T]) expire(path string, target *CacheNode[T], expiresAt time.Time) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	idx := strings.LastIndex(path, "/")
+	parent := ct.root
+	if idx >= 0 {
+		parent = ct.findNode(path[:idx])
+	}
+	if parent == nil {
+		return
+	}
+	key := path[idx+1:]
+	if child, ok := parent.Children[key]; ok && child == target && child.ExpiresAt.Equal(expiresAt) {
+		delete(parent.Children, key)
+		cancelExpiry(child)
+	}
+}
+
+// cancelExpiry 取消节点及其子树上所有的到期任务
+func cancelExpiry[T any](node *CacheNode[T]) {
+	if node.expiry != nil {
+		node.expiry.Cancel()
+		node.expiry = nil
+	}
+	for _, child := range node.Children {
+		cancelExpiry(child)
 	}
 }
 
 // Get 从缓存获取值
 func (ct *CacheTree[T]) Get(path string) (T, error) {
+	value, expired, expiresAt, err := ct.get(path)
+	if expired != nil {
+		// 读锁下不能修改树，换成写锁删除过期节点并取消子树上的到期任务
+		ct.expire(path, expired, expiresAt)
+	}
+	return value, err
+}
+
+// get 在读锁下查找缓存项，缓存项已过期时返回该节点及其过期时间，由调用方在写锁下删除
+func (ct *CacheTree[T]) get(path string) (T, *CacheNode[T], time.Time, error) {
 	ct.mu.RLock()
 	defer ct.mu.RUnlock()
 
@@ -313,25 +399,23 @@ func (ct *CacheTree[T]) Get(path string) (T, error) {
 		child, exists := node.Children[part]
 		if !exists {
 			var zero T
-			return zero, errors.New("ggu: 缓存项不存在")
+			return zero, nil, time.Time{}, errors.New("ggu: 缓存项不存在")
 		}
 
 		// 如果是最后一部分，检查是否过期并返回值
 		if i == len(parts)-1 {
-			if !child.ExpiresAt.IsZero() && time.Now().After(child.ExpiresAt) {
-				// 过期了，移除它
-				delete(node.Children, part)
+			if !child.ExpiresAt.IsZero() && ct.clock.Now().After(child.ExpiresAt) {
 				var zero T
-				return zero, ErrExpired
+				return zero, child, child.ExpiresAt, ErrExpired
 			}
-			return child.Value, nil
+			return child.Value, nil, time.Time{}, nil
 		}
 
 		node = child
 	}
 
 	var zero T
-	return zero, errors.New("ggu: 无效的缓存路径")
+	return zero, nil, time.Time{}, errors.New("ggu: 无效的缓存路径")
 }
 
 // Delete 删除缓存项
@@ -363,8 +447,9 @@ func (ct *CacheTree[T]) Delete(path string) bool {
 		return false
 	}
 
-	if _, exists := node.Children[lastPart]; exists {
+	if child, exists := node.Children[lastPart]; exists {
 		delete(node.Children, lastPart)
+		cancelExpiry(child)
 		return true
 	}
 
@@ -384,7 +469,7 @@ func (ct *CacheTree[T]) GetChildren(path string) map[string]T {
 
 	// 收集子项
 	result := make(map[string]T)
-	now := time.Now()
+	now := ct.clock.Now()
 
 	for key, child := range node.Children {
 		// 跳过过期的项
@@ -436,7 +521,7 @@ func (ct *CacheTree[T]) cleanupNode(node *CacheNode[T]) int {
 	}
 
 	removed := 0
-	now := time.Now()
+	now := ct.clock.Now()
 
 	// 创建待删除键的列表
 	keysToDelete := make([]string, 0)
@@ -455,6 +540,7 @@ func (ct *CacheTree[T]) cleanupNode(node *CacheNode[T]) int {
 
 	// 删除过期的键
 	for _, key := range keysToDelete {
+		cancelExpiry(node.Children[key])
 		delete(node.Children, key)
 	}
 
@@ -0,0 +1,553 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件实现 webhook 分发器 Dispatcher。发布事件时为订阅者的每个匹配端点创建一条投递记录，
+// 记录先写入 Store 再放入延迟队列，工作协程按到期时间取出并发送；失败的投递按重试策略计算
+// 退避时间后重新放入延迟队列。每个端点有独立的熔断器，端点持续失败时暂停向它投递，
+// 不影响其他端点。每次尝试都记录在投递日志中，重试耗尽的投递可以手动重放。
+// 工作协程发送前先认领投递：把下次投递时间推迟到租约到期，之后对记录的修改都以认领时的版本为条件，
+// 因此共享存储的其他实例、过期的任务和并发的重放都不会与正在进行的投递互相覆盖。
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	"github.com/Humphrey-He/go-generic-utils/dataStructures/queue"
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+	"github.com/Humphrey-He/go-generic-utils/retry"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultWorkers         = 4
+	defaultRescheduleDelay = 30 * time.Second
+	defaultLeaseTimeout    = time.Minute
+	maxLoggedBody          = 1024
+	maxDrainedBody         = 64 << 10
+)
+
+// Event 发送给端点的请求体
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// task 延迟队列中的元素，at 为放入队列时投递记录的 NextAttemptAt；nil 通知工作协程退出
+type task struct {
+	deliveryID string
+	at         time.Time
+}
+
+// ===================== Dispatcher 分发器 =====================
+
+// Dispatcher webhook 分发器，线程安全
+type Dispatcher struct {
+	store           Store
+	client          *ggunet.HTTPClient
+	newStrategy     func() retry.Strategy
+	newBreaker      func(endpointID string) *breaker.Breaker
+	clock           clock.Clock
+	workers         int
+	rescheduleDelay time.Duration
+	leaseTimeout    time.Duration
+	onError         func(err error)
+
+	queue *queue.DelayQueue[*task]
+	wg    sync.WaitGroup
+
+	mu        sync.RWMutex
+	endpoints map[string]*Endpoint
+	breakers  map[string]*breaker.Breaker
+	started   bool
+	closed    bool
+}
+
+// Option 分发器配置选项
+type Option func(*Dispatcher)
+
+// WithStore 设置投递记录的存储，默认使用 MemoryStore；需要在重启后继续重试时使用 RedisStore
+func WithStore(s Store) Option {
+	return func(d *Dispatcher) {
+		d.store = s
+	}
+}
+
+// WithHTTPClient 设置发送请求的客户端
+// 默认客户端超时10秒、不自动重试，并使用 net.SSRFGuard 防止商家填写内网地址
+func WithHTTPClient(c *ggunet.HTTPClient) Option {
+	return func(d *Dispatcher) {
+		d.client = c
+	}
+}
+
+// WithRetryStrategy 设置重试策略的工厂函数，每次计算退避时间都会创建新的策略
+// 默认从10秒开始指数退避，最长间隔1小时，最多重试10次
+func WithRetryStrategy(fn func() retry.Strategy) Option {
+	return func(d *Dispatcher) {
+		d.newStrategy = fn
+	}
+}
+
+// WithBreakerFactory 设置为端点创建熔断器的函数，默认使用 breaker.New 的默认配置
+func WithBreakerFactory(fn func(endpointID string) *breaker.Breaker) Option {
+	return func(d *Dispatcher) {
+		d.newBreaker = fn
+	}
+}
+
+// WithClock 设置时钟，测试中可以传入 clock.FakeClock
+func WithClock(clk clock.Clock) Option {
+	return func(d *Dispatcher) {
+		d.clock = clock.OrReal(clk)
+	}
+}
+
+// WithWorkers 设置并发发送的工作协程数，默认4个
+func WithWorkers(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.workers = n
+		}
+	}
+}
+
+// WithRescheduleDelay 设置熔断器打开或存储出错时推迟投递的时间，默认30秒，推迟不计入重试次数
+func WithRescheduleDelay(delay time.Duration) Option {
+	return func(d *Dispatcher) {
+		if delay > 0 {
+			d.rescheduleDelay = delay
+		}
+	}
+}
+
+// WithLeaseTimeout 设置认领投递的租约时间，默认1分钟，应大于发送请求的超时时间
+// 认领投递的实例在发送过程中退出时，投递在租约到期后由下次 Start 加载的分发器继续
+func WithLeaseTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.leaseTimeout = timeout
+		}
+	}
+}
+
+// WithErrorHandler 设置工作协程中存储出错时的回调，默认忽略
+func WithErrorHandler(fn func(err error)) Option {
+	return func(d *Dispatcher) {
+		d.onError = fn
+	}
+}
+
+// NewDispatcher 创建分发器，调用 Start 后开始投递
+func NewDispatcher(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store: NewMemoryStore(),
+		newStrategy: func() retry.Strategy {
+			s, _ := retry.NewExponentialBackoffRetryStrategy(10*time.Second, time.Hour, 10)
+			return s
+		},
+		clock:           clock.Real,
+		workers:         defaultWorkers,
+		rescheduleDelay: defaultRescheduleDelay,
+		leaseTimeout:    defaultLeaseTimeout,
+		onError:         func(error) {},
+		endpoints:       make(map[string]*Endpoint),
+		breakers:        make(map[string]*breaker.Breaker),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = ggunet.NewHTTPClient(
+			ggunet.WithTimeout(10*time.Second),
+			ggunet.WithMaxRetries(0),
+			ggunet.WithSSRFGuard(ggunet.NewSSRFGuard()),
+			ggunet.WithDefaultHeader("User-Agent", "ggu-webhook/1.0"),
+		)
+	}
+	if d.newBreaker == nil {
+		d.newBreaker = func(endpointID string) *breaker.Breaker {
+			return breaker.New(breaker.WithName(endpointID), breaker.WithClock(d.clock))
+		}
+	}
+	d.queue = queue.NewDelayQueue[*task](queue.WithClock(d.clock))
+	return d
+}
+
+// RegisterEndpoint 注册或更新端点，更新时保留端点的熔断器状态
+func (d *Dispatcher) RegisterEndpoint(ep Endpoint) error {
+	if ep.ID == "" || ep.Secret == "" {
+		return fmt.Errorf("%w: ID 和密钥不能为空", ErrInvalidEndpoint)
+	}
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: 无效的URL %q", ErrInvalidEndpoint, ep.URL)
+	}
+	ep.Events = append([]string(nil), ep.Events...)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.endpoints[ep.ID] = &ep
+	return nil
+}
+
+// RemoveEndpoint 删除端点，等待投递的记录在到期时标记为失败
+func (d *Dispatcher) RemoveEndpoint(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.endpoints, id)
+	delete(d.breakers, id)
+}
+
+// Endpoint 返回端点
+func (d *Dispatcher) Endpoint(id string) (Endpoint, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	ep, ok := d.endpoints[id]
+	if !ok {
+		return Endpoint{}, false
+	}
+	return *ep, true
+}
+
+// Endpoints 返回订阅者注册的全部端点
+func (d *Dispatcher) Endpoints(subscriberID string) []Endpoint {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var res []Endpoint
+	for _, ep := range d.endpoints {
+		if ep.SubscriberID == subscriberID {
+			res = append(res, *ep)
+		}
+	}
+	return res
+}
+
+// Start 从存储中加载等待投递的记录并启动工作协程，重复调用无效
+func (d *Dispatcher) Start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	if d.started {
+		return nil
+	}
+
+	pending, err := d.store.PendingDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range pending {
+		d.enqueue(delivery)
+	}
+
+	d.started = true
+	d.wg.Add(d.workers)
+	for i := 0; i < d.workers; i++ {
+		go d.work()
+	}
+	return nil
+}
+
+// Close 停止工作协程，等待正在进行的投递完成后返回
+// 未到期的投递保留在存储中，下次 Start 时继续
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	started := d.started
+	d.mu.Unlock()
+
+	if started {
+		// 每个工作协程取到一个 nil 后退出
+		for i := 0; i < d.workers; i++ {
+			_ = d.queue.EnqueueWithDelay(nil, time.Time{})
+		}
+		d.wg.Wait()
+	}
+}
+
+// Publish 向订阅者发布事件，订阅者每个接受该事件类型的端点各创建一条投递记录，返回创建的记录
+// payload 为 []byte 或 json.RawMessage 时作为 JSON 原样使用，其他值编码为 JSON。
+// 投递至少成功一次：网络错误或进程重启可能导致重复发送，接收方应按 Webhook-Id 去重
+func (d *Dispatcher) Publish(ctx context.Context, subscriberID, eventType string, payload any) ([]*Delivery, error) {
+	var data json.RawMessage
+	switch p := payload.(type) {
+	case json.RawMessage:
+		data = p
+	case []byte:
+		data = p
+	default:
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	now := d.clock.Now()
+	event := Event{ID: uuid.NewString(), Type: eventType, CreatedAt: now, Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return nil, ErrDispatcherClosed
+	}
+
+	var deliveries []*Delivery
+	for _, ep := range d.endpoints {
+		if ep.SubscriberID != subscriberID || ep.Disabled || !ep.Accepts(eventType) {
+			continue
+		}
+		delivery := &Delivery{
+			ID:            uuid.NewString(),
+			EndpointID:    ep.ID,
+			EventID:       event.ID,
+			EventType:     eventType,
+			Payload:       body,
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err = d.store.SaveDelivery(ctx, delivery); err != nil {
+			return deliveries, err
+		}
+		d.enqueueIfStarted(delivery)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Replay 立即重新投递，常用于端点修复后重放失败的投递，重放后重新计算重试次数
+// 投递正在进行时，该次投递的结果被丢弃，由重放重新发送；记录在重放期间被修改时返回 ErrDeliveryConflict
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID string) error {
+	delivery, err := d.store.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	if _, ok := d.endpoints[delivery.EndpointID]; !ok {
+		return ErrEndpointNotFound
+	}
+
+	now := d.clock.Now()
+	delivery.Status = StatusPending
+	delivery.Retries = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err = d.store.UpdateDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	d.enqueueIfStarted(delivery)
+	return nil
+}
+
+// Delivery 返回投递记录
+func (d *Dispatcher) Delivery(ctx context.Context, id string) (*Delivery, error) {
+	return d.store.GetDelivery(ctx, id)
+}
+
+// Attempts 返回投递日志
+func (d *Dispatcher) Attempts(ctx context.Context, deliveryID string) ([]*Attempt, error) {
+	return d.store.Attempts(ctx, deliveryID)
+}
+
+// enqueue 按投递记录的下次投递时间放入延迟队列
+func (d *Dispatcher) enqueue(delivery *Delivery) {
+	_ = d.queue.EnqueueWithDelay(&task{deliveryID: delivery.ID, at: delivery.NextAttemptAt}, delivery.NextAttemptAt)
+}
+
+// enqueueIfStarted 分发器已启动时放入延迟队列，否则由 Start 从存储中加载，调用方需持有锁
+func (d *Dispatcher) enqueueIfStarted(delivery *Delivery) {
+	if d.started {
+		d.enqueue(delivery)
+	}
+}
+
+// work 工作协程，取到 nil 时退出
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		t, _ := d.queue.Dequeue()
+		if t == nil {
+			return
+		}
+		d.process(t)
+	}
+}
+
+// process 执行一次投递
+func (d *Dispatcher) process(t *task) {
+	ctx := context.Background()
+	delivery, err := d.store.GetDelivery(ctx, t.deliveryID)
+	if err != nil {
+		if !errors.Is(err, ErrDeliveryNotFound) {
+			d.onError(err)
+			d.retryLater(t)
+		}
+		return
+	}
+	// 已完成的投递，或者投递已被重放、重新安排，这是过期的任务
+	if delivery.Status != StatusPending || !delivery.NextAttemptAt.Equal(t.at) {
+		return
+	}
+
+	d.mu.Lock()
+	ep, ok := d.endpoints[delivery.EndpointID]
+	var b *breaker.Breaker
+	if ok && !ep.Disabled {
+		if b = d.breakers[ep.ID]; b == nil {
+			b = d.newBreaker(ep.ID)
+			d.breakers[ep.ID] = b
+		}
+		copied := *ep
+		ep = &copied
+	}
+	d.mu.Unlock()
+
+	now := d.clock.Now()
+	if !ok || ep.Disabled {
+		delivery.Status = StatusFailed
+		delivery.LastError = ErrEndpointNotFound.Error()
+		delivery.UpdatedAt = now
+		d.save(ctx, t, delivery)
+		return
+	}
+
+	// 认领投递，其他实例或过期任务读到的下次投递时间或版本不再匹配；发送过程中退出时租约到期后继续
+	delivery.NextAttemptAt = now.Add(d.leaseTimeout)
+	delivery.UpdatedAt = now
+	if !d.save(ctx, t, delivery) {
+		return
+	}
+	t = &task{deliveryID: delivery.ID, at: delivery.NextAttemptAt}
+
+	done, err := b.Allow()
+	if err != nil {
+		// 熔断器打开，推迟投递且不计入重试次数
+		delivery.NextAttemptAt = now.Add(d.rescheduleDelay)
+		delivery.LastError = err.Error()
+		delivery.UpdatedAt = now
+		if d.save(ctx, t, delivery) {
+			d.enqueue(delivery)
+		}
+		return
+	}
+
+	attempt, sendErr := d.send(ctx, ep, delivery)
+	done(sendErr)
+	if err = d.store.AddAttempt(ctx, attempt); err != nil {
+		d.onError(err)
+	}
+
+	now = d.clock.Now()
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	if sendErr == nil {
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+		d.save(ctx, t, delivery)
+		return
+	}
+
+	delivery.Retries++
+	delivery.LastError = sendErr.Error()
+	delay, retryable := d.backoff(delivery.Retries)
+	if !retryable {
+		delivery.Status = StatusFailed
+		d.save(ctx, t, delivery)
+		return
+	}
+	delivery.NextAttemptAt = now.Add(delay)
+	if d.save(ctx, t, delivery) {
+		d.enqueue(delivery)
+	}
+}
+
+// send 签名并发送请求，返回本次尝试的记录；端点返回非 2xx 时返回错误
+func (d *Dispatcher) send(ctx context.Context, ep *Endpoint, delivery *Delivery) (*Attempt, error) {
+	start := d.clock.Now()
+	timestamp := start.Unix()
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		HeaderID:        delivery.ID,
+		HeaderEvent:     delivery.EventType,
+		HeaderTimestamp: strconv.FormatInt(timestamp, 10),
+		HeaderSignature: Sign(ep.Secret, delivery.ID, timestamp, delivery.Payload),
+	}
+	attempt := &Attempt{DeliveryID: delivery.ID, Number: delivery.Attempts + 1, StartedAt: start}
+
+	resp, err := d.client.Post(ctx, ep.URL, delivery.Payload, headers)
+	attempt.Duration = d.clock.Since(start)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBody))
+	// 读完剩余的少量内容以便复用连接
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return attempt, fmt.Errorf("ggu: webhook 端点返回 %s", resp.Status)
+	}
+	return attempt, nil
+}
+
+// backoff 返回第 retries 次重试前的等待时间，重试次数耗尽时返回 false
+func (d *Dispatcher) backoff(retries int) (time.Duration, bool) {
+	s := d.newStrategy()
+	var delay time.Duration
+	for i := 0; i < retries; i++ {
+		var ok bool
+		if delay, ok = s.Next(); !ok {
+			return 0, false
+		}
+	}
+	return delay, true
+}
+
+// save 以读取时的版本为条件保存投递记录
+// 记录已被重放、其他实例修改或删除时放弃本次结果；存储出错时存储中仍是旧记录，稍后重新处理原任务
+func (d *Dispatcher) save(ctx context.Context, t *task, delivery *Delivery) bool {
+	err := d.store.UpdateDelivery(ctx, delivery)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, ErrDeliveryConflict) && !errors.Is(err, ErrDeliveryNotFound) {
+		d.onError(err)
+		d.retryLater(t)
+	}
+	return false
+}
+
+// retryLater 存储出错时推迟处理任务，任务的 at 不变，仍与存储中的记录匹配
+func (d *Dispatcher) retryLater(t *task) {
+	_ = d.queue.EnqueueWithDelay(t, d.clock.Now().Add(d.rescheduleDelay))
+}
@@ -0,0 +1,400 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/breaker"
+	"github.com/Humphrey-He/go-generic-utils/clock"
+	ggunet "github.com/Humphrey-He/go-generic-utils/net"
+	"github.com/Humphrey-He/go-generic-utils/retry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received 接收方收到的一次请求
+type received struct {
+	id        string
+	eventType string
+	event     Event
+	verifyErr error
+}
+
+// receiver 测试用的 webhook 接收方，使用假时钟校验签名，返回 status 指定的状态码
+type receiver struct {
+	*httptest.Server
+	status atomic.Int32
+
+	mu       sync.Mutex
+	requests []received
+}
+
+func newReceiver(t *testing.T, clk clock.Clock, secret string) *receiver {
+	r := &receiver{}
+	r.status.Store(http.StatusOK)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rec := received{id: req.Header.Get(HeaderID), eventType: req.Header.Get(HeaderEvent)}
+		rec.verifyErr = Verify(secret, rec.id, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature),
+			body, time.Minute, clk.Now())
+		_ = json.Unmarshal(body, &rec.event)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, rec)
+		r.mu.Unlock()
+
+		w.WriteHeader(int(r.status.Load()))
+		_, _ = w.Write([]byte("ack"))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+// newTestDispatcher 创建使用假时钟和单个工作协程的分发器，测试服务器在本机，因此不使用默认的 SSRF 防护
+func newTestDispatcher(t *testing.T, clk *clock.FakeClock, opts ...Option) *Dispatcher {
+	d := NewDispatcher(append([]Option{
+		WithClock(clk),
+		WithWorkers(1),
+		WithHTTPClient(ggunet.NewHTTPClient(ggunet.WithMaxRetries(0))),
+		WithRetryStrategy(func() retry.Strategy {
+			s, _ := retry.NewFixedIntervalRetryStrategy(10*time.Second, 2)
+			return s
+		}),
+	}, opts...)...)
+	t.Cleanup(d.Close)
+	return d
+}
+
+// waitStatus 等待投递记录变为指定状态和尝试次数
+func waitStatus(t *testing.T, d *Dispatcher, id string, status Status, attempts int) *Delivery {
+	var delivery *Delivery
+	require.Eventually(t, func() bool {
+		var err error
+		delivery, err = d.Delivery(context.Background(), id)
+		return err == nil && delivery.Status == status && delivery.Attempts == attempts
+	}, 2*time.Second, time.Millisecond, "等待投递状态 %s，尝试次数 %d", status, attempts)
+	return delivery
+}
+
+// advance 等待工作协程在下一个未到期的投递上等待后推进假时钟
+func advance(clk *clock.FakeClock, d time.Duration) {
+	clk.BlockUntil(1)
+	clk.Advance(d)
+}
+
+// 测试发布事件后按订阅关系投递，接收方能够校验签名
+func TestDispatcher_Publish(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	orders := newReceiver(t, clk, "secret-orders")
+	refunds := newReceiver(t, clk, "secret-refunds")
+	other := newReceiver(t, clk, "secret-other")
+
+	d := newTestDispatcher(t, clk)
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_orders", SubscriberID: "m_1", URL: orders.URL, Secret: "secret-orders", Events: []string{"order.*"}}))
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_refunds", SubscriberID: "m_1", URL: refunds.URL, Secret: "secret-refunds", Events: []string{"refund.*"}}))
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_other", SubscriberID: "m_2", URL: other.URL, Secret: "secret-other"}))
+	require.NoError(t, d.Start(context.Background()))
+
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", map[string]any{"order_id": "o_1"})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "ep_orders", deliveries[0].EndpointID)
+
+	delivery := waitStatus(t, d, deliveries[0].ID, StatusSucceeded, 1)
+	assert.Empty(t, delivery.LastError)
+
+	got := orders.received()
+	require.Len(t, got, 1)
+	assert.NoError(t, got[0].verifyErr)
+	assert.Equal(t, delivery.ID, got[0].id)
+	assert.Equal(t, "order.paid", got[0].eventType)
+	assert.Equal(t, delivery.EventID, got[0].event.ID)
+	assert.JSONEq(t, `{"order_id":"o_1"}`, string(got[0].event.Data))
+	assert.Empty(t, refunds.received())
+	assert.Empty(t, other.received())
+
+	attempts, err := d.Attempts(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.True(t, attempts[0].Succeeded())
+	assert.Equal(t, 1, attempts[0].Number)
+	assert.Equal(t, http.StatusOK, attempts[0].StatusCode)
+	assert.Equal(t, "ack", attempts[0].ResponseBody)
+}
+
+// 测试失败后按退避时间重试，重试耗尽后标记失败，修复后可以重放
+func TestDispatcher_RetryAndReplay(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	start := clk.Now()
+	r := newReceiver(t, clk, "secret-1")
+	r.status.Store(http.StatusInternalServerError)
+
+	d := newTestDispatcher(t, clk)
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: r.URL, Secret: "secret-1"}))
+	require.NoError(t, d.Start(context.Background()))
+
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{"order_id":"o_1"}`))
+	require.NoError(t, err)
+	id := deliveries[0].ID
+
+	delivery := waitStatus(t, d, id, StatusPending, 1)
+	assert.Equal(t, 1, delivery.Retries)
+	assert.Equal(t, start.Add(10*time.Second), delivery.NextAttemptAt)
+	assert.Contains(t, delivery.LastError, "500")
+
+	// 未到重试时间不投递
+	clk.BlockUntil(1)
+	clk.Advance(9 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, r.received(), 1)
+
+	advance(clk, time.Second)
+	waitStatus(t, d, id, StatusPending, 2)
+	advance(clk, 10*time.Second)
+	delivery = waitStatus(t, d, id, StatusFailed, 3)
+	assert.Equal(t, 3, delivery.Retries)
+
+	attempts, err := d.Attempts(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	for i, a := range attempts {
+		assert.Equal(t, i+1, a.Number)
+		assert.Equal(t, http.StatusInternalServerError, a.StatusCode)
+		assert.False(t, a.Succeeded())
+	}
+	// 同一投递的每次重试使用相同的投递ID
+	for _, rec := range r.received() {
+		assert.Equal(t, id, rec.id)
+		assert.NoError(t, rec.verifyErr)
+	}
+
+	r.status.Store(http.StatusNoContent)
+	require.NoError(t, d.Replay(context.Background(), id))
+	delivery = waitStatus(t, d, id, StatusSucceeded, 4)
+	assert.Equal(t, 0, delivery.Retries)
+
+	assert.ErrorIs(t, d.Replay(context.Background(), "missing"), ErrDeliveryNotFound)
+}
+
+// 测试端点持续失败时熔断器打开，投递被推迟且不计入重试次数，其他端点不受影响
+func TestDispatcher_Breaker(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	broken := newReceiver(t, clk, "secret-1")
+	broken.status.Store(http.StatusBadGateway)
+	healthy := newReceiver(t, clk, "secret-2")
+
+	d := newTestDispatcher(t, clk,
+		WithRescheduleDelay(time.Minute),
+		WithRetryStrategy(func() retry.Strategy {
+			s, _ := retry.NewFixedIntervalRetryStrategy(time.Hour, 5)
+			return s
+		}),
+		WithBreakerFactory(func(endpointID string) *breaker.Breaker {
+			return breaker.New(breaker.WithName(endpointID), breaker.WithClock(clk),
+				breaker.WithCountWindow(2), breaker.WithMinimumCalls(2), breaker.WithOpenTimeout(time.Minute))
+		}),
+	)
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_broken", SubscriberID: "m_1", URL: broken.URL, Secret: "secret-1"}))
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_healthy", SubscriberID: "m_2", URL: healthy.URL, Secret: "secret-2"}))
+	require.NoError(t, d.Start(context.Background()))
+
+	for i := 0; i < 2; i++ {
+		deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+		require.NoError(t, err)
+		waitStatus(t, d, deliveries[0].ID, StatusPending, 1)
+	}
+
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+	var delivery *Delivery
+	require.Eventually(t, func() bool {
+		delivery, _ = d.Delivery(context.Background(), deliveries[0].ID)
+		return delivery.LastError != ""
+	}, 2*time.Second, time.Millisecond)
+	assert.Contains(t, delivery.LastError, breaker.ErrOpen.Error())
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, 0, delivery.Retries)
+	assert.Equal(t, clk.Now().Add(time.Minute), delivery.NextAttemptAt)
+	assert.Len(t, broken.received(), 2)
+
+	deliveries, err = d.Publish(context.Background(), "m_2", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+	waitStatus(t, d, deliveries[0].ID, StatusSucceeded, 1)
+
+	// 熔断器半开后推迟的投递继续进行
+	broken.status.Store(http.StatusOK)
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	waitStatus(t, d, delivery.ID, StatusSucceeded, 1)
+}
+
+// 测试未启动时发布的投递保存在存储中，使用同一存储的新分发器启动后继续投递
+func TestDispatcher_ResumeFromStore(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	r := newReceiver(t, clk, "secret-1")
+	store := NewMemoryStore()
+	ep := Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: r.URL, Secret: "secret-1"}
+
+	d1 := newTestDispatcher(t, clk, WithStore(store))
+	require.NoError(t, d1.RegisterEndpoint(ep))
+	deliveries, err := d1.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+	d1.Close()
+	_, err = d1.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	assert.ErrorIs(t, err, ErrDispatcherClosed)
+	assert.ErrorIs(t, d1.Start(context.Background()), ErrDispatcherClosed)
+
+	pending, err := store.PendingDeliveries(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Empty(t, r.received())
+
+	d2 := newTestDispatcher(t, clk, WithStore(store))
+	require.NoError(t, d2.RegisterEndpoint(ep))
+	require.NoError(t, d2.Start(context.Background()))
+	waitStatus(t, d2, deliveries[0].ID, StatusSucceeded, 1)
+	assert.Len(t, r.received(), 1)
+}
+
+// 测试端点被删除后等待投递的记录标记为失败
+func TestDispatcher_RemoveEndpoint(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	r := newReceiver(t, clk, "secret-1")
+	r.status.Store(http.StatusServiceUnavailable)
+
+	d := newTestDispatcher(t, clk)
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: r.URL, Secret: "secret-1"}))
+	require.NoError(t, d.Start(context.Background()))
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+	waitStatus(t, d, deliveries[0].ID, StatusPending, 1)
+
+	d.RemoveEndpoint("ep_1")
+	_, ok := d.Endpoint("ep_1")
+	assert.False(t, ok)
+	advance(clk, 10*time.Second)
+	delivery := waitStatus(t, d, deliveries[0].ID, StatusFailed, 1)
+	assert.Equal(t, ErrEndpointNotFound.Error(), delivery.LastError)
+	assert.ErrorIs(t, d.Replay(context.Background(), delivery.ID), ErrEndpointNotFound)
+}
+
+func TestDispatcher_RegisterEndpoint(t *testing.T) {
+	d := NewDispatcher()
+	defer d.Close()
+
+	assert.ErrorIs(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", URL: "https://merchant.com/hooks"}), ErrInvalidEndpoint)
+	assert.ErrorIs(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", URL: "ftp://merchant.com/hooks", Secret: "s"}), ErrInvalidEndpoint)
+	assert.ErrorIs(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", URL: "/hooks", Secret: "s"}), ErrInvalidEndpoint)
+
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: "https://merchant.com/hooks", Secret: "s"}))
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_2", SubscriberID: "m_1", URL: "https://merchant.com/hooks2", Secret: "s", Disabled: true}))
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_3", SubscriberID: "m_2", URL: "https://other.com/hooks", Secret: "s"}))
+	assert.Len(t, d.Endpoints("m_1"), 2)
+
+	// 停用的端点不创建投递记录
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "ep_1", deliveries[0].EndpointID)
+}
+
+// 测试条件更新：记录被修改后旧版本的更新返回 ErrDeliveryConflict
+func TestMemoryStore_UpdateDelivery(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	assert.ErrorIs(t, store.UpdateDelivery(ctx, &Delivery{ID: "d_1"}), ErrDeliveryNotFound)
+
+	require.NoError(t, store.SaveDelivery(ctx, &Delivery{ID: "d_1", Status: StatusPending}))
+	first, err := store.GetDelivery(ctx, "d_1")
+	require.NoError(t, err)
+	second, err := store.GetDelivery(ctx, "d_1")
+	require.NoError(t, err)
+
+	first.Status = StatusSucceeded
+	require.NoError(t, store.UpdateDelivery(ctx, first))
+	assert.Equal(t, int64(1), first.Version)
+	second.Status = StatusFailed
+	assert.ErrorIs(t, store.UpdateDelivery(ctx, second), ErrDeliveryConflict)
+
+	saved, err := store.GetDelivery(ctx, "d_1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, saved.Status)
+}
+
+// 测试共享存储的两个分发器同时加载同一投递时只发送一次
+func TestDispatcher_SharedStore(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	r := newReceiver(t, clk, "secret-1")
+	store := NewMemoryStore()
+	ep := Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: r.URL, Secret: "secret-1"}
+
+	var deliveries []*Delivery
+	dispatchers := make([]*Dispatcher, 2)
+	for i := range dispatchers {
+		dispatchers[i] = newTestDispatcher(t, clk, WithStore(store))
+		require.NoError(t, dispatchers[i].RegisterEndpoint(ep))
+	}
+	for i := 0; i < 20; i++ {
+		published, err := dispatchers[0].Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+		require.NoError(t, err)
+		deliveries = append(deliveries, published...)
+	}
+	for _, d := range dispatchers {
+		require.NoError(t, d.Start(context.Background()))
+	}
+
+	for _, delivery := range deliveries {
+		waitStatus(t, dispatchers[0], delivery.ID, StatusSucceeded, 1)
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, r.received(), len(deliveries))
+}
+
+// 测试投递进行中重放时，进行中的结果被丢弃，重放的投递不会被覆盖
+func TestDispatcher_ReplayDuringDelivery(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now().Truncate(time.Second))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	d := newTestDispatcher(t, clk)
+	require.NoError(t, d.RegisterEndpoint(Endpoint{ID: "ep_1", SubscriberID: "m_1", URL: server.URL, Secret: "secret-1"}))
+	require.NoError(t, d.Start(context.Background()))
+	deliveries, err := d.Publish(context.Background(), "m_1", "order.paid", []byte(`{}`))
+	require.NoError(t, err)
+
+	<-started
+	delivery, err := d.Delivery(context.Background(), deliveries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, clk.Now().Add(defaultLeaseTimeout), delivery.NextAttemptAt, "发送前认领投递")
+	require.NoError(t, d.Replay(context.Background(), delivery.ID))
+	close(release)
+
+	delivery = waitStatus(t, d, delivery.ID, StatusSucceeded, 1)
+	assert.Equal(t, 0, delivery.Retries)
+	assert.Equal(t, int32(2), calls.Load())
+}
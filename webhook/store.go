@@ -0,0 +1,276 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件定义投递记录的持久化存储 Store，以及单机的 MemoryStore 和基于 Redis 的 RedisStore。
+// 分发器启动时从存储中加载等待投递的记录，因此进程重启后未完成的重试会继续进行。
+// 投递记录的修改都是按 Version 的条件更新，共享存储的多个工作协程或实例不会覆盖彼此的结果。
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store 投递记录和投递日志的存储
+type Store interface {
+	// SaveDelivery 保存投递记录，已存在时覆盖
+	SaveDelivery(ctx context.Context, d *Delivery) error
+	// UpdateDelivery 仅当存储中记录的 Version 等于 d.Version 时保存，保存后 d.Version 加1；
+	// 记录已被修改时返回 ErrDeliveryConflict，不存在时返回 ErrDeliveryNotFound
+	UpdateDelivery(ctx context.Context, d *Delivery) error
+	// GetDelivery 返回投递记录，不存在时返回 ErrDeliveryNotFound
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	// PendingDeliveries 返回所有状态为 StatusPending 的投递记录
+	PendingDeliveries(ctx context.Context) ([]*Delivery, error)
+	// AddAttempt 追加一次投递尝试
+	AddAttempt(ctx context.Context, a *Attempt) error
+	// Attempts 按时间顺序返回投递的全部尝试
+	Attempts(ctx context.Context, deliveryID string) ([]*Attempt, error)
+}
+
+// ===================== MemoryStore =====================
+
+// MemoryStore 内存存储，进程退出后数据丢失，适合测试和单机部署
+type MemoryStore struct {
+	mu         sync.RWMutex
+	deliveries map[string]*Delivery
+	attempts   map[string][]*Attempt
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		deliveries: make(map[string]*Delivery),
+		attempts:   make(map[string][]*Attempt),
+	}
+}
+
+// SaveDelivery 实现 Store 接口
+func (s *MemoryStore) SaveDelivery(_ context.Context, d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *d
+	s.deliveries[d.ID] = &copied
+	return nil
+}
+
+// UpdateDelivery 实现 Store 接口
+func (s *MemoryStore) UpdateDelivery(_ context.Context, d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.deliveries[d.ID]
+	if !ok {
+		return ErrDeliveryNotFound
+	}
+	if cur.Version != d.Version {
+		return ErrDeliveryConflict
+	}
+	d.Version++
+	copied := *d
+	s.deliveries[d.ID] = &copied
+	return nil
+}
+
+// GetDelivery 实现 Store 接口
+func (s *MemoryStore) GetDelivery(_ context.Context, id string) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	copied := *d
+	return &copied, nil
+}
+
+// PendingDeliveries 实现 Store 接口，按下次投递时间排序
+func (s *MemoryStore) PendingDeliveries(_ context.Context) ([]*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var res []*Delivery
+	for _, d := range s.deliveries {
+		if d.Status == StatusPending {
+			copied := *d
+			res = append(res, &copied)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].NextAttemptAt.Before(res[j].NextAttemptAt)
+	})
+	return res, nil
+}
+
+// AddAttempt 实现 Store 接口
+func (s *MemoryStore) AddAttempt(_ context.Context, a *Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *a
+	s.attempts[a.DeliveryID] = append(s.attempts[a.DeliveryID], &copied)
+	return nil
+}
+
+// Attempts 实现 Store 接口
+func (s *MemoryStore) Attempts(_ context.Context, deliveryID string) ([]*Attempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]*Attempt, 0, len(s.attempts[deliveryID]))
+	for _, a := range s.attempts[deliveryID] {
+		copied := *a
+		res = append(res, &copied)
+	}
+	return res, nil
+}
+
+// ===================== RedisStore =====================
+
+// updateScript 比较 JSON 中的 version 后更新投递记录和等待投递的集合
+// 返回1表示更新成功，0表示版本不一致，-1表示记录不存在
+var updateScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+	return -1
+end
+if tonumber(cjson.decode(cur)['version'] or 0) ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+if ARGV[3] == '1' then
+	redis.call('SADD', KEYS[2], ARGV[4])
+else
+	redis.call('SREM', KEYS[2], ARGV[4])
+end
+return 1
+`)
+
+// RedisStore 基于 Redis 的存储
+// 投递记录保存为 JSON 字符串，等待投递的记录ID保存在一个集合中，投递日志保存在列表中。
+// 多个实例可以共享同一个 RedisStore，条件更新保证同一投递不会被多个实例同时发送；
+// 但每个实例只投递自己发布的记录和 Start 时加载的记录，实例退出后它负责的投递要等到某个实例重新 Start 才会继续
+type RedisStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisStore 创建 Redis 存储，keyPrefix 为空时使用 "webhook:"
+func NewRedisStore(client *redis.Client, keyPrefix string) *RedisStore {
+	if keyPrefix == "" {
+		keyPrefix = "webhook:"
+	}
+	return &RedisStore{client: client, keyPrefix: keyPrefix}
+}
+
+func (s *RedisStore) deliveryKey(id string) string { return s.keyPrefix + "delivery:" + id }
+func (s *RedisStore) attemptsKey(id string) string { return s.keyPrefix + "attempts:" + id }
+func (s *RedisStore) pendingKey() string           { return s.keyPrefix + "pending" }
+
+// SaveDelivery 实现 Store 接口
+func (s *RedisStore) SaveDelivery(ctx context.Context, d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.deliveryKey(d.ID), data, 0)
+	if d.Status == StatusPending {
+		pipe.SAdd(ctx, s.pendingKey(), d.ID)
+	} else {
+		pipe.SRem(ctx, s.pendingKey(), d.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// UpdateDelivery 实现 Store 接口
+func (s *RedisStore) UpdateDelivery(ctx context.Context, d *Delivery) error {
+	next := *d
+	next.Version++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return err
+	}
+	pending := "0"
+	if d.Status == StatusPending {
+		pending = "1"
+	}
+	res, err := updateScript.Run(ctx, s.client, []string{s.deliveryKey(d.ID), s.pendingKey()},
+		strconv.FormatInt(d.Version, 10), data, pending, d.ID).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case -1:
+		return ErrDeliveryNotFound
+	case 0:
+		return ErrDeliveryConflict
+	}
+	d.Version = next.Version
+	return nil
+}
+
+// GetDelivery 实现 Store 接口
+func (s *RedisStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	data, err := s.client.Get(ctx, s.deliveryKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &Delivery{}
+	if err = json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// PendingDeliveries 实现 Store 接口
+func (s *RedisStore) PendingDeliveries(ctx context.Context) ([]*Delivery, error) {
+	ids, err := s.client.SMembers(ctx, s.pendingKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Delivery, 0, len(ids))
+	for _, id := range ids {
+		d, err := s.GetDelivery(ctx, id)
+		if errors.Is(err, ErrDeliveryNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// AddAttempt 实现 Store 接口
+func (s *RedisStore) AddAttempt(ctx context.Context, a *Attempt) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return s.client.RPush(ctx, s.attemptsKey(a.DeliveryID), data).Err()
+}
+
+// Attempts 实现 Store 接口
+func (s *RedisStore) Attempts(ctx context.Context, deliveryID string) ([]*Attempt, error) {
+	items, err := s.client.LRange(ctx, s.attemptsKey(deliveryID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Attempt, 0, len(items))
+	for _, item := range items {
+		a := &Attempt{}
+		if err = json.Unmarshal([]byte(item), a); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
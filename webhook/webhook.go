@@ -0,0 +1,214 @@
+// Copyright 2024 Humphrey-He
+//
+// 本文件定义 webhook 的端点、投递记录和投递尝试，以及签名和验签。
+// 签名内容为 "投递ID.时间戳.请求体"，使用端点的密钥计算 HMAC-SHA256，
+// 签名头可以包含多个以空格分隔的签名，便于接收方在轮换密钥期间同时接受新旧密钥。
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+)
+
+// webhook 相关错误定义
+var (
+	ErrEndpointNotFound  = errors.New("ggu: webhook 端点不存在")
+	ErrDeliveryNotFound  = errors.New("ggu: webhook 投递记录不存在")
+	ErrDeliveryConflict  = errors.New("ggu: webhook 投递记录已被修改")
+	ErrDispatcherClosed  = errors.New("ggu: webhook 分发器已关闭")
+	ErrInvalidEndpoint   = errors.New("ggu: webhook 端点配置不合法")
+	ErrSignatureMissing  = errors.New("ggu: 缺少 webhook 签名")
+	ErrSignatureExpired  = errors.New("ggu: webhook 签名已过期")
+	ErrSignatureMismatch = errors.New("ggu: webhook 签名不匹配")
+	ErrBodyTooLarge      = errors.New("ggu: webhook 请求体过大")
+)
+
+// webhook 请求头
+const (
+	HeaderID        = "Webhook-Id"        // 投递ID，同一投递的重试使用相同的ID，接收方可以据此去重
+	HeaderEvent     = "Webhook-Event"     // 事件类型
+	HeaderTimestamp = "Webhook-Timestamp" // 发送时间，Unix 秒
+	HeaderSignature = "Webhook-Signature" // 签名，格式为 "v1=十六进制摘要"
+)
+
+// signatureVersion 签名格式的版本前缀
+const signatureVersion = "v1="
+
+// defaultMaxBodySize VerifyRequest 默认读取的请求体最大字节数，与 auth 中间件的 MaxBodySize 默认值一致
+const defaultMaxBodySize = 10 << 20
+
+// ===================== 端点和投递记录 =====================
+
+// Endpoint 订阅者注册的 webhook 端点
+type Endpoint struct {
+	ID           string   `json:"id"`
+	SubscriberID string   `json:"subscriber_id"` // 订阅者，例如商家ID
+	URL          string   `json:"url"`
+	Secret       string   `json:"-"`                // 签名密钥
+	Events       []string `json:"events,omitempty"` // 订阅的事件类型，为空表示全部；"order.*" 匹配 "order." 开头的事件
+	Disabled     bool     `json:"disabled"`
+}
+
+// Accepts 判断端点是否订阅了事件类型
+func (e *Endpoint) Accepts(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, pattern := range e.Events {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status 投递状态
+type Status string
+
+const (
+	// StatusPending 等待投递或等待重试
+	StatusPending Status = "pending"
+	// StatusSucceeded 投递成功，端点返回了 2xx
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed 重试次数耗尽或端点已被删除，可以通过 Dispatcher.Replay 重新投递
+	StatusFailed Status = "failed"
+)
+
+// Delivery 一个事件投递到一个端点的记录
+type Delivery struct {
+	ID            string    `json:"id"`
+	EndpointID    string    `json:"endpoint_id"`
+	EventID       string    `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	Payload       []byte    `json:"payload"` // 发送的请求体
+	Status        Status    `json:"status"`
+	Attempts      int       `json:"attempts"` // 累计的尝试次数
+	Retries       int       `json:"retries"`  // 最近一次发布或重放之后失败的次数，用于计算退避时间
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int64     `json:"version"` // 每次条件更新加1，用于检测并发修改
+}
+
+// Attempt 一次投递尝试，构成投递日志
+type Attempt struct {
+	DeliveryID   string        `json:"delivery_id"`
+	Number       int           `json:"number"` // 第几次尝试，从1开始
+	StartedAt    time.Time     `json:"started_at"`
+	Duration     time.Duration `json:"duration"`
+	StatusCode   int           `json:"status_code,omitempty"`
+	ResponseBody string        `json:"response_body,omitempty"` // 截断后的响应体
+	Error        string        `json:"error,omitempty"`
+}
+
+// Succeeded 尝试是否成功
+func (a *Attempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// ===================== 签名和验签 =====================
+
+// Sign 计算签名，返回 "v1=十六进制摘要"
+func Sign(secret, id string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	mac.Write([]byte("."))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名：时间戳与 now 的偏差不能超过 tolerance，signature 中任意一个签名匹配即通过
+func Verify(secret, id, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	if id == "" || timestamp == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureExpired
+	}
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > tolerance || skew < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	expected := []byte(Sign(secret, id, ts, body))
+	for _, sig := range strings.Fields(signature) {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// verifyConfig VerifyRequest 的配置
+type verifyConfig struct {
+	maxBodySize int64
+	clock       clock.Clock
+}
+
+// VerifyOption VerifyRequest 的配置选项
+type VerifyOption func(*verifyConfig)
+
+// WithVerifyMaxBodySize 设置 VerifyRequest 读取的请求体最大字节数，默认10MB
+func WithVerifyMaxBodySize(n int64) VerifyOption {
+	return func(c *verifyConfig) {
+		if n > 0 {
+			c.maxBodySize = n
+		}
+	}
+}
+
+// WithVerifyClock 设置 VerifyRequest 校验时间戳使用的时钟，默认使用系统时钟
+func WithVerifyClock(clk clock.Clock) VerifyOption {
+	return func(c *verifyConfig) {
+		c.clock = clk
+	}
+}
+
+// VerifyRequest 校验 net/http 请求的签名并返回请求体，请求体会被还原，后续可以再次读取。
+// 请求体超过最大字节数时返回 ErrBodyTooLarge，不再继续读取；
+// gin 应用可以使用 ginutil/middleware/auth 中的 NewWebhookMiddleware
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration, opts ...VerifyOption) ([]byte, error) {
+	cfg := verifyConfig{maxBodySize: defaultMaxBodySize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.clock = clock.OrReal(cfg.clock)
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, cfg.maxBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(body)) > cfg.maxBodySize {
+			return nil, ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	err := Verify(secret, r.Header.Get(HeaderID), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature),
+		body, tolerance, cfg.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
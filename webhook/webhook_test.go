@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Humphrey-He/go-generic-utils/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试签名校验，包括篡改、过期和密钥轮换期间的多个签名
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	sig := Sign("secret-1", "dlv_1", now.Unix(), body)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	assert.True(t, strings.HasPrefix(sig, "v1="))
+
+	assert.NoError(t, Verify("secret-1", "dlv_1", ts, sig, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("secret-2", "dlv_1", ts, sig, body, 5*time.Minute, now), ErrSignatureMismatch)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_2", ts, sig, body, 5*time.Minute, now), ErrSignatureMismatch)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_1", ts, sig, []byte(`{"id":"evt_2"}`), 5*time.Minute, now), ErrSignatureMismatch)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_1", ts, sig, body, 5*time.Minute, now.Add(6*time.Minute)), ErrSignatureExpired)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_1", ts, sig, body, 5*time.Minute, now.Add(-6*time.Minute)), ErrSignatureExpired)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_1", "abc", sig, body, 5*time.Minute, now), ErrSignatureExpired)
+	assert.ErrorIs(t, Verify("secret-1", "dlv_1", ts, "", body, 5*time.Minute, now), ErrSignatureMissing)
+
+	// 轮换密钥期间发送方可以同时携带新旧密钥的签名
+	rotated := Sign("secret-old", "dlv_1", now.Unix(), body) + " " + sig
+	assert.NoError(t, Verify("secret-1", "dlv_1", ts, rotated, body, 5*time.Minute, now))
+	assert.NoError(t, Verify("secret-old", "dlv_1", ts, rotated, body, 5*time.Minute, now))
+}
+
+// 测试校验 net/http 请求后请求体仍可读取
+func TestVerifyRequest(t *testing.T) {
+	now := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(`{"id":"evt_1"}`))
+	req.Header.Set(HeaderID, "dlv_1")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign("secret-1", "dlv_1", now.Unix(), []byte(`{"id":"evt_1"}`)))
+
+	body, err := VerifyRequest(req, "secret-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"evt_1"}`, string(body))
+	again, err := VerifyRequest(req, "secret-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, body, again)
+
+	_, err = VerifyRequest(req, "secret-2", time.Minute)
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+}
+
+// 测试 VerifyRequest 使用注入的时钟并限制请求体大小
+func TestVerifyRequest_ClockAndMaxBodySize(t *testing.T) {
+	signed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFakeClock(signed.Add(30 * time.Second))
+	newReq := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+		req.Header.Set(HeaderID, "dlv_1")
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(signed.Unix(), 10))
+		req.Header.Set(HeaderSignature, Sign("secret-1", "dlv_1", signed.Unix(), []byte(body)))
+		return req
+	}
+
+	_, err := VerifyRequest(newReq(`{"id":"evt_1"}`), "secret-1", time.Minute, WithVerifyClock(clk))
+	require.NoError(t, err)
+	clk.Advance(time.Minute)
+	_, err = VerifyRequest(newReq(`{"id":"evt_1"}`), "secret-1", time.Minute, WithVerifyClock(clk))
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+
+	clk = clock.NewFakeClock(signed)
+	_, err = VerifyRequest(newReq(`{"id":"evt_1"}`), "secret-1", time.Minute, WithVerifyClock(clk), WithVerifyMaxBodySize(8))
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	body, err := VerifyRequest(newReq(`{"id":1}`), "secret-1", time.Minute, WithVerifyClock(clk), WithVerifyMaxBodySize(8))
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(body))
+}
+
+func TestEndpoint_Accepts(t *testing.T) {
+	tests := []struct {
+		events    []string
+		eventType string
+		want      bool
+	}{
+		{nil, "order.paid", true},
+		{[]string{"*"}, "order.paid", true},
+		{[]string{"order.paid"}, "order.paid", true},
+		{[]string{"order.paid"}, "order.refunded", false},
+		{[]string{"order.*"}, "order.refunded", true},
+		{[]string{"order.*"}, "orders.created", false},
+		{[]string{"refund.*", "order.paid"}, "order.paid", true},
+	}
+	for _, tt := range tests {
+		ep := &Endpoint{Events: tt.events}
+		assert.Equal(t, tt.want, ep.Accepts(tt.eventType), "%v %s", tt.events, tt.eventType)
+	}
+}